@@ -2,10 +2,34 @@
 package ae
 
 import (
+	"context"
+	"errors"
+	"fmt"
+	"hash"
 	"io"
 	"math"
+	"time"
 )
 
+// maxInt is the largest value of type int on the target platform.
+const maxInt = int64(^uint(0) >> 1)
+
+// ErrSizeOverflow is returned if MaxSize exceeds what can be buffered in memory on the target platform.
+var ErrSizeOverflow = errors.New("ae: MaxSize exceeds addressable memory")
+
+// ErrMemoryLimit is returned if MaxMemory does not suffice to process chunks of MaxSize.
+var ErrMemoryLimit = errors.New("ae: MaxMemory is less than twice the MaxSize")
+
+// QuotaError is returned by Next once the input turns out to exceed Options.MaxTotalBytes.
+type QuotaError struct {
+	// Limit is the MaxTotalBytes exceeded.
+	Limit int64
+}
+
+func (e *QuotaError) Error() string {
+	return fmt.Sprintf("ae: input exceeds the limit of %d bytes", e.Limit)
+}
+
 // Extremum defines if the algorithm should look for local minima or maxima.
 type Extremum uint8
 
@@ -17,113 +41,740 @@ const (
 	MIN
 )
 
+// String returns the name of the mode, i.e. "max" or "min".
+func (e Extremum) String() string {
+	switch e {
+	case MAX:
+		return "max"
+	case MIN:
+		return "min"
+	default:
+		return fmt.Sprintf("Extremum(%d)", uint8(e))
+	}
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (e Extremum) MarshalText() ([]byte, error) {
+	if e != MAX && e != MIN {
+		return nil, fmt.Errorf("ae: invalid mode %d", uint8(e))
+	}
+	return []byte(e.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (e *Extremum) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "max":
+		*e = MAX
+	case "min":
+		*e = MIN
+	default:
+		return fmt.Errorf("ae: invalid mode %q", text)
+	}
+	return nil
+}
+
+// Algorithm is a chunking algorithm.
+type Algorithm uint8
+
+const (
+	// AsymmetricExtremum is the content-defined chunking algorithm of this package and the default.
+	AsymmetricExtremum Algorithm = iota
+
+	// Fixed cuts the input into chunks of exactly AverageSize bytes regardless of their content, ignoring MaxSize
+	// and Mode. It is a baseline to compare the deduplication and speed of content-defined chunking against,
+	// as inserting a single byte shifts all boundaries behind it.
+	// A MaxSize of AverageSize makes AsymmetricExtremum cut the same chunks, which is how manifests record it.
+	Fixed
+)
+
+// String returns the name of the algorithm, i.e. "ae" or "fixed".
+func (a Algorithm) String() string {
+	switch a {
+	case AsymmetricExtremum:
+		return "ae"
+	case Fixed:
+		return "fixed"
+	default:
+		return fmt.Sprintf("Algorithm(%d)", uint8(a))
+	}
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (a Algorithm) MarshalText() ([]byte, error) {
+	if a != AsymmetricExtremum && a != Fixed {
+		return nil, fmt.Errorf("ae: invalid algorithm %d", uint8(a))
+	}
+	return []byte(a.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (a *Algorithm) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "ae":
+		*a = AsymmetricExtremum
+	case "fixed":
+		*a = Fixed
+	default:
+		return fmt.Errorf("ae: invalid algorithm %q", text)
+	}
+	return nil
+}
+
+// minBufSize is the initial capacity of the read buffer.
+const minBufSize = 64 * 1024
+
 // Options configure the parameters for the Chunker.
 type Options struct {
 	// AverageSize of a chunk in bytes as is desired.
-	AverageSize int
+	AverageSize int64
+
+	// Algorithm to chunk with (optional). It defaults to AsymmetricExtremum.
+	Algorithm Algorithm
 
 	// Mode of the algorithm (optional).
 	Mode Extremum
 
 	// MaxSize of a single chunk (cf. AE_MAX_T and AE_MIN_T) (optional).
-	MaxSize int
+	MaxSize int64
+
+	// Hasher constructs the hash used to fingerprint each chunk (optional).
+	// If set, every Chunk returned by Next carries its digest in Sum, computed while the chunk is copied
+	// out of the read buffer rather than in another pass over its data.
+	Hasher func() hash.Hash
+
+	// HashName selects the hash by its registered name, e.g. "sha256" or "blake3" (optional).
+	// Unlike an arbitrary Hasher, a known hash allows for deriving multihashes and CIDs from the digests.
+	HashName string
+
+	// SHA256 is a shorthand for setting the HashName to "sha256" (optional).
+	SHA256 bool
+
+	// Limiter bounds the bandwidth at which the input is read (optional).
+	Limiter Limiter
+
+	// MaxMemory bounds the memory in bytes used for buffering (optional).
+	// One half is available to the read buffer, the other half to chunks in flight,
+	// i.e. the chunk just returned by Next or the chunks queued up in a Pipeline.
+	// It must therefore be at least twice the MaxSize.
+	MaxMemory int64
+
+	// MaxTotalBytes bounds the size of the input (optional), e.g. to protect a service from endless client streams.
+	// Of a longer input, the chunks that end within the limit are emitted, after which Next returns a *QuotaError.
+	// Beyond the limit, at most one byte is read.
+	MaxTotalBytes int64
+
+	// Trace receives the decisions of the algorithm, i.e. the extrema and the reason of every cut (optional).
+	// It is meant for debugging unexpected chunking behavior and slows down the chunker considerably.
+	Trace func(event TraceEvent)
+
+	// Metrics are updated for every chunk emitted (optional).
+	Metrics *Metrics
+
+	// Logger receives diagnostic events at debug level (optional): the parameters chosen,
+	// chunks truncated at MaxSize and errors reading the input. It is satisfied by *slog.Logger.
+	Logger Logger
+
+	// Boundaries are offsets in the input at which a new chunk must start, in increasing order (optional).
+	// Set to the offsets of the entries of an archive, e.g. of the local file headers of a zip file,
+	// they isolate the entries from each other, so that identical files yield identical chunks in any archive.
+	Boundaries []int64
+
+	// Tar states that the input is a tar stream, every entry of which starts a new chunk (optional).
+	// The boundaries are found while reading, in addition to any given by Boundaries.
+	Tar bool
+
+	// SnapTolerance enables snapping cut points to record delimiters (optional).
+	// Every cut point is moved to just after the nearest Delimiter at most SnapTolerance bytes away, if any,
+	// so that records are not split while the chunks stay content defined. Chunks never exceed MaxSize, though.
+	SnapTolerance int64
+
+	// Delimiter terminates the records if SnapTolerance is set, e.g. '\n' for NDJSON or logs.
+	Delimiter byte
+
+	// RuneSafe shifts cut points back by up to 3 bytes, so that chunks of UTF-8 text never split a character (optional).
+	RuneSafe bool
+
+	// Alignment makes cut points found by the algorithm prefer multiples of Alignment bytes in the input (optional),
+	// e.g. 4096 for disk images and databases, whose writes are aligned to blocks. A cut point within AlignTolerance
+	// bytes of a multiple is moved onto it, so that a rewritten block leaves the chunks of its neighbors intact
+	// more often, while the chunks stay content defined. Chunks truncated at MaxSize or by the input are left as they are.
+	Alignment int64
+
+	// AlignTolerance is the distance up to which cut points are moved to a multiple of Alignment (optional).
+	// It defaults to an eighth of the Alignment.
+	AlignTolerance int64
+
+	// MaxChunkAge bounds the time data is buffered by a ChunkingWriter waiting for a boundary (optional).
+	// If no boundary was found for MaxChunkAge since the last one, the buffered data is flushed,
+	// so that sparse live data, such as logs or sensor feeds, is shipped in time.
+	// The Chunker itself, which blocks reading its input, ignores it.
+	MaxChunkAge time.Duration
+
+	// Entropy has every Chunk carry an estimate of the entropy of its data in Entropy (optional),
+	// e.g. for a storage layer to skip compressing chunks that are random already.
+	Entropy bool
 }
 
-type Chunker struct {
-	// reader to be chunked.
-	reader io.Reader
+// Limiter throttles reads from the input.
+// It is satisfied by *rate.Limiter from golang.org/x/time/rate, with one token per byte.
+type Limiter interface {
+	// Burst returns the maximum number of tokens that can be consumed at once.
+	Burst() int
+
+	// WaitN blocks until n tokens are available.
+	WaitN(ctx context.Context, n int) error
+}
+
+// Logger logs diagnostic messages with alternating keys and values as arguments.
+// It is satisfied by *slog.Logger from log/slog.
+type Logger interface {
+	// Debug logs msg at debug level.
+	Debug(msg string, args ...any)
+}
+
+// Chunk is a single piece of the input as emitted by the Chunker.
+type Chunk struct {
+	// Offset of the chunk in the input stream.
+	Offset int64
+
+	// Data of the chunk.
+	Data []byte
+
+	// Sum is the digest of Data if a hash was configured, nil otherwise.
+	Sum []byte
+
+	// Reason tells why the chunk ends where it does, as determined by the Chunker.
+	Reason CutReason
+
+	// Entropy of Data in bits per byte as estimated by the function Entropy, if requested by Options.Entropy.
+	Entropy float64
 
+	// File is the name of the file the chunk belongs to if it was returned by a MultiChunker or ChunkFiles, empty otherwise.
+	File string
+
+	// Final states that the chunk is the last one of the input, so that, e.g., the terminal frame of a protocol
+	// can be tagged without waiting for Next to return io.EOF. To find out, the Chunker may read ahead
+	// when a chunk ends with its buffer. The last chunk before a forced boundary is not final.
+	Final bool
+
+	// hashName is the registered name of the hash that produced Sum, if known.
+	hashName string
+}
+
+// params are the parameters of the algorithm as derived from Options.
+type params struct {
 	// avgSize is the desired average size in bytes for a single chunk.
-	avgSize int
+	avgSize int64
 
 	// extremum to be considered in the algorithm (optional).
 	extremum Extremum
 
 	// windowSize is computed from avgSize.
-	windowSize int
+	windowSize int64
 
 	// minSize is a computed minimum size for a single chunk.
-	minSize int
+	minSize int64
 
 	// maxSize of a single chunk (cf. AE_MAX_T and AE_MIN_T) (optional).
-	maxSize int
-
-	overflow []byte
+	maxSize int64
 }
 
-func NewChunker(r io.Reader, opts *Options) *Chunker {
+// newParams derives the algorithm parameters from opts, falling back to defaults where unset.
+func newParams(opts *Options) params {
 	mode := MAX
-	avgSize := 256 * 1024 * 1024
-	var maxSize int
+	var avgSize int64 = 256 * 1024 * 1024
+	var maxSize int64
 	if opts != nil {
 		mode = opts.Mode
 		if opts.AverageSize > 0 {
 			avgSize = opts.AverageSize
 		}
-		if opts.MaxSize > 0 {
-			maxSize = opts.MaxSize
-		} else {
-			maxSize = avgSize * 2
+		maxSize = opts.MaxSize
+		if opts.Algorithm == Fixed {
+			// Chunks that cannot exceed the sum of minSize and windowSize are always cut at maxSize.
+			maxSize = avgSize
 		}
 	}
-	windowSize := int(math.Round(float64(avgSize) / (math.E - 1)))
+	if maxSize <= 0 {
+		maxSize = avgSize * 2
+	}
+	windowSize := int64(math.Round(float64(avgSize) / (math.E - 1)))
 
-	ch := &Chunker{
-		reader:     r,
+	return params{
 		extremum:   mode,
 		avgSize:    avgSize,
 		windowSize: windowSize,
 		minSize:    avgSize - windowSize,
 		maxSize:    maxSize,
-		overflow:   make([]byte, 0),
+	}
+}
+
+func (p *params) isExtreme(cur byte, prev byte) bool {
+	if p.extremum == MAX {
+		return cur > prev
+	} else {
+		return cur < prev
+	}
+}
+
+type Chunker struct {
+	params
+
+	// reader to be chunked.
+	reader io.Reader
+
+	// hash used to fingerprint chunks (optional).
+	hash hash.Hash
+
+	// hashName is the registered name of hash, if known.
+	hashName string
+
+	// optsErr is an error in the options passed to the Chunker.
+	optsErr error
+
+	// limiter throttling reads from reader (optional).
+	limiter Limiter
+
+	// maxMemory bounds the memory used for buffering (optional).
+	maxMemory int64
+
+	// maxTotalBytes bounds the size of the input (optional).
+	maxTotalBytes int64
+
+	// overQuota states that the input exceeds maxTotalBytes, in which case err is a *QuotaError.
+	overQuota bool
+
+	// trace receives the decisions of the algorithm (optional).
+	trace func(event TraceEvent)
+
+	// metrics updated for every chunk (optional).
+	metrics *Metrics
+
+	// logger receiving diagnostic events (optional).
+	logger Logger
+
+	// offset of buf in the input stream.
+	offset int64
+
+	// buf holds the bytes read from reader.
+	buf []byte
+
+	// start of the bytes in buf that have not been emitted yet.
+	start int
+
+	// err is the first error returned by reader.
+	err error
+
+	// ahead is a byte read past the buffer by lookAhead, which belongs after the pending bytes if hasAhead is set.
+	ahead    [1]byte
+	hasAhead bool
+
+	// forced states that the end of the input is a boundary forced by the caller rather than the end of the stream.
+	forced bool
+
+	// entries are the offsets at which chunks must start, as given by Options.Boundaries.
+	entries []int64
+
+	// tar states that the input is parsed as a tar stream to find the entries.
+	tar bool
+
+	// tarInput is the reader parsing the input if tar is set.
+	tarInput *tarReader
+
+	// boundaries are the offsets of the entries not passed yet.
+	boundaries []int64
+
+	// snapTolerance is the distance up to which cut points are snapped to a delimiter (optional).
+	snapTolerance int64
+
+	// delimiter of records that cut points are snapped to.
+	delimiter byte
+
+	// runeSafe states that cut points are moved to the start of a UTF-8 sequence.
+	runeSafe bool
+
+	// entropy states that the entropy of every chunk is estimated.
+	entropy bool
+
+	// alignment is the multiple of bytes in the input that cut points are moved to (optional).
+	alignment int64
+
+	// alignTolerance is the distance up to which cut points are moved to a multiple of alignment.
+	alignTolerance int64
+
+	// stats about the chunks emitted so far.
+	stats ChunkerStats
+}
+
+func NewChunker(r io.Reader, opts *Options) *Chunker {
+	return newChunker(r, newParams(opts), opts)
+}
+
+// newChunker returns a Chunker for r with the already derived parameters p.
+func newChunker(r io.Reader, p params, opts *Options) *Chunker {
+	var h hash.Hash
+	var hashName string
+	var optsErr error
+	var limiter Limiter
+	var maxMemory, maxTotalBytes int64
+	var trace func(event TraceEvent)
+	var metrics *Metrics
+	var logger Logger
+	var entries []int64
+	var tar bool
+	var snapTolerance int64
+	var delimiter byte
+	var runeSafe bool
+	var entropy bool
+	var alignment, alignTolerance int64
+	if opts != nil {
+		if opts.Hasher != nil {
+			h = opts.Hasher()
+		} else if opts.HashName != "" || opts.SHA256 {
+			hashName = opts.HashName
+			if hashName == "" {
+				hashName = "sha256"
+			}
+			if fn, err := LookupHash(hashName); err != nil {
+				optsErr = err
+			} else {
+				h = fn()
+			}
+		}
+		limiter = opts.Limiter
+		maxMemory = opts.MaxMemory
+		maxTotalBytes = opts.MaxTotalBytes
+		trace = opts.Trace
+		metrics = opts.Metrics
+		logger = opts.Logger
+		entries = opts.Boundaries
+		tar = opts.Tar
+		snapTolerance = opts.SnapTolerance
+		delimiter = opts.Delimiter
+		runeSafe = opts.RuneSafe
+		entropy = opts.Entropy
+		alignment = opts.Alignment
+		alignTolerance = opts.AlignTolerance
+		if alignTolerance <= 0 {
+			alignTolerance = alignment / 8
+		}
+	}
+
+	ch := &Chunker{
+		params:    p,
+		hash:      h,
+		hashName:  hashName,
+		optsErr:   optsErr,
+		limiter:   limiter,
+		maxMemory: maxMemory,
+		trace:     trace,
+		metrics:   metrics,
+		logger:    logger,
+		entries:   entries,
+		tar:       tar,
+
+		snapTolerance: snapTolerance,
+		delimiter:     delimiter,
+		runeSafe:      runeSafe,
+		entropy:       entropy,
+
+		alignment:      alignment,
+		alignTolerance: alignTolerance,
+		maxTotalBytes:  maxTotalBytes,
+	}
+	ch.setReader(r)
+	if logger != nil {
+		logger.Debug("ae: chunker created", "averageSize", p.avgSize, "minSize", p.minSize, "maxSize", p.maxSize,
+			"windowSize", p.windowSize, "mode", p.extremum.String(), "hash", hashName)
 	}
 
 	return ch
 }
 
-func (ch *Chunker) NextChunk() []byte {
+// setReader makes r the input of the Chunker, parsing it as a tar stream if requested.
+func (ch *Chunker) setReader(r io.Reader) {
+	ch.boundaries = append(ch.boundaries[:0], ch.entries...)
+	ch.tarInput = nil
+	if ch.tar && r != nil {
+		ch.tarInput = newTarReader(r)
+		r = ch.tarInput
+	}
+	ch.reader = r
+}
 
-	nextBytes := make([]byte, ch.maxSize-len(ch.overflow))
-	n, err := ch.reader.Read(nextBytes)
-	if err != nil && err != io.EOF {
-		panic(err)
+// reset prepares the Chunker for a new input r while keeping its buffers.
+func (ch *Chunker) reset(r io.Reader) {
+	ch.setReader(r)
+	ch.offset = 0
+	ch.buf = ch.buf[:0]
+	ch.start = 0
+	ch.err = nil
+	ch.hasAhead = false
+	ch.overQuota = false
+	ch.stats = ChunkerStats{}
+}
+
+// Next returns the next chunk of the input or io.EOF once the input is exhausted.
+// Any other error returned by the underlying reader is passed on to the caller.
+func (ch *Chunker) Next() (*Chunk, error) {
+	if ch.optsErr != nil {
+		return nil, ch.optsErr
+	}
+	if ch.maxSize > maxInt {
+		return nil, ErrSizeOverflow
+	}
+	if ch.maxMemory > 0 && ch.maxMemory < 2*ch.maxSize {
+		return nil, ErrMemoryLimit
+	}
+	ch.fill()
+	pending := ch.buf[ch.start:]
+	if len(pending) == 0 {
+		if ch.err == nil || ch.err == io.EOF {
+			return nil, io.EOF
+		}
+		return nil, ch.err
+	}
+	if ch.err != nil && ch.err != io.EOF && !ch.overQuota {
+		return nil, ch.err
+	}
+
+	if int64(len(pending)) > ch.maxSize {
+		pending = pending[:ch.maxSize]
+	}
+	// A chunk ending at a boundary is cut as if the input ended there.
+	boundary := false
+	if b, ok := ch.nextBoundary(); ok && b-ch.offset < int64(len(pending)) {
+		pending = pending[:b-ch.offset]
+		boundary = true
+	}
+	var n int
+	if ch.trace != nil {
+		n = ch.tracedCutPoint(pending, ch.offset, ch.trace)
+	} else {
+		n = ch.cutPoint(pending)
+	}
+	if ch.err == nil && ch.start+n == len(ch.buf) {
+		ch.lookAhead()
+	}
+	final := ch.err != nil && ch.start+n == len(ch.buf) && !ch.hasAhead
+	if final && ch.overQuota {
+		// The chunk is cut by the limit rather than by its content.
+		return nil, ch.err
+	}
+	reason := cutReason(n, len(pending), final)
+	if reason == CutMaxSize && ch.fixed() {
+		reason = CutFixed
+	}
+	if reason == CutEOF && ch.forced || boundary && n == len(pending) {
+		reason = CutForced
+	}
+	if !final && reason != CutForced {
+		if ch.alignment > 0 && reason == CutExtremum {
+			if m := align(ch.offset, n, len(pending), ch.alignment, ch.alignTolerance); m != n {
+				n, reason = m, CutAligned
+			}
+		}
+		if ch.snapTolerance > 0 {
+			n = snap(pending, n, ch.delimiter, ch.snapTolerance)
+		}
+		if ch.runeSafe {
+			n = runeSafe(pending[:n])
+		}
+	}
+	ch.stats.count(n, reason)
+	if ch.trace != nil {
+		ch.trace(TraceEvent{Kind: TraceCut, Offset: ch.offset + int64(n), Reason: reason})
+	}
+	if ch.metrics != nil {
+		ch.metrics.observe(n, reason)
+	}
+	if ch.logger != nil && reason == CutMaxSize {
+		ch.logger.Debug("ae: chunk truncated at max size", "offset", ch.offset, "maxSize", ch.maxSize)
 	}
-	subject := append(ch.overflow, nextBytes[:n]...)
-	if len(subject) == 0 {
+	c := &Chunk{Offset: ch.offset, Data: getBuf(n), Reason: reason}
+	ch.copyChunk(c.Data, pending)
+	ch.start += n
+	ch.offset += int64(n)
+	if ch.hasAhead {
+		if len(ch.buf) == cap(ch.buf) {
+			ch.grow(int(ch.maxSize))
+		}
+		ch.buf = append(ch.buf, ch.ahead[0])
+		ch.hasAhead = false
+	}
+	c.Final = ch.start == len(ch.buf) && ch.err == io.EOF && !ch.forced
+
+	if ch.hash != nil {
+		c.Sum = ch.hash.Sum(nil)
+		c.hashName = ch.hashName
+	}
+	if ch.entropy {
+		c.Entropy = Entropy(c.Data)
+	}
+
+	return c, nil
+}
+
+// hashBlockSize is the size of the blocks in which chunks are copied and hashed, small enough to stay in the L1 cache.
+const hashBlockSize = 16 * 1024
+
+// copyChunk copies the pending bytes src to the data of a chunk dst and feeds them to the hash, if any.
+// Both happen block by block, so that the hash reads every block from the cache right after the copy
+// rather than the whole chunk from memory a second time.
+func (ch *Chunker) copyChunk(dst, src []byte) {
+	if ch.hash == nil {
+		copy(dst, src)
+		return
+	}
+	ch.hash.Reset()
+	for off := 0; off < len(dst); off += hashBlockSize {
+		block := dst[off:]
+		if len(block) > hashBlockSize {
+			block = block[:hashBlockSize]
+		}
+		copy(block, src[off:])
+		ch.hash.Write(block)
+	}
+}
+
+// nextBoundary returns the offset of the next boundary after the start of the pending chunk, if any.
+func (ch *Chunker) nextBoundary() (int64, bool) {
+	if ch.tarInput != nil {
+		ch.boundaries = append(ch.boundaries, ch.tarInput.take()...)
+	}
+	for len(ch.boundaries) > 0 && ch.boundaries[0] <= ch.offset {
+		ch.boundaries = ch.boundaries[1:]
+	}
+	if len(ch.boundaries) == 0 {
+		return 0, false
+	}
+	return ch.boundaries[0], true
+}
+
+// NextChunk returns the data of the next chunk or nil once the input is exhausted.
+// It panics on read errors; use Next to handle them instead.
+func (ch *Chunker) NextChunk() []byte {
+	c, err := ch.Next()
+	if err == io.EOF {
 		return nil
 	}
-	nextSlice := ch.nextChunkedSlice(subject)
-	ch.overflow = subject[len(nextSlice):]
+	if err != nil {
+		panic(err)
+	}
+	return c.Data
+}
 
-	return nextSlice
+// fill reads from the underlying reader until at least maxSize bytes are pending or the reader fails.
+// The buffer grows on demand so that small inputs do not allocate a full maxSize buffer.
+// With small chunk sizes, the buffer spans many chunks so that reads are batched.
+func (ch *Chunker) fill() {
+	maxSize := int(ch.maxSize)
+	for len(ch.buf)-ch.start < maxSize && ch.err == nil {
+		if len(ch.buf) == cap(ch.buf) {
+			ch.grow(maxSize)
+		}
+		ch.read()
+	}
 }
 
-func (ch *Chunker) nextChunkedSlice(input []byte) []byte {
-	if len(input) <= ch.minSize+ch.windowSize {
-		return input
+// lookAhead reads a single byte past the buffer into ahead, which tells whether the input ends where the buffer does.
+// The end of the input must be known before a chunk taking all buffered bytes is cut, as readers differ
+// in whether they return io.EOF along with the last bytes or with the next read.
+func (ch *Chunker) lookAhead() {
+	for !ch.hasAhead && ch.err == nil {
+		ch.hasAhead = ch.readInto(ch.ahead[:]) == 1
 	}
+}
 
-	markerPos := 0
+// read appends the bytes of a single read from the underlying reader to the buffer, which must have room for them.
+func (ch *Chunker) read() {
+	n := ch.readInto(ch.buf[len(ch.buf):cap(ch.buf)])
+	ch.buf = ch.buf[:len(ch.buf)+n]
+}
 
-	for i := ch.minSize; i < len(input); i++ {
-		if i == ch.maxSize {
-			return input[:i]
+// readInto performs a single read from the underlying reader into p and returns the number of bytes
+// that are within the quota.
+func (ch *Chunker) readInto(p []byte) int {
+	if ch.maxTotalBytes > 0 && int64(len(p)) > ch.maxTotalBytes+1-ch.stats.BytesRead {
+		p = p[:ch.maxTotalBytes+1-ch.stats.BytesRead]
+	}
+	n, err := ch.reader.Read(p)
+	ch.stats.BytesRead += int64(n)
+	ch.err = err
+	if ch.logger != nil && err != nil && err != io.EOF {
+		ch.logger.Debug("ae: reading input failed", "offset", ch.offset+int64(len(ch.buf)-ch.start), "error", err)
+	}
+	if ch.limiter != nil && n > 0 {
+		if err := ch.throttle(n); err != nil {
+			ch.err = err
 		}
-		if ch.isExtreme(input[i], input[markerPos]) {
-			markerPos = i
+	}
+	if ch.maxTotalBytes > 0 && ch.stats.BytesRead > ch.maxTotalBytes {
+		n -= int(ch.stats.BytesRead - ch.maxTotalBytes)
+		ch.err = &QuotaError{Limit: ch.maxTotalBytes}
+		ch.overQuota = true
+	}
+	return n
+}
+
+// grow makes room in the full buffer, either by discarding the bytes that have already been emitted
+// or by enlarging it until it can hold maxSize bytes.
+func (ch *Chunker) grow(maxSize int) {
+	if ch.start > 0 {
+		ch.buf = ch.buf[:copy(ch.buf, ch.buf[ch.start:])]
+		ch.start = 0
+		return
+	}
+	size := 2 * cap(ch.buf)
+	if size < minBufSize {
+		size = minBufSize
+	}
+	if size > maxSize && maxSize >= minBufSize {
+		size = maxSize
+	}
+	if limit := ch.maxMemory / 2; limit > 0 && int64(size) > limit {
+		size = int(limit)
+	}
+	buf := make([]byte, len(ch.buf), size)
+	copy(buf, ch.buf)
+	ch.buf = buf
+}
+
+// throttle waits until the limiter permits n more bytes to be read.
+// The read sizes themselves are left untouched, tokens are rather paid off after each read.
+func (ch *Chunker) throttle(n int) error {
+	burst := ch.limiter.Burst()
+	if burst <= 0 {
+		burst = n
+	}
+	for n > 0 {
+		k := n
+		if k > burst {
+			k = burst
 		}
-		if i == markerPos+ch.windowSize {
-			return input[:i]
+		if err := ch.limiter.WaitN(context.Background(), k); err != nil {
+			return err
 		}
+		n -= k
 	}
+	return nil
+}
 
-	return input
+// fixed reports whether every chunk but the last is cut at maxSize, as with the Fixed algorithm,
+// since the chunks cannot exceed the sum of minSize and windowSize.
+func (p *params) fixed() bool {
+	return p.maxSize <= p.minSize+p.windowSize
 }
 
-func (ch *Chunker) isExtreme(cur byte, prev byte) bool {
-	if ch.extremum == MAX {
-		return cur > prev
-	} else {
-		return cur < prev
+// cutPoint returns the length of the next chunk at the beginning of input,
+// which must not hold more than maxSize bytes.
+func (p *params) cutPoint(input []byte) int {
+	if int64(len(input)) <= p.minSize+p.windowSize {
+		return len(input)
+	}
+	if p.extremum == MAX {
+		return scanMax(input, int(p.minSize), int(p.windowSize))
 	}
+	return scanMin(input, int(p.minSize), int(p.windowSize))
 }
@@ -2,10 +2,16 @@ package ae
 
 import (
 	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
 	"github.com/stretchr/testify/assert"
+	"io"
 	"math"
 	"math/rand"
 	"testing"
+	"testing/iotest"
 	"time"
 )
 
@@ -109,7 +115,7 @@ func TestChunker_NextBytes(t *testing.T) {
 
 	t.Run("window size << 256", func(t *testing.T) {
 		avgSize := (math.E - 1) * 100 // w = 100
-		_ = getChunks(NewChunker(bytes.NewReader(randBytes(1024)), &Options{AverageSize: int(avgSize)}))
+		_ = getChunks(NewChunker(bytes.NewReader(randBytes(1024)), &Options{AverageSize: int64(avgSize)}))
 		// in error case, there will actually be an infinite loop and the test will never finish
 	})
 
@@ -129,3 +135,240 @@ func TestChunker_NextBytes(t *testing.T) {
 		})
 	})*/
 }
+
+// errReader fails with err after r is exhausted.
+type errReader struct {
+	r   io.Reader
+	err error
+}
+
+func (er *errReader) Read(p []byte) (int, error) {
+	n, err := er.r.Read(p)
+	if err == io.EOF {
+		err = er.err
+	}
+	return n, err
+}
+
+// countingLimiter records the tokens requested from it.
+type countingLimiter struct {
+	burst  int
+	tokens int
+}
+
+func (l *countingLimiter) Burst() int { return l.burst }
+
+func (l *countingLimiter) WaitN(_ context.Context, n int) error {
+	if n > l.burst {
+		return errors.New("exceeds burst")
+	}
+	l.tokens += n
+	return nil
+}
+
+func TestChunker_Next(t *testing.T) {
+	t.Run("returns io.EOF at end of input", func(t *testing.T) {
+		c := NewChunker(bytes.NewReader(randBytes(1024)), &Options{AverageSize: 256})
+		var data []byte
+		for {
+			chunk, err := c.Next()
+			if err == io.EOF {
+				break
+			}
+			assert.NoError(t, err)
+			assert.Nil(t, chunk.Sum)
+			assert.Equal(t, int64(len(data)), chunk.Offset)
+			data = append(data, chunk.Data...)
+		}
+		assert.Len(t, data, 1024)
+		_, err := c.Next()
+		assert.Equal(t, io.EOF, err)
+	})
+
+	t.Run("passes on reader errors", func(t *testing.T) {
+		errFoo := errors.New("foo")
+		c := NewChunker(&errReader{bytes.NewReader(randBytes(1024)), errFoo}, &Options{AverageSize: 256})
+		var err error
+		for err == nil {
+			_, err = c.Next()
+		}
+		assert.Equal(t, errFoo, err)
+	})
+
+	t.Run("fused hashing", func(t *testing.T) {
+		c := NewChunker(bytes.NewReader(testFile[:8*MiB]), &Options{AverageSize: 256 * 1024, Hasher: sha256.New})
+		for {
+			chunk, err := c.Next()
+			if err == io.EOF {
+				break
+			}
+			assert.NoError(t, err)
+			sum := sha256.Sum256(chunk.Data)
+			assert.Equal(t, sum[:], chunk.Sum)
+		}
+	})
+
+	t.Run("SHA-256 shorthand", func(t *testing.T) {
+		c := NewChunker(bytes.NewReader(testFile[:MiB]), &Options{AverageSize: 64 * 1024, SHA256: true})
+		chunk, err := c.Next()
+		assert.NoError(t, err)
+		sum := sha256.Sum256(chunk.Data)
+		assert.Equal(t, sum[:], chunk.Sum)
+	})
+
+	t.Run("final chunk", func(t *testing.T) {
+		finals := func(r io.Reader, opts *Options) []bool {
+			c := NewChunker(r, opts)
+			var finals []bool
+			for {
+				chunk, err := c.Next()
+				if err == io.EOF {
+					return finals
+				}
+				assert.NoError(t, err)
+				finals = append(finals, chunk.Final)
+			}
+		}
+		for name, r := range map[string]io.Reader{
+			"random":      bytes.NewReader(testFile[:MiB]),
+			"single byte": iotest.OneByteReader(bytes.NewReader(testFile[:MiB])),
+			"data on eof": iotest.DataErrReader(bytes.NewReader(testFile[:MiB])),
+		} {
+			f := finals(r, &Options{AverageSize: 64 * 1024})
+			assert.Greater(t, len(f), 1, name)
+			assert.Equal(t, make([]bool, len(f)-1), f[:len(f)-1], name)
+			assert.True(t, f[len(f)-1], name)
+		}
+		// The end of the input is only noticed by reading ahead.
+		assert.Equal(t, []bool{false, true}, finals(bytes.NewReader(testFile[:8192]), &Options{AverageSize: 4096, Algorithm: Fixed}))
+		assert.Empty(t, finals(bytes.NewReader(nil), nil))
+	})
+
+	t.Run("total bytes limit", func(t *testing.T) {
+		opts := &Options{AverageSize: 16 * 1024, MaxTotalBytes: 512 * 1024}
+		all := getChunks(NewChunker(bytes.NewReader(testFile[:MiB]), &Options{AverageSize: 16 * 1024}))
+
+		c := NewChunker(bytes.NewReader(testFile[:MiB]), opts)
+		var chunks [][]byte
+		var err error
+		for {
+			var chunk *Chunk
+			if chunk, err = c.Next(); err != nil {
+				break
+			}
+			chunks = append(chunks, chunk.Data)
+		}
+		var quotaErr *QuotaError
+		assert.True(t, errors.As(err, &quotaErr))
+		assert.Equal(t, int64(512*1024), quotaErr.Limit)
+		_, err = c.Next()
+		assert.Equal(t, quotaErr, err)
+		assert.Equal(t, int64(512*1024+1), c.Stats().BytesRead)
+
+		// The chunks within the limit are those of the whole input.
+		assert.Equal(t, all[:len(chunks)], chunks)
+		size := len(bytes.Join(chunks, nil))
+		assert.LessOrEqual(t, size, 512*1024)
+		assert.Greater(t, size, 512*1024-int(newParams(opts).maxSize))
+
+		// An input of exactly the limit is chunked in full.
+		chunks = getChunks(NewChunker(bytes.NewReader(testFile[:512*1024]), opts))
+		assert.Equal(t, getChunks(NewChunker(bytes.NewReader(testFile[:512*1024]), &Options{AverageSize: 16 * 1024})), chunks)
+	})
+
+	t.Run("fixed size", func(t *testing.T) {
+		opts := &Options{AverageSize: 4096, MaxSize: 100 * 1024, Mode: MIN, Algorithm: Fixed}
+		chunks := getChunks(NewChunker(bytes.NewReader(testFile[:MiB+100]), opts))
+		assert.Len(t, chunks, int(MiB/4096+1))
+		for _, chunk := range chunks[:len(chunks)-1] {
+			assert.Len(t, chunk, 4096)
+		}
+		assert.Len(t, chunks[len(chunks)-1], 100)
+
+		// Manifests record fixed-size chunking as a maximum size equal to the average one.
+		m, err := BuildManifest(bytes.NewReader(testFile[:MiB+100]), opts)
+		assert.NoError(t, err)
+		assert.Equal(t, m.Parameters.AverageSize, m.Parameters.MaxSize)
+		again, err := BuildManifest(bytes.NewReader(testFile[:MiB+100]), m.Parameters.Options())
+		assert.NoError(t, err)
+		assert.Equal(t, m, again)
+
+		var a Algorithm
+		assert.NoError(t, a.UnmarshalText([]byte("fixed")))
+		assert.Equal(t, Fixed, a)
+		assert.Equal(t, "ae", AsymmetricExtremum.String())
+		assert.Error(t, a.UnmarshalText([]byte("rabin")))
+		_, err = Algorithm(2).MarshalText()
+		assert.Error(t, err)
+	})
+
+	t.Run("limiter is charged for every byte read", func(t *testing.T) {
+		l := &countingLimiter{burst: 1000}
+		chunks := getChunks(NewChunker(bytes.NewReader(testFile[:MiB]), &Options{AverageSize: 64 * 1024, Limiter: l}))
+		assert.NotEmpty(t, chunks)
+		assert.Equal(t, int(MiB), l.tokens)
+	})
+}
+
+func BenchmarkChunker_Next(b *testing.B) {
+	input := testFile[:32*MiB]
+	for _, avgSize := range []int64{1024, 2048, 4096, 8192, 16 * 1024, 64 * 1024, 256 * 1024} {
+		b.Run(fmt.Sprintf("avg=%dKiB", avgSize/1024), func(b *testing.B) {
+			b.SetBytes(int64(len(input)))
+			for i := 0; i < b.N; i++ {
+				c := NewChunker(bytes.NewReader(input), &Options{AverageSize: avgSize})
+				for {
+					chunk, err := c.Next()
+					if err == io.EOF {
+						break
+					}
+					ReleaseChunk(chunk)
+				}
+			}
+		})
+	}
+}
+
+// BenchmarkChunker_Hash compares hashing the chunks within Next, fused with copying them out of the read buffer,
+// to a consumer hashing every chunk returned, which reads it from memory a second time.
+func BenchmarkChunker_Hash(b *testing.B) {
+	input := testFile[:64*MiB]
+	for _, name := range []string{"sha256", "blake3"} {
+		hasher, err := LookupHash(name)
+		if err != nil {
+			b.Fatal(err)
+		}
+		for _, avgSize := range []int64{64 * 1024, 4 * MiB} {
+			b.Run(fmt.Sprintf("%s/avg=%dKiB/fused", name, avgSize/1024), func(b *testing.B) {
+				b.SetBytes(int64(len(input)))
+				for i := 0; i < b.N; i++ {
+					c := NewChunker(bytes.NewReader(input), &Options{AverageSize: avgSize, HashName: name})
+					for {
+						chunk, err := c.Next()
+						if err == io.EOF {
+							break
+						}
+						ReleaseChunk(chunk)
+					}
+				}
+			})
+			b.Run(fmt.Sprintf("%s/avg=%dKiB/separate", name, avgSize/1024), func(b *testing.B) {
+				b.SetBytes(int64(len(input)))
+				h := hasher()
+				for i := 0; i < b.N; i++ {
+					c := NewChunker(bytes.NewReader(input), &Options{AverageSize: avgSize})
+					for {
+						chunk, err := c.Next()
+						if err == io.EOF {
+							break
+						}
+						h.Reset()
+						h.Write(chunk.Data)
+						h.Sum(nil)
+						ReleaseChunk(chunk)
+					}
+				}
+			})
+		}
+	}
+}
@@ -0,0 +1,142 @@
+package aepb
+
+import (
+	"fmt"
+	ae "github.com/mg98/ae-chunker-go"
+)
+
+// Version of the manifest format represented by Manifest.
+const Version = 1
+
+// ParamsVersion is the version of manifests whose parameters include any beyond those of Version,
+// so that readers of Version reject them instead of chunking differently.
+const ParamsVersion = 2
+
+// hasParams reports whether p includes any parameters beyond those of Version.
+func hasParams(p ae.Parameters) bool {
+	return p.Alignment != 0 || p.AlignTolerance != 0 || p.SnapTolerance != 0 || p.Delimiter != 0 || p.RuneSafe || p.Tar
+}
+
+// FromParameters converts p to its protobuf representation.
+func FromParameters(p ae.Parameters) *Parameters {
+	return &Parameters{
+		AverageSize: p.AverageSize,
+		MaxSize:     p.MaxSize,
+		Mode:        FromMode(p.Mode),
+		Hash:        p.Hash,
+
+		Alignment:      p.Alignment,
+		AlignTolerance: p.AlignTolerance,
+		SnapTolerance:  p.SnapTolerance,
+		Delimiter:      uint32(p.Delimiter),
+		RuneSafe:       p.RuneSafe,
+		Tar:            p.Tar,
+	}
+}
+
+// ToParameters converts x to ae.Parameters.
+func (x *Parameters) ToParameters() ae.Parameters {
+	return ae.Parameters{
+		AverageSize: x.GetAverageSize(),
+		MaxSize:     x.GetMaxSize(),
+		Mode:        x.GetMode().ToMode(),
+		Hash:        x.GetHash(),
+
+		Alignment:      x.GetAlignment(),
+		AlignTolerance: x.GetAlignTolerance(),
+		SnapTolerance:  x.GetSnapTolerance(),
+		Delimiter:      byte(x.GetDelimiter()),
+		RuneSafe:       x.GetRuneSafe(),
+		Tar:            x.GetTar(),
+	}
+}
+
+// FromMode converts e to its protobuf representation.
+func FromMode(e ae.Extremum) Mode {
+	if e == ae.MIN {
+		return Mode_MODE_MIN
+	}
+	return Mode_MODE_MAX
+}
+
+// ToMode converts x to ae.Extremum.
+func (x Mode) ToMode() ae.Extremum {
+	if x == Mode_MODE_MIN {
+		return ae.MIN
+	}
+	return ae.MAX
+}
+
+// FromChunkRef converts ref to its protobuf representation.
+func FromChunkRef(ref ae.ChunkRef) *ChunkRef {
+	return &ChunkRef{Offset: ref.Offset, Length: ref.Length, Hash: ref.Hash}
+}
+
+// ToChunkRef converts x to ae.ChunkRef.
+func (x *ChunkRef) ToChunkRef() ae.ChunkRef {
+	return ae.ChunkRef{Offset: x.GetOffset(), Length: x.GetLength(), Hash: x.GetHash()}
+}
+
+// FromChunk converts c to its protobuf representation.
+// The data is shared rather than copied.
+func FromChunk(c *ae.Chunk) *Chunk {
+	return &Chunk{Offset: c.Offset, Data: c.Data, Sum: c.Sum}
+}
+
+// ToChunk converts x to an ae.Chunk.
+// The data is shared rather than copied.
+func (x *Chunk) ToChunk() *ae.Chunk {
+	return &ae.Chunk{Offset: x.GetOffset(), Data: x.GetData(), Sum: x.GetSum()}
+}
+
+// FromManifest converts m to its protobuf representation.
+func FromManifest(m *ae.Manifest) *Manifest {
+	x := &Manifest{
+		Version:    Version,
+		Size:       m.Size,
+		Parameters: FromParameters(m.Parameters),
+		Chunks:     make([]*ChunkRef, len(m.Chunks)),
+	}
+	if hasParams(m.Parameters) {
+		x.Version = ParamsVersion
+	}
+	for i, ref := range m.Chunks {
+		x.Chunks[i] = FromChunkRef(ref)
+	}
+	for _, g := range m.Parity {
+		x.Parity = append(x.Parity, &ParityGroup{
+			First:     int64(g.First),
+			Chunks:    int64(g.Chunks),
+			ShardSize: g.ShardSize,
+			Shards:    g.Shards,
+		})
+	}
+	return x
+}
+
+// ToManifest converts x to an ae.Manifest and validates it.
+func (x *Manifest) ToManifest() (*ae.Manifest, error) {
+	if x.GetVersion() < 1 || x.GetVersion() > ParamsVersion {
+		return nil, fmt.Errorf("aepb: unsupported manifest version %d", x.GetVersion())
+	}
+	m := &ae.Manifest{
+		Size:       x.GetSize(),
+		Parameters: x.GetParameters().ToParameters(),
+		Chunks:     make([]ae.ChunkRef, len(x.GetChunks())),
+	}
+	for i, ref := range x.GetChunks() {
+		m.Chunks[i] = ref.ToChunkRef()
+	}
+	for _, g := range x.GetParity() {
+		m.Parity = append(m.Parity, ae.ParityGroup{
+			First:     int(g.GetFirst()),
+			Chunks:    int(g.GetChunks()),
+			ShardSize: g.GetShardSize(),
+			Shards:    g.GetShards(),
+		})
+	}
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
@@ -0,0 +1,84 @@
+package aepb
+
+import (
+	"bytes"
+	ae "github.com/mg98/ae-chunker-go"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/proto"
+	"math/rand"
+	"testing"
+)
+
+func TestManifest(t *testing.T) {
+	data := make([]byte, 1024*1024)
+	rand.New(rand.NewSource(1)).Read(data)
+	m, err := ae.BuildManifest(bytes.NewReader(data), &ae.Options{AverageSize: 16 * 1024, Mode: ae.MIN})
+	assert.NoError(t, err)
+
+	t.Run("round trip", func(t *testing.T) {
+		b, err := proto.Marshal(FromManifest(m))
+		assert.NoError(t, err)
+		var x Manifest
+		assert.NoError(t, proto.Unmarshal(b, &x))
+		decoded, err := x.ToManifest()
+		assert.NoError(t, err)
+		assert.Equal(t, m, decoded)
+	})
+
+	t.Run("parity", func(t *testing.T) {
+		s := ae.NewMemoryStore(0)
+		for _, ref := range m.Chunks {
+			assert.NoError(t, s.Put(ref.Hash, data[ref.Offset:ref.Offset+ref.Length]))
+		}
+		withParity := *m
+		assert.NoError(t, ae.AddParity(&withParity, s, 8, 2))
+
+		b, err := proto.Marshal(FromManifest(&withParity))
+		assert.NoError(t, err)
+		var x Manifest
+		assert.NoError(t, proto.Unmarshal(b, &x))
+		decoded, err := x.ToManifest()
+		assert.NoError(t, err)
+		assert.Equal(t, &withParity, decoded)
+	})
+
+	t.Run("parameters", func(t *testing.T) {
+		p := m.Parameters
+		p.Alignment, p.AlignTolerance = 4096, 512
+		p.SnapTolerance, p.Delimiter = 1024, '\n'
+		p.RuneSafe, p.Tar = true, true
+		assert.Equal(t, p, FromParameters(p).ToParameters())
+
+		aligned := *m
+		aligned.Parameters.Alignment = 4096
+		x := FromManifest(&aligned)
+		assert.Equal(t, uint32(ParamsVersion), x.Version)
+		decoded, err := x.ToManifest()
+		assert.NoError(t, err)
+		assert.Equal(t, &aligned, decoded)
+		assert.Equal(t, uint32(Version), FromManifest(m).Version)
+	})
+
+	t.Run("unsupported version", func(t *testing.T) {
+		x := FromManifest(m)
+		x.Version = ParamsVersion + 1
+		_, err := x.ToManifest()
+		assert.Error(t, err)
+	})
+
+	t.Run("inconsistent chunks", func(t *testing.T) {
+		x := FromManifest(m)
+		x.Chunks = x.Chunks[1:]
+		_, err := x.ToManifest()
+		assert.Error(t, err)
+	})
+}
+
+func TestChunk(t *testing.T) {
+	c := &ae.Chunk{Offset: 42, Data: []byte("foo"), Sum: []byte{1, 2}}
+	b, err := proto.Marshal(FromChunk(c))
+	assert.NoError(t, err)
+	var x Chunk
+	assert.NoError(t, proto.Unmarshal(b, &x))
+	assert.Equal(t, c, x.ToChunk())
+}
@@ -0,0 +1,6 @@
+// Package aepb provides protobuf types for chunks and manifests (see ae.proto)
+// along with converters from and to the types of package ae,
+// so that services can exchange chunk metadata over gRPC or other protobuf-based transports.
+package aepb
+
+//go:generate protoc --go_out=. --go_opt=paths=source_relative ae.proto
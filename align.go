@@ -0,0 +1,22 @@
+package ae
+
+// align moves the cut point n within pending bytes at offset to the nearest multiple of alignment in the input
+// at most tolerance bytes away, if any. Of two multiples at the same distance, the earlier one wins.
+// The chunk neither becomes empty nor longer than pending.
+func align(offset int64, n, pending int, alignment, tolerance int64) int {
+	end := offset + int64(n)
+	r := end % alignment
+	if r == 0 {
+		return n
+	}
+	below, above := end-r, end-r+alignment
+	down := r <= tolerance && below > offset
+	up := alignment-r <= tolerance && above-offset <= int64(pending)
+	switch {
+	case down && (r <= alignment-r || !up):
+		return int(below - offset)
+	case up:
+		return int(above - offset)
+	}
+	return n
+}
@@ -0,0 +1,85 @@
+package ae
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestOptions_Alignment(t *testing.T) {
+	input := testFile[:4*MiB]
+	opts := &Options{AverageSize: 16 * 1024, Alignment: 4096, AlignTolerance: 1024}
+	chunks := getChunks(NewChunker(bytes.NewReader(input), opts))
+	assert.Equal(t, input, bytes.Join(chunks, nil))
+
+	// Cut points that were moved are reported as such.
+	c := NewChunker(bytes.NewReader(input), opts)
+	var offset int64
+	for {
+		chunk, err := c.Next()
+		if err != nil {
+			break
+		}
+		offset += int64(len(chunk.Data))
+		if chunk.Reason == CutAligned {
+			assert.Zero(t, offset%4096)
+		}
+		ReleaseChunk(chunk)
+	}
+	assert.Greater(t, c.Stats().AlignedCuts, int64(0))
+	assert.Equal(t, c.Stats().Chunks, c.Stats().ExtremumCuts+c.Stats().AlignedCuts+c.Stats().MaxSizeCuts+c.Stats().EOFCuts)
+
+	aligned := func(chunks [][]byte) (n int) {
+		var offset int
+		for _, c := range chunks {
+			offset += len(c)
+			if offset%4096 == 0 {
+				n++
+			}
+		}
+		return n
+	}
+	// Within a quarter of the alignment on either side, about half of the cut points are moved.
+	plain := getChunks(NewChunker(bytes.NewReader(input), &Options{AverageSize: 16 * 1024}))
+	assert.Greater(t, aligned(chunks), len(chunks)/3)
+	assert.Less(t, aligned(plain), len(plain)/10)
+	for _, c := range chunks {
+		assert.LessOrEqual(t, int64(len(c)), newParams(opts).maxSize)
+	}
+
+	// Rewriting a block changes the chunks around it only.
+	edited := append([]byte{}, input...)
+	for i := 2 * MiB; i < 2*MiB+4096; i++ {
+		edited[i] ^= 0xff
+	}
+	m1, err := BuildManifest(bytes.NewReader(input), opts)
+	assert.NoError(t, err)
+	m2, err := BuildManifest(bytes.NewReader(edited), opts)
+	assert.NoError(t, err)
+	assert.Less(t, DiffManifests(m1, m2).AddedBytes, int64(256*1024))
+}
+
+func TestAlign(t *testing.T) {
+	for _, tc := range []struct {
+		offset  int64
+		n       int
+		pending int
+		want    int
+	}{
+		{0, 4096, 8192, 4096},
+		{0, 4000, 8192, 4096},
+		{0, 4200, 8192, 4096},
+		{0, 4400, 8192, 4400},
+		{0, 4100, 4100, 4096},
+		{0, 4000, 4050, 4000},
+		{0, 100, 8192, 100},
+		{1000, 3000, 8192, 3096},
+		{4000, 50, 8192, 96},
+		{4000, 4100, 8192, 4192},
+		{0, 2048, 8192, 2048},
+	} {
+		assert.Equal(t, tc.want, align(tc.offset, tc.n, tc.pending, 4096, 256), "offset=%d n=%d pending=%d", tc.offset, tc.n, tc.pending)
+	}
+	// Of two multiples at the same distance, the earlier one wins.
+	assert.Equal(t, 8, align(0, 12, 100, 8, 4))
+}
@@ -0,0 +1,254 @@
+// Package azstore implements an ae.ChunkStore on top of Azure Blob Storage,
+// so that chunks can be pushed directly to a storage account.
+package azstore
+
+import (
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	ae "github.com/mg98/ae-chunker-go"
+	"github.com/mg98/ae-chunker-go/internal/retry"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// apiVersion is the version of the Blob service REST API the requests are made for.
+const apiVersion = "2021-08-06"
+
+// Options configure a Store.
+type Options struct {
+	// Endpoint is the base URL of the blob service (optional).
+	// It defaults to "https://<Account>.blob.core.windows.net".
+	Endpoint string
+
+	// Account is the name of the storage account.
+	Account string
+
+	// Key is the base64 encoded access key of the account (optional).
+	// Requests are signed with Shared Key authorization if set.
+	Key string
+
+	// SASToken is a shared access signature appended to every request (optional), e.g. "sv=...&sig=...".
+	// It is used instead of Key. Requests are anonymous if neither is set.
+	SASToken string
+
+	// Container holding the chunks.
+	Container string
+
+	// Prefix prepended to the blob names (optional), e.g. "chunks/".
+	Prefix string
+
+	// Concurrency bounds the number of requests in flight (optional), so that the store can be shared
+	// by parallel uploads. It defaults to 16.
+	Concurrency int
+
+	// Retries is the number of times a failed request is retried (optional). It defaults to 3.
+	// Only network errors, throttling and server errors are retried. A negative value disables retries.
+	Retries int
+
+	// Client sends the requests (optional). It defaults to http.DefaultClient.
+	Client *http.Client
+}
+
+// Error is an error response of the service.
+type Error struct {
+	StatusCode int
+	Code       string `xml:"Code"`
+	Message    string `xml:"Message"`
+}
+
+func (e *Error) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("azstore: %s", http.StatusText(e.StatusCode))
+	}
+	if e.Message == "" {
+		return fmt.Sprintf("azstore: %s", e.Code)
+	}
+	return fmt.Sprintf("azstore: %s: %s", e.Code, e.Message)
+}
+
+// Store is an ae.ChunkStore keeping each chunk in a block blob named by the hex encoded hash.
+type Store struct {
+	endpoint  *url.URL
+	container string
+	prefix    string
+	sas       url.Values
+	signer    signer
+	client    *retry.Client
+}
+
+var _ ae.ChunkStore = (*Store)(nil)
+
+// New returns a Store for the container described by opts.
+func New(opts Options) (*Store, error) {
+	if opts.Account == "" {
+		return nil, errors.New("azstore: missing account")
+	}
+	if opts.Container == "" {
+		return nil, errors.New("azstore: missing container")
+	}
+	rawEndpoint := opts.Endpoint
+	if rawEndpoint == "" {
+		rawEndpoint = "https://" + opts.Account + ".blob.core.windows.net"
+	}
+	endpoint, err := url.Parse(rawEndpoint)
+	if err != nil {
+		return nil, err
+	}
+	if endpoint.Scheme == "" || endpoint.Host == "" {
+		return nil, fmt.Errorf("azstore: invalid endpoint %q", rawEndpoint)
+	}
+	var key []byte
+	if opts.Key != "" {
+		if key, err = base64.StdEncoding.DecodeString(opts.Key); err != nil {
+			return nil, fmt.Errorf("azstore: invalid key: %w", err)
+		}
+	}
+	sas, err := url.ParseQuery(strings.TrimPrefix(opts.SASToken, "?"))
+	if err != nil {
+		return nil, fmt.Errorf("azstore: invalid SAS token: %w", err)
+	}
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = 16
+	}
+
+	return &Store{
+		endpoint:  endpoint,
+		container: opts.Container,
+		prefix:    opts.Prefix,
+		sas:       sas,
+		signer:    signer{account: opts.Account, key: key},
+		client:    retry.New(opts.Client, opts.Retries, concurrency),
+	}, nil
+}
+
+// Put uploads data under hash.
+func (s *Store) Put(hash []byte, data []byte) error {
+	_, err := s.do(http.MethodPut, hash, data)
+	return err
+}
+
+// Get downloads the data stored under hash.
+func (s *Store) Get(hash []byte) ([]byte, error) {
+	return s.do(http.MethodGet, hash, nil)
+}
+
+// Has reports whether a chunk is stored under hash.
+func (s *Store) Has(hash []byte) (bool, error) {
+	_, err := s.do(http.MethodHead, hash, nil)
+	if err == ae.ErrChunkNotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Delete removes the chunk stored under hash.
+func (s *Store) Delete(hash []byte) error {
+	_, err := s.do(http.MethodDelete, hash, nil)
+	return err
+}
+
+// listResult is the response to a List Blobs request.
+type listResult struct {
+	Blobs []struct {
+		Name string `xml:"Name"`
+		Size int64  `xml:"Properties>Content-Length"`
+	} `xml:"Blobs>Blob"`
+	NextMarker string `xml:"NextMarker"`
+}
+
+// Walk calls fn for every chunk in the store. Blobs under the prefix that are not named by a hash are skipped.
+func (s *Store) Walk(fn func(hash []byte, size int64) error) error {
+	var marker string
+	for {
+		query := url.Values{"restype": {"container"}, "comp": {"list"}, "prefix": {s.prefix}}
+		if marker != "" {
+			query.Set("marker", marker)
+		}
+		data, err := s.request(http.MethodGet, s.containerURL(), query, nil)
+		if err != nil {
+			return err
+		}
+		var result listResult
+		if err := xml.Unmarshal(data, &result); err != nil {
+			return fmt.Errorf("azstore: invalid listing: %w", err)
+		}
+		for _, blob := range result.Blobs {
+			hash, err := hex.DecodeString(strings.TrimPrefix(blob.Name, s.prefix))
+			if err != nil || len(hash) == 0 {
+				continue
+			}
+			if err := fn(hash, blob.Size); err != nil {
+				return err
+			}
+		}
+		if result.NextMarker == "" {
+			return nil
+		}
+		marker = result.NextMarker
+	}
+}
+
+// do sends a request for the blob of hash with an optional body and returns the response body.
+// A missing blob is reported as ae.ErrChunkNotFound.
+func (s *Store) do(method string, hash []byte, body []byte) ([]byte, error) {
+	u := s.containerURL()
+	u.Path += "/" + s.prefix + hex.EncodeToString(hash)
+	return s.request(method, u, nil, body)
+}
+
+// containerURL returns the URL of the container.
+func (s *Store) containerURL() *url.URL {
+	u := *s.endpoint
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + s.container
+	return &u
+}
+
+// request sends a request with the query and an optional body to u, retrying on transient errors,
+// and returns the response body.
+func (s *Store) request(method string, u *url.URL, query url.Values, body []byte) ([]byte, error) {
+	if query == nil {
+		query = url.Values{}
+	}
+	for k, v := range s.sas {
+		query[k] = v
+	}
+	u.RawQuery = query.Encode()
+
+	resp, err := s.client.Do(func() (*http.Request, error) {
+		req, err := retry.NewRequest(method, u.String(), body)
+		if err != nil {
+			return nil, err
+		}
+		req.Header.Set("X-Ms-Version", apiVersion)
+		if method == http.MethodPut {
+			req.Header.Set("X-Ms-Blob-Type", "BlockBlob")
+		}
+		s.signer.sign(req, time.Now())
+		return req, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		e := &Error{StatusCode: resp.StatusCode}
+		xml.Unmarshal(resp.Body, e)
+		// Responses to HEAD requests carry the error code in a header only.
+		if code := resp.Header.Get("X-Ms-Error-Code"); code != "" {
+			e.Code = code
+		}
+		if e.StatusCode == http.StatusNotFound && e.Code != "ContainerNotFound" {
+			return nil, ae.ErrChunkNotFound
+		}
+		return nil, e
+	}
+	if method == http.MethodHead {
+		return nil, nil
+	}
+	return resp.Body, nil
+}
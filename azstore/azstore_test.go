@@ -0,0 +1,199 @@
+package azstore
+
+import (
+	"fmt"
+	ae "github.com/mg98/ae-chunker-go"
+	"github.com/mg98/ae-chunker-go/internal/retry/retrytest"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeAzure is a minimal in-memory implementation of the Blob service API.
+type fakeAzure struct {
+	*retrytest.Flaky
+
+	mu    sync.Mutex
+	blobs map[string][]byte
+}
+
+func (f *fakeAzure) serve(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "SharedKey account:") && r.URL.Query().Get("sig") != "signature" {
+		w.Header().Set("X-Ms-Error-Code", "AuthenticationFailed")
+		w.WriteHeader(http.StatusForbidden)
+		io.WriteString(w, "<Error><Code>AuthenticationFailed</Code><Message>Server failed to authenticate the request.</Message></Error>")
+		return
+	}
+	if r.URL.Query().Get("comp") == "list" {
+		f.list(w, r)
+		return
+	}
+	switch r.Method {
+	case http.MethodPut:
+		if r.Header.Get("X-Ms-Blob-Type") != "BlockBlob" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		data, _ := io.ReadAll(r.Body)
+		f.blobs[r.URL.Path] = data
+		w.WriteHeader(http.StatusCreated)
+	case http.MethodGet, http.MethodHead, http.MethodDelete:
+		data, ok := f.blobs[r.URL.Path]
+		if !ok {
+			w.Header().Set("X-Ms-Error-Code", "BlobNotFound")
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.Method == http.MethodDelete {
+			delete(f.blobs, r.URL.Path)
+			w.WriteHeader(http.StatusAccepted)
+			return
+		}
+		w.Write(data)
+	}
+}
+
+// list answers a List Blobs request with pages of two blobs.
+func (f *fakeAzure) list(w http.ResponseWriter, r *http.Request) {
+	prefix := r.URL.Path + "/" + r.URL.Query().Get("prefix")
+	var names []string
+	for k := range f.blobs {
+		if strings.HasPrefix(k, prefix) && k > r.URL.Path+"/"+r.URL.Query().Get("marker") {
+			names = append(names, k)
+		}
+	}
+	sort.Strings(names)
+	io.WriteString(w, "<EnumerationResults><Blobs>")
+	for i, k := range names {
+		if i == 2 {
+			fmt.Fprintf(w, "</Blobs><NextMarker>%s</NextMarker></EnumerationResults>", strings.TrimPrefix(names[i-1], r.URL.Path+"/"))
+			return
+		}
+		fmt.Fprintf(w, "<Blob><Name>%s</Name><Properties><Content-Length>%d</Content-Length></Properties></Blob>",
+			strings.TrimPrefix(k, r.URL.Path+"/"), len(f.blobs[k]))
+	}
+	io.WriteString(w, "</Blobs><NextMarker /></EnumerationResults>")
+}
+
+func newTestStore(t *testing.T, opts Options) (*Store, *fakeAzure) {
+	f := &fakeAzure{blobs: make(map[string][]byte)}
+	f.Flaky = &retrytest.Flaky{Handler: http.HandlerFunc(f.serve), Status: http.StatusServiceUnavailable,
+		Body: "<Error><Code>ServerBusy</Code><Message>The server is busy.</Message></Error>"}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	opts.Endpoint = srv.URL
+	opts.Container = "container"
+	if opts.Account == "" {
+		opts.Account = "account"
+		opts.Key = "c2VjcmV0"
+	}
+	s, err := New(opts)
+	assert.NoError(t, err)
+	s.client.Backoff = 0
+	return s, f
+}
+
+func TestStore(t *testing.T) {
+	s, f := newTestStore(t, Options{Prefix: "chunks/"})
+	hash := []byte{0xca, 0xfe}
+
+	ok, err := s.Has(hash)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	_, err = s.Get(hash)
+	assert.Equal(t, ae.ErrChunkNotFound, err)
+	assert.Equal(t, ae.ErrChunkNotFound, s.Delete(hash))
+
+	assert.NoError(t, s.Put(hash, []byte("hello world")))
+	assert.Equal(t, []byte("hello world"), f.blobs["/container/chunks/cafe"])
+	ok, err = s.Has(hash)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	data, err := s.Get(hash)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("hello world"), data)
+
+	assert.NoError(t, s.Delete(hash))
+	assert.Empty(t, f.blobs)
+
+	t.Run("concurrent", func(t *testing.T) {
+		var wg sync.WaitGroup
+		for i := 0; i < 50; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				assert.NoError(t, s.Put([]byte{byte(i)}, []byte{byte(i)}))
+			}(i)
+		}
+		wg.Wait()
+		assert.Len(t, f.blobs, 50)
+	})
+}
+
+func TestStore_Walk(t *testing.T) {
+	s, f := newTestStore(t, Options{Prefix: "chunks/"})
+	want := map[string]int64{}
+	for i := 0; i < 5; i++ {
+		hash := []byte{byte(i), 0xff}
+		assert.NoError(t, s.Put(hash, make([]byte, i)))
+		want[string(hash)] = int64(i)
+	}
+	f.blobs["/container/chunks/README"] = []byte("foreign blob")
+	f.blobs["/container/other/abcd"] = []byte("outside of prefix")
+
+	got := map[string]int64{}
+	assert.NoError(t, s.Walk(func(hash []byte, size int64) error {
+		got[string(hash)] = size
+		return nil
+	}))
+	assert.Equal(t, want, got)
+}
+
+func TestStore_retries(t *testing.T) {
+	s, f := newTestStore(t, Options{Retries: 2})
+
+	f.Failures = 2
+	assert.NoError(t, s.Put([]byte{1}, []byte("foo")))
+	assert.Equal(t, 3, f.Requests)
+
+	f.Failures = 3
+	f.Requests = 0
+	_, err := s.Get([]byte{1})
+	assert.Equal(t, &Error{StatusCode: http.StatusServiceUnavailable, Code: "ServerBusy", Message: "The server is busy."}, err)
+	assert.Equal(t, 3, f.Requests)
+
+	t.Run("client errors are not retried", func(t *testing.T) {
+		s, f := newTestStore(t, Options{Account: "other"})
+		_, err := s.Has([]byte{1})
+		assert.Equal(t, &Error{StatusCode: http.StatusForbidden, Code: "AuthenticationFailed"}, err)
+		assert.Equal(t, 1, f.Requests)
+	})
+}
+
+func TestStore_sas(t *testing.T) {
+	s, f := newTestStore(t, Options{Account: "account", SASToken: "?sv=2021-08-06&sig=signature"})
+	assert.NoError(t, s.Put([]byte{1}, []byte("foo")))
+	assert.NoError(t, s.Walk(func(hash []byte, size int64) error { return nil }))
+	assert.Equal(t, 2, f.Requests)
+}
+
+func TestNew(t *testing.T) {
+	s, err := New(Options{Account: "account", Container: "container"})
+	assert.NoError(t, err)
+	assert.Equal(t, "https://account.blob.core.windows.net/container", s.containerURL().String())
+	_, err = New(Options{Account: "account"})
+	assert.Error(t, err)
+	_, err = New(Options{Container: "container"})
+	assert.Error(t, err)
+	_, err = New(Options{Account: "account", Container: "container", Key: "not base64!"})
+	assert.Error(t, err)
+	_, err = New(Options{Endpoint: "localhost:10000", Account: "account", Container: "container"})
+	assert.Error(t, err)
+}
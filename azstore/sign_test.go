@@ -0,0 +1,39 @@
+package azstore
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSigner_sign(t *testing.T) {
+	req, err := http.NewRequest(http.MethodPut, "https://myaccount.blob.core.windows.net/chunks/ab%20c?comp=list&restype=container", strings.NewReader("hello"))
+	assert.NoError(t, err)
+	req.Header.Set("X-Ms-Version", apiVersion)
+	req.Header.Set("X-Ms-Blob-Type", "BlockBlob")
+	req.Header.Set("Content-Type", "application/octet-stream")
+
+	key := []byte("secret")
+	s := &signer{account: "myaccount", key: key}
+	s.sign(req, time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC))
+	assert.Equal(t, "Wed, 01 May 2024 12:00:00 GMT", req.Header.Get("X-Ms-Date"))
+
+	stringToSign := "PUT\n\n\n5\n\napplication/octet-stream\n\n\n\n\n\n\n" +
+		"x-ms-blob-type:BlockBlob\nx-ms-date:Wed, 01 May 2024 12:00:00 GMT\nx-ms-version:" + apiVersion + "\n" +
+		"/myaccount/chunks/ab%20c\ncomp:list\nrestype:container"
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(stringToSign))
+	assert.Equal(t, "SharedKey myaccount:"+base64.StdEncoding.EncodeToString(mac.Sum(nil)), req.Header.Get("Authorization"))
+
+	t.Run("anonymous", func(t *testing.T) {
+		req, err := http.NewRequest(http.MethodGet, "https://myaccount.blob.core.windows.net/chunks/abc", nil)
+		assert.NoError(t, err)
+		(&signer{account: "myaccount"}).sign(req, time.Now())
+		assert.Empty(t, req.Header.Get("Authorization"))
+	})
+}
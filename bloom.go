@@ -0,0 +1,177 @@
+package ae
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"sync"
+)
+
+// BloomFilter is a probabilistic set of chunk hashes.
+// It never misses a hash that has been added, but may report hashes that have not,
+// at a false-positive rate chosen on construction. It is safe for concurrent use.
+type BloomFilter struct {
+	mu   sync.RWMutex
+	bits []uint64
+	m    uint64
+	k    uint64
+}
+
+// NewBloomFilter returns a BloomFilter sized for n hashes at the false-positive rate fpRate, e.g. 0.01.
+func NewBloomFilter(n int, fpRate float64) *BloomFilter {
+	if n < 1 {
+		n = 1
+	}
+	if fpRate <= 0 || fpRate >= 1 {
+		fpRate = 0.01
+	}
+	m := uint64(math.Ceil(-float64(n) * math.Log(fpRate) / (math.Ln2 * math.Ln2)))
+	k := uint64(math.Round(float64(m) / float64(n) * math.Ln2))
+	if k < 1 {
+		k = 1
+	}
+	return &BloomFilter{bits: make([]uint64, (m+63)/64), m: m, k: k}
+}
+
+// Add inserts hash into the filter.
+func (f *BloomFilter) Add(hash []byte) {
+	h1, h2 := bloomHashes(hash)
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	for i := uint64(0); i < f.k; i++ {
+		bit := (h1 + i*h2) % f.m
+		f.bits[bit/64] |= 1 << (bit % 64)
+	}
+}
+
+// MayContain reports whether hash may have been added.
+// If it returns false, hash has certainly not been added.
+func (f *BloomFilter) MayContain(hash []byte) bool {
+	h1, h2 := bloomHashes(hash)
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	for i := uint64(0); i < f.k; i++ {
+		bit := (h1 + i*h2) % f.m
+		if f.bits[bit/64]&(1<<(bit%64)) == 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// bloomHashes derives the two base hashes for the bit positions of hash.
+// Digests of at least 128 bits are uniformly distributed already, anything shorter is hashed first.
+func bloomHashes(hash []byte) (uint64, uint64) {
+	if len(hash) < 16 {
+		sum := sha256.Sum256(hash)
+		hash = sum[:]
+	}
+	// an odd step visits distinct bits for every k below m
+	return binary.LittleEndian.Uint64(hash), binary.LittleEndian.Uint64(hash[8:]) | 1
+}
+
+// bloomMagic prefixes every Bloom filter in binary encoding.
+var bloomMagic = []byte("AEbf")
+
+// bloomVersion is the version of the binary encoding of BloomFilter.
+const bloomVersion = 1
+
+// ErrInvalidBloomFilter is returned when decoding a malformed binary Bloom filter.
+var ErrInvalidBloomFilter = errors.New("ae: invalid binary Bloom filter")
+
+// MarshalBinary encodes the filter for persistence.
+func (f *BloomFilter) MarshalBinary() ([]byte, error) {
+	f.mu.RLock()
+	defer f.mu.RUnlock()
+	buf := append([]byte(nil), bloomMagic...)
+	buf = append(buf, bloomVersion)
+	buf = appendUvarint(buf, f.m)
+	buf = appendUvarint(buf, f.k)
+	n := len(buf)
+	buf = append(buf, make([]byte, 8*len(f.bits))...)
+	for i, w := range f.bits {
+		binary.LittleEndian.PutUint64(buf[n+8*i:], w)
+	}
+	return buf, nil
+}
+
+// UnmarshalBinary decodes a filter in the encoding of MarshalBinary.
+func (f *BloomFilter) UnmarshalBinary(data []byte) error {
+	r := bytes.NewReader(data)
+	header := make([]byte, len(bloomMagic)+1)
+	if _, err := io.ReadFull(r, header); err != nil || !bytes.Equal(header[:len(bloomMagic)], bloomMagic) {
+		return ErrInvalidBloomFilter
+	}
+	if header[len(bloomMagic)] != bloomVersion {
+		return fmt.Errorf("ae: unsupported Bloom filter version %d", header[len(bloomMagic)])
+	}
+	m, err1 := binary.ReadUvarint(r)
+	k, err2 := binary.ReadUvarint(r)
+	if err1 != nil || err2 != nil || m == 0 || k == 0 || k > 64 || uint64(r.Len()) != (m+63)/64*8 {
+		return ErrInvalidBloomFilter
+	}
+	bits := make([]uint64, (m+63)/64)
+	rest := data[len(data)-r.Len():]
+	for i := range bits {
+		bits[i] = binary.LittleEndian.Uint64(rest[8*i:])
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	f.bits, f.m, f.k = bits, m, k
+	return nil
+}
+
+// FilteredStore is a ChunkStore answering Has from a BloomFilter wherever possible.
+// Placed in front of a remote store, it saves the round trips for chunks that are new for certain,
+// which are the majority during a first backup.
+// The filter must have been populated with all chunks in the underlying store beforehand.
+type FilteredStore struct {
+	store  ChunkStore
+	filter *BloomFilter
+}
+
+// NewFilteredStore returns a FilteredStore over s using filter.
+func NewFilteredStore(s ChunkStore, filter *BloomFilter) *FilteredStore {
+	return &FilteredStore{store: s, filter: filter}
+}
+
+// Put stores data under hash in the underlying store and adds hash to the filter.
+func (s *FilteredStore) Put(hash []byte, data []byte) error {
+	if err := s.store.Put(hash, data); err != nil {
+		return err
+	}
+	s.filter.Add(hash)
+	return nil
+}
+
+// Get returns the data stored under hash.
+func (s *FilteredStore) Get(hash []byte) ([]byte, error) {
+	if !s.filter.MayContain(hash) {
+		return nil, ErrChunkNotFound
+	}
+	return s.store.Get(hash)
+}
+
+// Has reports whether a chunk is stored under hash, consulting the underlying store only if the filter cannot rule it out.
+func (s *FilteredStore) Has(hash []byte) (bool, error) {
+	if !s.filter.MayContain(hash) {
+		return false, nil
+	}
+	return s.store.Has(hash)
+}
+
+// Delete removes the chunk stored under hash from the underlying store.
+// Bloom filters do not support removals, so the hash remains in the filter.
+func (s *FilteredStore) Delete(hash []byte) error {
+	return s.store.Delete(hash)
+}
+
+// Walk calls fn for every chunk in the underlying store.
+func (s *FilteredStore) Walk(fn func(hash []byte, size int64) error) error {
+	return walkStore(s.store, fn)
+}
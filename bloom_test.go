@@ -0,0 +1,84 @@
+package ae
+
+import (
+	"crypto/sha256"
+	"encoding/binary"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// testHash returns the SHA-256 digest of i.
+func testHash(i int) []byte {
+	var buf [8]byte
+	binary.LittleEndian.PutUint64(buf[:], uint64(i))
+	sum := sha256.Sum256(buf[:])
+	return sum[:]
+}
+
+func TestBloomFilter(t *testing.T) {
+	const n = 10000
+	f := NewBloomFilter(n, 0.01)
+	for i := 0; i < n; i++ {
+		f.Add(testHash(i))
+	}
+
+	t.Run("no false negatives", func(t *testing.T) {
+		for i := 0; i < n; i++ {
+			assert.True(t, f.MayContain(testHash(i)))
+		}
+		f.Add([]byte{1, 2, 3})
+		assert.True(t, f.MayContain([]byte{1, 2, 3}))
+	})
+
+	t.Run("false positive rate", func(t *testing.T) {
+		fp := 0
+		for i := n; i < 2*n; i++ {
+			if f.MayContain(testHash(i)) {
+				fp++
+			}
+		}
+		assert.Less(t, float64(fp)/n, 0.02)
+	})
+
+	t.Run("serialization", func(t *testing.T) {
+		data, err := f.MarshalBinary()
+		assert.NoError(t, err)
+		var decoded BloomFilter
+		assert.NoError(t, decoded.UnmarshalBinary(data))
+		for i := 0; i < 2*n; i++ {
+			assert.Equal(t, f.MayContain(testHash(i)), decoded.MayContain(testHash(i)))
+		}
+
+		assert.Equal(t, ErrInvalidBloomFilter, decoded.UnmarshalBinary(data[:len(data)-1]))
+		assert.Equal(t, ErrInvalidBloomFilter, decoded.UnmarshalBinary(data[:3]))
+	})
+}
+
+// countingStore counts the calls to Has of the underlying store.
+type countingStore struct {
+	ChunkStore
+	has int
+}
+
+func (s *countingStore) Has(hash []byte) (bool, error) {
+	s.has++
+	return s.ChunkStore.Has(hash)
+}
+
+func TestFilteredStore(t *testing.T) {
+	testChunkStore(t, NewFilteredStore(NewMemoryStore(0), NewBloomFilter(100, 0.01)))
+
+	backend := &countingStore{ChunkStore: NewMemoryStore(0)}
+	s := NewFilteredStore(backend, NewBloomFilter(1000, 0.01))
+	for i := 0; i < 100; i++ {
+		ok, err := s.Has(testHash(i))
+		assert.NoError(t, err)
+		assert.False(t, ok)
+	}
+	assert.Less(t, backend.has, 5)
+
+	assert.NoError(t, s.Put(testHash(0), []byte("foo")))
+	ok, err := s.Has(testHash(0))
+	assert.NoError(t, err)
+	assert.True(t, ok)
+}
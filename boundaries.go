@@ -0,0 +1,162 @@
+package ae
+
+import (
+	"bufio"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// BoundaryFormat is a machine-readable format of chunk boundaries.
+type BoundaryFormat uint8
+
+const (
+	// BoundaryCSV writes a header line followed by one line per chunk.
+	BoundaryCSV BoundaryFormat = iota
+
+	// BoundaryJSON writes a single JSON array of chunk objects.
+	BoundaryJSON
+
+	// BoundaryNDJSON writes one JSON object per chunk and line.
+	BoundaryNDJSON
+)
+
+// String returns the name of the format, i.e. "csv", "json" or "ndjson".
+func (f BoundaryFormat) String() string {
+	switch f {
+	case BoundaryCSV:
+		return "csv"
+	case BoundaryJSON:
+		return "json"
+	case BoundaryNDJSON:
+		return "ndjson"
+	default:
+		return fmt.Sprintf("BoundaryFormat(%d)", uint8(f))
+	}
+}
+
+// MarshalText implements encoding.TextMarshaler.
+func (f BoundaryFormat) MarshalText() ([]byte, error) {
+	if f > BoundaryNDJSON {
+		return nil, fmt.Errorf("ae: invalid boundary format %d", uint8(f))
+	}
+	return []byte(f.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (f *BoundaryFormat) UnmarshalText(text []byte) error {
+	switch string(text) {
+	case "csv":
+		*f = BoundaryCSV
+	case "json":
+		*f = BoundaryJSON
+	case "ndjson":
+		*f = BoundaryNDJSON
+	default:
+		return fmt.Errorf("ae: invalid boundary format %q", text)
+	}
+	return nil
+}
+
+// boundaryJSON is the JSON representation of a chunk boundary.
+type boundaryJSON struct {
+	Offset int64  `json:"offset"`
+	Length int64  `json:"length"`
+	Hash   string `json:"hash,omitempty"`
+}
+
+// BoundaryWriter is a ChunkSink writing the offset, length and hex encoded hash of every chunk
+// in a machine-readable format, so that the cut points can be consumed by other tools.
+// The hash is left empty if the Chunker is not configured with one.
+// Close must be called after the last chunk to complete the output.
+type BoundaryWriter struct {
+	w      *bufio.Writer
+	csv    *csv.Writer
+	format BoundaryFormat
+	count  int
+	err    error
+}
+
+// NewBoundaryWriter returns a BoundaryWriter writing to w in the given format.
+func NewBoundaryWriter(w io.Writer, format BoundaryFormat) *BoundaryWriter {
+	bw := &BoundaryWriter{w: bufio.NewWriter(w), format: format}
+	switch format {
+	case BoundaryCSV:
+		bw.csv = csv.NewWriter(bw.w)
+		bw.err = bw.csv.Write([]string{"offset", "length", "hash"})
+	case BoundaryJSON:
+		_, bw.err = bw.w.WriteString("[")
+	case BoundaryNDJSON:
+	default:
+		bw.err = fmt.Errorf("ae: invalid boundary format %d", uint8(format))
+	}
+	return bw
+}
+
+// WriteChunk writes the boundary of c. It does not retain c.
+func (bw *BoundaryWriter) WriteChunk(c *Chunk) error {
+	return bw.Write(ChunkRef{Offset: c.Offset, Length: int64(len(c.Data)), Hash: c.Sum})
+}
+
+// Write writes the boundary of the chunk referred to by ref, e.g. taken from a Manifest.
+func (bw *BoundaryWriter) Write(ref ChunkRef) error {
+	if bw.err != nil {
+		return bw.err
+	}
+	hash := hex.EncodeToString(ref.Hash)
+	switch bw.format {
+	case BoundaryCSV:
+		bw.err = bw.csv.Write([]string{
+			strconv.FormatInt(ref.Offset, 10),
+			strconv.FormatInt(ref.Length, 10),
+			hash,
+		})
+	default:
+		var data []byte
+		data, bw.err = json.Marshal(boundaryJSON{Offset: ref.Offset, Length: ref.Length, Hash: hash})
+		if bw.err != nil {
+			return bw.err
+		}
+		if bw.format == BoundaryJSON {
+			if bw.count > 0 {
+				bw.w.WriteByte(',')
+			}
+			bw.w.WriteByte('\n')
+		}
+		bw.w.Write(data)
+		if bw.format == BoundaryNDJSON {
+			bw.w.WriteByte('\n')
+		}
+	}
+	bw.count++
+	return bw.err
+}
+
+// Close completes the output and flushes it to the underlying writer, which is not closed.
+func (bw *BoundaryWriter) Close() error {
+	if bw.err != nil {
+		return bw.err
+	}
+	switch bw.format {
+	case BoundaryCSV:
+		bw.csv.Flush()
+		bw.err = bw.csv.Error()
+	case BoundaryJSON:
+		if bw.count > 0 {
+			bw.w.WriteByte('\n')
+		}
+		bw.w.WriteString("]\n")
+	}
+	if bw.err == nil {
+		bw.err = bw.w.Flush()
+	}
+	if bw.err != nil {
+		return bw.err
+	}
+	bw.err = errors.New("ae: boundary writer is closed")
+	return nil
+}
@@ -0,0 +1,111 @@
+package ae
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/hex"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+func TestBoundaryFormat_Text(t *testing.T) {
+	for _, f := range []BoundaryFormat{BoundaryCSV, BoundaryJSON, BoundaryNDJSON} {
+		text, err := f.MarshalText()
+		assert.NoError(t, err)
+		var decoded BoundaryFormat
+		assert.NoError(t, decoded.UnmarshalText(text))
+		assert.Equal(t, f, decoded)
+	}
+	_, err := BoundaryFormat(3).MarshalText()
+	assert.Error(t, err)
+	var f BoundaryFormat
+	assert.Error(t, f.UnmarshalText([]byte("xml")))
+}
+
+func TestBoundaryWriter(t *testing.T) {
+	input := testFile[:2*MiB]
+	m, err := BuildManifest(bytes.NewReader(input), &Options{AverageSize: 64 * 1024})
+	assert.NoError(t, err)
+
+	// export writes the boundaries of input in format f.
+	export := func(f BoundaryFormat, opts *Options) string {
+		var buf bytes.Buffer
+		bw := NewBoundaryWriter(&buf, f)
+		_, err := CopyChunks(bw, NewChunker(bytes.NewReader(input), opts))
+		assert.NoError(t, err)
+		assert.NoError(t, bw.Close())
+		assert.Error(t, bw.Write(ChunkRef{}))
+		return buf.String()
+	}
+	opts := &Options{AverageSize: 64 * 1024, SHA256: true}
+
+	t.Run("csv", func(t *testing.T) {
+		records, err := csv.NewReader(strings.NewReader(export(BoundaryCSV, opts))).ReadAll()
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"offset", "length", "hash"}, records[0])
+		assert.Len(t, records, len(m.Chunks)+1)
+		for i, ref := range m.Chunks {
+			assert.Equal(t, []string{
+				strconv.FormatInt(ref.Offset, 10),
+				strconv.FormatInt(ref.Length, 10),
+				hex.EncodeToString(ref.Hash),
+			}, records[i+1])
+		}
+	})
+
+	t.Run("json", func(t *testing.T) {
+		var boundaries []boundaryJSON
+		assert.NoError(t, json.Unmarshal([]byte(export(BoundaryJSON, opts)), &boundaries))
+		assert.Len(t, boundaries, len(m.Chunks))
+		for i, ref := range m.Chunks {
+			assert.Equal(t, boundaryJSON{ref.Offset, ref.Length, hex.EncodeToString(ref.Hash)}, boundaries[i])
+		}
+	})
+
+	t.Run("ndjson", func(t *testing.T) {
+		lines := strings.Split(strings.TrimSuffix(export(BoundaryNDJSON, opts), "\n"), "\n")
+		assert.Len(t, lines, len(m.Chunks))
+		for i, ref := range m.Chunks {
+			var b boundaryJSON
+			assert.NoError(t, json.Unmarshal([]byte(lines[i]), &b))
+			assert.Equal(t, boundaryJSON{ref.Offset, ref.Length, hex.EncodeToString(ref.Hash)}, b)
+		}
+	})
+
+	t.Run("without hash", func(t *testing.T) {
+		out := export(BoundaryNDJSON, &Options{AverageSize: 64 * 1024})
+		assert.True(t, strings.HasPrefix(out, `{"offset":0,"length":`))
+		assert.NotContains(t, out, "hash")
+	})
+
+	t.Run("empty input", func(t *testing.T) {
+		for f, want := range map[BoundaryFormat]string{
+			BoundaryCSV:    "offset,length,hash\n",
+			BoundaryJSON:   "[]\n",
+			BoundaryNDJSON: "",
+		} {
+			var buf bytes.Buffer
+			assert.NoError(t, NewBoundaryWriter(&buf, f).Close())
+			assert.Equal(t, want, buf.String())
+		}
+	})
+
+	t.Run("from manifest", func(t *testing.T) {
+		var buf bytes.Buffer
+		bw := NewBoundaryWriter(&buf, BoundaryJSON)
+		for _, ref := range m.Chunks {
+			assert.NoError(t, bw.Write(ref))
+		}
+		assert.NoError(t, bw.Close())
+		assert.Equal(t, export(BoundaryJSON, opts), buf.String())
+	})
+
+	t.Run("invalid format", func(t *testing.T) {
+		bw := NewBoundaryWriter(&bytes.Buffer{}, BoundaryFormat(3))
+		assert.Error(t, bw.Write(m.Chunks[0]))
+		assert.Error(t, bw.Close())
+	})
+}
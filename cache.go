@@ -0,0 +1,258 @@
+package ae
+
+import (
+	"container/list"
+	"os"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// CachingStore is a ChunkStore serving reads from a cache in front of a slower store, such as a remote backend,
+// so that reassembling files and reading virtual files do not fetch the same chunks over and over again.
+// The cache is typically a bounded MemoryStore or DiskCache, which evict the least recently used chunks.
+//
+// Chunks read from the store are added to the cache. Chunks written are only passed on to the store,
+// so that a backup does not evict the chunks that are read often.
+type CachingStore struct {
+	store ChunkStore
+	cache ChunkStore
+
+	hits, misses int64
+}
+
+// CacheStats counts the reads of a CachingStore.
+type CacheStats struct {
+	// Hits is the number of chunks read from the cache.
+	Hits int64
+
+	// Misses is the number of chunks read from the underlying store.
+	Misses int64
+}
+
+// NewCachingStore returns a CachingStore reading the chunks of s through cache.
+func NewCachingStore(s ChunkStore, cache ChunkStore) *CachingStore {
+	return &CachingStore{store: s, cache: cache}
+}
+
+// Put stores data in the underlying store.
+func (s *CachingStore) Put(hash []byte, data []byte) error {
+	return s.store.Put(hash, data)
+}
+
+// Get returns the data stored under hash from the cache, or reads it from the underlying store and caches it.
+// Failures of the cache are not reported, as the data can still be read from the store.
+func (s *CachingStore) Get(hash []byte) ([]byte, error) {
+	if data, err := s.cache.Get(hash); err == nil {
+		atomic.AddInt64(&s.hits, 1)
+		return data, nil
+	}
+	data, err := s.store.Get(hash)
+	if err != nil {
+		return nil, err
+	}
+	atomic.AddInt64(&s.misses, 1)
+	s.cache.Put(hash, data)
+	return data, nil
+}
+
+// Has reports whether a chunk is stored under hash, asking the underlying store unless it is cached.
+func (s *CachingStore) Has(hash []byte) (bool, error) {
+	if ok, err := s.cache.Has(hash); err == nil && ok {
+		return true, nil
+	}
+	return s.store.Has(hash)
+}
+
+// Delete removes the chunk stored under hash from the cache and the underlying store.
+func (s *CachingStore) Delete(hash []byte) error {
+	if err := s.cache.Delete(hash); err != nil && err != ErrChunkNotFound {
+		return err
+	}
+	return s.store.Delete(hash)
+}
+
+// Walk calls fn for every chunk in the underlying store.
+func (s *CachingStore) Walk(fn func(hash []byte, size int64) error) error {
+	return walkStore(s.store, fn)
+}
+
+// Stats returns the number of cache hits and misses so far.
+func (s *CachingStore) Stats() CacheStats {
+	return CacheStats{Hits: atomic.LoadInt64(&s.hits), Misses: atomic.LoadInt64(&s.misses)}
+}
+
+// DiskCache is a ChunkStore keeping chunks in the files of a directory, like a FileStore,
+// bounded in size by evicting the least recently used chunks. It is meant to be the cache of a CachingStore,
+// for chunks that outlive the process or outgrow the memory.
+//
+// The modification time of a file records when its chunk was last used,
+// so that the order of eviction survives reopening the cache.
+type DiskCache struct {
+	mu    sync.Mutex
+	files *FileStore
+
+	// maxBytes bounds the total size of the cached chunks.
+	maxBytes int64
+
+	// size is the total size of the cached chunks.
+	size int64
+
+	// entries maps hashes to their element in lru.
+	entries map[string]*list.Element
+
+	// lru orders the entries from most to least recently used.
+	lru *list.List
+}
+
+// diskEntry is a chunk held by a DiskCache.
+type diskEntry struct {
+	key  string
+	size int64
+}
+
+// NewDiskCache returns a DiskCache holding at most maxBytes of chunk data in dir, which is created if necessary.
+// The chunks already in dir are kept, evicting the least recently used ones if they exceed maxBytes.
+func NewDiskCache(dir string, maxBytes int64) (*DiskCache, error) {
+	files, err := NewFileStore(dir, nil)
+	if err != nil {
+		return nil, err
+	}
+	c := &DiskCache{files: files, maxBytes: maxBytes, entries: make(map[string]*list.Element), lru: list.New()}
+
+	type file struct {
+		entry *diskEntry
+		used  time.Time
+	}
+	var existing []file
+	err = files.Walk(func(hash []byte, size int64) error {
+		info, err := os.Stat(files.path(hash))
+		if err != nil {
+			return err
+		}
+		existing = append(existing, file{&diskEntry{key: string(hash), size: size}, info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	sort.Slice(existing, func(i, j int) bool { return existing[i].used.Before(existing[j].used) })
+	for _, f := range existing {
+		c.entries[f.entry.key] = c.lru.PushFront(f.entry)
+		c.size += f.entry.size
+	}
+	if err := c.evict(0); err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// Put stores data under hash, evicting the least recently used chunks if needed.
+// Chunks larger than the size bound are not stored at all.
+func (c *DiskCache) Put(hash []byte, data []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if e, ok := c.entries[string(hash)]; ok {
+		c.lru.MoveToFront(e)
+		return nil
+	}
+	if int64(len(data)) > c.maxBytes {
+		return nil
+	}
+	if err := c.evict(int64(len(data))); err != nil {
+		return err
+	}
+	if err := c.files.Put(hash, data); err != nil {
+		return err
+	}
+	entry := &diskEntry{key: string(hash), size: int64(len(data))}
+	c.entries[entry.key] = c.lru.PushFront(entry)
+	c.size += entry.size
+	return nil
+}
+
+// evict removes the least recently used chunks until n more bytes fit into the cache.
+func (c *DiskCache) evict(n int64) error {
+	for c.size+n > c.maxBytes && c.lru.Len() > 0 {
+		e := c.lru.Back()
+		entry := e.Value.(*diskEntry)
+		if err := c.files.Delete([]byte(entry.key)); err != nil && err != ErrChunkNotFound {
+			return err
+		}
+		c.remove(e)
+	}
+	return nil
+}
+
+// Get returns the data stored under hash and marks it as the most recently used.
+func (c *DiskCache) Get(hash []byte) ([]byte, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[string(hash)]
+	if !ok {
+		return nil, ErrChunkNotFound
+	}
+	data, err := c.files.Get(hash)
+	if err == ErrChunkNotFound {
+		// The file was removed behind the cache's back.
+		c.remove(e)
+	}
+	if err != nil {
+		return nil, err
+	}
+	c.lru.MoveToFront(e)
+	now := time.Now()
+	os.Chtimes(c.files.path(hash), now, now)
+	return data, nil
+}
+
+// Has reports whether a chunk is stored under hash.
+func (c *DiskCache) Has(hash []byte) (bool, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	_, ok := c.entries[string(hash)]
+	return ok, nil
+}
+
+// Delete removes the chunk stored under hash.
+func (c *DiskCache) Delete(hash []byte) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[string(hash)]
+	if !ok {
+		return ErrChunkNotFound
+	}
+	if err := c.files.Delete(hash); err != nil && err != ErrChunkNotFound {
+		return err
+	}
+	c.remove(e)
+	return nil
+}
+
+// Walk calls fn for every chunk in the cache, from the most to the least recently used.
+func (c *DiskCache) Walk(fn func(hash []byte, size int64) error) error {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for e := c.lru.Front(); e != nil; e = e.Next() {
+		entry := e.Value.(*diskEntry)
+		if err := fn([]byte(entry.key), entry.size); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Size returns the total size of the cached chunks in bytes.
+func (c *DiskCache) Size() int64 {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.size
+}
+
+// remove drops the entry e from the index.
+func (c *DiskCache) remove(e *list.Element) {
+	entry := c.lru.Remove(e).(*diskEntry)
+	delete(c.entries, entry.key)
+	c.size -= entry.size
+}
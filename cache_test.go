@@ -0,0 +1,92 @@
+package ae
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"testing"
+)
+
+func TestCachingStore(t *testing.T) {
+	input := testFile[:4*MiB]
+	m, backend := storeInput(t, input, &Options{AverageSize: 64 * 1024})
+	s := NewCachingStore(backend, NewMemoryStore(2*MiB))
+
+	// Reading the first MiB twice only fetches its chunks once.
+	r, err := ReassembleAt(m, s)
+	require.NoError(t, err)
+	for i := 0; i < 2; i++ {
+		_, err := r.ReadAt(make([]byte, MiB), 0)
+		require.NoError(t, err)
+	}
+	stats := s.Stats()
+	assert.Greater(t, stats.Hits, int64(0))
+	assert.Equal(t, stats.Hits, stats.Misses)
+
+	var buf bytes.Buffer
+	require.NoError(t, Reassemble(&buf, m, s))
+	assert.Equal(t, input, buf.Bytes())
+
+	// Writes go to the underlying store only.
+	require.NoError(t, s.Put([]byte{1}, []byte("foo")))
+	ok, err := s.cache.Has([]byte{1})
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	ok, err = s.Has([]byte{1})
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.Equal(t, countChunks(t, backend), countChunks(t, s))
+
+	require.NoError(t, s.Delete(m.Chunks[0].Hash))
+	_, err = s.Get(m.Chunks[0].Hash)
+	assert.Equal(t, ErrChunkNotFound, err)
+}
+
+func TestDiskCache(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "cache")
+	c, err := NewDiskCache(dir, 1000)
+	require.NoError(t, err)
+	for i := 0; i < 4; i++ {
+		require.NoError(t, c.Put([]byte{byte(i)}, bytes.Repeat([]byte{byte(i)}, 300)))
+	}
+	assert.Equal(t, int64(900), c.Size())
+	ok, err := c.Has([]byte{0})
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	// Reading a chunk keeps it from being evicted next.
+	data, err := c.Get([]byte{1})
+	require.NoError(t, err)
+	assert.Equal(t, bytes.Repeat([]byte{1}, 300), data)
+	require.NoError(t, c.Put([]byte{4}, bytes.Repeat([]byte{4}, 300)))
+	_, err = c.Get([]byte{2})
+	assert.Equal(t, ErrChunkNotFound, err)
+
+	// Chunks larger than the cache are not stored.
+	require.NoError(t, c.Put([]byte{5}, make([]byte, 1001)))
+	ok, err = c.Has([]byte{5})
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	var order []byte
+	require.NoError(t, c.Walk(func(hash []byte, size int64) error {
+		order = append(order, hash[0])
+		return nil
+	}))
+	assert.Equal(t, []byte{4, 1, 3}, order)
+
+	t.Run("reopen", func(t *testing.T) {
+		c, err := NewDiskCache(dir, 600)
+		require.NoError(t, err)
+		assert.Equal(t, int64(600), c.Size())
+		ok, err := c.Has([]byte{3})
+		assert.NoError(t, err)
+		assert.False(t, ok)
+		assert.Equal(t, 2, countChunks(t, c.files))
+
+		require.NoError(t, c.Delete([]byte{4}))
+		assert.Equal(t, ErrChunkNotFound, c.Delete([]byte{4}))
+		assert.Equal(t, int64(300), c.Size())
+	})
+}
@@ -0,0 +1,255 @@
+package ae
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+)
+
+// dagPBCodec is the multicodec code of DAG-PB, the format of UnixFS nodes.
+const dagPBCodec = 0x70
+
+// carV2Pragma introduces every CARv2 file.
+var carV2Pragma = []byte{0x0a, 0xa1, 0x67, 0x76, 0x65, 0x72, 0x73, 0x69, 0x6f, 0x6e, 0x02}
+
+// carV2HeaderSize is the size of the CARv2 header following the pragma.
+const carV2HeaderSize = 40
+
+// CAROptions configure ExportCAR.
+type CAROptions struct {
+	// Options configure the chunking.
+	// The hash selected by HashName defaults to SHA-256 and must have a multihash code, Hasher is not supported.
+	Options
+
+	// Version of the CAR format, 1 or 2 (optional). It defaults to 1.
+	Version int
+}
+
+// carBlock is a block of a CAR file.
+type carBlock struct {
+	cid    []byte
+	digest []byte
+	length int64
+}
+
+// ExportCAR chunks r and writes the chunks as raw IPLD blocks to a CAR (Content Addressable aRchive) file,
+// so that the data can be imported by IPFS tooling as is. It returns the CID of the root,
+// a UnixFS file node linking all chunks in order, which is the first block of the archive.
+//
+// The root of a CAR file must be known up front. If r is an io.Seeker, it is thus read twice,
+// otherwise the chunks are spooled to a temporary file in between.
+// As the root links all chunks directly, files should not exceed about ten thousand chunks
+// to keep the root within the block size limits of IPFS.
+func ExportCAR(w io.Writer, r io.Reader, opts *CAROptions) (string, error) {
+	o := CAROptions{Version: 1}
+	if opts != nil {
+		o = *opts
+	}
+	o.Hasher = nil
+	if o.HashName == "" {
+		o.HashName = "sha256"
+	}
+	if _, ok := multihashCodes[o.HashName]; !ok {
+		return "", fmt.Errorf("ae: no multihash code for hash %q", o.HashName)
+	}
+	if o.Version == 0 {
+		o.Version = 1
+	}
+	if o.Version != 1 && o.Version != 2 {
+		return "", fmt.Errorf("ae: unsupported CAR version %d", o.Version)
+	}
+
+	// the first pass determines the blocks and spools them if necessary
+	var replay io.Reader
+	var start int64
+	seeker, ok := r.(io.Seeker)
+	if ok {
+		pos, err := seeker.Seek(0, io.SeekCurrent)
+		ok = err == nil
+		start = pos
+	}
+	var spool *os.File
+	if !ok {
+		f, err := os.CreateTemp("", "ae-car-*")
+		if err != nil {
+			return "", err
+		}
+		defer os.Remove(f.Name())
+		defer f.Close()
+		spool = f
+		replay = f
+	}
+
+	var blocks []carBlock
+	ch := NewChunker(r, &o.Options)
+	for {
+		c, err := ch.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return "", err
+		}
+		mh, err := c.Multihash()
+		if err != nil {
+			return "", err
+		}
+		blocks = append(blocks, carBlock{cid: cidV1(rawCodec, mh), digest: c.Sum, length: int64(len(c.Data))})
+		if spool != nil {
+			if _, err := spool.Write(c.Data); err != nil {
+				return "", err
+			}
+		}
+		ReleaseChunk(c)
+	}
+	if spool != nil {
+		if _, err := spool.Seek(0, io.SeekStart); err != nil {
+			return "", err
+		}
+	} else {
+		if _, err := seeker.Seek(start, io.SeekStart); err != nil {
+			return "", err
+		}
+		replay = r
+	}
+
+	links := make([]dagLink, len(blocks))
+	for i, b := range blocks {
+		links[i] = dagLink{cid: b.cid, fileSize: uint64(b.length), size: uint64(b.length)}
+	}
+	root := unixFSFileNode(links)
+	hasher, _ := LookupHash(o.HashName)
+	h := hasher()
+	h.Write(root)
+	rootMH, err := multihash(o.HashName, h.Sum(nil))
+	if err != nil {
+		return "", err
+	}
+	rootCID := cidV1(dagPBCodec, rootMH)
+
+	header := carHeader(rootCID)
+	if o.Version == 2 {
+		size := int64(len(header)) + carSectionSize(rootCID, int64(len(root)))
+		for _, b := range blocks {
+			size += carSectionSize(b.cid, b.length)
+		}
+		v2 := make([]byte, 0, len(carV2Pragma)+carV2HeaderSize)
+		v2 = append(v2, carV2Pragma...)
+		v2 = append(v2, make([]byte, carV2HeaderSize)...)
+		fields := v2[len(carV2Pragma)+16:]
+		binary.LittleEndian.PutUint64(fields, uint64(len(carV2Pragma)+carV2HeaderSize))
+		binary.LittleEndian.PutUint64(fields[8:], uint64(size))
+		if _, err := w.Write(v2); err != nil {
+			return "", err
+		}
+	}
+
+	// the second pass writes the archive
+	if _, err := w.Write(header); err != nil {
+		return "", err
+	}
+	if err := writeCARSection(w, rootCID, root); err != nil {
+		return "", err
+	}
+	var data []byte
+	for i, b := range blocks {
+		if int64(cap(data)) < b.length {
+			data = make([]byte, b.length)
+		}
+		data = data[:b.length]
+		if _, err := io.ReadFull(replay, data); err != nil {
+			return "", err
+		}
+		h.Reset()
+		h.Write(data)
+		if !bytes.Equal(h.Sum(nil), b.digest) {
+			return "", fmt.Errorf("ae: input changed while exporting chunk %d", i)
+		}
+		if err := writeCARSection(w, b.cid, data); err != nil {
+			return "", err
+		}
+	}
+	return "b" + cidEncoding.EncodeToString(rootCID), nil
+}
+
+// carHeader returns the CARv1 header with the single root, i.e. the DAG-CBOR map {"roots": [root], "version": 1}
+// prefixed by its length.
+func carHeader(root []byte) []byte {
+	var node []byte
+	node = append(node, 0xa2)                          // map of 2 entries
+	node = append(node, 0x65, 'r', 'o', 'o', 't', 's') // text of 5 bytes
+	node = append(node, 0x81, 0xd8, 0x2a)              // array of 1 entry, tag 42 (CID)
+	node = appendCBORBytesHeader(node, len(root)+1)
+	node = append(node, 0x00) // identity multibase prefix
+	node = append(node, root...)
+	node = append(node, 0x67, 'v', 'e', 'r', 's', 'i', 'o', 'n', 0x01)
+	return append(appendUvarint(nil, uint64(len(node))), node...)
+}
+
+// appendCBORBytesHeader appends the header of a CBOR byte string of length n.
+func appendCBORBytesHeader(b []byte, n int) []byte {
+	switch {
+	case n < 24:
+		return append(b, 0x40|byte(n))
+	case n < 256:
+		return append(b, 0x58, byte(n))
+	default:
+		return append(b, 0x59, byte(n>>8), byte(n))
+	}
+}
+
+// carSectionSize returns the size of a CAR section holding a block of the given length.
+func carSectionSize(cid []byte, length int64) int64 {
+	n := int64(len(cid)) + length
+	return int64(len(appendUvarint(nil, uint64(n)))) + n
+}
+
+// writeCARSection writes a block to a CAR file.
+func writeCARSection(w io.Writer, cid []byte, data []byte) error {
+	prefix := appendUvarint(nil, uint64(len(cid)+len(data)))
+	if _, err := w.Write(append(prefix, cid...)); err != nil {
+		return err
+	}
+	_, err := w.Write(data)
+	return err
+}
+
+// unixFSFileNode returns the DAG-PB encoding of a UnixFS file node with the given links in order.
+func unixFSFileNode(links []dagLink) []byte {
+	var total uint64
+	var data []byte
+	data = appendProtoVarint(data, 1, 2) // Type: File
+	for _, l := range links {
+		total += l.fileSize
+	}
+	data = appendProtoVarint(data, 3, total) // filesize
+	for _, l := range links {
+		data = appendProtoVarint(data, 4, l.fileSize) // blocksizes
+	}
+
+	// links precede the data in the canonical encoding of DAG-PB
+	var node []byte
+	for _, l := range links {
+		var link []byte
+		link = appendProtoBytes(link, 1, l.cid)   // Hash
+		link = appendProtoBytes(link, 2, nil)     // Name
+		link = appendProtoVarint(link, 3, l.size) // Tsize
+		node = appendProtoBytes(node, 2, link)    // Links
+	}
+	return appendProtoBytes(node, 1, data) // Data
+}
+
+// appendProtoVarint appends a protobuf varint field.
+func appendProtoVarint(b []byte, field int, v uint64) []byte {
+	b = appendUvarint(b, uint64(field)<<3)
+	return appendUvarint(b, v)
+}
+
+// appendProtoBytes appends a protobuf length-delimited field.
+func appendProtoBytes(b []byte, field int, v []byte) []byte {
+	b = appendUvarint(b, uint64(field)<<3|2)
+	b = appendUvarint(b, uint64(len(v)))
+	return append(b, v...)
+}
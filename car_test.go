@@ -0,0 +1,134 @@
+package ae
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"github.com/stretchr/testify/assert"
+	"google.golang.org/protobuf/encoding/protowire"
+	"io"
+	"testing"
+)
+
+// carSection is a block read from a CAR file.
+type carSection struct {
+	cid  []byte
+	data []byte
+}
+
+// readCARv1 parses a CARv1 file into its header and blocks, assuming CIDv1 with SHA-256.
+func readCARv1(t *testing.T, r *bufio.Reader) ([]byte, []carSection) {
+	n, err := binary.ReadUvarint(r)
+	assert.NoError(t, err)
+	header := make([]byte, n)
+	_, err = io.ReadFull(r, header)
+	assert.NoError(t, err)
+
+	var sections []carSection
+	for {
+		n, err := binary.ReadUvarint(r)
+		if err == io.EOF {
+			return header, sections
+		}
+		assert.NoError(t, err)
+		section := make([]byte, n)
+		_, err = io.ReadFull(r, section)
+		assert.NoError(t, err)
+		// version, codec, hash code and digest length are single bytes for raw and DAG-PB nodes with SHA-256
+		cidLen := 4 + 32
+		if section[1] != rawCodec {
+			assert.Equal(t, byte(dagPBCodec), section[1])
+		}
+		sections = append(sections, carSection{cid: section[:cidLen], data: section[cidLen:]})
+	}
+}
+
+// unixFSLinks returns the CIDs of the links of a DAG-PB node.
+func unixFSLinks(t *testing.T, node []byte) [][]byte {
+	var links [][]byte
+	for len(node) > 0 {
+		num, typ, n := protowire.ConsumeTag(node)
+		assert.Equal(t, protowire.BytesType, typ)
+		node = node[n:]
+		v, n := protowire.ConsumeBytes(node)
+		node = node[n:]
+		if num == 2 {
+			_, _, n := protowire.ConsumeTag(v)
+			hash, _ := protowire.ConsumeBytes(v[n:])
+			links = append(links, hash)
+		}
+	}
+	return links
+}
+
+func TestExportCAR(t *testing.T) {
+	input := testFile[:2*MiB]
+	opts := &CAROptions{Options: Options{AverageSize: 64 * 1024}}
+
+	var buf bytes.Buffer
+	root, err := ExportCAR(&buf, bytes.NewReader(input), opts)
+	assert.NoError(t, err)
+	assert.Equal(t, byte('b'), root[0])
+
+	header, sections := readCARv1(t, bufio.NewReader(bytes.NewReader(buf.Bytes())))
+	rootCID, err := cidEncoding.DecodeString(root[1:])
+	assert.NoError(t, err)
+	assert.True(t, bytes.Contains(header, rootCID))
+	assert.True(t, bytes.HasSuffix(header, []byte("version\x01")))
+
+	assert.Equal(t, rootCID, sections[0].cid)
+	sum := sha256.Sum256(sections[0].data)
+	assert.Equal(t, sum[:], rootCID[4:])
+	links := unixFSLinks(t, sections[0].data)
+	assert.Len(t, links, len(sections)-1)
+
+	var data []byte
+	for i, s := range sections[1:] {
+		sum := sha256.Sum256(s.data)
+		assert.Equal(t, sum[:], s.cid[4:])
+		assert.Equal(t, links[i], s.cid)
+		data = append(data, s.data...)
+	}
+	assert.Equal(t, input, data)
+
+	t.Run("not seekable", func(t *testing.T) {
+		var spooled bytes.Buffer
+		root2, err := ExportCAR(&spooled, bufio.NewReader(bytes.NewReader(input)), opts)
+		assert.NoError(t, err)
+		assert.Equal(t, root, root2)
+		assert.Equal(t, buf.Bytes(), spooled.Bytes())
+	})
+
+	t.Run("CARv2", func(t *testing.T) {
+		var v2 bytes.Buffer
+		root2, err := ExportCAR(&v2, bytes.NewReader(input), &CAROptions{Options: opts.Options, Version: 2})
+		assert.NoError(t, err)
+		assert.Equal(t, root, root2)
+
+		b := v2.Bytes()
+		assert.Equal(t, carV2Pragma, b[:len(carV2Pragma)])
+		fields := b[len(carV2Pragma)+16:]
+		offset := binary.LittleEndian.Uint64(fields)
+		size := binary.LittleEndian.Uint64(fields[8:])
+		assert.Equal(t, uint64(51), offset)
+		assert.Equal(t, uint64(buf.Len()), size)
+		assert.Zero(t, binary.LittleEndian.Uint64(fields[16:]))
+		assert.Equal(t, buf.Bytes(), b[offset:])
+	})
+
+	t.Run("empty input", func(t *testing.T) {
+		var empty bytes.Buffer
+		_, err := ExportCAR(&empty, bytes.NewReader(nil), nil)
+		assert.NoError(t, err)
+		_, sections := readCARv1(t, bufio.NewReader(&empty))
+		assert.Len(t, sections, 1)
+	})
+
+	t.Run("invalid options", func(t *testing.T) {
+		_, err := ExportCAR(io.Discard, bytes.NewReader(input), &CAROptions{Options: Options{HashName: "sha512/256"}})
+		assert.Error(t, err)
+		_, err = ExportCAR(io.Discard, bytes.NewReader(input), &CAROptions{Version: 3})
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,235 @@
+package ae
+
+import (
+	"bufio"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"github.com/klauspost/compress/zstd"
+	"io"
+	"io/fs"
+	"math"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Constants of the casync index format.
+const (
+	caFormatIndex           = 0x96824d9c7b129ff9
+	caFormatTable           = 0xe75b9e112f17417d
+	caFormatTableTailMarker = 0x4b4f050e5549ecd1
+
+	// caFormatSHA512256 is the feature flag selecting SHA-512/256 instead of SHA-256 for the chunk IDs.
+	caFormatSHA512256 = 0x2000000000000000
+
+	// caIndexHeaderSize is the size of the index header, which the table follows.
+	caIndexHeaderSize = 48
+
+	// caTableItemSize is the size of a table item and of the table tail.
+	caTableItemSize = 40
+
+	// casyncChunkExt is the extension of the compressed chunk files of casync and desync stores.
+	casyncChunkExt = ".cacnk"
+)
+
+// ErrInvalidCaibx is returned when reading a malformed casync index.
+var ErrInvalidCaibx = errors.New("ae: invalid casync index")
+
+// WriteCaibx writes the chunks of m as a casync blob index (.caibx), as used by casync and desync.
+// The chunk IDs of casync are SHA-512/256 or SHA-256 digests, so m must have been built with either hash.
+func WriteCaibx(w io.Writer, m *Manifest) error {
+	var flags uint64
+	switch m.Parameters.Hash {
+	case "sha512/256":
+		flags = caFormatSHA512256
+	case "sha256":
+	default:
+		return fmt.Errorf("ae: casync index requires hash sha512/256 or sha256, got %q", m.Parameters.Hash)
+	}
+	p := newParams(m.Parameters.Options())
+
+	bw := bufio.NewWriter(w)
+	buf := make([]byte, caIndexHeaderSize)
+	for i, v := range []uint64{caIndexHeaderSize, caFormatIndex, flags, uint64(p.minSize), uint64(p.avgSize), uint64(p.maxSize)} {
+		binary.LittleEndian.PutUint64(buf[8*i:], v)
+	}
+	bw.Write(buf)
+	binary.LittleEndian.PutUint64(buf[0:], math.MaxUint64)
+	binary.LittleEndian.PutUint64(buf[8:], caFormatTable)
+	bw.Write(buf[:16])
+
+	item := make([]byte, caTableItemSize)
+	for i, ref := range m.Chunks {
+		if len(ref.Hash) != 32 {
+			return fmt.Errorf("ae: digest of chunk %d must be 32 bytes, got %d", i, len(ref.Hash))
+		}
+		binary.LittleEndian.PutUint64(item, uint64(ref.Offset+ref.Length))
+		copy(item[8:], ref.Hash)
+		bw.Write(item)
+	}
+	tableSize := 16 + caTableItemSize*uint64(len(m.Chunks)) + caTableItemSize
+	for i, v := range []uint64{0, 0, caIndexHeaderSize, tableSize, caFormatTableTailMarker} {
+		binary.LittleEndian.PutUint64(item[8*i:], v)
+	}
+	bw.Write(item)
+	return bw.Flush()
+}
+
+// ReadCaibx reads a casync blob index (.caibx) written by WriteCaibx, casync or desync.
+// The parameters of the returned manifest record the sizes from the index and the hash of the chunk IDs.
+// Unless the index was written by WriteCaibx, they do not reproduce the chunks, as casync chunks differently.
+func ReadCaibx(r io.Reader) (*Manifest, error) {
+	br := bufio.NewReader(r)
+	buf := make([]byte, caIndexHeaderSize)
+	if _, err := io.ReadFull(br, buf); err != nil {
+		return nil, ErrInvalidCaibx
+	}
+	var header [6]uint64
+	for i := range header {
+		header[i] = binary.LittleEndian.Uint64(buf[8*i:])
+	}
+	if header[0] != caIndexHeaderSize || header[1] != caFormatIndex {
+		return nil, ErrInvalidCaibx
+	}
+	if header[4] > math.MaxInt64 || header[5] > math.MaxInt64 {
+		return nil, ErrInvalidCaibx
+	}
+	m := &Manifest{Parameters: Parameters{AverageSize: int64(header[4]), MaxSize: int64(header[5]), Hash: "sha256"}}
+	if header[2]&caFormatSHA512256 != 0 {
+		m.Parameters.Hash = "sha512/256"
+	}
+
+	if _, err := io.ReadFull(br, buf[:16]); err != nil {
+		return nil, ErrInvalidCaibx
+	}
+	if binary.LittleEndian.Uint64(buf) != math.MaxUint64 || binary.LittleEndian.Uint64(buf[8:]) != caFormatTable {
+		return nil, ErrInvalidCaibx
+	}
+	item := make([]byte, caTableItemSize)
+	for {
+		if _, err := io.ReadFull(br, item); err != nil {
+			return nil, ErrInvalidCaibx
+		}
+		end := binary.LittleEndian.Uint64(item)
+		if end == 0 {
+			break
+		}
+		if end <= uint64(m.Size) || end > math.MaxInt64 {
+			return nil, ErrInvalidCaibx
+		}
+		m.Chunks = append(m.Chunks, ChunkRef{
+			Offset: m.Size,
+			Length: int64(end) - m.Size,
+			Hash:   append([]byte(nil), item[8:]...),
+		})
+		m.Size = int64(end)
+	}
+	if binary.LittleEndian.Uint64(item[32:]) != caFormatTableTailMarker {
+		return nil, ErrInvalidCaibx
+	}
+	return m, nil
+}
+
+// CasyncStore is a ChunkStore in the layout of casync and desync stores, so that chunks produced by this package
+// can populate them, and vice versa. Each chunk is kept compressed with zstd in a file named by its hex encoded ID
+// with extension .cacnk, in a directory named by the first four hex digits.
+type CasyncStore struct {
+	dir     string
+	encoder *zstd.Encoder
+	decoder *zstd.Decoder
+}
+
+// NewCasyncStore returns a CasyncStore rooted at dir, which is created if necessary.
+func NewCasyncStore(dir string) (*CasyncStore, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	encoder, err := zstd.NewWriter(nil)
+	if err != nil {
+		return nil, err
+	}
+	decoder, err := zstd.NewReader(nil)
+	if err != nil {
+		return nil, err
+	}
+	return &CasyncStore{dir: dir, encoder: encoder, decoder: decoder}, nil
+}
+
+// path returns the file path of the chunk with the given hash.
+func (s *CasyncStore) path(hash []byte) string {
+	name := hex.EncodeToString(hash)
+	if len(name) < 4 {
+		return filepath.Join(s.dir, name+casyncChunkExt)
+	}
+	return filepath.Join(s.dir, name[:4], name+casyncChunkExt)
+}
+
+// Put compresses data and stores it under hash.
+func (s *CasyncStore) Put(hash []byte, data []byte) error {
+	p := s.path(hash)
+	if _, err := os.Stat(p); err == nil {
+		return nil
+	}
+	return writeFileAtomic(p, s.encoder.EncodeAll(data, nil), SyncNone)
+}
+
+// Get returns the decompressed data stored under hash.
+func (s *CasyncStore) Get(hash []byte) ([]byte, error) {
+	stored, err := os.ReadFile(s.path(hash))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrChunkNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	data, err := s.decoder.DecodeAll(stored, nil)
+	if err != nil {
+		return nil, ErrCorruptChunk
+	}
+	return data, nil
+}
+
+// Has reports whether a chunk is stored under hash.
+func (s *CasyncStore) Has(hash []byte) (bool, error) {
+	_, err := os.Stat(s.path(hash))
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Delete removes the chunk stored under hash.
+func (s *CasyncStore) Delete(hash []byte) error {
+	err := os.Remove(s.path(hash))
+	if errors.Is(err, fs.ErrNotExist) {
+		return ErrChunkNotFound
+	}
+	return err
+}
+
+// Walk calls fn for every chunk in the store with its compressed size.
+func (s *CasyncStore) Walk(fn func(hash []byte, size int64) error) error {
+	return filepath.WalkDir(s.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() || !strings.HasSuffix(d.Name(), casyncChunkExt) {
+			return nil
+		}
+		hash, err := hex.DecodeString(strings.TrimSuffix(d.Name(), casyncChunkExt))
+		if err != nil || s.path(hash) != path {
+			// temporary or foreign file
+			return nil
+		}
+		info, err := d.Info()
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return fn(hash, info.Size())
+	})
+}
@@ -0,0 +1,94 @@
+package ae
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestCaibx(t *testing.T) {
+	input := testFile[:2*MiB]
+	m, err := BuildManifest(bytes.NewReader(input), &Options{AverageSize: 64 * 1024, HashName: "sha512/256"})
+	assert.NoError(t, err)
+
+	var buf bytes.Buffer
+	assert.NoError(t, WriteCaibx(&buf, m))
+	data := buf.Bytes()
+	assert.Len(t, data, caIndexHeaderSize+16+caTableItemSize*(len(m.Chunks)+1))
+	p := newParams(m.Parameters.Options())
+	for i, v := range []uint64{48, caFormatIndex, caFormatSHA512256, uint64(p.minSize), 64 * 1024, 128 * 1024} {
+		assert.Equal(t, v, binary.LittleEndian.Uint64(data[8*i:]), "header field %d", i)
+	}
+	tail := data[len(data)-caTableItemSize:]
+	assert.Equal(t, uint64(48), binary.LittleEndian.Uint64(tail[16:]))
+	assert.Equal(t, uint64(len(data)-caIndexHeaderSize), binary.LittleEndian.Uint64(tail[24:]))
+
+	read, err := ReadCaibx(bytes.NewReader(data))
+	assert.NoError(t, err)
+	assert.Equal(t, m, read)
+
+	t.Run("sha256", func(t *testing.T) {
+		m, err := BuildManifest(bytes.NewReader(input), &Options{AverageSize: 64 * 1024})
+		assert.NoError(t, err)
+		var buf bytes.Buffer
+		assert.NoError(t, WriteCaibx(&buf, m))
+		read, err := ReadCaibx(&buf)
+		assert.NoError(t, err)
+		assert.Equal(t, m, read)
+	})
+
+	t.Run("unsupported hash", func(t *testing.T) {
+		m, err := BuildManifest(bytes.NewReader(input), &Options{AverageSize: 64 * 1024, HashName: "sha1"})
+		assert.NoError(t, err)
+		assert.Error(t, WriteCaibx(&bytes.Buffer{}, m))
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		for name, data := range map[string][]byte{
+			"empty":     nil,
+			"truncated": data[:len(data)-1],
+			"header":    append([]byte{47}, data[1:]...),
+			"no tail":   data[:len(data)-caTableItemSize],
+		} {
+			_, err := ReadCaibx(bytes.NewReader(data))
+			assert.Equal(t, ErrInvalidCaibx, err, name)
+		}
+	})
+}
+
+func TestCasyncStore(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewCasyncStore(dir)
+	assert.NoError(t, err)
+	input := testFile[:MiB]
+	m, err := StoreFile(bytes.NewReader(input), s, &Options{AverageSize: 64 * 1024, HashName: "sha512/256"})
+	assert.NoError(t, err)
+
+	id := hex.EncodeToString(m.Chunks[0].Hash)
+	_, err = os.Stat(filepath.Join(dir, id[:4], id+".cacnk"))
+	assert.NoError(t, err)
+
+	var buf bytes.Buffer
+	assert.NoError(t, Reassemble(&buf, m, s))
+	assert.Equal(t, input, buf.Bytes())
+
+	n := 0
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "foreign.txt"), nil, 0o644))
+	assert.NoError(t, s.Walk(func(hash []byte, size int64) error {
+		n++
+		return nil
+	}))
+	assert.Equal(t, len(m.Chunks), n)
+
+	ok, err := s.Has(m.Chunks[0].Hash)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	assert.NoError(t, s.Delete(m.Chunks[0].Hash))
+	_, err = s.Get(m.Chunks[0].Hash)
+	assert.Equal(t, ErrChunkNotFound, err)
+	assert.Equal(t, ErrChunkNotFound, s.Delete(m.Chunks[0].Hash))
+}
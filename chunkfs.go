@@ -0,0 +1,57 @@
+package ae
+
+import (
+	"context"
+	"io/fs"
+)
+
+// ChunkFS chunks every regular file in fsys like ChunkFiles and returns their manifests by path.
+// Files are chunked concurrently by GOMAXPROCS goroutines. If visit is not nil, it is called for every chunk;
+// the chunks of a file are visited in order, but different files are visited concurrently,
+// so visit must be safe for concurrent use. The data of the chunk must not be retained after visit returns.
+// The hash defaults to SHA-256 as with BuildManifest. ChunkFS stops at the first error.
+func ChunkFS(fsys fs.FS, opts *Options, visit func(path string, c Chunk) error) (map[string]*Manifest, error) {
+	o, err := manifestOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	err = fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.Type().IsRegular() {
+			paths = append(paths, path)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	pool := NewChunkerPool(o)
+	ch := pool.Get(nil)
+	params := ch.parameters()
+	pool.Put(ch)
+	// Every file is chunked by a single goroutine, which is the only one to append to its manifest.
+	manifests := make(map[string]*Manifest, len(paths))
+	for _, path := range paths {
+		manifests[path] = &Manifest{Parameters: params}
+	}
+	err = chunkFiles(context.Background(), paths, pool, 0, fsys.Open, func(path string, c Chunk) error {
+		if visit != nil {
+			if err := visit(path, c); err != nil {
+				return err
+			}
+		}
+		m := manifests[path]
+		m.Chunks = append(m.Chunks, ChunkRef{Offset: c.Offset, Length: int64(len(c.Data)), Hash: c.Sum})
+		m.Size += int64(len(c.Data))
+		ReleaseChunk(&c)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return manifests, nil
+}
@@ -0,0 +1,59 @@
+package ae
+
+import (
+	"bytes"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io/fs"
+	"sync"
+	"testing"
+	"testing/fstest"
+)
+
+func TestChunkFS(t *testing.T) {
+	opts := &Options{AverageSize: 16 * 1024}
+	fsys := fstest.MapFS{
+		"a":         {Data: testFile[:100*1024]},
+		"dir/b":     {Data: testFile[MiB : MiB+300*1024]},
+		"dir/empty": {Data: nil},
+		"dir/sub":   {Mode: fs.ModeDir | 0o755},
+	}
+
+	var mu sync.Mutex
+	visited := make(map[string][]byte)
+	manifests, err := ChunkFS(fsys, opts, func(path string, c Chunk) error {
+		mu.Lock()
+		defer mu.Unlock()
+		assert.Equal(t, int64(len(visited[path])), c.Offset)
+		visited[path] = append(visited[path], c.Data...)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Len(t, manifests, 3)
+	for path, file := range fsys {
+		if file.Mode.IsDir() {
+			continue
+		}
+		want, err := BuildManifest(bytes.NewReader(file.Data), opts)
+		assert.NoError(t, err)
+		assert.Equal(t, want, manifests[path], path)
+		assert.Equal(t, string(file.Data), string(visited[path]), path)
+	}
+
+	t.Run("without visit", func(t *testing.T) {
+		got, err := ChunkFS(fsys, opts, nil)
+		assert.NoError(t, err)
+		assert.Equal(t, manifests, got)
+	})
+
+	t.Run("visit fails", func(t *testing.T) {
+		errVisit := errors.New("visit failed")
+		_, err := ChunkFS(fsys, opts, func(path string, c Chunk) error {
+			if path == "dir/b" {
+				return errVisit
+			}
+			return nil
+		})
+		assert.Equal(t, errVisit, err)
+	})
+}
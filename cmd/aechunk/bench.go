@@ -0,0 +1,130 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	ae "github.com/mg98/ae-chunker-go"
+	"github.com/mg98/ae-chunker-go/corpus"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+)
+
+func init() {
+	commands["bench"] = command{usage: "bench [flags] [file...]", run: runBench}
+}
+
+// algorithm is a chunking algorithm compared by bench.
+type algorithm struct {
+	name string
+
+	// configure adapts the options shared by all algorithms.
+	configure func(o *ae.Options)
+}
+
+// algorithms are the chunking algorithms compared by bench.
+var algorithms = []algorithm{
+	{"ae-max", func(o *ae.Options) { o.Mode = ae.MAX }},
+	{"ae-min", func(o *ae.Options) { o.Mode = ae.MIN }},
+	{"fixed", func(o *ae.Options) { o.Algorithm = ae.Fixed }},
+}
+
+// benchResult is the outcome of running an algorithm over an input.
+type benchResult struct {
+	elapsed time.Duration
+	stats   sizeStats
+}
+
+// bench chunks data with opts runs times and returns the fastest run.
+func bench(data []byte, opts *ae.Options, runs int) (benchResult, error) {
+	var best benchResult
+	for run := 0; run < runs; run++ {
+		var sizes []int64
+		start := time.Now()
+		ch := ae.NewChunker(bytes.NewReader(data), opts)
+		for {
+			c, err := ch.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return benchResult{}, err
+			}
+			sizes = append(sizes, int64(len(c.Data)))
+			ae.ReleaseChunk(c)
+		}
+		elapsed := time.Since(start)
+		if run == 0 || elapsed < best.elapsed {
+			best = benchResult{elapsed: elapsed, stats: newSizeStats(sizes)}
+		}
+	}
+	return best, nil
+}
+
+// runBench compares the throughput and chunk sizes of the algorithms on files or synthetic corpora.
+func runBench(e *env, args []string) error {
+	fs := newFlagSet(e, "bench", "aechunk bench [flags] [file...]")
+	avg := sizeValue(256 * 1024)
+	var max, size sizeValue
+	size = 64 << 20
+	fs.Var(&avg, "avg", "average chunk size")
+	fs.Var(&max, "max", "maximum chunk size (default twice the average)")
+	hash := fs.String("hash", "", "hash to fingerprint chunks with (default none)")
+	kinds := fs.String("corpus", "random,text,zeros", "comma-separated synthetic corpora to run if no file is given ("+strings.Join(corpus.Kinds(), ", ")+")")
+	fs.Var(&size, "size", "size of the synthetic corpora")
+	seed := fs.Int64("seed", 1, "seed of the synthetic corpora")
+	runs := fs.Int("runs", 3, "number of runs per algorithm, of which the fastest is reported")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *runs < 1 {
+		fs.Usage()
+		return errUsage
+	}
+
+	// Inputs are held in memory, so that the throughput of the algorithms is not bounded by I/O.
+	type input struct {
+		name string
+		data []byte
+	}
+	var inputs []input
+	for _, name := range fs.Args() {
+		data, err := os.ReadFile(name)
+		if err != nil {
+			return err
+		}
+		inputs = append(inputs, input{name, data})
+	}
+	if len(inputs) == 0 {
+		for _, kind := range strings.Split(*kinds, ",") {
+			data, err := corpus.Generate(kind, *seed, int(size))
+			if err != nil {
+				return err
+			}
+			inputs = append(inputs, input{kind, data})
+		}
+	}
+
+	w := tabwriter.NewWriter(e.stdout, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "INPUT\tALGORITHM\tMB/S\tCHUNKS\tAVG\tSTDDEV\tMIN\tMAX\t")
+	for _, in := range inputs {
+		for _, alg := range algorithms {
+			opts := &ae.Options{AverageSize: int64(avg), MaxSize: int64(max), HashName: *hash}
+			alg.configure(opts)
+			res, err := bench(in.data, opts, *runs)
+			if err != nil {
+				return err
+			}
+			s := res.stats
+			var lo, hi int64
+			if len(s.sizes) > 0 {
+				lo, hi = s.sizes[0], s.sizes[len(s.sizes)-1]
+			}
+			fmt.Fprintf(w, "%s\t%s\t%.1f\t%d\t%.0f\t%.0f\t%d\t%d\t\n", in.name, alg.name,
+				float64(len(in.data))/1e6/res.elapsed.Seconds(), len(s.sizes), s.mean, s.stddev, lo, hi)
+		}
+	}
+	return w.Flush()
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestRun_bench(t *testing.T) {
+	out, err := runTest(t, nil, "bench", "-avg", "8KiB", "-size", "1MiB", "-runs", "1")
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(out), "\n")
+	assert.Equal(t, []string{"INPUT", "ALGORITHM", "MB/S", "CHUNKS", "AVG", "STDDEV", "MIN", "MAX"}, strings.Fields(lines[0]))
+	assert.Len(t, lines, 1+3*len(algorithms))
+	for _, line := range lines[1:] {
+		fields := strings.Fields(line)
+		assert.Len(t, fields, 8)
+		assert.Contains(t, []string{"random", "text", "zeros"}, fields[0])
+	}
+
+	path, _ := randomFile(t, 1<<20, 1)
+	out, err = runTest(t, nil, "bench", "-avg", "8KiB", "-runs", "2", "-hash", "sha256", path)
+	assert.NoError(t, err)
+	lines = strings.Split(strings.TrimSpace(out), "\n")
+	assert.Len(t, lines, 1+len(algorithms))
+	assert.Equal(t, path, strings.Fields(lines[1])[0])
+
+	_, err = runTest(t, nil, "bench", "-corpus", "random,noise", "-size", "1KiB")
+	assert.EqualError(t, err, `corpus: unknown kind "noise"`)
+	_, err = runTest(t, nil, "bench", "-runs", "0")
+	assert.Equal(t, errUsage, err)
+}
@@ -0,0 +1,40 @@
+package main
+
+import (
+	ae "github.com/mg98/ae-chunker-go"
+)
+
+func init() {
+	commands["boundaries"] = command{usage: "boundaries [flags] [file]", run: runBoundaries}
+}
+
+// runBoundaries writes the chunk boundaries of a file in a machine-readable format.
+func runBoundaries(e *env, args []string) error {
+	var cf chunkFlags
+	fs := newFlagSet(e, "boundaries", "aechunk boundaries [flags] [file]")
+	cf.register(fs)
+	var format formatValue
+	fs.Var(&format, "format", "output format, csv, json or ndjson (default csv)")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 {
+		fs.Usage()
+		return errUsage
+	}
+	name := "-"
+	if fs.NArg() == 1 {
+		name = fs.Arg(0)
+	}
+
+	f, err := openInput(e, name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	bw := ae.NewBoundaryWriter(e.stdout, ae.BoundaryFormat(format))
+	if _, err := ae.CopyChunks(bw, ae.NewChunker(f, cf.options())); err != nil {
+		return err
+	}
+	return bw.Close()
+}
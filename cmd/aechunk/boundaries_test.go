@@ -0,0 +1,43 @@
+package main
+
+import (
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestRun_boundaries(t *testing.T) {
+	path, data := randomFile(t, 1<<20, 1)
+
+	chunks, err := runTest(t, nil, "-avg", "32KiB", path)
+	assert.NoError(t, err)
+	rows := strings.Split(strings.TrimSpace(chunks), "\n")[1:]
+
+	out, err := runTest(t, nil, "boundaries", "--format=json", "-avg", "32KiB", path)
+	assert.NoError(t, err)
+	var boundaries []struct {
+		Offset, Length int64
+		Hash           string
+	}
+	assert.NoError(t, json.Unmarshal([]byte(out), &boundaries))
+	assert.Len(t, boundaries, len(rows))
+	for i, row := range rows {
+		fields := strings.Fields(row)
+		assert.Equal(t, fields[2], boundaries[i].Hash)
+	}
+
+	csv, err := runTest(t, data, "boundaries", "-avg", "32KiB")
+	assert.NoError(t, err)
+	lines := strings.Split(strings.TrimSpace(csv), "\n")
+	assert.Equal(t, "offset,length,hash", lines[0])
+	assert.Len(t, lines, len(rows)+1)
+	assert.Equal(t, strings.Join(strings.Fields(rows[0]), ","), lines[1])
+
+	ndjson, err := runTest(t, nil, "boundaries", "-format", "ndjson", "-avg", "32KiB", path)
+	assert.NoError(t, err)
+	assert.Len(t, strings.Split(strings.TrimSpace(ndjson), "\n"), len(rows))
+
+	_, err = runTest(t, nil, "boundaries", "-format", "xml", path)
+	assert.Equal(t, errUsage, err)
+}
@@ -0,0 +1,99 @@
+package main
+
+import (
+	"fmt"
+	ae "github.com/mg98/ae-chunker-go"
+	"github.com/mg98/ae-chunker-go/storeurl"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+func init() {
+	commands["cp"] = command{usage: "cp [flags] -repo dir src dst", run: runCp}
+}
+
+// openStore opens the chunk store at location, a directory or a URL of a remote store as understood by storeurl.Open.
+// A directory must exist already unless create is set.
+func openStore(location string, create bool) (ae.ChunkStore, error) {
+	if !create && !strings.Contains(location, "://") {
+		if _, err := os.Stat(location); err != nil {
+			return nil, err
+		}
+	}
+	return storeurl.Open(location)
+}
+
+// newChunkStore is a ChunkStore counting the chunks that are not stored yet when they are put.
+type newChunkStore struct {
+	ae.ChunkStore
+
+	// chunks and bytes count the chunks put that were new to the store.
+	chunks int64
+	bytes  int64
+}
+
+// Put stores data under hash unless a chunk is stored under hash already.
+func (s *newChunkStore) Put(hash []byte, data []byte) error {
+	ok, err := s.Has(hash)
+	if err != nil || ok {
+		return err
+	}
+	if err := s.ChunkStore.Put(hash, data); err != nil {
+		return err
+	}
+	s.chunks++
+	s.bytes += int64(len(data))
+	return nil
+}
+
+// runCp copies a file into a repository of chunks, storing only the chunks not in the repository yet,
+// and writes the manifest of the copy to the destination.
+func runCp(e *env, args []string) error {
+	var cf chunkFlags
+	fs := newFlagSet(e, "cp", "aechunk cp [flags] -repo dir src dst")
+	cf.register(fs)
+	repo := fs.String("repo", "", "directory of the repository holding the chunks, or the URL of a remote store such as s3://bucket/prefix")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *repo == "" || fs.NArg() != 2 {
+		fs.Usage()
+		return errUsage
+	}
+	src, dst := fs.Arg(0), fs.Arg(1)
+	if info, err := os.Stat(dst); err == nil && info.IsDir() {
+		base := "stdin"
+		if src != "-" {
+			base = filepath.Base(src)
+		}
+		dst = filepath.Join(dst, base+manifestSuffix)
+	}
+
+	store, err := openStore(*repo, true)
+	if err != nil {
+		return err
+	}
+	s := &newChunkStore{ChunkStore: store}
+	f, err := openInput(e, src)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	var m *ae.Manifest
+	if file, ok := f.(*os.File); ok {
+		// Holes of sparse files are skipped rather than read.
+		m, err = ae.ChunkFile(file, s, cf.options())
+	} else {
+		m, err = ae.StoreFile(f, s, cf.options())
+	}
+	if err != nil {
+		return err
+	}
+	if err := writeManifest(dst, m); err != nil {
+		return err
+	}
+	fmt.Fprintf(e.stderr, "%d of %d chunks (%d of %d bytes) were new to %s, manifest written to %s\n",
+		s.chunks, len(m.Chunks), s.bytes, m.Size, *repo, dst)
+	return nil
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	ae "github.com/mg98/ae-chunker-go"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRun_cp(t *testing.T) {
+	path, data := randomFile(t, 1<<20, 1)
+	repo := filepath.Join(t.TempDir(), "repo")
+	dst := t.TempDir()
+
+	countChunks := func() int {
+		s, err := ae.NewFileStore(repo, nil)
+		assert.NoError(t, err)
+		var n int
+		assert.NoError(t, s.Walk(func(hash []byte, size int64) error {
+			n++
+			return nil
+		}))
+		return n
+	}
+
+	_, err := runTest(t, nil, "cp", "-repo", repo, "-avg", "64KiB", path, dst)
+	assert.NoError(t, err)
+	m, err := readManifest(filepath.Join(dst, filepath.Base(path)+manifestSuffix))
+	assert.NoError(t, err)
+	stored := countChunks()
+	assert.Equal(t, len(m.Chunks), stored)
+
+	// A copy with data appended only adds the chunks at its end.
+	appended := append(append([]byte(nil), data...), data[:1000]...)
+	manifest := filepath.Join(dst, "appended")
+	_, err = runTest(t, appended, "cp", "-repo", repo, "-avg", "64KiB", "-", manifest)
+	assert.NoError(t, err)
+	assert.LessOrEqual(t, countChunks(), stored+2)
+
+	out, err := runTest(t, nil, "join", "-chunks", repo, manifest)
+	assert.NoError(t, err)
+	assert.Equal(t, appended, []byte(out))
+
+	t.Run("url", func(t *testing.T) {
+		_, err := runTest(t, data, "cp", "-repo", "file://"+filepath.ToSlash(repo), "-avg", "64KiB", "-", manifest)
+		assert.NoError(t, err)
+		assert.LessOrEqual(t, countChunks(), stored+2)
+		out, err := runTest(t, nil, "join", "-chunks", "file://"+filepath.ToSlash(repo), manifest)
+		assert.NoError(t, err)
+		assert.Equal(t, data, []byte(out))
+	})
+
+	t.Run("usage", func(t *testing.T) {
+		_, err := runTest(t, nil, "cp", path, dst)
+		assert.ErrorIs(t, err, errUsage)
+		_, err = runTest(t, nil, "cp", "-repo", repo, path)
+		assert.ErrorIs(t, err, errUsage)
+	})
+
+	t.Run("missing source", func(t *testing.T) {
+		_, err := runTest(t, nil, "cp", "-repo", repo, filepath.Join(t.TempDir(), "missing"), dst)
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+}
@@ -0,0 +1,198 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	ae "github.com/mg98/ae-chunker-go"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"text/tabwriter"
+)
+
+func init() {
+	commands["dedup"] = command{usage: "dedup [flags] dir...", run: runDedup}
+}
+
+// lazyFile is an io.Reader that opens a file on the first read and closes it at the end,
+// so that many files can be handed to a function at once without exhausting file descriptors.
+type lazyFile struct {
+	name string
+	f    *os.File
+	done bool
+}
+
+func (l *lazyFile) Read(p []byte) (int, error) {
+	if l.done {
+		return 0, io.EOF
+	}
+	if l.f == nil {
+		f, err := os.Open(l.name)
+		if err != nil {
+			return 0, err
+		}
+		l.f = f
+	}
+	n, err := l.f.Read(p)
+	if err == io.EOF {
+		l.done = true
+		l.f.Close()
+	}
+	return n, err
+}
+
+// walkFiles returns the regular files in the directory trees rooted at dirs.
+func walkFiles(dirs []string) ([]string, error) {
+	var files []string
+	for _, dir := range dirs {
+		err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if d.Type().IsRegular() {
+				files = append(files, path)
+			}
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// duplicate is a chunk occurring more than once.
+type duplicate struct {
+	hash  []byte
+	entry ae.DedupEntry
+}
+
+// saved returns the number of bytes saved by storing the chunk only once.
+func (d duplicate) saved() int64 {
+	return int64(d.entry.RefCount-1) * d.entry.Length
+}
+
+// runDedup reports how well the files in the given directories deduplicate.
+func runDedup(e *env, args []string) error {
+	var cf chunkFlags
+	fs := newFlagSet(e, "dedup", "aechunk dedup [flags] dir...")
+	cf.register(fs)
+	top := fs.Int("top", 10, "number of most duplicated chunks to list")
+	sizes := sizeListValue{4 << 10, 16 << 10, 64 << 10, 256 << 10, 1 << 20}
+	fs.Var(&sizes, "sizes", "comma-separated candidate average sizes to estimate the savings for")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() == 0 {
+		fs.Usage()
+		return errUsage
+	}
+	files, err := walkFiles(fs.Args())
+	if err != nil {
+		return err
+	}
+
+	index := ae.NewDedupIndex()
+	var hashes [][]byte
+	for _, name := range files {
+		f, err := os.Open(name)
+		if err != nil {
+			return err
+		}
+		m, err := ae.BuildManifest(f, cf.options())
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+		for _, ref := range m.Chunks {
+			if !index.Add(ref.Hash, ref.Length, ae.Location{File: name, Offset: ref.Offset}) {
+				hashes = append(hashes, ref.Hash)
+			}
+		}
+	}
+
+	var dups []duplicate
+	var sharedBytes int64
+	for _, h := range hashes {
+		entry, _ := index.Lookup(h)
+		if entry.RefCount < 2 {
+			continue
+		}
+		dups = append(dups, duplicate{hash: h, entry: entry})
+		for _, loc := range entry.Locations[1:] {
+			if loc.File != entry.Locations[0].File {
+				sharedBytes += entry.Length
+				break
+			}
+		}
+	}
+	sort.Slice(dups, func(i, j int) bool {
+		if dups[i].saved() != dups[j].saved() {
+			return dups[i].saved() > dups[j].saved()
+		}
+		return string(dups[i].hash) < string(dups[j].hash)
+	})
+
+	stats := index.Stats()
+	w := tabwriter.NewWriter(e.stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(w, "files:\t%d\n", len(files))
+	fmt.Fprintf(w, "chunks:\t%d (%d unique)\n", stats.Chunks, stats.UniqueChunks)
+	fmt.Fprintf(w, "bytes:\t%d (%d unique)\n", stats.Bytes, stats.UniqueBytes)
+	fmt.Fprintf(w, "shared bytes:\t%d in chunks occurring in more than one file\n", sharedBytes)
+	fmt.Fprintf(w, "dedup ratio:\t%.4f\n", stats.DedupRatio())
+	fmt.Fprintf(w, "savings:\t%d bytes (%.2f%%)\n", stats.Bytes-stats.UniqueBytes, savedPercent(stats))
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if len(dups) > *top {
+		dups = dups[:*top]
+	}
+	if len(dups) > 0 {
+		fmt.Fprintln(e.stdout)
+		w = tabwriter.NewWriter(e.stdout, 0, 8, 2, ' ', 0)
+		fmt.Fprintln(w, "HASH\tSIZE\tREFS\tSAVED\tFIRST SEEN")
+		for _, d := range dups {
+			first := d.entry.Locations[0]
+			fmt.Fprintf(w, "%s\t%d\t%d\t%d\t%s@%d\n",
+				hex.EncodeToString(d.hash)[:16], d.entry.Length, d.entry.RefCount, d.saved(), first.File, first.Offset)
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+
+	if len(sizes) > 0 {
+		fmt.Fprintln(e.stdout)
+		w = tabwriter.NewWriter(e.stdout, 0, 8, 2, ' ', tabwriter.AlignRight)
+		fmt.Fprintln(w, "AVG\tCHUNKS\tUNIQUE BYTES\tRATIO\tSAVINGS\t")
+		for _, size := range sizes {
+			readers := make([]io.Reader, len(files))
+			for i, name := range files {
+				readers[i] = &lazyFile{name: name}
+			}
+			o := cf.options()
+			o.AverageSize, o.MaxSize = size, 0
+			report, err := ae.EstimateDedup(readers, o)
+			if err != nil {
+				return err
+			}
+			fmt.Fprintf(w, "%s\t%d\t%d\t%.4f\t%.2f%%\t\n",
+				formatSize(size), report.Chunks, report.UniqueBytes, report.DedupRatio(), savedPercent(report.DedupStats))
+		}
+		if err := w.Flush(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// savedPercent returns the share of bytes saved by deduplication in percent.
+func savedPercent(s ae.DedupStats) float64 {
+	if s.Bytes == 0 {
+		return 0
+	}
+	return 100 * float64(s.Bytes-s.UniqueBytes) / float64(s.Bytes)
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRun_dedup(t *testing.T) {
+	data := make([]byte, 1<<20)
+	rand.New(rand.NewSource(1)).Read(data)
+	dir := t.TempDir()
+	assert.NoError(t, os.MkdirAll(filepath.Join(dir, "a", "b"), 0o755))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "a", "x"), data, 0o644))
+	assert.NoError(t, os.WriteFile(filepath.Join(dir, "a", "b", "y"), data, 0o644))
+	other := t.TempDir()
+	assert.NoError(t, os.WriteFile(filepath.Join(other, "z"), data[:1<<19], 0o644))
+
+	out, err := runTest(t, nil, "dedup", "-avg", "32KiB", "-top", "3", "-sizes", "16KiB,64KiB", dir, other)
+	assert.NoError(t, err)
+	sections := strings.Split(out, "\n\n")
+	assert.Len(t, sections, 3)
+
+	summary := sections[0]
+	assert.Contains(t, summary, "files:         3\n")
+	assert.Contains(t, summary, "bytes:         2621440 (")
+	assert.Regexp(t, `dedup ratio:\s+2\.\d{4}`, summary)
+
+	dups := strings.Split(strings.TrimSpace(sections[1]), "\n")
+	assert.Len(t, dups, 4)
+	assert.Equal(t, []string{"HASH", "SIZE", "REFS", "SAVED", "FIRST", "SEEN"}, strings.Fields(dups[0]))
+	for _, line := range dups[1:] {
+		assert.Contains(t, line, filepath.Join(dir, "a", "b", "y")+"@")
+	}
+
+	candidates := strings.Split(strings.TrimSpace(sections[2]), "\n")
+	assert.Len(t, candidates, 3)
+	assert.Equal(t, "16KiB", strings.Fields(candidates[1])[0])
+	assert.Equal(t, "64KiB", strings.Fields(candidates[2])[0])
+
+	_, err = runTest(t, nil, "dedup")
+	assert.Equal(t, errUsage, err)
+	_, err = runTest(t, nil, "dedup", "-sizes", "4KiB,0", dir)
+	assert.Equal(t, errUsage, err)
+	_, err = runTest(t, nil, "dedup", filepath.Join(dir, "missing"))
+	assert.Error(t, err)
+}
+
+func TestLazyFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "file")
+	assert.NoError(t, os.WriteFile(path, []byte("hello world"), 0o644))
+
+	l := &lazyFile{name: path}
+	assert.Nil(t, l.f)
+	data, err := io.ReadAll(l)
+	assert.NoError(t, err)
+	assert.Equal(t, "hello world", string(data))
+	assert.Error(t, l.f.Close(), "file must be closed at EOF")
+
+	_, err = (&lazyFile{name: path + ".missing"}).Read(make([]byte, 1))
+	assert.Error(t, err)
+}
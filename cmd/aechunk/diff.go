@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	ae "github.com/mg98/ae-chunker-go"
+	"text/tabwriter"
+)
+
+func init() {
+	commands["diff"] = command{usage: "diff [flags] a b", run: runDiff}
+}
+
+// region is a run of consecutive chunks of the new file that relate to the old file in the same way.
+type region struct {
+	offset, length int64
+	chunks         int
+
+	// found states whether the chunks occur in the old file.
+	found bool
+
+	// shift is the distance the chunks have moved from their offset in the old file.
+	shift int64
+}
+
+// String describes how the region relates to the old file.
+func (r region) String() string {
+	switch {
+	case !r.found:
+		return "changed"
+	case r.shift == 0:
+		return "unchanged"
+	default:
+		return fmt.Sprintf("shifted by %+d", r.shift)
+	}
+}
+
+// diffRegions splits the new file into regions of chunks that are unchanged, shifted by the same distance,
+// or missing from the old file. A chunk occurring several times in the old file is matched with its first occurrence.
+func diffRegions(old, new *ae.Manifest) []region {
+	offsets := make(map[string]int64, len(old.Chunks))
+	for _, ref := range old.Chunks {
+		if _, ok := offsets[string(ref.Hash)]; !ok {
+			offsets[string(ref.Hash)] = ref.Offset
+		}
+	}
+
+	var regions []region
+	for _, ref := range new.Chunks {
+		oldOffset, found := offsets[string(ref.Hash)]
+		var shift int64
+		if found {
+			shift = ref.Offset - oldOffset
+		}
+		if n := len(regions); n > 0 && regions[n-1].found == found && regions[n-1].shift == shift {
+			regions[n-1].length += ref.Length
+			regions[n-1].chunks++
+			continue
+		}
+		regions = append(regions, region{offset: ref.Offset, length: ref.Length, chunks: 1, found: found, shift: shift})
+	}
+	return regions
+}
+
+// changedBytes returns the number of bytes of m in chunks that do not occur in other.
+func changedBytes(m, other *ae.Manifest) int64 {
+	hashes := make(map[string]bool, len(other.Chunks))
+	for _, ref := range other.Chunks {
+		hashes[string(ref.Hash)] = true
+	}
+	var n int64
+	for _, ref := range m.Chunks {
+		if !hashes[string(ref.Hash)] {
+			n += ref.Length
+		}
+	}
+	return n
+}
+
+// runDiff compares two files by their chunks.
+func runDiff(e *env, args []string) error {
+	var cf chunkFlags
+	fs := newFlagSet(e, "diff", "aechunk diff [flags] a b")
+	cf.register(fs)
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 2 {
+		fs.Usage()
+		return errUsage
+	}
+
+	var manifests [2]*ae.Manifest
+	for i, name := range fs.Args() {
+		f, err := openInput(e, name)
+		if err != nil {
+			return err
+		}
+		manifests[i], err = ae.BuildManifest(f, cf.options())
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %w", name, err)
+		}
+	}
+	a, b := manifests[0], manifests[1]
+	d := ae.DiffManifests(a, b)
+
+	w := tabwriter.NewWriter(e.stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(w, "a:\t%d bytes in %d chunks\n", a.Size, len(a.Chunks))
+	fmt.Fprintf(w, "b:\t%d bytes in %d chunks\n", b.Size, len(b.Chunks))
+	fmt.Fprintf(w, "shared:\t%d distinct chunks, %d bytes\n", len(d.Unchanged), d.UnchangedBytes)
+	fmt.Fprintf(w, "only in a:\t%d distinct chunks, %d bytes\n", len(d.Removed), d.RemovedBytes)
+	fmt.Fprintf(w, "only in b:\t%d distinct chunks, %d bytes\n", len(d.Added), d.AddedBytes)
+	fmt.Fprintf(w, "differing bytes:\t%d in a, %d in b\n", changedBytes(a, b), changedBytes(b, a))
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	regions := diffRegions(a, b)
+	if len(regions) == 0 {
+		return nil
+	}
+	fmt.Fprintln(e.stdout)
+	w = tabwriter.NewWriter(e.stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "OFFSET\tLENGTH\tCHUNKS\tREGION OF B")
+	for _, r := range regions {
+		fmt.Fprintf(w, "%d\t%d\t%d\t%s\n", r.offset, r.length, r.chunks, r)
+	}
+	return w.Flush()
+}
@@ -0,0 +1,67 @@
+package main
+
+import (
+	ae "github.com/mg98/ae-chunker-go"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// manifestOf returns a manifest of chunks with the given lengths, each identified by a single letter.
+func manifestOf(chunks string, lengths ...int64) *ae.Manifest {
+	m := &ae.Manifest{}
+	for i, name := range chunks {
+		m.Chunks = append(m.Chunks, ae.ChunkRef{Offset: m.Size, Length: lengths[i], Hash: []byte{byte(name)}})
+		m.Size += lengths[i]
+	}
+	return m
+}
+
+func TestDiffRegions(t *testing.T) {
+	old := manifestOf("abcde", 10, 20, 30, 40, 50)
+	new := manifestOf("abxcdy", 10, 20, 5, 30, 40, 60)
+
+	regions := diffRegions(old, new)
+	assert.Equal(t, []region{
+		{offset: 0, length: 30, chunks: 2, found: true},
+		{offset: 30, length: 5, chunks: 1},
+		{offset: 35, length: 70, chunks: 2, found: true, shift: 5},
+		{offset: 105, length: 60, chunks: 1},
+	}, regions)
+	assert.Equal(t, []string{"unchanged", "changed", "shifted by +5", "changed"},
+		[]string{regions[0].String(), regions[1].String(), regions[2].String(), regions[3].String()})
+
+	assert.Equal(t, int64(50), changedBytes(old, new))
+	assert.Equal(t, int64(65), changedBytes(new, old))
+	assert.Empty(t, diffRegions(old, &ae.Manifest{}))
+}
+
+func TestRun_diff(t *testing.T) {
+	path, data := randomFile(t, 1<<20, 1)
+	edited := filepath.Join(t.TempDir(), "edited")
+	assert.NoError(t, os.WriteFile(edited, append(data[:1<<19:1<<19], data[1<<19+100:]...), 0o644))
+
+	out, err := runTest(t, nil, "diff", "-avg", "32KiB", path, edited)
+	assert.NoError(t, err)
+	sections := strings.Split(out, "\n\n")
+	assert.Len(t, sections, 2)
+	assert.Contains(t, sections[0], "a:                1048576 bytes in ")
+	assert.Contains(t, sections[0], "b:                1048476 bytes in ")
+	assert.Regexp(t, `differing bytes:  \d+ in a, \d+ in b`, sections[0])
+
+	regions := strings.Split(strings.TrimSpace(sections[1]), "\n")
+	assert.Equal(t, []string{"OFFSET", "LENGTH", "CHUNKS", "REGION", "OF", "B"}, strings.Fields(regions[0]))
+	first := strings.Fields(regions[1])
+	assert.Equal(t, "0", first[0])
+	assert.Equal(t, "unchanged", first[3])
+
+	out, err = runTest(t, data, "diff", "-avg", "32KiB", path, "-")
+	assert.NoError(t, err)
+	assert.Contains(t, out, "differing bytes:  0 in a, 0 in b\n")
+	assert.Len(t, strings.Split(strings.TrimSpace(out), "\n"), 9)
+
+	_, err = runTest(t, nil, "diff", path)
+	assert.Equal(t, errUsage, err)
+}
@@ -0,0 +1,146 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	ae "github.com/mg98/ae-chunker-go"
+	"strconv"
+	"strings"
+)
+
+// sizeUnits maps the accepted size suffixes to their multiples in bytes.
+var sizeUnits = []struct {
+	suffix string
+	size   int64
+}{
+	{"KiB", 1 << 10}, {"MiB", 1 << 20}, {"GiB", 1 << 30},
+	{"K", 1 << 10}, {"M", 1 << 20}, {"G", 1 << 30},
+	{"KB", 1e3}, {"MB", 1e6}, {"GB", 1e9},
+	{"B", 1},
+}
+
+// parseSize parses a size in bytes with an optional unit, e.g. "4096", "64KiB" or "1M".
+func parseSize(s string) (int64, error) {
+	num, unit := s, int64(1)
+	for _, u := range sizeUnits {
+		if strings.HasSuffix(s, u.suffix) {
+			num, unit = strings.TrimSuffix(s, u.suffix), u.size
+			break
+		}
+	}
+	n, err := strconv.ParseInt(strings.TrimSpace(num), 10, 64)
+	if err != nil || n < 0 {
+		return 0, fmt.Errorf("invalid size %q", s)
+	}
+	return n * unit, nil
+}
+
+// formatSize formats a size in bytes with a binary unit.
+func formatSize(n int64) string {
+	for _, u := range sizeUnits[:3] {
+		if n == 0 || n >= u.size<<10 || n%u.size != 0 {
+			continue
+		}
+		return fmt.Sprintf("%d%s", n/u.size, u.suffix)
+	}
+	return strconv.FormatInt(n, 10)
+}
+
+// sizeValue is a flag.Value holding a size in bytes.
+type sizeValue int64
+
+func (v *sizeValue) String() string { return formatSize(int64(*v)) }
+
+func (v *sizeValue) Set(s string) error {
+	n, err := parseSize(s)
+	*v = sizeValue(n)
+	return err
+}
+
+// modeValue is a flag.Value holding the mode of the algorithm.
+type modeValue ae.Extremum
+
+func (v *modeValue) String() string { return ae.Extremum(*v).String() }
+
+func (v *modeValue) Set(s string) error {
+	return (*ae.Extremum)(v).UnmarshalText([]byte(s))
+}
+
+// algorithmValue is a flag.Value holding the chunking algorithm.
+type algorithmValue ae.Algorithm
+
+func (v *algorithmValue) String() string { return ae.Algorithm(*v).String() }
+
+func (v *algorithmValue) Set(s string) error {
+	return (*ae.Algorithm)(v).UnmarshalText([]byte(s))
+}
+
+// chunkFlags are the flags configuring the chunker, shared by all commands.
+type chunkFlags struct {
+	avg       sizeValue
+	max       sizeValue
+	mode      modeValue
+	algorithm algorithmValue
+	align     sizeValue
+	hash      string
+}
+
+// register adds the chunking flags to fs.
+func (f *chunkFlags) register(fs *flag.FlagSet) {
+	f.avg = 256 * 1024
+	f.hash = "sha256"
+	fs.Var(&f.avg, "avg", "average chunk size, e.g. 64KiB (the minimum size is derived from it)")
+	fs.Var(&f.max, "max", "maximum chunk size (default twice the average)")
+	fs.Var(&f.mode, "mode", "extremum to cut at, max or min (default max)")
+	fs.Var(&f.algorithm, "algorithm", "chunking algorithm, ae or fixed for chunks of exactly the average size (default ae)")
+	fs.Var(&f.align, "align", "alignment that cut points near a multiple of are moved to, e.g. 4KiB for disk images")
+	fs.StringVar(&f.hash, "hash", f.hash, "hash to fingerprint chunks with ("+strings.Join(ae.Hashes(), ", ")+")")
+}
+
+// options returns the chunker options as configured by the flags.
+func (f *chunkFlags) options() *ae.Options {
+	return &ae.Options{
+		AverageSize: int64(f.avg),
+		MaxSize:     int64(f.max),
+		Mode:        ae.Extremum(f.mode),
+		Algorithm:   ae.Algorithm(f.algorithm),
+		Alignment:   int64(f.align),
+		HashName:    f.hash,
+	}
+}
+
+// sizeListValue is a flag.Value holding a comma-separated list of sizes in bytes.
+type sizeListValue []int64
+
+func (v *sizeListValue) String() string {
+	s := make([]string, len(*v))
+	for i, n := range *v {
+		s[i] = formatSize(n)
+	}
+	return strings.Join(s, ",")
+}
+
+func (v *sizeListValue) Set(s string) error {
+	var sizes []int64
+	for _, field := range strings.Split(s, ",") {
+		n, err := parseSize(field)
+		if err != nil {
+			return err
+		}
+		if n == 0 {
+			return fmt.Errorf("invalid size %q", field)
+		}
+		sizes = append(sizes, n)
+	}
+	*v = sizes
+	return nil
+}
+
+// formatValue is a flag.Value holding a boundary format.
+type formatValue ae.BoundaryFormat
+
+func (v *formatValue) String() string { return ae.BoundaryFormat(*v).String() }
+
+func (v *formatValue) Set(s string) error {
+	return (*ae.BoundaryFormat)(v).UnmarshalText([]byte(s))
+}
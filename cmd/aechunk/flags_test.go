@@ -0,0 +1,62 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestParseSize(t *testing.T) {
+	for s, want := range map[string]int64{
+		"4096":  4096,
+		"64KiB": 64 << 10,
+		"64K":   64 << 10,
+		"2MiB":  2 << 20,
+		"1M":    1 << 20,
+		"1G":    1 << 30,
+		"8KB":   8000,
+		"512B":  512,
+		"1 MiB": 1 << 20,
+		"0":     0,
+		"3GiB":  3 << 30,
+		"100MB": 100e6,
+		"7":     7,
+	} {
+		got, err := parseSize(s)
+		assert.NoError(t, err, s)
+		assert.Equal(t, want, got, s)
+	}
+
+	for _, s := range []string{"", "KiB", "-1", "1.5M", "1TiB", "ten", "16KiB "} {
+		_, err := parseSize(s)
+		assert.Error(t, err, s)
+	}
+}
+
+func TestFormatSize(t *testing.T) {
+	for n, want := range map[int64]string{
+		0:             "0",
+		1000:          "1000",
+		1 << 10:       "1KiB",
+		256 << 10:     "256KiB",
+		1 << 20:       "1MiB",
+		3 << 30:       "3GiB",
+		(1 << 20) + 1: "1048577",
+	} {
+		assert.Equal(t, want, formatSize(n))
+		if n > 0 {
+			parsed, err := parseSize(want)
+			assert.NoError(t, err)
+			assert.Equal(t, n, parsed)
+		}
+	}
+}
+
+func TestSizeListValue(t *testing.T) {
+	var v sizeListValue
+	assert.NoError(t, v.Set("4KiB,1M,100"))
+	assert.Equal(t, sizeListValue{4 << 10, 1 << 20, 100}, v)
+	assert.Equal(t, "4KiB,1MiB,100", v.String())
+
+	assert.Error(t, v.Set("4KiB,,1M"))
+	assert.Error(t, v.Set("0"))
+}
@@ -0,0 +1,213 @@
+// Command aechunk splits files into chunks with the asymmetric extremum algorithm.
+//
+// Usage:
+//
+//	aechunk [flags] [file...]
+//	aechunk stats [flags] [file]
+//	aechunk dedup [flags] dir...
+//	aechunk diff [flags] a b
+//	aechunk split [flags] -o dir [file]
+//	aechunk join [flags] [-o file] manifest
+//	aechunk cp [flags] -repo dir src dst
+//	aechunk boundaries [flags] [file]
+//	aechunk tune [flags] dir...
+//	aechunk bench [flags] [file...]
+//	aechunk pipe [flags] [file]
+//
+// Without a command, aechunk prints the offset, length and hash of every chunk of the given files,
+// or of the standard input if no file or "-" is given. With -entropy, it adds the entropy of every chunk in bits per byte.
+//
+// The stats command prints the distribution of the chunk sizes of a file, including percentiles and a histogram,
+// to validate the choice of parameters on real data.
+//
+// The dedup command chunks every file in the given directories and reports how well they deduplicate,
+// the most duplicated chunks and the estimated savings at a range of candidate average sizes.
+//
+// The diff command compares two files by their chunks. Besides the shared and differing bytes,
+// it lists the regions of the second file that are unchanged, shifted or changed with respect to the first one.
+//
+// The split command writes the chunks of a file into a directory, each named by its hash, along with a manifest.
+// With -tar, every entry of a tar stream starts a new chunk, so that files shared by archives deduplicate
+// regardless of their position.
+// The join command reassembles the file from the manifest, verifying every chunk.
+//
+// The cp command copies a file into a repository, i.e. a directory of chunks shared by many files.
+// Only the chunks not in the repository yet are stored, and the manifest of the copy is written to dst,
+// or into dst if it is a directory. The file is restored with join and -chunks.
+// Instead of a directory, the repository may be a remote store given by its URL,
+// e.g. s3://bucket/prefix, azblob://container/prefix or gs://bucket/prefix (see package storeurl).
+//
+// The boundaries command writes the offset, length and hash of every chunk as CSV, JSON or NDJSON
+// for consumption by other tools.
+//
+// The tune command sweeps the average and maximum size over a sample of the files in the given directories
+// and prints the number of chunks and the deduplication savings of every combination. It recommends the parameters
+// with the fewest chunks that save the share of bytes given by -target-dedup, or those saving the most
+// within the number of chunks given by -max-chunks.
+//
+// The bench command compares the throughput and the realized chunk sizes of the algorithms and modes
+// on the given files or on synthetic corpora.
+//
+// The pipe command writes the chunks of a file as a stream of length-prefixed frames, tagged with their hashes,
+// for consumption by another process. With -d, it decodes such a stream into the original data.
+//
+// Flags may follow the positional arguments.
+package main
+
+import (
+	"encoding/hex"
+	"errors"
+	"flag"
+	"fmt"
+	ae "github.com/mg98/ae-chunker-go"
+	"io"
+	"os"
+	"sort"
+	"text/tabwriter"
+)
+
+// command is a subcommand of aechunk.
+type command struct {
+	usage string
+	run   func(env *env, args []string) error
+}
+
+// commands maps the names of the subcommands to their implementation.
+var commands = map[string]command{}
+
+// env is the environment a command runs in.
+type env struct {
+	stdin  io.Reader
+	stdout io.Writer
+	stderr io.Writer
+}
+
+// errUsage is returned by commands if they are invoked incorrectly, after printing their usage.
+var errUsage = errors.New("invalid usage")
+
+func main() {
+	err := run(&env{stdin: os.Stdin, stdout: os.Stdout, stderr: os.Stderr}, os.Args[1:])
+	if err == errUsage {
+		os.Exit(2)
+	}
+	if err != nil {
+		fmt.Fprintln(os.Stderr, "aechunk:", err)
+		os.Exit(1)
+	}
+}
+
+// run executes aechunk with args, dispatching to the command named by the first argument.
+func run(e *env, args []string) error {
+	if len(args) > 0 {
+		if cmd, ok := commands[args[0]]; ok {
+			return cmd.run(e, args[1:])
+		}
+	}
+	return runChunk(e, args)
+}
+
+// newFlagSet returns a flag set for the command name, printing its usage to e.
+func newFlagSet(e *env, name, usage string) *flag.FlagSet {
+	fs := flag.NewFlagSet(name, flag.ContinueOnError)
+	fs.SetOutput(e.stderr)
+	fs.Usage = func() {
+		fmt.Fprintf(e.stderr, "Usage: %s\n\nFlags:\n", usage)
+		fs.PrintDefaults()
+		if name == "aechunk" && len(commands) > 0 {
+			fmt.Fprintln(e.stderr, "\nCommands:")
+			names := make([]string, 0, len(commands))
+			for name := range commands {
+				names = append(names, name)
+			}
+			sort.Strings(names)
+			for _, name := range names {
+				fmt.Fprintf(e.stderr, "  %s\n", commands[name].usage)
+			}
+		}
+	}
+	return fs
+}
+
+// parseFlags parses args into fs. Unlike fs.Parse, it accepts flags after positional arguments,
+// which remain available through fs.Args, unless they follow "--".
+// The flag package has already reported any error, so it is mapped to errUsage.
+func parseFlags(fs *flag.FlagSet, args []string) error {
+	var positional []string
+	for {
+		if err := fs.Parse(args); err != nil {
+			return errUsage
+		}
+		rest := fs.Args()
+		if len(rest) == 0 || len(rest) < len(args) && args[len(args)-len(rest)-1] == "--" {
+			positional = append(positional, rest...)
+			break
+		}
+		positional = append(positional, rest[0])
+		args = rest[1:]
+	}
+	// Parsing the positional arguments after "--" leaves the flags untouched and resets fs.Args.
+	return fs.Parse(append([]string{"--"}, positional...))
+}
+
+// openInput opens the file name for reading, or returns the standard input for "-".
+func openInput(e *env, name string) (io.ReadCloser, error) {
+	if name == "-" {
+		return io.NopCloser(e.stdin), nil
+	}
+	return os.Open(name)
+}
+
+// runChunk prints the chunks of the given files.
+func runChunk(e *env, args []string) error {
+	var cf chunkFlags
+	fs := newFlagSet(e, "aechunk", "aechunk [flags] [file...]")
+	cf.register(fs)
+	entropy := fs.Bool("entropy", false, "print the entropy of every chunk in bits per byte")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	files := fs.Args()
+	if len(files) == 0 {
+		files = []string{"-"}
+	}
+	opts := cf.options()
+	opts.Entropy = *entropy
+
+	w := tabwriter.NewWriter(e.stdout, 0, 8, 2, ' ', tabwriter.AlignRight)
+	if len(files) > 1 {
+		fmt.Fprint(w, "FILE\t")
+	}
+	fmt.Fprint(w, "OFFSET\tLENGTH\tHASH\t")
+	if *entropy {
+		fmt.Fprint(w, "ENTROPY\t")
+	}
+	fmt.Fprintln(w)
+	for _, name := range files {
+		f, err := openInput(e, name)
+		if err != nil {
+			return err
+		}
+		ch := ae.NewChunker(f, opts)
+		for {
+			c, err := ch.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				f.Close()
+				return err
+			}
+			if len(files) > 1 {
+				fmt.Fprintf(w, "%s\t", name)
+			}
+			fmt.Fprintf(w, "%d\t%d\t%s\t", c.Offset, len(c.Data), hex.EncodeToString(c.Sum))
+			if *entropy {
+				fmt.Fprintf(w, "%.2f\t", c.Entropy)
+			}
+			fmt.Fprintln(w)
+			ae.ReleaseChunk(c)
+		}
+		f.Close()
+	}
+	return w.Flush()
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"flag"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// runTest runs aechunk with args and stdin and returns its output.
+func runTest(t *testing.T, stdin []byte, args ...string) (string, error) {
+	t.Helper()
+	var stdout, stderr bytes.Buffer
+	err := run(&env{stdin: bytes.NewReader(stdin), stdout: &stdout, stderr: &stderr}, args)
+	return stdout.String(), err
+}
+
+// randomFile writes n random bytes to a temporary file and returns its path and content.
+func randomFile(t *testing.T, n int, seed int64) (string, []byte) {
+	t.Helper()
+	data := make([]byte, n)
+	rand.New(rand.NewSource(seed)).Read(data)
+	path := filepath.Join(t.TempDir(), "data"+strconv.FormatInt(seed, 10))
+	assert.NoError(t, os.WriteFile(path, data, 0o644))
+	return path, data
+}
+
+func TestRun_chunk(t *testing.T) {
+	path, data := randomFile(t, 1<<20, 1)
+
+	t.Run("file", func(t *testing.T) {
+		out, err := runTest(t, nil, "-avg", "32KiB", path)
+		assert.NoError(t, err)
+		lines := strings.Split(strings.TrimSpace(out), "\n")
+		assert.Equal(t, []string{"OFFSET", "LENGTH", "HASH"}, strings.Fields(lines[0]))
+		assert.Greater(t, len(lines), 2)
+
+		var offset int64
+		for _, line := range lines[1:] {
+			fields := strings.Fields(line)
+			assert.Len(t, fields, 3)
+			off, _ := strconv.ParseInt(fields[0], 10, 64)
+			length, _ := strconv.ParseInt(fields[1], 10, 64)
+			assert.Equal(t, offset, off)
+			sum := sha256.Sum256(data[off : off+length])
+			assert.Equal(t, hex.EncodeToString(sum[:]), fields[2])
+			offset += length
+		}
+		assert.Equal(t, int64(len(data)), offset)
+	})
+
+	t.Run("stdin", func(t *testing.T) {
+		fromFile, err := runTest(t, nil, "-avg", "32KiB", "-mode", "min", path)
+		assert.NoError(t, err)
+		fromStdin, err := runTest(t, data, "-avg", "32KiB", "-mode", "min")
+		assert.NoError(t, err)
+		assert.Equal(t, fromFile, fromStdin)
+		fromDash, err := runTest(t, data, "-avg", "32KiB", "-mode", "min", "-")
+		assert.NoError(t, err)
+		assert.Equal(t, fromFile, fromDash)
+	})
+
+	t.Run("multiple files", func(t *testing.T) {
+		other, _ := randomFile(t, 1<<16, 2)
+		out, err := runTest(t, nil, "-avg", "32KiB", path, other)
+		assert.NoError(t, err)
+		lines := strings.Split(strings.TrimSpace(out), "\n")
+		assert.Equal(t, []string{"FILE", "OFFSET", "LENGTH", "HASH"}, strings.Fields(lines[0]))
+		assert.Equal(t, other, strings.Fields(lines[len(lines)-1])[0])
+	})
+
+	t.Run("entropy", func(t *testing.T) {
+		out, err := runTest(t, bytes.Repeat([]byte{'a'}, 1000), "-entropy")
+		assert.NoError(t, err)
+		lines := strings.Split(strings.TrimSpace(out), "\n")
+		assert.Equal(t, []string{"OFFSET", "LENGTH", "HASH", "ENTROPY"}, strings.Fields(lines[0]))
+		assert.Equal(t, "0.00", strings.Fields(lines[1])[3])
+		out, err = runTest(t, nil, "-avg", "32KiB", "-entropy", path)
+		assert.NoError(t, err)
+		e, err := strconv.ParseFloat(strings.Fields(strings.Split(out, "\n")[1])[3], 64)
+		assert.NoError(t, err)
+		assert.Greater(t, e, 7.9)
+	})
+
+	t.Run("fixed", func(t *testing.T) {
+		out, err := runTest(t, nil, "-avg", "32KiB", "-algorithm", "fixed", path)
+		assert.NoError(t, err)
+		lines := strings.Split(strings.TrimSpace(out), "\n")
+		assert.Len(t, lines, 1+len(data)/(32*1024))
+		for _, line := range lines[1:] {
+			assert.Equal(t, "32768", strings.Fields(line)[1])
+		}
+		_, err = runTest(t, nil, "-algorithm", "rabin", path)
+		assert.Equal(t, errUsage, err)
+	})
+
+	t.Run("invalid flags", func(t *testing.T) {
+		_, err := runTest(t, nil, "-avg", "lots", path)
+		assert.Equal(t, errUsage, err)
+		_, err = runTest(t, nil, "-mode", "median", path)
+		assert.Equal(t, errUsage, err)
+	})
+
+	t.Run("missing file", func(t *testing.T) {
+		_, err := runTest(t, nil, filepath.Join(t.TempDir(), "missing"))
+		assert.Error(t, err)
+	})
+}
+
+func TestParseFlags(t *testing.T) {
+	for _, tc := range []struct {
+		args       []string
+		verbose    bool
+		positional []string
+	}{
+		{nil, false, []string{}},
+		{[]string{"-v", "a", "b"}, true, []string{"a", "b"}},
+		{[]string{"a", "-v", "b"}, true, []string{"a", "b"}},
+		{[]string{"a", "b", "-v"}, true, []string{"a", "b"}},
+		{[]string{"-", "-v"}, true, []string{"-"}},
+		{[]string{"a", "--", "-v"}, false, []string{"a", "-v"}},
+		{[]string{"--", "-v", "a"}, false, []string{"-v", "a"}},
+	} {
+		fs := flag.NewFlagSet("test", flag.ContinueOnError)
+		verbose := fs.Bool("v", false, "")
+		assert.NoError(t, parseFlags(fs, tc.args), tc.args)
+		assert.Equal(t, tc.verbose, *verbose, tc.args)
+		assert.Equal(t, tc.positional, fs.Args(), tc.args)
+	}
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	fs.SetOutput(io.Discard)
+	assert.Equal(t, errUsage, parseFlags(fs, []string{"a", "-unknown"}))
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bufio"
+	ae "github.com/mg98/ae-chunker-go"
+	"io"
+)
+
+func init() {
+	commands["pipe"] = command{usage: "pipe [flags] [file]", run: runPipe}
+}
+
+// runPipe encodes the chunks of a file as a stream of frames, or decodes such a stream.
+func runPipe(e *env, args []string) error {
+	var cf chunkFlags
+	fs := newFlagSet(e, "pipe", "aechunk pipe [flags] [file]")
+	cf.register(fs)
+	decode := fs.Bool("d", false, "decode a stream of frames into the original data instead")
+	untagged := fs.Bool("untagged", false, "omit the digests from the frames")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 {
+		fs.Usage()
+		return errUsage
+	}
+	name := "-"
+	if fs.NArg() == 1 {
+		name = fs.Arg(0)
+	}
+
+	f, err := openInput(e, name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	w := bufio.NewWriter(e.stdout)
+	if *decode {
+		fr, err := ae.NewFrameReader(f)
+		if err != nil {
+			return err
+		}
+		for {
+			c, err := fr.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return err
+			}
+			if _, err := w.Write(c.Data); err != nil {
+				return err
+			}
+			ae.ReleaseChunk(c)
+		}
+		return w.Flush()
+	}
+
+	hashName := cf.hash
+	if *untagged {
+		hashName = ""
+	}
+	fw, err := ae.NewFrameWriter(w, hashName)
+	if err != nil {
+		return err
+	}
+	ch := ae.NewChunker(f, cf.options())
+	for {
+		c, err := ch.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if err := fw.WriteChunk(c); err != nil {
+			return err
+		}
+		ae.ReleaseChunk(c)
+	}
+	if err := fw.Close(); err != nil {
+		return err
+	}
+	return w.Flush()
+}
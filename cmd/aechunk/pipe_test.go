@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	ae "github.com/mg98/ae-chunker-go"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"testing"
+)
+
+func TestRun_pipe(t *testing.T) {
+	path, data := randomFile(t, 1<<20, 1)
+
+	for _, flags := range [][]string{
+		{"-avg", "32KiB"},
+		{"-avg", "32KiB", "-hash", "blake3"},
+		{"-avg", "32KiB", "-untagged"},
+	} {
+		stream, err := runTest(t, nil, append([]string{"pipe", path}, flags...)...)
+		assert.NoError(t, err)
+
+		fr, err := ae.NewFrameReader(bytes.NewReader([]byte(stream)))
+		assert.NoError(t, err)
+		var chunks int
+		for {
+			_, err := fr.Next()
+			if err == io.EOF {
+				break
+			}
+			assert.NoError(t, err)
+			chunks++
+		}
+		assert.Greater(t, chunks, 1)
+
+		decoded, err := runTest(t, []byte(stream), "pipe", "-d")
+		assert.NoError(t, err)
+		assert.Equal(t, data, []byte(decoded))
+	}
+
+	fromStdin, err := runTest(t, data, "pipe", "-avg", "32KiB")
+	assert.NoError(t, err)
+	fromFile, err := runTest(t, nil, "pipe", "-avg", "32KiB", path)
+	assert.NoError(t, err)
+	assert.Equal(t, fromFile, fromStdin)
+
+	stream := []byte(fromFile)
+	stream[len(stream)/2] ^= 1
+	_, err = runTest(t, stream, "pipe", "-d")
+	assert.ErrorIs(t, err, ae.ErrCorruptChunk)
+	_, err = runTest(t, data, "pipe", "-d")
+	assert.Equal(t, ae.ErrInvalidFrame, err)
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	ae "github.com/mg98/ae-chunker-go"
+	"os"
+	"path/filepath"
+)
+
+func init() {
+	commands["split"] = command{usage: "split [flags] -o dir [file]", run: runSplit}
+	commands["join"] = command{usage: "join [flags] [-o file] manifest", run: runJoin}
+}
+
+// manifestSuffix is appended to the name of a file to name its manifest.
+const manifestSuffix = ".manifest.json"
+
+// readManifest reads a manifest in JSON or binary encoding from the file name.
+func readManifest(name string) (*ae.Manifest, error) {
+	data, err := os.ReadFile(name)
+	if err != nil {
+		return nil, err
+	}
+	m := &ae.Manifest{}
+	if bytes.HasPrefix(data, []byte("AEmf")) {
+		err = m.UnmarshalBinary(data)
+	} else {
+		err = json.Unmarshal(data, m)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", name, err)
+	}
+	return m, nil
+}
+
+// writeManifest writes m in JSON encoding to the file name.
+func writeManifest(name string, m *ae.Manifest) error {
+	data, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(name, append(data, '\n'), 0o644)
+}
+
+// runSplit writes the chunks of a file into a directory, along with its manifest.
+func runSplit(e *env, args []string) error {
+	var cf chunkFlags
+	fs := newFlagSet(e, "split", "aechunk split [flags] -o dir [file]")
+	cf.register(fs)
+	out := fs.String("o", "", "directory to write the chunks to, named by their hashes")
+	tarMode := fs.Bool("tar", false, "parse the input as a tar stream and start a new chunk with every entry")
+	manifest := fs.String("manifest", "", "path of the manifest (default the name of the file with suffix "+manifestSuffix+" in the output directory)")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if *out == "" || fs.NArg() > 1 {
+		fs.Usage()
+		return errUsage
+	}
+	name := "-"
+	if fs.NArg() == 1 {
+		name = fs.Arg(0)
+	}
+	if *manifest == "" {
+		base := "stdin"
+		if name != "-" {
+			base = filepath.Base(name)
+		}
+		*manifest = filepath.Join(*out, base+manifestSuffix)
+	}
+
+	s, err := ae.NewFileStore(*out, nil)
+	if err != nil {
+		return err
+	}
+	f, err := openInput(e, name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	opts := cf.options()
+	opts.Tar = *tarMode
+	m, err := ae.StoreFile(f, s, opts)
+	if err != nil {
+		return err
+	}
+	if err := writeManifest(*manifest, m); err != nil {
+		return err
+	}
+	fmt.Fprintf(e.stderr, "%d bytes in %d chunks, manifest written to %s\n", m.Size, len(m.Chunks), *manifest)
+	return nil
+}
+
+// runJoin reassembles a file from its manifest and chunks written by split, verifying every chunk.
+func runJoin(e *env, args []string) error {
+	fs := newFlagSet(e, "join", "aechunk join [flags] [-o file] manifest")
+	out := fs.String("o", "-", "file to write, or - for the standard output")
+	chunks := fs.String("chunks", "", "directory or URL of the store holding the chunks (default the directory of the manifest)")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return errUsage
+	}
+	if *chunks == "" {
+		*chunks = filepath.Dir(fs.Arg(0))
+	}
+
+	m, err := readManifest(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	s, err := openStore(*chunks, false)
+	if err != nil {
+		return err
+	}
+	if *out == "-" {
+		return ae.Reassemble(e.stdout, m, s)
+	}
+
+	// The file is written under a temporary name, so that a failed verification leaves nothing behind.
+	f, err := os.CreateTemp(filepath.Dir(*out), "."+filepath.Base(*out)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if err := ae.ReassembleSparse(f, m, s); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Chmod(0o644); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), *out)
+}
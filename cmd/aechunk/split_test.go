@@ -0,0 +1,184 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	ae "github.com/mg98/ae-chunker-go"
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestRun_splitJoin(t *testing.T) {
+	path, data := randomFile(t, 1<<20, 1)
+	dir := filepath.Join(t.TempDir(), "chunks")
+
+	_, err := runTest(t, nil, "split", path, "-o", dir, "-avg", "64KiB")
+	assert.NoError(t, err)
+	manifest := filepath.Join(dir, filepath.Base(path)+manifestSuffix)
+	m, err := readManifest(manifest)
+	assert.NoError(t, err)
+	assert.Equal(t, int64(len(data)), m.Size)
+	assert.Equal(t, ae.Parameters{AverageSize: 64 << 10, MaxSize: 128 << 10, Hash: "sha256"}, m.Parameters)
+	s, err := ae.NewFileStore(dir, nil)
+	assert.NoError(t, err)
+	for _, ref := range m.Chunks {
+		ok, err := s.Has(ref.Hash)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+	}
+
+	t.Run("join to file", func(t *testing.T) {
+		out := filepath.Join(t.TempDir(), "joined")
+		_, err := runTest(t, nil, "join", "-o", out, manifest)
+		assert.NoError(t, err)
+		joined, err := os.ReadFile(out)
+		assert.NoError(t, err)
+		assert.Equal(t, data, joined)
+	})
+
+	t.Run("join to stdout", func(t *testing.T) {
+		out, err := runTest(t, nil, "join", manifest)
+		assert.NoError(t, err)
+		assert.Equal(t, data, []byte(out))
+	})
+
+	t.Run("split stdin", func(t *testing.T) {
+		other := t.TempDir()
+		_, err := runTest(t, data, "split", "-avg", "64KiB", "-o", other)
+		assert.NoError(t, err)
+		fromStdin, err := readManifest(filepath.Join(other, "stdin"+manifestSuffix))
+		assert.NoError(t, err)
+		assert.Equal(t, m, fromStdin)
+	})
+
+	t.Run("binary manifest in other directory", func(t *testing.T) {
+		encoded, err := m.MarshalBinary()
+		assert.NoError(t, err)
+		binary := filepath.Join(t.TempDir(), "manifest.bin")
+		assert.NoError(t, os.WriteFile(binary, encoded, 0o644))
+
+		_, err = runTest(t, nil, "join", binary)
+		assert.Error(t, err)
+		out, err := runTest(t, nil, "join", "-chunks", dir, binary)
+		assert.NoError(t, err)
+		assert.True(t, bytes.Equal(data, []byte(out)))
+	})
+
+	t.Run("corrupt chunk", func(t *testing.T) {
+		corrupt := t.TempDir()
+		_, err := runTest(t, nil, "split", "-avg", "64KiB", "-o", corrupt, path)
+		assert.NoError(t, err)
+		s, err := ae.NewFileStore(corrupt, nil)
+		assert.NoError(t, err)
+		ref := m.Chunks[len(m.Chunks)/2]
+		assert.NoError(t, s.Delete(ref.Hash))
+		assert.NoError(t, s.Put(ref.Hash, []byte("garbage")))
+
+		out := filepath.Join(t.TempDir(), "joined")
+		_, err = runTest(t, nil, "join", "-o", out, filepath.Join(corrupt, filepath.Base(path)+manifestSuffix))
+		assert.ErrorIs(t, err, ae.ErrCorruptChunk)
+		entries, err := os.ReadDir(filepath.Dir(out))
+		assert.NoError(t, err)
+		assert.Empty(t, entries)
+	})
+
+	t.Run("invalid usage", func(t *testing.T) {
+		_, err := runTest(t, nil, "split", path)
+		assert.Equal(t, errUsage, err)
+		_, err = runTest(t, nil, "join")
+		assert.Equal(t, errUsage, err)
+	})
+}
+
+// tarFile is a file in an archive written by writeTar.
+type tarFile struct {
+	name string
+	data []byte
+}
+
+// writeTar writes files into a tar archive and returns it along with the offset of every entry.
+func writeTar(t *testing.T, files ...tarFile) ([]byte, []int64) {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	var offsets []int64
+	for _, f := range files {
+		assert.NoError(t, tw.Flush())
+		offsets = append(offsets, int64(buf.Len()))
+		hdr := &tar.Header{Name: f.name, Mode: 0o644, Size: int64(len(f.data)), ModTime: time.Unix(1e9, 0)}
+		assert.NoError(t, tw.WriteHeader(hdr))
+		_, err := tw.Write(f.data)
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, tw.Close())
+	return buf.Bytes(), offsets
+}
+
+func TestRun_splitTar(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	random := func(n int) []byte {
+		b := make([]byte, n)
+		rnd.Read(b)
+		return b
+	}
+	shared := tarFile{"shared", random(300 << 10)}
+	a, offsetsA := writeTar(t, tarFile{"a", random(100<<10 + 7)}, shared, tarFile{"empty", nil})
+	b, offsetsB := writeTar(t, tarFile{"b", random(37<<10 + 123)}, tarFile{"dir/", nil}, shared)
+
+	split := func(archive []byte, args ...string) *ae.Manifest {
+		dir := t.TempDir()
+		_, err := runTest(t, archive, append([]string{"split", "-avg", "16KiB", "-o", dir}, args...)...)
+		assert.NoError(t, err)
+		m, err := readManifest(filepath.Join(dir, "stdin"+manifestSuffix))
+		assert.NoError(t, err)
+		assert.NoError(t, m.Validate())
+		assert.Equal(t, int64(len(archive)), m.Size)
+		return m
+	}
+	ma, mb := split(a, "-tar"), split(b, "-tar")
+
+	// Every entry starts a chunk.
+	for _, tc := range []struct {
+		m       *ae.Manifest
+		offsets []int64
+	}{{ma, offsetsA}, {mb, offsetsB}} {
+		starts := make(map[int64]bool)
+		for _, ref := range tc.m.Chunks {
+			starts[ref.Offset] = true
+		}
+		for _, offset := range tc.offsets {
+			assert.True(t, starts[offset], "no chunk starts at entry at offset %d", offset)
+		}
+	}
+
+	// All chunks of the shared entry reappear in the other archive.
+	hashes := make(map[string]bool)
+	for _, ref := range mb.Chunks {
+		hashes[string(ref.Hash)] = true
+	}
+	var found int
+	for _, ref := range ma.Chunks {
+		if ref.Offset >= offsetsA[1] && ref.Offset+ref.Length <= offsetsA[2] {
+			assert.True(t, hashes[string(ref.Hash)], "chunk at offset %d is not shared", ref.Offset)
+			found++
+		}
+	}
+	assert.Greater(t, found, 1)
+
+	t.Run("join", func(t *testing.T) {
+		dir := t.TempDir()
+		_, err := runTest(t, a, "split", "-tar", "-o", dir)
+		assert.NoError(t, err)
+		out, err := runTest(t, nil, "join", filepath.Join(dir, "stdin"+manifestSuffix))
+		assert.NoError(t, err)
+		assert.Equal(t, a, []byte(out))
+	})
+
+	t.Run("not a tar stream", func(t *testing.T) {
+		_, err := runTest(t, random(4096), "split", "-tar", "-o", t.TempDir())
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	ae "github.com/mg98/ae-chunker-go"
+	"io"
+	"math"
+	"sort"
+	"strings"
+	"text/tabwriter"
+)
+
+func init() {
+	commands["stats"] = command{usage: "stats [flags] [file]", run: runStats}
+}
+
+// sizeStats summarize a distribution of chunk sizes.
+type sizeStats struct {
+	sizes  []int64 // sorted
+	total  int64
+	mean   float64
+	stddev float64
+}
+
+// newSizeStats computes the statistics of sizes, which it sorts in place.
+func newSizeStats(sizes []int64) sizeStats {
+	sort.Slice(sizes, func(i, j int) bool { return sizes[i] < sizes[j] })
+	s := sizeStats{sizes: sizes}
+	if len(sizes) == 0 {
+		return s
+	}
+	for _, n := range sizes {
+		s.total += n
+	}
+	s.mean = float64(s.total) / float64(len(sizes))
+	var sq float64
+	for _, n := range sizes {
+		d := float64(n) - s.mean
+		sq += d * d
+	}
+	s.stddev = math.Sqrt(sq / float64(len(sizes)))
+	return s
+}
+
+// percentile returns the p-th percentile (0 to 100) of the sizes by the nearest-rank method.
+func (s sizeStats) percentile(p float64) int64 {
+	if len(s.sizes) == 0 {
+		return 0
+	}
+	rank := int(math.Ceil(p / 100 * float64(len(s.sizes))))
+	if rank < 1 {
+		rank = 1
+	}
+	return s.sizes[rank-1]
+}
+
+// histogram counts the sizes in n buckets of equal width between the smallest and the largest size.
+// It returns the lower bound of every bucket, the width of the buckets and the counts.
+func (s sizeStats) histogram(n int) (lower []int64, width int64, counts []int) {
+	if len(s.sizes) == 0 {
+		return nil, 0, nil
+	}
+	lo, hi := s.sizes[0], s.sizes[len(s.sizes)-1]
+	width = (hi - lo + int64(n)) / int64(n)
+	if width < 1 {
+		width = 1
+	}
+	n = int((hi-lo)/width) + 1
+	lower = make([]int64, n)
+	counts = make([]int, n)
+	for i := range lower {
+		lower[i] = lo + int64(i)*width
+	}
+	for _, size := range s.sizes {
+		counts[(size-lo)/width]++
+	}
+	return lower, width, counts
+}
+
+// runStats prints the distribution of the chunk sizes of a file.
+func runStats(e *env, args []string) error {
+	var cf chunkFlags
+	fs := newFlagSet(e, "stats", "aechunk stats [flags] [file]")
+	cf.register(fs)
+	buckets := fs.Int("buckets", 20, "number of histogram buckets")
+	width := fs.Int("width", 50, "width of the histogram bars in characters")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	if fs.NArg() > 1 || *buckets < 1 || *width < 1 {
+		fs.Usage()
+		return errUsage
+	}
+	name := "-"
+	if fs.NArg() == 1 {
+		name = fs.Arg(0)
+	}
+
+	f, err := openInput(e, name)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	ch := ae.NewChunker(f, cf.options())
+	var sizes []int64
+	for {
+		c, err := ch.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		sizes = append(sizes, int64(len(c.Data)))
+		ae.ReleaseChunk(c)
+	}
+	s := newSizeStats(sizes)
+	cs := ch.Stats()
+
+	w := tabwriter.NewWriter(e.stdout, 0, 8, 2, ' ', 0)
+	fmt.Fprintf(w, "size:\t%d bytes\n", s.total)
+	fmt.Fprintf(w, "chunks:\t%d\n", len(sizes))
+	if len(sizes) == 0 {
+		return w.Flush()
+	}
+	fmt.Fprintf(w, "mean:\t%.0f\n", s.mean)
+	fmt.Fprintf(w, "median:\t%d\n", s.percentile(50))
+	fmt.Fprintf(w, "stddev:\t%.0f\n", s.stddev)
+	fmt.Fprintf(w, "min:\t%d\n", sizes[0])
+	fmt.Fprintf(w, "max:\t%d\n", sizes[len(sizes)-1])
+	for _, p := range []float64{1, 5, 25, 75, 95, 99} {
+		fmt.Fprintf(w, "p%g:\t%d\n", p, s.percentile(p))
+	}
+	fmt.Fprintf(w, "cuts:\t%d at extremum, %d aligned, %d fixed, %d at max size (%.1f%%), %d at end of input\n",
+		cs.ExtremumCuts, cs.AlignedCuts, cs.FixedCuts, cs.MaxSizeCuts, 100*cs.TruncationRate(), cs.EOFCuts)
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(e.stdout)
+	lower, bucketWidth, counts := s.histogram(*buckets)
+	most := 0
+	for _, n := range counts {
+		if n > most {
+			most = n
+		}
+	}
+	w = tabwriter.NewWriter(e.stdout, 0, 8, 1, ' ', tabwriter.AlignRight)
+	for i, n := range counts {
+		bar := strings.Repeat("#", (n**width+most-1)/most)
+		fmt.Fprintf(w, "%d\t- %d\t%d\t %s\n", lower[i], lower[i]+bucketWidth-1, n, bar)
+	}
+	return w.Flush()
+}
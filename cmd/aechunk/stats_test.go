@@ -0,0 +1,61 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"strings"
+	"testing"
+)
+
+func TestSizeStats(t *testing.T) {
+	s := newSizeStats([]int64{9, 1, 5, 3, 7, 2, 4, 8, 6, 10})
+	assert.Equal(t, []int64{1, 2, 3, 4, 5, 6, 7, 8, 9, 10}, s.sizes)
+	assert.Equal(t, int64(55), s.total)
+	assert.Equal(t, 5.5, s.mean)
+	assert.InDelta(t, 2.872, s.stddev, 0.001)
+	assert.Equal(t, int64(1), s.percentile(0))
+	assert.Equal(t, int64(1), s.percentile(10))
+	assert.Equal(t, int64(5), s.percentile(50))
+	assert.Equal(t, int64(10), s.percentile(95))
+	assert.Equal(t, int64(10), s.percentile(100))
+
+	lower, width, counts := s.histogram(3)
+	assert.Equal(t, int64(4), width)
+	assert.Equal(t, []int64{1, 5, 9}, lower)
+	assert.Equal(t, []int{4, 4, 2}, counts)
+
+	lower, width, counts = newSizeStats([]int64{7, 7, 7}).histogram(20)
+	assert.Equal(t, int64(1), width)
+	assert.Equal(t, []int64{7}, lower)
+	assert.Equal(t, []int{3}, counts)
+
+	empty := newSizeStats(nil)
+	assert.Equal(t, int64(0), empty.percentile(50))
+	lower, _, _ = empty.histogram(10)
+	assert.Empty(t, lower)
+}
+
+func TestRun_stats(t *testing.T) {
+	path, data := randomFile(t, 1<<20, 1)
+
+	out, err := runTest(t, nil, "stats", "-avg", "32KiB", "-buckets", "5", path)
+	assert.NoError(t, err)
+	assert.Contains(t, out, "size:    1048576 bytes\n")
+	for _, label := range []string{"chunks:", "mean:", "median:", "stddev:", "min:", "max:", "p99:", "cuts:"} {
+		assert.Contains(t, out, "\n"+label)
+	}
+	histogram := strings.SplitN(out, "\n\n", 2)[1]
+	assert.Len(t, strings.Split(strings.TrimSpace(histogram), "\n"), 5)
+
+	fromStdin, err := runTest(t, data, "stats", "-avg", "32KiB", "-buckets", "5")
+	assert.NoError(t, err)
+	assert.Equal(t, out, fromStdin)
+
+	out, err = runTest(t, nil, "stats")
+	assert.NoError(t, err)
+	assert.Equal(t, "size:    0 bytes\nchunks:  0\n", out)
+
+	_, err = runTest(t, nil, "stats", "-buckets", "0", path)
+	assert.Equal(t, errUsage, err)
+	_, err = runTest(t, nil, "stats", path, path)
+	assert.Equal(t, errUsage, err)
+}
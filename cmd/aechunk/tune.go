@@ -0,0 +1,168 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	ae "github.com/mg98/ae-chunker-go"
+	"io"
+	"math"
+	"math/rand"
+	"os"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+)
+
+func init() {
+	commands["tune"] = command{usage: "tune [flags] dir...", run: runTune}
+}
+
+// candidate is a combination of parameters evaluated by tune.
+type candidate struct {
+	avg, max int64
+	report   *ae.DedupReport
+
+	// chunks is the number of chunks extrapolated from the sample to all files.
+	chunks int64
+}
+
+// savings returns the share of bytes saved by deduplication.
+func (c candidate) savings() float64 {
+	return savedPercent(c.report.DedupStats) / 100
+}
+
+// better reports whether c is a better recommendation than other.
+// With a target for the savings, fewer chunks are better, otherwise higher savings.
+func (c candidate) better(other candidate, targetDedup float64) bool {
+	if targetDedup > 0 && c.chunks != other.chunks {
+		return c.chunks < other.chunks
+	}
+	if c.savings() != other.savings() {
+		return c.savings() > other.savings()
+	}
+	return c.chunks < other.chunks
+}
+
+// sampleFiles returns readers for a random sample of files of up to size bytes in total,
+// the last of which may be truncated, along with the number of sampled bytes.
+// If size is 0, all files are sampled.
+func sampleFiles(files []string, sizes []int64, size int64, seed int64) ([]io.Reader, int64) {
+	order := rand.New(rand.NewSource(seed)).Perm(len(files))
+	var readers []io.Reader
+	var sampled int64
+	for _, i := range order {
+		if size > 0 && sampled >= size {
+			break
+		}
+		n := sizes[i]
+		if size > 0 && n > size-sampled {
+			n = size - sampled
+		}
+		readers = append(readers, io.LimitReader(&lazyFile{name: files[i]}, n))
+		sampled += n
+	}
+	return readers, sampled
+}
+
+// parseFactors parses a comma-separated list of ratios of the maximum to the average size.
+func parseFactors(s string) ([]int64, error) {
+	var factors []int64
+	for _, field := range strings.Split(s, ",") {
+		f, err := strconv.ParseInt(field, 10, 64)
+		if err != nil || f < 1 {
+			return nil, fmt.Errorf("invalid factor %q", field)
+		}
+		factors = append(factors, f)
+	}
+	return factors, nil
+}
+
+// runTune sweeps the average and maximum size over a sample of the files in the given directories
+// and recommends the parameters meeting a target for the deduplication savings or the number of chunks.
+func runTune(e *env, args []string) error {
+	fs := newFlagSet(e, "tune", "aechunk tune [flags] dir...")
+	targetDedup := fs.Float64("target-dedup", 0, "minimum share of bytes to be saved by deduplication, e.g. 0.3")
+	maxChunks := fs.Int64("max-chunks", 0, "maximum number of chunks of all files, extrapolated from the sample (default no limit)")
+	sizes := sizeListValue{4 << 10, 16 << 10, 64 << 10, 256 << 10, 1 << 20}
+	fs.Var(&sizes, "sizes", "comma-separated average sizes to sweep")
+	factorList := fs.String("factors", "2,4,8", "comma-separated ratios of the maximum to the average size to sweep")
+	var mode modeValue
+	fs.Var(&mode, "mode", "extremum to cut at, max or min (default max)")
+	sample := sizeValue(256 << 20)
+	fs.Var(&sample, "sample", "number of bytes to sample from the files, or 0 for all")
+	seed := fs.Int64("seed", 1, "seed of the sample")
+	if err := parseFlags(fs, args); err != nil {
+		return err
+	}
+	factors, err := parseFactors(*factorList)
+	if err != nil || fs.NArg() == 0 || *targetDedup < 0 || *targetDedup >= 1 || *maxChunks < 0 {
+		fs.Usage()
+		return errUsage
+	}
+
+	files, err := walkFiles(fs.Args())
+	if err != nil {
+		return err
+	}
+	fileSizes := make([]int64, len(files))
+	var total int64
+	for i, name := range files {
+		info, err := os.Stat(name)
+		if err != nil {
+			return err
+		}
+		fileSizes[i] = info.Size()
+		total += info.Size()
+	}
+
+	var candidates []candidate
+	var sampled int64
+	for _, avg := range sizes {
+		for _, factor := range factors {
+			var readers []io.Reader
+			readers, sampled = sampleFiles(files, fileSizes, int64(sample), *seed)
+			c := candidate{avg: avg, max: avg * factor}
+			c.report, err = ae.EstimateDedup(readers, &ae.Options{AverageSize: c.avg, MaxSize: c.max, Mode: ae.Extremum(mode)})
+			if err != nil {
+				return err
+			}
+			c.chunks = c.report.Chunks
+			if sampled > 0 {
+				c.chunks = int64(math.Round(float64(c.report.Chunks) * float64(total) / float64(sampled)))
+			}
+			candidates = append(candidates, c)
+		}
+	}
+
+	best := -1
+	for i, c := range candidates {
+		if c.savings() < *targetDedup || (*maxChunks > 0 && c.chunks > *maxChunks) {
+			continue
+		}
+		if best < 0 || c.better(candidates[best], *targetDedup) {
+			best = i
+		}
+	}
+
+	fmt.Fprintf(e.stdout, "files:  %d (%d bytes, %d sampled)\n\n", len(files), total, sampled)
+	w := tabwriter.NewWriter(e.stdout, 0, 8, 2, ' ', tabwriter.AlignRight)
+	fmt.Fprintln(w, "AVG\tMAX\tCHUNKS\tSAVINGS\t\t")
+	for i, c := range candidates {
+		mark := ""
+		if i == best {
+			mark = "*"
+		}
+		fmt.Fprintf(w, "%s\t%s\t%d\t%.2f%%\t%s\t\n", formatSize(c.avg), formatSize(c.max), c.chunks, 100*c.savings(), mark)
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintln(e.stdout)
+	if best < 0 {
+		return errors.New("no parameters meet the target")
+	}
+	c := candidates[best]
+	fmt.Fprintf(e.stdout, "recommended: -avg %s -max %s (%.2f%% savings, %d chunks)\n",
+		formatSize(c.avg), formatSize(c.max), 100*c.savings(), c.chunks)
+	return nil
+}
@@ -0,0 +1,60 @@
+package main
+
+import (
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestRun_tune(t *testing.T) {
+	// Every file shares its first half with the others.
+	dir := t.TempDir()
+	rnd := rand.New(rand.NewSource(1))
+	shared := make([]byte, 256<<10)
+	rnd.Read(shared)
+	for _, name := range []string{"a", "b", "c", "d"} {
+		data := make([]byte, 512<<10)
+		copy(data, shared)
+		rnd.Read(data[len(shared):])
+		assert.NoError(t, os.WriteFile(filepath.Join(dir, name), data, 0o644))
+	}
+	args := []string{"tune", "-sizes", "4KiB,16KiB,64KiB", "-factors", "2,4", dir}
+
+	t.Run("target dedup", func(t *testing.T) {
+		out, err := runTest(t, nil, append(args, "-target-dedup", "0.3")...)
+		assert.NoError(t, err)
+		assert.Contains(t, out, "files:  4 (2097152 bytes, 2097152 sampled)")
+		lines := strings.Split(out, "\n")
+		assert.Equal(t, []string{"AVG", "MAX", "CHUNKS", "SAVINGS"}, strings.Fields(lines[2]))
+		assert.Equal(t, 1, strings.Count(out, "*"))
+		assert.Contains(t, out, "recommended: -avg 16KiB -max ")
+	})
+
+	t.Run("chunk budget", func(t *testing.T) {
+		out, err := runTest(t, nil, append(args, "-max-chunks", "100")...)
+		assert.NoError(t, err)
+		assert.Contains(t, out, "recommended: -avg 64KiB -max ")
+	})
+
+	t.Run("sample", func(t *testing.T) {
+		out, err := runTest(t, nil, append(args, "-sample", "1MiB")...)
+		assert.NoError(t, err)
+		assert.Contains(t, out, "files:  4 (2097152 bytes, 1048576 sampled)")
+	})
+
+	t.Run("unreachable target", func(t *testing.T) {
+		out, err := runTest(t, nil, append(args, "-target-dedup", "0.9")...)
+		assert.EqualError(t, err, "no parameters meet the target")
+		assert.NotContains(t, out, "*")
+	})
+
+	t.Run("usage", func(t *testing.T) {
+		_, err := runTest(t, nil, "tune")
+		assert.ErrorIs(t, err, errUsage)
+		_, err = runTest(t, nil, "tune", "-factors", "0", dir)
+		assert.ErrorIs(t, err, errUsage)
+	})
+}
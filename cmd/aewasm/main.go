@@ -0,0 +1,74 @@
+//go:build js && wasm
+
+// Command aewasm exposes the chunker to JavaScript when compiled to WebAssembly,
+// so that browser-based clients chunk files exactly like a Go backend before uploading them.
+//
+// Build and load it with the wasm_exec.js shipped with Go:
+//
+//	GOOS=js GOARCH=wasm go build -o ae.wasm github.com/mg98/ae-chunker-go/cmd/aewasm
+//
+// It defines the global function aeChunk(data, parameters), which takes the data as Uint8Array or ArrayBuffer
+// and the parameters of a manifest, e.g. {AverageSize: 65536, Mode: "max", Hash: "sha256"}.
+// It returns {chunks: [{offset, length, hash}, ...]} with the hashes in hex, or {error} if the parameters are invalid.
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	ae "github.com/mg98/ae-chunker-go"
+	"io"
+	"syscall/js"
+)
+
+func main() {
+	js.Global().Set("aeChunk", js.FuncOf(func(this js.Value, args []js.Value) interface{} {
+		if len(args) == 0 {
+			return map[string]interface{}{"error": "aeChunk: missing data"}
+		}
+		params := js.Undefined()
+		if len(args) > 1 {
+			params = args[1]
+		}
+		chunks, err := chunk(args[0], params)
+		if err != nil {
+			return map[string]interface{}{"error": err.Error()}
+		}
+		return map[string]interface{}{"chunks": chunks}
+	}))
+	select {}
+}
+
+// chunk chunks the Uint8Array or ArrayBuffer data with the manifest parameters params, if defined.
+func chunk(data, params js.Value) ([]interface{}, error) {
+	p := ae.Parameters{Hash: "sha256"}
+	if params.Type() == js.TypeObject {
+		text := js.Global().Get("JSON").Call("stringify", params).String()
+		if err := json.Unmarshal([]byte(text), &p); err != nil {
+			return nil, err
+		}
+	}
+	if !data.InstanceOf(js.Global().Get("Uint8Array")) {
+		data = js.Global().Get("Uint8Array").New(data)
+	}
+	buf := make([]byte, data.Length())
+	js.CopyBytesToGo(buf, data)
+
+	chunks := []interface{}{}
+	ch := ae.NewChunker(bytes.NewReader(buf), p.Options())
+	for {
+		c, err := ch.Next()
+		if err == io.EOF {
+			return chunks, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		chunks = append(chunks, map[string]interface{}{
+			"offset": c.Offset,
+			"length": len(c.Data),
+			"hash":   hex.EncodeToString(c.Sum),
+		})
+		ae.ReleaseChunk(c)
+	}
+}
@@ -0,0 +1,40 @@
+//go:build js && wasm
+
+package main
+
+import (
+	"bytes"
+	"encoding/hex"
+	ae "github.com/mg98/ae-chunker-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"math/rand"
+	"syscall/js"
+	"testing"
+)
+
+func TestChunk(t *testing.T) {
+	data := make([]byte, 1<<20)
+	rand.New(rand.NewSource(1)).Read(data)
+	array := js.Global().Get("Uint8Array").New(len(data))
+	js.CopyBytesToJS(array, data)
+	params := js.ValueOf(map[string]interface{}{"AverageSize": 16 * 1024, "Mode": "min", "Hash": "sha256"})
+
+	m, err := ae.BuildManifest(bytes.NewReader(data), &ae.Options{AverageSize: 16 * 1024, Mode: ae.MIN})
+	require.NoError(t, err)
+	for _, v := range []js.Value{array, array.Get("buffer")} {
+		chunks, err := chunk(v, params)
+		require.NoError(t, err)
+		require.Len(t, chunks, len(m.Chunks))
+		for i, ref := range m.Chunks {
+			assert.Equal(t, map[string]interface{}{
+				"offset": ref.Offset,
+				"length": int(ref.Length),
+				"hash":   hex.EncodeToString(ref.Hash),
+			}, chunks[i])
+		}
+	}
+
+	_, err = chunk(array, js.ValueOf(map[string]interface{}{"Mode": "median"}))
+	assert.Error(t, err)
+}
@@ -0,0 +1,189 @@
+package ae
+
+import (
+	"encoding/binary"
+	"fmt"
+	"github.com/klauspost/compress/zstd"
+	"github.com/pierrec/lz4/v4"
+)
+
+// Codec is a compression algorithm for chunks.
+type Codec uint8
+
+const (
+	// CodecNone stores chunks uncompressed.
+	CodecNone Codec = iota
+
+	// CodecZstd compresses chunks with Zstandard.
+	CodecZstd
+
+	// CodecLZ4 compresses chunks with LZ4, trading compression ratio for speed.
+	CodecLZ4
+)
+
+// String returns the name of the codec.
+func (c Codec) String() string {
+	switch c {
+	case CodecNone:
+		return "none"
+	case CodecZstd:
+		return "zstd"
+	case CodecLZ4:
+		return "lz4"
+	default:
+		return fmt.Sprintf("Codec(%d)", uint8(c))
+	}
+}
+
+const (
+	// compressSampleSize is the size of the sample compressed ahead of large chunks
+	// to detect incompressible data early.
+	compressSampleSize = 16 * 1024
+
+	// minCompressSavings is the minimum fraction of bytes, as a power of two, that compression must save
+	// for a chunk to be stored compressed, i.e. 1/32.
+	minCompressSavings = 5
+)
+
+// CompressedStore is a ChunkStore that compresses chunks before they are written to an underlying store.
+// Each stored chunk starts with a header recording its codec, so chunks remain readable if the codec is changed.
+// Chunks that do not compress well, such as already compressed or encrypted data, are stored as they are.
+//
+// To combine compression with encryption, the CompressedStore must wrap the EncryptedStore, not vice versa.
+type CompressedStore struct {
+	store   ChunkStore
+	codec   Codec
+	encoder *zstd.Encoder
+	decoder *zstd.Decoder
+}
+
+// NewCompressedStore returns a CompressedStore compressing chunks with codec before writing them to s.
+func NewCompressedStore(s ChunkStore, codec Codec) (*CompressedStore, error) {
+	if codec > CodecLZ4 {
+		return nil, fmt.Errorf("ae: unknown codec %d", codec)
+	}
+	return newCompressedStore(s, codec, nil)
+}
+
+// NewDictCompressedStore returns a CompressedStore compressing chunks with zstd and the dictionary d
+// before writing them to s. Chunks compressed this way can only be read back with the same dictionary.
+func NewDictCompressedStore(s ChunkStore, d *Dictionary) (*CompressedStore, error) {
+	return newCompressedStore(s, CodecZstd, d)
+}
+
+// newCompressedStore returns a CompressedStore with an optional zstd dictionary.
+func newCompressedStore(s ChunkStore, codec Codec, d *Dictionary) (*CompressedStore, error) {
+	var eopts []zstd.EOption
+	var dopts []zstd.DOption
+	if d != nil {
+		eopts = append(eopts, zstd.WithEncoderDictRaw(d.ID, d.Content))
+		dopts = append(dopts, zstd.WithDecoderDictRaw(d.ID, d.Content))
+	}
+	encoder, err := zstd.NewWriter(nil, eopts...)
+	if err != nil {
+		return nil, err
+	}
+	decoder, err := zstd.NewReader(nil, dopts...)
+	if err != nil {
+		return nil, err
+	}
+	return &CompressedStore{store: s, codec: codec, encoder: encoder, decoder: decoder}, nil
+}
+
+// Put compresses data and stores it in the underlying store.
+func (s *CompressedStore) Put(hash []byte, data []byte) error {
+	return s.store.Put(hash, s.encode(data))
+}
+
+// Get fetches the chunk stored under hash from the underlying store and decompresses it.
+func (s *CompressedStore) Get(hash []byte) ([]byte, error) {
+	stored, err := s.store.Get(hash)
+	if err != nil {
+		return nil, err
+	}
+	return s.decode(stored)
+}
+
+// encode compresses data, prefixed with the codec used.
+func (s *CompressedStore) encode(data []byte) []byte {
+	codec := s.codec
+	if codec != CodecNone && len(data) > 2*compressSampleSize {
+		if sample := data[:compressSampleSize]; !s.worthIt(sample, s.compress(codec, nil, sample)) {
+			codec = CodecNone
+		}
+	}
+	var buf []byte
+	if codec != CodecNone {
+		buf = s.compress(codec, []byte{byte(codec)}, data)
+	}
+	if buf == nil || !s.worthIt(data, buf[1:]) {
+		buf = append([]byte{byte(CodecNone)}, data...)
+	}
+	return buf
+}
+
+// decode decompresses a chunk encoded by encode.
+func (s *CompressedStore) decode(stored []byte) ([]byte, error) {
+	if len(stored) == 0 {
+		return nil, ErrCorruptChunk
+	}
+	payload := stored[1:]
+	switch Codec(stored[0]) {
+	case CodecNone:
+		return payload, nil
+	case CodecZstd:
+		data, err := s.decoder.DecodeAll(payload, nil)
+		if err != nil {
+			return nil, ErrCorruptChunk
+		}
+		return data, nil
+	case CodecLZ4:
+		size, n := binary.Uvarint(payload)
+		if n <= 0 || size > uint64(maxInt) {
+			return nil, ErrCorruptChunk
+		}
+		data := make([]byte, size)
+		if k, err := lz4.UncompressBlock(payload[n:], data); err != nil || k != len(data) {
+			return nil, ErrCorruptChunk
+		}
+		return data, nil
+	default:
+		return nil, ErrCorruptChunk
+	}
+}
+
+// Has reports whether a chunk is stored under hash.
+func (s *CompressedStore) Has(hash []byte) (bool, error) {
+	return s.store.Has(hash)
+}
+
+// Delete removes the chunk stored under hash.
+func (s *CompressedStore) Delete(hash []byte) error {
+	return s.store.Delete(hash)
+}
+
+// Walk calls fn for every chunk in the underlying store.
+func (s *CompressedStore) Walk(fn func(hash []byte, size int64) error) error {
+	return walkStore(s.store, fn)
+}
+
+// compress appends data compressed with codec to dst.
+// It returns nil if LZ4 fails to compress the data.
+func (s *CompressedStore) compress(codec Codec, dst []byte, data []byte) []byte {
+	if codec == CodecZstd {
+		return s.encoder.EncodeAll(data, dst)
+	}
+	dst = appendUvarint(dst, uint64(len(data)))
+	n := len(dst)
+	dst = append(dst, make([]byte, lz4.CompressBlockBound(len(data)))...)
+	k, err := lz4.CompressBlock(data, dst[n:], nil)
+	if err != nil || k == 0 {
+		return nil
+	}
+	return dst[:n+k]
+}
+
+// worthIt reports whether compressing data into compressed saves enough space.
+func (s *CompressedStore) worthIt(data []byte, compressed []byte) bool {
+	return len(compressed) > 0 && len(compressed) <= len(data)-len(data)>>minCompressSavings
+}
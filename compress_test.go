@@ -0,0 +1,71 @@
+package ae
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestCompressedStore(t *testing.T) {
+	compressible := bytes.Repeat([]byte("the quick brown fox jumps over the lazy dog. "), 4096)
+	incompressible := testFile[:len(compressible)]
+
+	for _, codec := range []Codec{CodecNone, CodecZstd, CodecLZ4} {
+		t.Run(codec.String(), func(t *testing.T) {
+			s, err := NewCompressedStore(NewMemoryStore(0), codec)
+			assert.NoError(t, err)
+			testChunkStore(t, s)
+
+			backend := NewMemoryStore(0)
+			s, err = NewCompressedStore(backend, codec)
+			assert.NoError(t, err)
+			for _, data := range [][]byte{compressible, incompressible, {}, []byte("tiny")} {
+				sum := sha256.Sum256(data)
+				assert.NoError(t, s.Put(sum[:], data))
+				stored, err := s.Get(sum[:])
+				assert.NoError(t, err)
+				assert.Equal(t, len(data), len(stored))
+				assert.True(t, bytes.Equal(data, stored))
+
+				raw, err := backend.Get(sum[:])
+				assert.NoError(t, err)
+				if codec != CodecNone && bytes.Equal(data, compressible) {
+					assert.Equal(t, byte(codec), raw[0])
+					assert.Less(t, len(raw), len(data)/10)
+				} else {
+					// stored as is, behind the header
+					assert.Equal(t, byte(CodecNone), raw[0])
+					assert.Equal(t, len(data)+1, len(raw))
+				}
+			}
+		})
+	}
+
+	t.Run("codec changed", func(t *testing.T) {
+		backend := NewMemoryStore(0)
+		zs, _ := NewCompressedStore(backend, CodecZstd)
+		ls, _ := NewCompressedStore(backend, CodecLZ4)
+		sum := sha256.Sum256(compressible)
+		assert.NoError(t, zs.Put(sum[:], compressible))
+		data, err := ls.Get(sum[:])
+		assert.NoError(t, err)
+		assert.Equal(t, compressible, data)
+	})
+
+	t.Run("corrupt", func(t *testing.T) {
+		backend := NewMemoryStore(0)
+		s, _ := NewCompressedStore(backend, CodecZstd)
+		assert.NoError(t, backend.Put([]byte{1}, []byte{byte(CodecZstd), 1, 2, 3}))
+		assert.NoError(t, backend.Put([]byte{2}, []byte{42}))
+		_, err := s.Get([]byte{1})
+		assert.Equal(t, ErrCorruptChunk, err)
+		_, err = s.Get([]byte{2})
+		assert.Equal(t, ErrCorruptChunk, err)
+	})
+
+	t.Run("unknown codec", func(t *testing.T) {
+		_, err := NewCompressedStore(NewMemoryStore(0), Codec(42))
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,147 @@
+// Package corpus generates deterministic synthetic datasets for evaluating chunking,
+// so that deduplication ratios and throughput can be measured and compared in CI
+// without shipping large binary fixtures. The same seed always yields the same data.
+package corpus
+
+import (
+	"encoding/binary"
+	"fmt"
+	"math/rand"
+	"sort"
+)
+
+// Kinds of datasets.
+const (
+	// Random data consists of uniformly distributed bytes and does not deduplicate.
+	Random = "random"
+
+	// Repetitive data is composed of blocks drawn from a small pool, repeated at unaligned offsets.
+	Repetitive = "repetitive"
+
+	// Text is made of English-like words and sentences with a skewed word distribution.
+	Text = "text"
+
+	// Compressed data resembles the output of a compressor or a media format:
+	// random payloads framed by small headers.
+	Compressed = "compressed"
+
+	// Zeros data consists of zero bytes only.
+	Zeros = "zeros"
+)
+
+// generators maps the kinds of datasets to their generator.
+var generators = map[string]func(rnd *rand.Rand, size int) []byte{
+	Random:     random,
+	Repetitive: repetitive,
+	Text:       text,
+	Compressed: compressed,
+	Zeros:      func(rnd *rand.Rand, size int) []byte { return make([]byte, size) },
+}
+
+// Kinds returns the names of all kinds of datasets in lexical order.
+func Kinds() []string {
+	kinds := make([]string, 0, len(generators))
+	for kind := range generators {
+		kinds = append(kinds, kind)
+	}
+	sort.Strings(kinds)
+	return kinds
+}
+
+// Generate returns size bytes of the given kind, derived from seed.
+func Generate(kind string, seed int64, size int) ([]byte, error) {
+	gen, ok := generators[kind]
+	if !ok {
+		return nil, fmt.Errorf("corpus: unknown kind %q", kind)
+	}
+	if size < 0 {
+		return nil, fmt.Errorf("corpus: negative size %d", size)
+	}
+	return gen(rand.New(rand.NewSource(seed)), size), nil
+}
+
+// random returns size random bytes.
+func random(rnd *rand.Rand, size int) []byte {
+	b := make([]byte, size)
+	rnd.Read(b)
+	return b
+}
+
+// repetitive concatenates blocks of 1 to 64 KiB drawn from a pool of 16 random blocks.
+func repetitive(rnd *rand.Rand, size int) []byte {
+	pool := make([][]byte, 16)
+	for i := range pool {
+		pool[i] = random(rnd, 1024+rnd.Intn(63*1024))
+	}
+	b := make([]byte, 0, size)
+	for len(b) < size {
+		b = append(b, pool[rnd.Intn(len(pool))]...)
+	}
+	return b[:size]
+}
+
+// words are the vocabulary of text, ordered by decreasing frequency.
+var words = []string{
+	"the", "of", "and", "to", "a", "in", "is", "that", "for", "it", "as", "was", "with", "be", "by",
+	"on", "not", "he", "this", "are", "or", "his", "from", "at", "which", "but", "have", "an", "they", "you",
+	"were", "her", "she", "there", "been", "one", "all", "we", "their", "has", "would", "when", "if", "so",
+	"no", "will", "more", "can", "time", "data", "chunk", "file", "system", "between", "boundary", "content",
+	"version", "storage", "algorithm", "extremum", "window", "deduplication", "asymmetric", "backup",
+	"network", "performance", "block", "hash", "index", "change", "value", "local", "maximum", "minimum",
+}
+
+// text writes sentences of words drawn from a Zipf distribution, broken into lines of about 72 characters.
+func text(rnd *rand.Rand, size int) []byte {
+	zipf := rand.NewZipf(rnd, 1.1, 2, uint64(len(words)-1))
+	b := make([]byte, 0, size+16)
+	line, sentence := 0, 0
+	for len(b) < size {
+		w := words[zipf.Uint64()]
+		if sentence == 0 {
+			w = string(w[0]-'a'+'A') + w[1:]
+		}
+		b = append(b, w...)
+		line += len(w)
+		sentence++
+		switch {
+		case sentence > 4 && rnd.Intn(8) == 0:
+			b = append(b, '.')
+			line++
+			sentence = 0
+			if rnd.Intn(6) == 0 {
+				b = append(b, '\n', '\n')
+				line = 0
+				continue
+			}
+		case rnd.Intn(12) == 0:
+			b = append(b, ',')
+			line++
+		}
+		if line > 72 {
+			b = append(b, '\n')
+			line = 0
+		} else {
+			b = append(b, ' ')
+			line++
+		}
+	}
+	return b[:size]
+}
+
+// compressedMagic starts every frame of compressed data.
+var compressedMagic = []byte{0x28, 0xb5, 0x2f, 0xfd}
+
+// compressed writes frames of random payloads between 4 and 128 KiB,
+// each preceded by a magic number and the little-endian length of the payload.
+func compressed(rnd *rand.Rand, size int) []byte {
+	b := make([]byte, 0, size+len(compressedMagic)+4)
+	for len(b) < size {
+		n := 4096 + rnd.Intn(124*1024)
+		var header [4]byte
+		binary.LittleEndian.PutUint32(header[:], uint32(n))
+		b = append(b, compressedMagic...)
+		b = append(b, header[:]...)
+		b = append(b, random(rnd, n)...)
+	}
+	return b[:size]
+}
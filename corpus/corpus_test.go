@@ -0,0 +1,89 @@
+package corpus
+
+import (
+	"bytes"
+	ae "github.com/mg98/ae-chunker-go"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"testing"
+	"unicode"
+)
+
+func TestGenerate(t *testing.T) {
+	assert.Equal(t, []string{Compressed, Random, Repetitive, Text, Zeros}, Kinds())
+
+	for _, kind := range Kinds() {
+		t.Run(kind, func(t *testing.T) {
+			data, err := Generate(kind, 1, 1<<20)
+			assert.NoError(t, err)
+			assert.Len(t, data, 1<<20)
+			again, _ := Generate(kind, 1, 1<<20)
+			assert.Equal(t, data, again, "data must be reproducible")
+			prefix, _ := Generate(kind, 1, 1000)
+			assert.Equal(t, data[:1000], prefix, "smaller sizes must be a prefix")
+			other, _ := Generate(kind, 2, 1<<20)
+			if kind != Zeros {
+				assert.NotEqual(t, data, other)
+			}
+			empty, err := Generate(kind, 1, 0)
+			assert.NoError(t, err)
+			assert.Empty(t, empty)
+		})
+	}
+
+	_, err := Generate("noise", 1, 100)
+	assert.Error(t, err)
+	_, err = Generate(Random, 1, -1)
+	assert.Error(t, err)
+}
+
+func TestGenerate_properties(t *testing.T) {
+	t.Run("text is printable", func(t *testing.T) {
+		data, _ := Generate(Text, 1, 1<<16)
+		for _, r := range string(data) {
+			assert.True(t, r == '\n' || unicode.IsPrint(r), "%q", r)
+		}
+		assert.Contains(t, string(data), ". ")
+	})
+
+	t.Run("compressed is framed", func(t *testing.T) {
+		data, _ := Generate(Compressed, 1, 1<<20)
+		assert.True(t, bytes.HasPrefix(data, compressedMagic))
+		assert.Greater(t, bytes.Count(data, compressedMagic), 1)
+	})
+
+	t.Run("deduplication", func(t *testing.T) {
+		ratio := func(kind string) float64 {
+			data, _ := Generate(kind, 1, 4<<20)
+			report, err := ae.EstimateDedup([]io.Reader{bytes.NewReader(data)}, &ae.Options{AverageSize: 4 * 1024})
+			assert.NoError(t, err)
+			return report.DedupRatio()
+		}
+		assert.InDelta(t, 1, ratio(Random), 0.01)
+		assert.InDelta(t, 1, ratio(Compressed), 0.01)
+		assert.Greater(t, ratio(Repetitive), 1.5)
+	})
+}
+
+func TestEdit(t *testing.T) {
+	base, _ := Generate(Random, 1, 1<<16)
+
+	edited := Edit(base, 1, 10, 100)
+	assert.Equal(t, edited, Edit(base, 1, 10, 100), "edits must be reproducible")
+	assert.NotEqual(t, base, edited)
+	assert.InDelta(t, len(base), len(edited), 10*100)
+	assert.Equal(t, base, Edit(base, 1, 0, 100))
+	assert.LessOrEqual(t, len(Edit(nil, 1, 5, 10)), 5*10)
+
+	versions := Versions(base, 1, 5, 3, 50)
+	assert.Len(t, versions, 5)
+	for i, v := range versions {
+		prev := base
+		if i > 0 {
+			prev = versions[i-1]
+		}
+		assert.NotEqual(t, prev, v)
+		assert.InDelta(t, len(prev), len(v), 3*50)
+	}
+	assert.Equal(t, versions, Versions(base, 1, 5, 3, 50))
+}
@@ -0,0 +1,63 @@
+package corpus
+
+import (
+	"math/rand"
+	"sort"
+)
+
+// Edit returns a copy of base with count random edits of 1 to size bytes each.
+// Every edit inserts random bytes, deletes bytes or overwrites them with random ones, with equal probability.
+// Edits never overlap, so fewer bytes may be deleted or replaced near the end of base.
+func Edit(base []byte, seed int64, count, size int) []byte {
+	return edit(rand.New(rand.NewSource(seed)), base, count, size)
+}
+
+// Versions returns n successive versions of base, each derived from its predecessor by count edits
+// of up to size bytes like Edit, as a series of backups of a changing file would see them.
+// The first version is derived from base, which is not part of the result.
+func Versions(base []byte, seed int64, n, count, size int) [][]byte {
+	rnd := rand.New(rand.NewSource(seed))
+	versions := make([][]byte, n)
+	for i := range versions {
+		base = edit(rnd, base, count, size)
+		versions[i] = base
+	}
+	return versions
+}
+
+// edit implements Edit with the random source rnd.
+func edit(rnd *rand.Rand, base []byte, count, size int) []byte {
+	if size < 1 {
+		size = 1
+	}
+	offsets := make([]int, count)
+	for i := range offsets {
+		offsets[i] = rnd.Intn(len(base) + 1)
+	}
+	sort.Ints(offsets)
+
+	out := make([]byte, 0, len(base)+count*size)
+	pos := 0
+	for _, offset := range offsets {
+		if offset < pos {
+			offset = pos
+		}
+		out = append(out, base[pos:offset]...)
+		pos = offset
+		n := 1 + rnd.Intn(size)
+		// The kind of the edit is 0 for an insertion, 1 for a deletion and 2 for a replacement.
+		kind := rnd.Intn(3)
+		if kind == 0 {
+			out = append(out, random(rnd, n)...)
+			continue
+		}
+		if n > len(base)-pos {
+			n = len(base) - pos
+		}
+		if kind == 2 {
+			out = append(out, random(rnd, n)...)
+		}
+		pos += n
+	}
+	return append(out, base[pos:]...)
+}
@@ -0,0 +1,232 @@
+package ae
+
+import (
+	"fmt"
+	"hash"
+)
+
+// DAGLayout is the shape of the UnixFS DAG a DAGBuilder arranges the chunks of a file in.
+type DAGLayout uint8
+
+const (
+	// Balanced fills every node with Fanout links before it starts the next one, so that all chunks
+	// are leaves at the same depth. It is the default layout of go-unixfs and `ipfs add`.
+	Balanced DAGLayout = iota
+
+	// Trickle links Fanout chunks directly from every node, followed by four subtrees of every depth below
+	// that of the node. It favours reading the file in order, e.g. streaming media, as the first chunks are
+	// close to the root. It is the layout of `ipfs add --trickle`.
+	Trickle
+)
+
+// String returns the name of the layout, i.e. "balanced" or "trickle".
+func (l DAGLayout) String() string {
+	switch l {
+	case Balanced:
+		return "balanced"
+	case Trickle:
+		return "trickle"
+	default:
+		return fmt.Sprintf("DAGLayout(%d)", uint8(l))
+	}
+}
+
+const (
+	// defaultFanout is the default maximum number of links of a node, as in go-unixfs.
+	defaultFanout = 174
+
+	// trickleRepeat is the number of subtrees of every depth of a trickle node, as in go-unixfs.
+	trickleRepeat = 4
+)
+
+// dagLink is a link of a UnixFS node to a chunk or another node.
+type dagLink struct {
+	cid []byte
+
+	// fileSize is the number of bytes of the file below the link.
+	fileSize uint64
+
+	// size is the cumulative size of the encoded nodes and chunks below the link.
+	size uint64
+}
+
+// DAGBuilder arranges the chunks of a file into a UnixFS DAG, i.e. a tree of DAG-PB nodes with the chunks
+// as raw leaves, and returns the CID of its root. The layouts match those of go-unixfs with raw leaves and CIDv1,
+// so that a file chunked alike, e.g. with the Fixed algorithm and an AverageSize of 256 KiB,
+// has the same root as `ipfs add --cid-version=1`, and other chunkings resolve in IPFS once their blocks are imported.
+//
+// The chunks must have been hashed with a hash with a multihash code, as for Chunk.CID,
+// which the nodes above them are hashed with as well.
+type DAGBuilder struct {
+	// Layout of the DAG (optional). It defaults to Balanced.
+	Layout DAGLayout
+
+	// Fanout is the maximum number of links to chunks or nodes below of every node (optional).
+	// It defaults to 174, as in go-unixfs.
+	Fanout int
+
+	// HashName of the hash of the chunks (optional). It defaults to the hash of the first chunk added,
+	// or "sha256" if there is none.
+	HashName string
+
+	// Node is called with the CID and encoding of every node built above the chunks (optional),
+	// e.g. to store them along with the chunks, without which the DAG cannot be resolved.
+	Node func(cid string, node []byte) error
+
+	leaves []dagLink
+}
+
+// Add appends c to the file. The chunks must be added in order.
+func (b *DAGBuilder) Add(c *Chunk) error {
+	if b.HashName == "" {
+		b.HashName = c.hashName
+	}
+	if c.hashName != b.HashName {
+		return fmt.Errorf("ae: chunk hashed with %q instead of %q", c.hashName, b.HashName)
+	}
+	mh, err := c.Multihash()
+	if err != nil {
+		return err
+	}
+	n := uint64(len(c.Data))
+	b.leaves = append(b.leaves, dagLink{cid: cidV1(rawCodec, mh), fileSize: n, size: n})
+	return nil
+}
+
+// Root builds the DAG of the chunks added and returns the CID of its root.
+// A file of a single chunk is its own root in the Balanced layout.
+func (b *DAGBuilder) Root() (string, error) {
+	d := &dagBuild{leaves: b.leaves, hashName: b.HashName, fanout: b.Fanout, visit: b.Node}
+	if d.hashName == "" {
+		d.hashName = "sha256"
+	}
+	if d.fanout <= 0 {
+		d.fanout = defaultFanout
+	}
+	if d.fanout < 2 {
+		return "", fmt.Errorf("ae: invalid DAG fanout %d", b.Fanout)
+	}
+	hasher, err := LookupHash(d.hashName)
+	if err != nil {
+		return "", err
+	}
+	d.h = hasher()
+
+	var root dagLink
+	switch b.Layout {
+	case Balanced:
+		root, err = d.balanced()
+	case Trickle:
+		root, err = d.trickle(-1)
+	default:
+		return "", fmt.Errorf("ae: invalid DAG layout %d", uint8(b.Layout))
+	}
+	if err != nil {
+		return "", err
+	}
+	return "b" + cidEncoding.EncodeToString(root.cid), nil
+}
+
+// dagBuild is the state of DAGBuilder.Root, which consumes the chunks in order.
+type dagBuild struct {
+	leaves   []dagLink
+	hashName string
+	fanout   int
+	visit    func(cid string, node []byte) error
+	h        hash.Hash
+
+	// next is the index of the next chunk to link.
+	next int
+}
+
+// done reports whether all chunks are linked.
+func (d *dagBuild) done() bool {
+	return d.next == len(d.leaves)
+}
+
+// leaf returns the link to the next chunk.
+func (d *dagBuild) leaf() dagLink {
+	l := d.leaves[d.next]
+	d.next++
+	return l
+}
+
+// node encodes a UnixFS file node with the given links, hands it to the Node callback and returns the link to it.
+func (d *dagBuild) node(links []dagLink) (dagLink, error) {
+	data := unixFSFileNode(links)
+	mh, err := d.digest(data)
+	if err != nil {
+		return dagLink{}, err
+	}
+	l := dagLink{cid: cidV1(dagPBCodec, mh), size: uint64(len(data))}
+	for _, c := range links {
+		l.fileSize += c.fileSize
+		l.size += c.size
+	}
+	if d.visit != nil {
+		if err := d.visit("b"+cidEncoding.EncodeToString(l.cid), data); err != nil {
+			return dagLink{}, err
+		}
+	}
+	return l, nil
+}
+
+// digest returns the multihash of data.
+func (d *dagBuild) digest(data []byte) ([]byte, error) {
+	d.h.Reset()
+	d.h.Write(data)
+	return multihash(d.hashName, d.h.Sum(nil))
+}
+
+// balanced builds the DAG like go-unixfs/importer/balanced: every time the tree is full,
+// it becomes the first child of a new root one level deeper, whose other children are filled in turn.
+func (d *dagBuild) balanced() (dagLink, error) {
+	if d.done() {
+		// an empty file is an empty raw block
+		mh, err := d.digest(nil)
+		return dagLink{cid: cidV1(rawCodec, mh)}, err
+	}
+	root := d.leaf()
+	for depth := 1; !d.done(); depth++ {
+		var err error
+		if root, err = d.fillBalanced([]dagLink{root}, depth); err != nil {
+			return dagLink{}, err
+		}
+	}
+	return root, nil
+}
+
+// fillBalanced adds full subtrees of the given depth to links until the node is full or all chunks are linked.
+func (d *dagBuild) fillBalanced(links []dagLink, depth int) (dagLink, error) {
+	for len(links) < d.fanout && !d.done() {
+		if depth == 1 {
+			links = append(links, d.leaf())
+			continue
+		}
+		child, err := d.fillBalanced(nil, depth-1)
+		if err != nil {
+			return dagLink{}, err
+		}
+		links = append(links, child)
+	}
+	return d.node(links)
+}
+
+// trickle builds the DAG like go-unixfs/importer/trickle: a node links up to fanout chunks,
+// then trickleRepeat subtrees of every depth from 1 up to maxDepth, exclusive, or without limit if it is negative.
+func (d *dagBuild) trickle(maxDepth int) (dagLink, error) {
+	var links []dagLink
+	for len(links) < d.fanout && !d.done() {
+		links = append(links, d.leaf())
+	}
+	for depth := 1; (maxDepth < 0 || depth < maxDepth) && !d.done(); depth++ {
+		for i := 0; i < trickleRepeat && !d.done(); i++ {
+			child, err := d.trickle(depth)
+			if err != nil {
+				return dagLink{}, err
+			}
+			links = append(links, child)
+		}
+	}
+	return d.node(links)
+}
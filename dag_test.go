@@ -0,0 +1,155 @@
+package ae
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"math/rand"
+	"testing"
+)
+
+// buildDAG adds the chunks of input to b and returns the CIDs of the chunks, the root and the nodes by binary CID.
+func buildDAG(t *testing.T, b *DAGBuilder, input []byte, opts *Options) ([][]byte, string, map[string][]byte) {
+	nodes := make(map[string][]byte)
+	b.Node = func(cid string, node []byte) error {
+		c, err := cidEncoding.DecodeString(cid[1:])
+		require.NoError(t, err)
+		nodes[string(c)] = node
+		return nil
+	}
+	var leaves [][]byte
+	ch := NewChunker(bytes.NewReader(input), opts)
+	for {
+		c, err := ch.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		require.NoError(t, b.Add(c))
+		mh, err := c.Multihash()
+		require.NoError(t, err)
+		leaves = append(leaves, cidV1(rawCodec, mh))
+	}
+	root, err := b.Root()
+	require.NoError(t, err)
+	return leaves, root, nodes
+}
+
+// dagLeaves returns the chunks below the node with the given CID in order along with their depths.
+func dagLeaves(t *testing.T, nodes map[string][]byte, cid []byte, depth int) ([][]byte, []int) {
+	node, ok := nodes[string(cid)]
+	if !ok {
+		assert.Equal(t, byte(rawCodec), cid[1])
+		return [][]byte{cid}, []int{depth}
+	}
+	sum := sha256.Sum256(node)
+	assert.Equal(t, sum[:], cid[4:])
+	var leaves [][]byte
+	var depths []int
+	for _, l := range unixFSLinks(t, node) {
+		ls, ds := dagLeaves(t, nodes, l, depth+1)
+		leaves = append(leaves, ls...)
+		depths = append(depths, ds...)
+	}
+	return leaves, depths
+}
+
+func TestDAGBuilder(t *testing.T) {
+	input := testFile[:MiB]
+	opts := &Options{AverageSize: 16 * 1024, SHA256: true}
+
+	t.Run("balanced", func(t *testing.T) {
+		leaves, root, nodes := buildDAG(t, &DAGBuilder{Fanout: 3}, input, opts)
+		require.Greater(t, len(leaves), 27)
+		rootCID, err := cidEncoding.DecodeString(root[1:])
+		require.NoError(t, err)
+		got, depths := dagLeaves(t, nodes, rootCID, 0)
+		assert.Equal(t, leaves, got)
+		for _, d := range depths {
+			assert.Equal(t, depths[0], d)
+		}
+		assert.Less(t, len(leaves), 27*3)
+		assert.Equal(t, 4, depths[0])
+		assert.Len(t, unixFSLinks(t, nodes[string(rootCID)]), (len(leaves)+26)/27)
+	})
+
+	t.Run("trickle", func(t *testing.T) {
+		leaves, root, nodes := buildDAG(t, &DAGBuilder{Layout: Trickle, Fanout: 3}, input, opts)
+		rootCID, err := cidEncoding.DecodeString(root[1:])
+		require.NoError(t, err)
+		got, depths := dagLeaves(t, nodes, rootCID, 0)
+		assert.Equal(t, leaves, got)
+		// The root links the first chunks directly, followed by four subtrees of depth 1 of three chunks each.
+		assert.Equal(t, []int{1, 1, 1, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2, 2}, depths[:15])
+		// Further chunks go into subtrees of depth 2, whose own chunks are at depth 2 as well.
+		assert.Contains(t, depths, 3)
+	})
+
+	t.Run("single level", func(t *testing.T) {
+		// Below the fanout, the root links all chunks like the root of ExportCAR.
+		_, root, nodes := buildDAG(t, &DAGBuilder{}, input, opts)
+		assert.Len(t, nodes, 1)
+		car, err := ExportCAR(io.Discard, bytes.NewReader(input), &CAROptions{Options: *opts})
+		require.NoError(t, err)
+		assert.Equal(t, car, root)
+		_, trickle, _ := buildDAG(t, &DAGBuilder{Layout: Trickle}, input, opts)
+		assert.Equal(t, root, trickle)
+	})
+
+	t.Run("go-unixfs", func(t *testing.T) {
+		// The roots were computed by the balanced and trickle importers of go-unixfs (boxo v0.43.0)
+		// with raw leaves, CIDv1 and 256 KiB chunks, as `ipfs add --cid-version=1` does, for 17 chunks.
+		input := make([]byte, 4*MiB+1000)
+		rand.New(rand.NewSource(1)).Read(input)
+		opts := &Options{Algorithm: Fixed, AverageSize: 256 * 1024, SHA256: true}
+		for _, tc := range []struct {
+			builder *DAGBuilder
+			root    string
+		}{
+			{&DAGBuilder{Fanout: 3}, "bafybeig5nrd5gy6dqjd2uwt7mccfwkvyrnapdrqb3evyrqxmjbvepaknxm"},
+			{&DAGBuilder{Layout: Trickle, Fanout: 3}, "bafybeibojbfg6bwzwxow2ztnjmpm5uy2wknuaa3jypna6opu2k5z6fzpje"},
+			{&DAGBuilder{}, "bafybeicgbpj4folpvq2tf3c6kd3profyzqagqbrtkwyyhzu6xw6pibsgwu"},
+			{&DAGBuilder{Layout: Trickle}, "bafybeicgbpj4folpvq2tf3c6kd3profyzqagqbrtkwyyhzu6xw6pibsgwu"},
+		} {
+			_, root, _ := buildDAG(t, tc.builder, input, opts)
+			assert.Equal(t, tc.root, root, "layout %d, fanout %d", tc.builder.Layout, tc.builder.Fanout)
+		}
+	})
+
+	t.Run("small files", func(t *testing.T) {
+		_, root, nodes := buildDAG(t, &DAGBuilder{}, nil, opts)
+		assert.Empty(t, nodes)
+		assert.Equal(t, "bafkreihdwdcefgh4dqkjv67uzcmw7ojee6xedzdetojuzjevtenxquvyku", root)
+
+		_, root, nodes = buildDAG(t, &DAGBuilder{Layout: Trickle}, nil, opts)
+		assert.Len(t, nodes, 1)
+		assert.NotEqual(t, "bafkreihdwdcefgh4dqkjv67uzcmw7ojee6xedzdetojuzjevtenxquvyku", root)
+
+		// A single chunk is its own root in the balanced layout.
+		ch := NewChunker(bytes.NewReader(input[:100]), opts)
+		c, err := ch.Next()
+		require.NoError(t, err)
+		b := &DAGBuilder{}
+		require.NoError(t, b.Add(c))
+		root, err = b.Root()
+		require.NoError(t, err)
+		cid, err := c.CID()
+		require.NoError(t, err)
+		assert.Equal(t, cid, root)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		b := &DAGBuilder{HashName: "blake3"}
+		ch := NewChunker(bytes.NewReader(input), opts)
+		c, err := ch.Next()
+		require.NoError(t, err)
+		assert.Error(t, b.Add(c))
+		assert.Error(t, (&DAGBuilder{}).Add(&Chunk{Data: input[:10]}))
+		_, err = (&DAGBuilder{Fanout: 1}).Root()
+		assert.Error(t, err)
+		_, err = (&DAGBuilder{Layout: 2}).Root()
+		assert.Error(t, err)
+	})
+}
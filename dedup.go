@@ -0,0 +1,123 @@
+package ae
+
+import (
+	"sync"
+)
+
+// Location is a place where a chunk occurs.
+type Location struct {
+	// File the chunk is part of, as named by the caller.
+	File string
+
+	// Offset of the chunk in the file.
+	Offset int64
+}
+
+// DedupEntry describes a distinct chunk in a DedupIndex.
+type DedupEntry struct {
+	// Length of the chunk in bytes.
+	Length int64
+
+	// RefCount is the number of times the chunk has been added.
+	RefCount int
+
+	// Locations where the chunk occurs.
+	Locations []Location
+}
+
+// DedupStats summarize the content of a DedupIndex.
+type DedupStats struct {
+	// Chunks is the total number of chunks added.
+	Chunks int64
+
+	// UniqueChunks is the number of distinct chunks.
+	UniqueChunks int64
+
+	// Bytes is the total size of all chunks added.
+	Bytes int64
+
+	// UniqueBytes is the total size of the distinct chunks.
+	UniqueBytes int64
+}
+
+// HitRatio returns the share of chunks that were already known when added.
+func (s DedupStats) HitRatio() float64 {
+	if s.Chunks == 0 {
+		return 0
+	}
+	return float64(s.Chunks-s.UniqueChunks) / float64(s.Chunks)
+}
+
+// DedupRatio returns the ratio of total to unique bytes, i.e. the factor saved by deduplication.
+func (s DedupStats) DedupRatio() float64 {
+	if s.UniqueBytes == 0 {
+		return 0
+	}
+	return float64(s.Bytes) / float64(s.UniqueBytes)
+}
+
+// DedupIndex keeps track of chunks by their hash to measure and exploit deduplication.
+// It is safe for concurrent use.
+type DedupIndex struct {
+	mu      sync.RWMutex
+	entries map[string]*DedupEntry
+	stats   DedupStats
+}
+
+// NewDedupIndex returns an empty DedupIndex.
+func NewDedupIndex() *DedupIndex {
+	return &DedupIndex{entries: make(map[string]*DedupEntry)}
+}
+
+// Add records an occurrence of the chunk with the given hash and length at loc.
+// It returns whether the chunk was known before.
+func (x *DedupIndex) Add(hash []byte, length int64, loc Location) bool {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	x.stats.Chunks++
+	x.stats.Bytes += length
+	e, ok := x.entries[string(hash)]
+	if !ok {
+		e = &DedupEntry{Length: length}
+		x.entries[string(hash)] = e
+		x.stats.UniqueChunks++
+		x.stats.UniqueBytes += length
+	}
+	e.RefCount++
+	e.Locations = append(e.Locations, loc)
+	return ok
+}
+
+// AddManifest adds all chunks of m as occurrences in file.
+// It returns the number of chunks that were known before.
+func (x *DedupIndex) AddManifest(file string, m *Manifest) int {
+	var hits int
+	for _, ref := range m.Chunks {
+		if x.Add(ref.Hash, ref.Length, Location{File: file, Offset: ref.Offset}) {
+			hits++
+		}
+	}
+	return hits
+}
+
+// Lookup returns the entry of the chunk with the given hash, if present.
+func (x *DedupIndex) Lookup(hash []byte) (DedupEntry, bool) {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+
+	e, ok := x.entries[string(hash)]
+	if !ok {
+		return DedupEntry{}, false
+	}
+	entry := *e
+	entry.Locations = append([]Location(nil), e.Locations...)
+	return entry, true
+}
+
+// Stats returns a summary of the index.
+func (x *DedupIndex) Stats() DedupStats {
+	x.mu.RLock()
+	defer x.mu.RUnlock()
+	return x.stats
+}
@@ -0,0 +1,45 @@
+package ae
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"testing"
+)
+
+func TestDedupIndex(t *testing.T) {
+	opts := &Options{AverageSize: 16 * 1024}
+	// A fixed input, as the boundaries after an insertion may resynchronize late on unlucky random data.
+	base := make([]byte, MiB)
+	rand.New(rand.NewSource(1)).Read(base)
+	edited := append(append(append([]byte{}, base[:MiB/2]...), "some inserted bytes"...), base[MiB/2:]...)
+
+	mBase, err := BuildManifest(bytes.NewReader(base), opts)
+	assert.NoError(t, err)
+	mEdited, err := BuildManifest(bytes.NewReader(edited), opts)
+	assert.NoError(t, err)
+
+	x := NewDedupIndex()
+	assert.Zero(t, x.AddManifest("base", mBase))
+	hits := x.AddManifest("edited", mEdited)
+	assert.Greater(t, hits, len(mEdited.Chunks)/2)
+
+	s := x.Stats()
+	assert.Equal(t, int64(len(mBase.Chunks)+len(mEdited.Chunks)), s.Chunks)
+	assert.Equal(t, int64(len(base)+len(edited)), s.Bytes)
+	assert.Equal(t, int64(len(mBase.Chunks)+len(mEdited.Chunks)-hits), s.UniqueChunks)
+	assert.InDelta(t, float64(hits)/float64(s.Chunks), s.HitRatio(), 1e-9)
+	assert.Greater(t, s.DedupRatio(), 1.5)
+
+	e, ok := x.Lookup(mBase.Chunks[0].Hash)
+	assert.True(t, ok)
+	assert.Equal(t, 2, e.RefCount)
+	assert.Equal(t, mBase.Chunks[0].Length, e.Length)
+	assert.Equal(t, []Location{{"base", 0}, {"edited", 0}}, e.Locations)
+
+	_, ok = x.Lookup([]byte("foo"))
+	assert.False(t, ok)
+
+	assert.Zero(t, DedupStats{}.HitRatio())
+	assert.Zero(t, DedupStats{}.DedupRatio())
+}
@@ -0,0 +1,436 @@
+package ae
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"math"
+	"sync"
+)
+
+// ErrCorruptDelta is returned by DecodeDelta if a delta is malformed or does not fit its base.
+var ErrCorruptDelta = errors.New("ae: corrupt delta")
+
+const (
+	// deltaBlockSize is the length of the shortest match between base and target that EncodeDelta copies.
+	deltaBlockSize = 16
+
+	// deltaIndexStride is the distance between the positions of the base that EncodeDelta indexes.
+	// Matches of at least deltaBlockSize+deltaIndexStride-1 bytes are always found.
+	deltaIndexStride = 4
+
+	// deltaFeatures is the number of features computed per chunk by DeltaStore,
+	// condensed into deltaSuperFeatures super-features.
+	deltaFeatures      = 12
+	deltaSuperFeatures = 3
+
+	// deltaSampleMask selects the positions whose rolling hash is sampled for the features, i.e. one in 16.
+	deltaSampleMask = 1<<4 - 1
+)
+
+// deltaGear maps bytes to the random values of the rolling hash that the features of a chunk are computed from.
+var deltaGear = func() (t [256]uint64) {
+	for i := range t {
+		t[i] = mix64(uint64(i) + 0x9e3779b97f4a7c15)
+	}
+	return t
+}()
+
+// EncodeDelta returns a delta that DecodeDelta turns back into target given base, in the style of xdelta:
+// a sequence of instructions copying ranges of base and inserting literal bytes.
+// The more content target shares with base, in any order, the smaller the delta.
+func EncodeDelta(base, target []byte) []byte {
+	delta := appendUvarint(nil, uint64(len(target)))
+	index := make(map[uint64]int, len(base)/deltaIndexStride)
+	for i := 0; i+deltaBlockSize <= len(base); i += deltaIndexStride {
+		if _, ok := index[deltaBlockKey(base[i:])]; !ok {
+			index[deltaBlockKey(base[i:])] = i
+		}
+	}
+	var literal int
+	for p := 0; p+deltaBlockSize <= len(target); {
+		off, ok := index[deltaBlockKey(target[p:])]
+		if !ok || !bytes.Equal(base[off:off+deltaBlockSize], target[p:p+deltaBlockSize]) {
+			p++
+			continue
+		}
+		for off > 0 && p > literal && base[off-1] == target[p-1] {
+			off--
+			p--
+		}
+		n := deltaBlockSize
+		for off+n < len(base) && p+n < len(target) && base[off+n] == target[p+n] {
+			n++
+		}
+		delta = appendDeltaInsert(delta, target[literal:p])
+		delta = appendUvarint(delta, uint64(n)<<1|1)
+		delta = appendUvarint(delta, uint64(off))
+		p += n
+		literal = p
+	}
+	return appendDeltaInsert(delta, target[literal:])
+}
+
+// DecodeDelta reconstructs the target that delta was encoded from by EncodeDelta with base.
+func DecodeDelta(base, delta []byte) ([]byte, error) {
+	size, n := binary.Uvarint(delta)
+	if n <= 0 || size > uint64(maxInt) {
+		return nil, ErrCorruptDelta
+	}
+	delta = delta[n:]
+	var target []byte
+	if size <= uint64(len(delta))+uint64(len(base))*8 {
+		// Only trust the size for allocation if the delta could plausibly produce it.
+		target = make([]byte, 0, size)
+	}
+	for len(delta) > 0 {
+		op, n := binary.Uvarint(delta)
+		if n <= 0 {
+			return nil, ErrCorruptDelta
+		}
+		delta = delta[n:]
+		length := op >> 1
+		if length > size-uint64(len(target)) {
+			return nil, ErrCorruptDelta
+		}
+		if op&1 == 0 {
+			if length > uint64(len(delta)) {
+				return nil, ErrCorruptDelta
+			}
+			target = append(target, delta[:length]...)
+			delta = delta[length:]
+			continue
+		}
+		off, n := binary.Uvarint(delta)
+		if n <= 0 || off > uint64(len(base)) || length > uint64(len(base))-off {
+			return nil, ErrCorruptDelta
+		}
+		delta = delta[n:]
+		target = append(target, base[off:off+length]...)
+	}
+	if uint64(len(target)) != size {
+		return nil, ErrCorruptDelta
+	}
+	return target, nil
+}
+
+// appendDeltaInsert appends an instruction inserting literal to delta, unless literal is empty.
+func appendDeltaInsert(delta []byte, literal []byte) []byte {
+	if len(literal) == 0 {
+		return delta
+	}
+	delta = appendUvarint(delta, uint64(len(literal))<<1)
+	return append(delta, literal...)
+}
+
+// deltaBlockKey returns the key of the block of deltaBlockSize bytes at the start of b in the index of EncodeDelta.
+func deltaBlockKey(b []byte) uint64 {
+	return binary.LittleEndian.Uint64(b)*0x9e3779b97f4a7c15 ^ binary.LittleEndian.Uint64(b[8:])
+}
+
+// Tags of the chunks stored by DeltaStore.
+const (
+	deltaTagFull byte = iota
+	deltaTagDelta
+)
+
+// DeltaStore is a ChunkStore that stores chunks as deltas against similar chunks stored before,
+// such as consecutive versions of a record that changed in a few places, which deduplication alone cannot exploit.
+// Every chunk stored in full is indexed by super-features of its content; a new chunk sharing a super-feature
+// with one of them is stored as a delta against it if that saves at least half of its size.
+// Get reconstructs such chunks from their base transparently.
+//
+// Deltas are only ever encoded against chunks stored in full, so reading a chunk takes at most two reads.
+// Deleting a chunk that others are encoded against stores these in full first.
+//
+// The chunks of the underlying store must all have been written by a DeltaStore.
+// To combine delta with regular compression, the DeltaStore must wrap the CompressedStore, not vice versa.
+type DeltaStore struct {
+	store ChunkStore
+
+	// mu is held for writing by Delete, which rewrites chunks stored as deltas, and for reading otherwise.
+	mu sync.RWMutex
+
+	// index guards the maps below.
+	index sync.Mutex
+
+	// bases maps the hash of every chunk stored in full, as a string, to its super-features.
+	bases map[string][]uint64
+
+	// features maps super-features to the hash of a chunk stored in full that has them.
+	features map[uint64][]byte
+
+	// dependents maps the hash of every chunk that others are encoded against to their hashes.
+	dependents map[string][][]byte
+}
+
+// NewDeltaStore returns a DeltaStore encoding chunks as deltas before writing them to s.
+// If s is a ChunkWalker, the chunks it holds are read to restore the index; otherwise only chunks put from now on
+// serve as bases, and chunks in s that are encoded against others must not be deleted through the DeltaStore.
+func NewDeltaStore(s ChunkStore) (*DeltaStore, error) {
+	d := &DeltaStore{
+		store:      s,
+		bases:      make(map[string][]uint64),
+		features:   make(map[uint64][]byte),
+		dependents: make(map[string][][]byte),
+	}
+	var hashes [][]byte
+	err := walkStore(s, func(hash []byte, size int64) error {
+		hashes = append(hashes, append([]byte{}, hash...))
+		return nil
+	})
+	if err == ErrWalkUnsupported {
+		return d, nil
+	} else if err != nil {
+		return nil, err
+	}
+	for _, hash := range hashes {
+		stored, err := s.Get(hash)
+		if err != nil {
+			return nil, err
+		}
+		if len(stored) == 0 {
+			return nil, ErrCorruptChunk
+		}
+		switch stored[0] {
+		case deltaTagFull:
+			d.addBase(hash, stored[1:])
+		case deltaTagDelta:
+			base, _, err := parseDeltaRecord(stored)
+			if err != nil {
+				return nil, err
+			}
+			d.dependents[string(base)] = append(d.dependents[string(base)], hash)
+		default:
+			return nil, ErrCorruptChunk
+		}
+	}
+	return d, nil
+}
+
+// Put stores data under hash, as a delta against a similar chunk if there is one.
+func (s *DeltaStore) Put(hash []byte, data []byte) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if ok, err := s.store.Has(hash); err != nil || ok {
+		return err
+	}
+	features := chunkSuperFeatures(data)
+	if base := s.similar(hash, features); base != nil {
+		record, err := s.encode(base, data)
+		if err != nil {
+			return err
+		}
+		if record != nil {
+			if err := s.store.Put(hash, record); err != nil {
+				return err
+			}
+			s.index.Lock()
+			s.dependents[string(base)] = append(s.dependents[string(base)], append([]byte{}, hash...))
+			s.index.Unlock()
+			return nil
+		}
+	}
+	if err := s.store.Put(hash, append([]byte{deltaTagFull}, data...)); err != nil {
+		return err
+	}
+	s.index.Lock()
+	s.indexBase(hash, features)
+	s.index.Unlock()
+	return nil
+}
+
+// Get fetches the chunk stored under hash and reconstructs it from its base if it is stored as a delta.
+func (s *DeltaStore) Get(hash []byte) ([]byte, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return s.get(hash)
+}
+
+// get is Get without locking.
+func (s *DeltaStore) get(hash []byte) ([]byte, error) {
+	stored, err := s.store.Get(hash)
+	if err != nil {
+		return nil, err
+	}
+	if len(stored) == 0 {
+		return nil, ErrCorruptChunk
+	}
+	switch stored[0] {
+	case deltaTagFull:
+		return stored[1:], nil
+	case deltaTagDelta:
+		base, delta, err := parseDeltaRecord(stored)
+		if err != nil {
+			return nil, err
+		}
+		b, err := s.store.Get(base)
+		if err == ErrChunkNotFound {
+			return nil, ErrCorruptChunk
+		} else if err != nil {
+			return nil, err
+		}
+		if len(b) == 0 || b[0] != deltaTagFull {
+			return nil, ErrCorruptChunk
+		}
+		data, err := DecodeDelta(b[1:], delta)
+		if err != nil {
+			return nil, ErrCorruptChunk
+		}
+		return data, nil
+	default:
+		return nil, ErrCorruptChunk
+	}
+}
+
+// Has reports whether a chunk is stored under hash.
+func (s *DeltaStore) Has(hash []byte) (bool, error) {
+	return s.store.Has(hash)
+}
+
+// Delete removes the chunk stored under hash. Chunks encoded against it are stored in full beforehand.
+func (s *DeltaStore) Delete(hash []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	stored, err := s.store.Get(hash)
+	if err != nil {
+		return err
+	}
+	// Unindex the chunk first, so that the chunks rewritten below take its place as bases.
+	if features, ok := s.bases[string(hash)]; ok {
+		delete(s.bases, string(hash))
+		for _, f := range features {
+			if bytes.Equal(s.features[f], hash) {
+				delete(s.features, f)
+			}
+		}
+	} else if base, _, err := parseDeltaRecord(stored); err == nil {
+		deps := s.dependents[string(base)]
+		for i, dep := range deps {
+			if bytes.Equal(dep, hash) {
+				s.dependents[string(base)] = append(deps[:i:i], deps[i+1:]...)
+				break
+			}
+		}
+	}
+	for _, dep := range s.dependents[string(hash)] {
+		data, err := s.get(dep)
+		if err == ErrChunkNotFound {
+			continue
+		} else if err != nil {
+			return err
+		}
+		if err := s.store.Delete(dep); err != nil && err != ErrChunkNotFound {
+			return err
+		}
+		if err := s.store.Put(dep, append([]byte{deltaTagFull}, data...)); err != nil {
+			return err
+		}
+		s.addBase(dep, data)
+	}
+	delete(s.dependents, string(hash))
+	return s.store.Delete(hash)
+}
+
+// Walk calls fn for every chunk in the underlying store, with its stored size.
+func (s *DeltaStore) Walk(fn func(hash []byte, size int64) error) error {
+	return walkStore(s.store, fn)
+}
+
+// encode returns data encoded as a delta against the chunk stored in full under base,
+// or nil if the delta does not save enough or the base is gone.
+func (s *DeltaStore) encode(base []byte, data []byte) ([]byte, error) {
+	stored, err := s.store.Get(base)
+	if err == ErrChunkNotFound {
+		return nil, nil
+	} else if err != nil {
+		return nil, err
+	}
+	if len(stored) == 0 || stored[0] != deltaTagFull {
+		return nil, nil
+	}
+	delta := EncodeDelta(stored[1:], data)
+	if len(delta) > len(data)/2 {
+		return nil, nil
+	}
+	record := appendUvarint([]byte{deltaTagDelta}, uint64(len(base)))
+	record = append(record, base...)
+	return append(record, delta...), nil
+}
+
+// similar returns the hash of a chunk stored in full that shares a super-feature with a chunk, or nil.
+func (s *DeltaStore) similar(hash []byte, features []uint64) []byte {
+	s.index.Lock()
+	defer s.index.Unlock()
+	for _, f := range features {
+		if base, ok := s.features[f]; ok && !bytes.Equal(base, hash) {
+			return base
+		}
+	}
+	return nil
+}
+
+// addBase indexes the chunk stored in full under hash with the super-features of data.
+// The caller must hold mu for writing, or be the only user of the store.
+func (s *DeltaStore) addBase(hash []byte, data []byte) {
+	s.indexBase(hash, chunkSuperFeatures(data))
+}
+
+// indexBase indexes the chunk stored in full under hash with features. The caller must hold index.
+func (s *DeltaStore) indexBase(hash []byte, features []uint64) {
+	hash = append([]byte{}, hash...)
+	s.bases[string(hash)] = features
+	for _, f := range features {
+		if _, ok := s.features[f]; !ok {
+			s.features[f] = hash
+		}
+	}
+}
+
+// parseDeltaRecord splits a chunk stored as a delta into the hash of its base and the delta.
+func parseDeltaRecord(stored []byte) (base []byte, delta []byte, err error) {
+	if len(stored) == 0 || stored[0] != deltaTagDelta {
+		return nil, nil, ErrCorruptChunk
+	}
+	n, k := binary.Uvarint(stored[1:])
+	if k <= 0 || n > uint64(len(stored)-1-k) {
+		return nil, nil, ErrCorruptChunk
+	}
+	stored = stored[1+k:]
+	return stored[:n], stored[n:], nil
+}
+
+// chunkSuperFeatures returns the super-features of the content of a chunk, following the resemblance detection
+// of Shilane et al.: every feature is the maximum of a different transformation of a rolling hash over the data,
+// sampled at content-defined positions, and the super-features hash groups of features.
+// Chunks sharing a super-feature most likely share most of their content. Too short chunks have none.
+func chunkSuperFeatures(data []byte) []uint64 {
+	var maxima [deltaFeatures]uint64
+	var sampled bool
+	var h uint64
+	for i, b := range data {
+		h = h<<1 + deltaGear[b]
+		if i < 63 || h&deltaSampleMask != 0 {
+			continue
+		}
+		sampled = true
+		for j := range maxima {
+			if v := mix64(h ^ uint64(j+1)*0x9e3779b97f4a7c15); v > maxima[j] {
+				maxima[j] = v
+			}
+		}
+	}
+	if !sampled {
+		return nil
+	}
+	features := make([]uint64, deltaSuperFeatures)
+	group := deltaFeatures / deltaSuperFeatures
+	for i := range features {
+		x := uint64(math.MaxUint64)
+		for _, v := range maxima[i*group : (i+1)*group] {
+			x = mix64(x ^ v)
+		}
+		features[i] = x
+	}
+	return features
+}
@@ -0,0 +1,116 @@
+package ae
+
+import (
+	"crypto/sha256"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+// editEvery returns a copy of data with a byte flipped and a few bytes inserted every stride bytes.
+func editEvery(data []byte, stride int) []byte {
+	var out []byte
+	for i := 0; i < len(data); i += stride {
+		end := i + stride
+		if end > len(data) {
+			end = len(data)
+		}
+		block := append([]byte{}, data[i:end]...)
+		block[len(block)/2] ^= 0xff
+		out = append(append(out, block...), "edit"...)
+	}
+	return out
+}
+
+// putHashed stores data in s under its SHA-256 hash and returns the hash.
+func putHashed(t *testing.T, s ChunkStore, data []byte) []byte {
+	hash := sha256.Sum256(data)
+	require.NoError(t, s.Put(hash[:], data))
+	return hash[:]
+}
+
+func TestEncodeDelta(t *testing.T) {
+	base := testFile[:256*1024]
+	for name, target := range map[string][]byte{
+		"equal":     base,
+		"edited":    editEvery(base, 8*1024),
+		"reordered": append(append([]byte{}, base[128*1024:]...), base[:128*1024]...),
+		"unrelated": testFile[MiB : MiB+64*1024],
+		"empty":     nil,
+		"short":     []byte("abc"),
+	} {
+		t.Run(name, func(t *testing.T) {
+			delta := EncodeDelta(base, target)
+			data, err := DecodeDelta(base, delta)
+			require.NoError(t, err)
+			assert.Equal(t, len(target), len(data))
+			assert.Equal(t, string(target), string(data))
+			if name == "edited" || name == "reordered" {
+				assert.Less(t, len(delta), len(target)/50)
+			}
+		})
+	}
+
+	delta := EncodeDelta(base, editEvery(base, 8*1024))
+	_, err := DecodeDelta(base[:1000], delta)
+	assert.Equal(t, ErrCorruptDelta, err)
+	_, err = DecodeDelta(base, delta[:len(delta)-1])
+	assert.Equal(t, ErrCorruptDelta, err)
+	_, err = DecodeDelta(base, nil)
+	assert.Equal(t, ErrCorruptDelta, err)
+}
+
+func TestDeltaStore(t *testing.T) {
+	backend := NewMemoryStore(0)
+	s, err := NewDeltaStore(backend)
+	require.NoError(t, err)
+	stored := func(hash []byte) int {
+		data, err := backend.Get(hash)
+		require.NoError(t, err)
+		return len(data)
+	}
+
+	v1 := testFile[:64*1024]
+	v2 := editEvery(v1, 16*1024)
+	other := testFile[MiB : MiB+64*1024]
+	h1, h2, h3 := putHashed(t, s, v1), putHashed(t, s, v2), putHashed(t, s, other)
+	assert.Equal(t, len(v1)+1, stored(h1))
+	assert.Less(t, stored(h2), len(v2)/10)
+	assert.Equal(t, len(other)+1, stored(h3))
+	// Putting a chunk again leaves it as it is.
+	putHashed(t, s, v2)
+	assert.Less(t, stored(h2), len(v2)/10)
+
+	for i, hash := range [][]byte{h1, h2, h3} {
+		data, err := s.Get(hash)
+		require.NoError(t, err)
+		assert.Equal(t, [][]byte{v1, v2, other}[i], data)
+	}
+
+	t.Run("reopen", func(t *testing.T) {
+		s, err := NewDeltaStore(backend)
+		require.NoError(t, err)
+		assert.Len(t, s.bases, 2)
+		assert.Len(t, s.dependents[string(h1)], 1)
+
+		// Deleting the base stores the chunk encoded against it in full.
+		require.NoError(t, s.Delete(h1))
+		assert.Equal(t, ErrChunkNotFound, s.Delete(h1))
+		assert.Equal(t, len(v2)+1, stored(h2))
+		data, err := s.Get(h2)
+		require.NoError(t, err)
+		assert.Equal(t, v2, data)
+		assert.Equal(t, 2, countChunks(t, s))
+
+		// The rewritten chunk serves as a base in turn.
+		v3 := editEvery(v2, 16*1024)
+		h4 := putHashed(t, s, v3)
+		assert.Less(t, stored(h4), len(v3)/10)
+		require.NoError(t, s.Delete(h4))
+		assert.Empty(t, s.dependents[string(h2)])
+	})
+
+	require.NoError(t, backend.Put([]byte{1}, []byte{0xff}))
+	_, err = s.Get([]byte{1})
+	assert.Equal(t, ErrCorruptChunk, err)
+}
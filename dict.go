@@ -0,0 +1,181 @@
+package ae
+
+import (
+	"container/heap"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"io"
+	"math/rand"
+)
+
+// Dictionary is a zstd dictionary consisting of raw content.
+// Small chunks compress poorly on their own, as there is little history to find matches in.
+// Content that is common to many chunks serves as shared history instead.
+type Dictionary struct {
+	// ID identifies the dictionary in compressed data.
+	ID uint32
+
+	// Content of the dictionary, with the most valuable data at the end.
+	Content []byte
+}
+
+const (
+	// dictDmerSize is the length of the substrings whose frequencies drive the training.
+	dictDmerSize = 8
+
+	// dictSegmentSize is the length of the pieces of samples the dictionary is composed of.
+	dictSegmentSize = 256
+
+	// dictMaxSampleSize bounds the data sampled from a single chunk.
+	dictMaxSampleSize = 64 * 1024
+
+	// dictTableBits is the logarithm of the size of the table counting substring frequencies.
+	dictTableBits = 20
+)
+
+// ErrNoSamples is returned by TrainDictionary if the input does not provide any data to train on.
+var ErrNoSamples = errors.New("ae: no samples to train a dictionary on")
+
+// TrainDictionary chunks r according to opts and trains a dictionary of at most size bytes on a sample of the chunks.
+// About a hundred times the dictionary size is sampled, picking chunks at random across the entire input.
+//
+// Following the COVER algorithm of the reference implementation, the dictionary is composed of the segments
+// of the samples that cover the most frequent substrings, each of which is counted only once.
+func TrainDictionary(r io.Reader, opts *Options, size int) (*Dictionary, error) {
+	samples, err := sampleChunks(NewChunker(r, opts), 100*int64(size))
+	if err != nil {
+		return nil, err
+	}
+	if len(samples) == 0 {
+		return nil, ErrNoSamples
+	}
+	content := trainCover(samples, size)
+	sum := sha256.Sum256(content)
+	// IDs below 32768 are reserved for registration with the zstd project
+	id := 32768 + binary.LittleEndian.Uint32(sum[:])%(1<<31-32768)
+	return &Dictionary{ID: id, Content: content}, nil
+}
+
+// sampleChunks returns a uniform sample of the chunks of ch totaling about budget bytes.
+// The sample is reproducible for the same input.
+func sampleChunks(ch *Chunker, budget int64) ([][]byte, error) {
+	rnd := rand.New(rand.NewSource(1))
+	n := 0
+	var samples [][]byte
+	var size int64
+	for ; ; n++ {
+		c, err := ch.Next()
+		if err == io.EOF {
+			return samples, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		data := c.Data
+		if len(data) > dictMaxSampleSize {
+			data = data[:dictMaxSampleSize]
+		}
+		data = append([]byte(nil), data...)
+		ReleaseChunk(c)
+
+		// reservoir sampling, with the reservoir sized by the chunks seen so far
+		if size < budget {
+			samples = append(samples, data)
+			size += int64(len(data))
+		} else if i := rnd.Intn(n + 1); i < len(samples) {
+			size += int64(len(data) - len(samples[i]))
+			samples[i] = data
+		}
+	}
+}
+
+// dmerSlot returns the slot in the frequency table of the substring at the beginning of p.
+func dmerSlot(p []byte) uint32 {
+	return uint32((binary.LittleEndian.Uint64(p) * 0x9E3779B97F4A7C15) >> (64 - dictTableBits))
+}
+
+// segment is a candidate piece of the dictionary.
+type segment struct {
+	data  []byte
+	score int
+}
+
+// segmentHeap orders segments by descending score.
+type segmentHeap []segment
+
+func (h segmentHeap) Len() int            { return len(h) }
+func (h segmentHeap) Less(i, j int) bool  { return h[i].score > h[j].score }
+func (h segmentHeap) Swap(i, j int)       { h[i], h[j] = h[j], h[i] }
+func (h *segmentHeap) Push(x interface{}) { *h = append(*h, x.(segment)) }
+func (h *segmentHeap) Pop() interface{} {
+	old := *h
+	s := old[len(old)-1]
+	*h = old[:len(old)-1]
+	return s
+}
+
+// trainCover composes a dictionary of at most size bytes from the segments of samples
+// that cover the most frequent substrings.
+func trainCover(samples [][]byte, size int) []byte {
+	counts := make([]uint32, 1<<dictTableBits)
+	for _, s := range samples {
+		for i := 0; i+dictDmerSize <= len(s); i++ {
+			counts[dmerSlot(s[i:])]++
+		}
+	}
+	score := func(seg []byte) int {
+		total := 0
+		for i := 0; i+dictDmerSize <= len(seg); i++ {
+			total += int(counts[dmerSlot(seg[i:])])
+		}
+		return total
+	}
+
+	var h segmentHeap
+	for _, s := range samples {
+		for i := 0; i < len(s); i += dictSegmentSize {
+			end := i + dictSegmentSize
+			if end > len(s) {
+				end = len(s)
+			}
+			if seg := s[i:end]; len(seg) >= dictDmerSize {
+				h = append(h, segment{data: seg, score: score(seg)})
+			}
+		}
+	}
+	heap.Init(&h)
+
+	// segments are picked greedily; as picking one devalues others, scores are updated lazily
+	var picked [][]byte
+	total := 0
+	for h.Len() > 0 && total < size {
+		top := heap.Pop(&h).(segment)
+		if s := score(top.data); s != top.score {
+			if s > 0 {
+				top.score = s
+				heap.Push(&h, top)
+			}
+			continue
+		}
+		if top.score == 0 {
+			break
+		}
+		data := top.data
+		if len(data) > size-total {
+			data = data[:size-total]
+		}
+		picked = append(picked, data)
+		total += len(data)
+		for i := 0; i+dictDmerSize <= len(top.data); i++ {
+			counts[dmerSlot(top.data[i:])] = 0
+		}
+	}
+
+	// the best segments go last, closest to the data to be compressed
+	content := make([]byte, 0, total)
+	for i := len(picked) - 1; i >= 0; i-- {
+		content = append(content, picked[i]...)
+	}
+	return content
+}
@@ -0,0 +1,66 @@
+package ae
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"testing"
+)
+
+// records returns n JSON records of the same structure with varying values.
+func records(n int, seed int64) []byte {
+	rnd := rand.New(rand.NewSource(seed))
+	var buf bytes.Buffer
+	for i := 0; i < n; i++ {
+		fmt.Fprintf(&buf, `{"id":%d,"user":{"name":"user%d","email":"user%d@example.com","active":%t},`+
+			`"event":"page_view","path":"/products/%d","referrer":"https://www.example.com/search?q=%x",`+
+			`"userAgent":"Mozilla/5.0 (X11; Linux x86_64) AppleWebKit/537.36 (KHTML, like Gecko)","latency":%d}`+"\n",
+			i, rnd.Intn(1000), rnd.Intn(1000), rnd.Intn(2) == 0, rnd.Intn(100000), rnd.Int63(), rnd.Intn(500))
+	}
+	return buf.Bytes()
+}
+
+func TestTrainDictionary(t *testing.T) {
+	opts := &Options{AverageSize: 1024}
+	d, err := TrainDictionary(bytes.NewReader(records(20000, 1)), opts, 16*1024)
+	assert.NoError(t, err)
+	assert.LessOrEqual(t, len(d.Content), 16*1024)
+	assert.NotEmpty(t, d.Content)
+	assert.GreaterOrEqual(t, d.ID, uint32(32768))
+
+	t.Run("reproducible", func(t *testing.T) {
+		d2, err := TrainDictionary(bytes.NewReader(records(20000, 1)), opts, 16*1024)
+		assert.NoError(t, err)
+		assert.Equal(t, d, d2)
+	})
+
+	t.Run("improves compression", func(t *testing.T) {
+		// compress chunks of unseen records of the same kind
+		input := records(2000, 2)
+		plain, withDict := NewMemoryStore(0), NewMemoryStore(0)
+		ps, err := NewCompressedStore(plain, CodecZstd)
+		assert.NoError(t, err)
+		ds, err := NewDictCompressedStore(withDict, d)
+		assert.NoError(t, err)
+
+		chunks := getChunks(NewChunker(bytes.NewReader(input), opts))
+		for _, c := range chunks {
+			sum := sha256.Sum256(c)
+			assert.NoError(t, ps.Put(sum[:], c))
+			assert.NoError(t, ds.Put(sum[:], c))
+
+			data, err := ds.Get(sum[:])
+			assert.NoError(t, err)
+			assert.Equal(t, c, data)
+		}
+		t.Logf("input: %d bytes, zstd: %d bytes, with dictionary: %d bytes", len(input), plain.Size(), withDict.Size())
+		assert.Less(t, withDict.Size(), plain.Size()*3/4)
+	})
+
+	t.Run("empty input", func(t *testing.T) {
+		_, err := TrainDictionary(bytes.NewReader(nil), opts, 1024)
+		assert.Equal(t, ErrNoSamples, err)
+	})
+}
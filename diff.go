@@ -0,0 +1,61 @@
+package ae
+
+// Delta describes the change between two versions of a file in terms of their chunks.
+// Each set holds every distinct chunk only once, in order of its first occurrence,
+// so Added is exactly what needs to be transferred to turn the old version into the new one.
+type Delta struct {
+	// Added are the chunks of the new version that are missing from the old one.
+	Added []ChunkRef
+
+	// Removed are the chunks of the old version that are missing from the new one.
+	Removed []ChunkRef
+
+	// Unchanged are the chunks of the new version that are present in the old one as well.
+	// Their offsets refer to the new version.
+	Unchanged []ChunkRef
+
+	// AddedBytes is the total length of the added chunks.
+	AddedBytes int64
+
+	// RemovedBytes is the total length of the removed chunks.
+	RemovedBytes int64
+
+	// UnchangedBytes is the total length of the unchanged chunks.
+	UnchangedBytes int64
+}
+
+// DiffManifests compares the chunks of two versions of a file by their hashes.
+// The manifests should share the same parameters, otherwise hardly any chunks will match.
+func DiffManifests(old, new *Manifest) Delta {
+	oldHashes := make(map[string]bool, len(old.Chunks))
+	for _, ref := range old.Chunks {
+		oldHashes[string(ref.Hash)] = true
+	}
+
+	var d Delta
+	seen := make(map[string]bool, len(new.Chunks))
+	for _, ref := range new.Chunks {
+		key := string(ref.Hash)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		if oldHashes[key] {
+			d.Unchanged = append(d.Unchanged, ref)
+			d.UnchangedBytes += ref.Length
+		} else {
+			d.Added = append(d.Added, ref)
+			d.AddedBytes += ref.Length
+		}
+	}
+	for _, ref := range old.Chunks {
+		key := string(ref.Hash)
+		if seen[key] {
+			continue
+		}
+		seen[key] = true
+		d.Removed = append(d.Removed, ref)
+		d.RemovedBytes += ref.Length
+	}
+	return d
+}
@@ -0,0 +1,55 @@
+package ae
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestDiffManifests(t *testing.T) {
+	opts := &Options{AverageSize: 64 * 1024}
+	input := testFile[:4*MiB]
+	old, err := BuildManifest(bytes.NewReader(input), opts)
+	assert.NoError(t, err)
+
+	t.Run("identical", func(t *testing.T) {
+		d := DiffManifests(old, old)
+		assert.Empty(t, d.Added)
+		assert.Empty(t, d.Removed)
+		assert.Len(t, d.Unchanged, len(old.Chunks))
+		assert.Equal(t, old.Size, d.UnchangedBytes)
+	})
+
+	t.Run("modified", func(t *testing.T) {
+		modified := append([]byte{}, input...)
+		modified[old.Chunks[3].Offset+10]++
+		new, err := BuildManifest(bytes.NewReader(modified), opts)
+		assert.NoError(t, err)
+
+		d := DiffManifests(old, new)
+		assert.NotEmpty(t, d.Added)
+		assert.NotEmpty(t, d.Removed)
+		assert.Contains(t, d.Removed, old.Chunks[3])
+		assert.Equal(t, new.Size, d.AddedBytes+d.UnchangedBytes)
+		assert.Equal(t, old.Size, d.RemovedBytes+d.UnchangedBytes)
+		// the change only affects the chunks around it
+		assert.Less(t, d.AddedBytes, old.Size/4)
+	})
+
+	t.Run("duplicate chunks", func(t *testing.T) {
+		// without any extrema, zeros are cut at the maximum size only
+		chunk := make([]byte, 64*1024)
+		repeated := bytes.Repeat(chunk, 4)
+		old, err := BuildManifest(bytes.NewReader(chunk), &Options{AverageSize: 64 * 1024, MaxSize: 64 * 1024})
+		assert.NoError(t, err)
+		new, err := BuildManifest(bytes.NewReader(repeated), &Options{AverageSize: 64 * 1024, MaxSize: 64 * 1024})
+		assert.NoError(t, err)
+		assert.Len(t, new.Chunks, 4)
+
+		d := DiffManifests(old, new)
+		assert.Empty(t, d.Added)
+		assert.Empty(t, d.Removed)
+		assert.Len(t, d.Unchanged, 1)
+		assert.Equal(t, int64(64*1024), d.UnchangedBytes)
+	})
+}
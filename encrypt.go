@@ -0,0 +1,87 @@
+package ae
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/sha256"
+)
+
+// EncryptedStore is a ChunkStore that encrypts chunks before they are written to an underlying store.
+//
+// It employs convergent encryption: the key of every chunk is derived from its hash, so identical chunks
+// encrypt identically and are still deduplicated by the underlying store, across all users sharing the secret.
+// Chunks are stored under an identifier derived from their hash as well, which does not reveal the hash itself.
+// An optional secret mixed into both derivations prevents anyone without it from confirming
+// that the store holds a known piece of data.
+//
+// Since the hash is all it takes to decrypt a chunk, the manifests referencing the chunks must be kept confidential.
+type EncryptedStore struct {
+	store  ChunkStore
+	secret []byte
+}
+
+// NewEncryptedStore returns an EncryptedStore writing to s, with keys derived using the optional secret.
+func NewEncryptedStore(s ChunkStore, secret []byte) *EncryptedStore {
+	return &EncryptedStore{store: s, secret: append([]byte(nil), secret...)}
+}
+
+// Put encrypts data and stores it in the underlying store.
+func (s *EncryptedStore) Put(hash []byte, data []byte) error {
+	aead, err := s.aead(hash)
+	if err != nil {
+		return err
+	}
+	return s.store.Put(s.id(hash), aead.Seal(nil, make([]byte, aead.NonceSize()), data, nil))
+}
+
+// Get fetches the chunk stored under hash from the underlying store and decrypts it.
+func (s *EncryptedStore) Get(hash []byte) ([]byte, error) {
+	ciphertext, err := s.store.Get(s.id(hash))
+	if err != nil {
+		return nil, err
+	}
+	aead, err := s.aead(hash)
+	if err != nil {
+		return nil, err
+	}
+	data, err := aead.Open(nil, make([]byte, aead.NonceSize()), ciphertext, nil)
+	if err != nil {
+		return nil, ErrCorruptChunk
+	}
+	return data, nil
+}
+
+// Has reports whether a chunk is stored under hash.
+func (s *EncryptedStore) Has(hash []byte) (bool, error) {
+	return s.store.Has(s.id(hash))
+}
+
+// Delete removes the chunk stored under hash.
+func (s *EncryptedStore) Delete(hash []byte) error {
+	return s.store.Delete(s.id(hash))
+}
+
+// id returns the identifier the chunk with the given hash is stored under.
+func (s *EncryptedStore) id(hash []byte) []byte {
+	return s.derive("id", hash)
+}
+
+// aead returns the cipher for the chunk with the given hash.
+// As every key only ever encrypts the one chunk it is derived from, a fixed nonce is safe to use.
+func (s *EncryptedStore) aead(hash []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(s.derive("key", hash))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// derive returns a 256-bit value for the given purpose, derived from hash and the secret.
+func (s *EncryptedStore) derive(purpose string, hash []byte) []byte {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(purpose))
+	mac.Write([]byte{0})
+	mac.Write(hash)
+	return mac.Sum(nil)
+}
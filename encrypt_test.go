@@ -0,0 +1,68 @@
+package ae
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestEncryptedStore(t *testing.T) {
+	testChunkStore(t, NewEncryptedStore(NewMemoryStore(0), []byte("secret")))
+
+	data := testFile[:64*1024]
+	sum := sha256.Sum256(data)
+
+	t.Run("confidential", func(t *testing.T) {
+		backend := NewMemoryStore(0)
+		s := NewEncryptedStore(backend, nil)
+		assert.NoError(t, s.Put(sum[:], data))
+
+		ok, _ := backend.Has(sum[:])
+		assert.False(t, ok)
+		assert.Equal(t, 1, backend.Len())
+		for _, e := range backend.entries {
+			stored := e.Value.(*memEntry).data
+			assert.False(t, bytes.Contains(stored, data[:32]))
+		}
+	})
+
+	t.Run("convergent", func(t *testing.T) {
+		backend := NewMemoryStore(0)
+		assert.NoError(t, NewEncryptedStore(backend, []byte("secret")).Put(sum[:], data))
+		assert.NoError(t, NewEncryptedStore(backend, []byte("secret")).Put(sum[:], data))
+		assert.Equal(t, 1, backend.Len())
+
+		// a different secret does not reveal the chunk
+		other := NewEncryptedStore(backend, []byte("other"))
+		ok, err := other.Has(sum[:])
+		assert.NoError(t, err)
+		assert.False(t, ok)
+		assert.NoError(t, other.Put(sum[:], data))
+		assert.Equal(t, 2, backend.Len())
+	})
+
+	t.Run("tampered", func(t *testing.T) {
+		backend := NewMemoryStore(0)
+		s := NewEncryptedStore(backend, nil)
+		assert.NoError(t, s.Put(sum[:], data))
+		for _, e := range backend.entries {
+			e.Value.(*memEntry).data[0]++
+		}
+		_, err := s.Get(sum[:])
+		assert.Equal(t, ErrCorruptChunk, err)
+	})
+
+	t.Run("reassemble", func(t *testing.T) {
+		input := testFile[:MiB]
+		m, plain := storeInput(t, input, &Options{AverageSize: 64 * 1024})
+		s := NewEncryptedStore(NewMemoryStore(0), []byte("secret"))
+		for _, ref := range m.Chunks {
+			data, _ := plain.Get(ref.Hash)
+			assert.NoError(t, s.Put(ref.Hash, data))
+		}
+		var buf bytes.Buffer
+		assert.NoError(t, Reassemble(&buf, m, s))
+		assert.Equal(t, input, buf.Bytes())
+	})
+}
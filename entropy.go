@@ -0,0 +1,36 @@
+package ae
+
+import (
+	"math"
+)
+
+// Entropy estimates the Shannon entropy of b in bits per byte, from the frequencies of its byte values:
+// 0 for a run of a single byte, up to 8 for random data. Data close to 8 bits per byte, such as compressed or encrypted
+// content, is not worth compressing again, whereas text usually ranges from 4 to 5.
+// The estimate ignores the order of the bytes, so it does not see repetitions that a compressor would exploit.
+func Entropy(b []byte) float64 {
+	if len(b) == 0 {
+		return 0
+	}
+	// Four interleaved tables break the dependency of consecutive increments of the same counter.
+	var counts [4][256]int
+	i := 0
+	for ; i+4 <= len(b); i += 4 {
+		counts[0][b[i]]++
+		counts[1][b[i+1]]++
+		counts[2][b[i+2]]++
+		counts[3][b[i+3]]++
+	}
+	for ; i < len(b); i++ {
+		counts[0][b[i]]++
+	}
+	n := float64(len(b))
+	var e float64
+	for v := range counts[0] {
+		if c := counts[0][v] + counts[1][v] + counts[2][v] + counts[3][v]; c > 0 {
+			p := float64(c) / n
+			e -= p * math.Log2(p)
+		}
+	}
+	return e
+}
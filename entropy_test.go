@@ -0,0 +1,23 @@
+package ae
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestEntropy(t *testing.T) {
+	assert.Zero(t, Entropy(nil))
+	assert.Zero(t, Entropy(bytes.Repeat([]byte{'a'}, 1000)))
+	assert.Equal(t, 1.0, Entropy([]byte("abababa")[:6]))
+	assert.InDelta(t, 8, Entropy(testFile[:MiB]), 0.01)
+	text := bytes.Repeat([]byte("the quick brown fox jumps over the lazy dog "), 100)
+	assert.InDelta(t, 4.3, Entropy(text), 0.2)
+
+	// Chunks carry their entropy if requested.
+	ch := NewChunker(bytes.NewReader(append(append([]byte{}, text...), testFile[:MiB]...)), &Options{AverageSize: 4096, Entropy: true})
+	c, err := ch.Next()
+	assert.NoError(t, err)
+	assert.Equal(t, Entropy(c.Data), c.Entropy)
+	assert.Greater(t, c.Entropy, 0.0)
+}
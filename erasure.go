@@ -0,0 +1,181 @@
+package ae
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/klauspost/reedsolomon"
+)
+
+// ParityGroup describes a group of consecutive chunks of a manifest protected by Reed–Solomon parity shards.
+// Any of the chunks and shards of a group can be lost as long as as many remain as the group has chunks.
+type ParityGroup struct {
+	// First is the index of the first chunk of the group.
+	First int
+
+	// Chunks is the number of chunks in the group.
+	Chunks int
+
+	// ShardSize is the length of every shard, i.e. of the largest chunk in the group.
+	// Smaller chunks are padded with zeros for the encoding.
+	ShardSize int64
+
+	// Shards are the hashes of the parity shards, which are stored alongside the chunks.
+	Shards [][]byte
+}
+
+// AddParity computes parityShards parity shards for every dataShards consecutive chunks of m,
+// stores them in s and records them in m, replacing any earlier parity.
+// The chunks of m must be available in s.
+func AddParity(m *Manifest, s ChunkStore, dataShards, parityShards int) error {
+	if dataShards < 1 || parityShards < 1 || dataShards+parityShards > 256 {
+		return fmt.Errorf("ae: invalid number of shards %d+%d", dataShards, parityShards)
+	}
+	hasher, err := manifestHasher(m)
+	if err != nil {
+		return err
+	}
+	h := hasher()
+
+	var groups []ParityGroup
+	for first := 0; first < len(m.Chunks); first += dataShards {
+		refs := m.Chunks[first:]
+		if len(refs) > dataShards {
+			refs = refs[:dataShards]
+		}
+		g := ParityGroup{First: first, Chunks: len(refs)}
+		for _, ref := range refs {
+			if ref.Length > g.ShardSize {
+				g.ShardSize = ref.Length
+			}
+		}
+		shards := make([][]byte, len(refs)+parityShards)
+		for i, ref := range refs {
+			data, err := fetchChunk(s, h, first+i, ref)
+			if err != nil {
+				return err
+			}
+			shards[i] = padShard(data, g.ShardSize)
+		}
+		for i := len(refs); i < len(shards); i++ {
+			shards[i] = make([]byte, g.ShardSize)
+		}
+		enc, err := reedsolomon.New(len(refs), parityShards)
+		if err != nil {
+			return err
+		}
+		if err := enc.Encode(shards); err != nil {
+			return err
+		}
+		for _, shard := range shards[len(refs):] {
+			h.Reset()
+			h.Write(shard)
+			sum := h.Sum(nil)
+			if err := s.Put(sum, shard); err != nil {
+				return err
+			}
+			g.Shards = append(g.Shards, sum)
+		}
+		groups = append(groups, g)
+	}
+	m.Parity = groups
+	return nil
+}
+
+// RepairChunks restores the chunks of m missing from s by means of the parity recorded in m,
+// and returns the number of chunks restored. Missing parity shards are restored as well.
+// It fails if a group has lost more chunks and shards than it has parity shards.
+func RepairChunks(m *Manifest, s ChunkStore) (int, error) {
+	hasher, err := manifestHasher(m)
+	if err != nil {
+		return 0, err
+	}
+	h := hasher()
+
+	repaired := 0
+	for _, g := range m.Parity {
+		if g.First < 0 || g.Chunks < 1 || g.First+g.Chunks > len(m.Chunks) {
+			return repaired, fmt.Errorf("ae: parity group at chunk %d is out of range", g.First)
+		}
+		refs := m.Chunks[g.First : g.First+g.Chunks]
+		for _, ref := range refs {
+			if ref.Length > g.ShardSize {
+				return repaired, fmt.Errorf("ae: parity group at chunk %d has shards smaller than its chunks", g.First)
+			}
+		}
+		hashes := make([][]byte, 0, len(refs)+len(g.Shards))
+		for _, ref := range refs {
+			hashes = append(hashes, ref.Hash)
+		}
+		hashes = append(hashes, g.Shards...)
+
+		shards := make([][]byte, len(hashes))
+		corrupt := make([]bool, len(hashes))
+		missing := 0
+		for i, hash := range hashes {
+			data, err := s.Get(hash)
+			if err == ErrChunkNotFound {
+				missing++
+				continue
+			}
+			if err != nil {
+				return repaired, err
+			}
+			h.Reset()
+			h.Write(data)
+			if !bytes.Equal(h.Sum(nil), hash) {
+				// corrupt shards are treated as lost
+				corrupt[i] = true
+				missing++
+				continue
+			}
+			shards[i] = padShard(data, g.ShardSize)
+		}
+		if missing == 0 {
+			continue
+		}
+
+		enc, err := reedsolomon.New(len(refs), len(g.Shards))
+		if err != nil {
+			return repaired, err
+		}
+		present := make([]bool, len(shards))
+		for i := range shards {
+			present[i] = shards[i] != nil
+		}
+		if err := enc.Reconstruct(shards); err != nil {
+			return repaired, fmt.Errorf("ae: cannot repair parity group at chunk %d: %w", g.First, err)
+		}
+		for i, shard := range shards {
+			if present[i] {
+				continue
+			}
+			if i < len(refs) {
+				shard = shard[:refs[i].Length]
+			}
+			h.Reset()
+			h.Write(shard)
+			if !bytes.Equal(h.Sum(nil), hashes[i]) {
+				return repaired, fmt.Errorf("ae: shard %d of parity group at chunk %d does not match its hash after repair", i, g.First)
+			}
+			if corrupt[i] {
+				if err := s.Delete(hashes[i]); err != nil && err != ErrChunkNotFound {
+					return repaired, err
+				}
+			}
+			if err := s.Put(hashes[i], shard); err != nil {
+				return repaired, err
+			}
+			if i < len(refs) {
+				repaired++
+			}
+		}
+	}
+	return repaired, nil
+}
+
+// padShard returns data padded with zeros to size.
+func padShard(data []byte, size int64) []byte {
+	shard := make([]byte, size)
+	copy(shard, data)
+	return shard
+}
@@ -0,0 +1,119 @@
+package ae
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestAddParity(t *testing.T) {
+	input := testFile[:2*MiB]
+	m, s := storeInput(t, input, &Options{AverageSize: 64 * 1024})
+	assert.NoError(t, AddParity(m, s, 4, 2))
+
+	groups := (len(m.Chunks) + 3) / 4
+	assert.Len(t, m.Parity, groups)
+	// with a single chunk in the last group, its parity shards replicate it
+	stored := map[string]bool{}
+	for _, ref := range m.Chunks {
+		stored[string(ref.Hash)] = true
+	}
+	for _, g := range m.Parity {
+		for _, shard := range g.Shards {
+			stored[string(shard)] = true
+		}
+	}
+	assert.Equal(t, len(stored), s.Len())
+	last := m.Parity[groups-1]
+	assert.Equal(t, len(m.Chunks)-last.First, last.Chunks)
+	for _, g := range m.Parity {
+		assert.Len(t, g.Shards, 2)
+		for _, ref := range m.Chunks[g.First : g.First+g.Chunks] {
+			assert.LessOrEqual(t, ref.Length, g.ShardSize)
+		}
+	}
+
+	t.Run("encodings", func(t *testing.T) {
+		data, err := json.Marshal(m)
+		assert.NoError(t, err)
+		var decoded Manifest
+		assert.NoError(t, json.Unmarshal(data, &decoded))
+		assert.Equal(t, m.Parity, decoded.Parity)
+
+		data, err = m.MarshalBinary()
+		assert.NoError(t, err)
+		assert.Equal(t, byte(manifestParityVersion), data[len(manifestMagic)])
+		decoded = Manifest{}
+		assert.NoError(t, decoded.UnmarshalBinary(data))
+		assert.Equal(t, m, &decoded)
+	})
+
+	t.Run("invalid shards", func(t *testing.T) {
+		assert.Error(t, AddParity(m, s, 0, 2))
+		assert.Error(t, AddParity(m, s, 200, 100))
+	})
+}
+
+func TestRepairChunks(t *testing.T) {
+	input := testFile[:2*MiB]
+	setup := func() (*Manifest, *MemoryStore) {
+		m, s := storeInput(t, input, &Options{AverageSize: 64 * 1024})
+		assert.NoError(t, AddParity(m, s, 4, 2))
+		return m, s
+	}
+
+	t.Run("intact", func(t *testing.T) {
+		m, s := setup()
+		n, err := RepairChunks(m, s)
+		assert.NoError(t, err)
+		assert.Zero(t, n)
+	})
+
+	t.Run("lost chunks", func(t *testing.T) {
+		m, s := setup()
+		assert.NoError(t, s.Delete(m.Chunks[0].Hash))
+		assert.NoError(t, s.Delete(m.Chunks[3].Hash))
+		assert.NoError(t, s.Delete(m.Chunks[4].Hash))
+		assert.NoError(t, s.Delete(m.Parity[1].Shards[0]))
+
+		n, err := RepairChunks(m, s)
+		assert.NoError(t, err)
+		assert.Equal(t, 3, n)
+		ok, _ := s.Has(m.Parity[1].Shards[0])
+		assert.True(t, ok)
+		var buf bytes.Buffer
+		assert.NoError(t, Reassemble(&buf, m, s))
+		assert.Equal(t, input, buf.Bytes())
+	})
+
+	t.Run("corrupt chunk", func(t *testing.T) {
+		m, s := setup()
+		data, _ := s.Get(m.Chunks[1].Hash)
+		data[0]++
+
+		n, err := RepairChunks(m, s)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, n)
+		var buf bytes.Buffer
+		assert.NoError(t, Reassemble(&buf, m, s))
+		assert.Equal(t, input, buf.Bytes())
+	})
+
+	t.Run("shards too small", func(t *testing.T) {
+		m, s := setup()
+		assert.NoError(t, s.Delete(m.Chunks[0].Hash))
+		m.Parity[0].ShardSize = m.Chunks[0].Length - 1
+		_, err := RepairChunks(m, s)
+		assert.Error(t, err)
+	})
+
+	t.Run("too many losses", func(t *testing.T) {
+		m, s := setup()
+		for _, ref := range m.Chunks[:3] {
+			assert.NoError(t, s.Delete(ref.Hash))
+		}
+		_, err := RepairChunks(m, s)
+		assert.Error(t, err)
+	})
+}
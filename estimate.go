@@ -0,0 +1,90 @@
+package ae
+
+import (
+	"io"
+)
+
+// DedupReport is the result of EstimateDedup.
+type DedupReport struct {
+	DedupStats
+
+	// Files holds the contribution of each file in the order given.
+	Files []FileContribution
+}
+
+// FileContribution describes how a single file contributes to the data in a DedupReport.
+type FileContribution struct {
+	// Chunks is the number of chunks of the file.
+	Chunks int64
+
+	// Bytes is the size of the file.
+	Bytes int64
+
+	// NewChunks is the number of distinct chunks not found in any of the preceding files.
+	NewChunks int64
+
+	// NewBytes is the size of the new chunks, i.e. what the file adds to the unique data
+	// if the files are stored in order.
+	NewBytes int64
+
+	// ExclusiveBytes is the size of the distinct chunks not found in any other file,
+	// i.e. what would be freed if the file was removed.
+	ExclusiveBytes int64
+}
+
+// estimateEntry tracks a distinct chunk for EstimateDedup.
+type estimateEntry struct {
+	length int64
+
+	// file is the index of the only file containing the chunk, or -1 if it is shared.
+	file int
+}
+
+// EstimateDedup chunks all files and reports how well they deduplicate, overall and per file.
+// The hash is taken from opts and defaults to SHA-256.
+func EstimateDedup(files []io.Reader, opts *Options) (*DedupReport, error) {
+	o := Options{}
+	if opts != nil {
+		o = *opts
+	}
+	if o.Hasher == nil && o.HashName == "" && !o.SHA256 {
+		o.HashName = "sha256"
+	}
+
+	report := &DedupReport{Files: make([]FileContribution, len(files))}
+	entries := make(map[string]*estimateEntry)
+	for i, r := range files {
+		f := &report.Files[i]
+		ch := NewChunker(r, &o)
+		for {
+			c, err := ch.Next()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return nil, err
+			}
+			length := int64(len(c.Data))
+			f.Chunks++
+			f.Bytes += length
+			if e, ok := entries[string(c.Sum)]; !ok {
+				entries[string(c.Sum)] = &estimateEntry{length: length, file: i}
+				f.NewChunks++
+				f.NewBytes += length
+			} else if e.file != i {
+				e.file = -1
+			}
+			ReleaseChunk(c)
+		}
+		report.Chunks += f.Chunks
+		report.Bytes += f.Bytes
+		report.UniqueChunks += f.NewChunks
+		report.UniqueBytes += f.NewBytes
+	}
+	for _, e := range entries {
+		if e.file >= 0 {
+			report.Files[e.file].ExclusiveBytes += e.length
+		}
+	}
+	return report, nil
+}
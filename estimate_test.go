@@ -0,0 +1,48 @@
+package ae
+
+import (
+	"bytes"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"testing"
+)
+
+func TestEstimateDedup(t *testing.T) {
+	opts := &Options{AverageSize: 64 * 1024}
+	a := testFile[:4*MiB]
+	b := testFile[4*MiB : 6*MiB]
+
+	t.Run("disjoint", func(t *testing.T) {
+		report, err := EstimateDedup([]io.Reader{bytes.NewReader(a), bytes.NewReader(b)}, opts)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(6*MiB), report.Bytes)
+		assert.Equal(t, report.Bytes, report.UniqueBytes)
+		assert.InDelta(t, 1, report.DedupRatio(), 0.01)
+		assert.Len(t, report.Files, 2)
+		assert.Equal(t, int64(4*MiB), report.Files[0].Bytes)
+		assert.Equal(t, report.Files[0].Bytes, report.Files[0].NewBytes)
+		assert.Equal(t, report.Files[1].Bytes, report.Files[1].ExclusiveBytes)
+	})
+
+	t.Run("duplicate", func(t *testing.T) {
+		report, err := EstimateDedup([]io.Reader{bytes.NewReader(a), bytes.NewReader(b), bytes.NewReader(a)}, opts)
+		assert.NoError(t, err)
+		assert.Equal(t, int64(10*MiB), report.Bytes)
+		assert.Equal(t, int64(6*MiB), report.UniqueBytes)
+		assert.Equal(t, report.Files[0].Chunks+report.Files[1].Chunks, report.UniqueChunks)
+		assert.InDelta(t, 10.0/6, report.DedupRatio(), 0.01)
+
+		assert.Equal(t, int64(4*MiB), report.Files[0].NewBytes)
+		assert.Zero(t, report.Files[0].ExclusiveBytes)
+		assert.Equal(t, int64(2*MiB), report.Files[1].ExclusiveBytes)
+		assert.Zero(t, report.Files[2].NewBytes)
+		assert.Zero(t, report.Files[2].NewChunks)
+	})
+
+	t.Run("read error", func(t *testing.T) {
+		errFoo := errors.New("foo")
+		_, err := EstimateDedup([]io.Reader{&errReader{bytes.NewReader(a), errFoo}}, opts)
+		assert.Equal(t, errFoo, err)
+	})
+}
@@ -0,0 +1,79 @@
+package ae
+
+import (
+	"context"
+	"fmt"
+	"golang.org/x/sync/errgroup"
+	"io"
+	"io/fs"
+	"os"
+	"runtime"
+)
+
+// ChunkFiles chunks the files at paths with opts, chunking up to workers files concurrently, and calls visit
+// for every chunk along with the path of its file, which the chunk also carries in Chunk.File.
+// Workers defaults to GOMAXPROCS. The chunks of a file are visited in order, but visit is called concurrently
+// for different files and must be safe for concurrent use.
+//
+// ChunkFiles stops at the first error, be it opening or reading a file or returned by visit, or when ctx is
+// cancelled, and returns that error once all files being chunked are closed. Errors of visit are returned as is.
+func ChunkFiles(ctx context.Context, paths []string, opts *Options, workers int, visit func(path string, c Chunk) error) error {
+	return chunkFiles(ctx, paths, NewChunkerPool(opts), workers, openFile, visit)
+}
+
+// openFile opens the file at path of the local file system.
+func openFile(path string) (fs.File, error) {
+	return os.Open(path)
+}
+
+// chunkFiles implements ChunkFiles with Chunkers of pool, opening the files with open.
+func chunkFiles(ctx context.Context, paths []string, pool *ChunkerPool, workers int, open func(path string) (fs.File, error),
+	visit func(path string, c Chunk) error) error {
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	g, gctx := errgroup.WithContext(ctx)
+	g.SetLimit(workers)
+	for _, path := range paths {
+		path := path
+		if gctx.Err() != nil {
+			break
+		}
+		g.Go(func() error {
+			return visitFile(gctx, pool, open, path, visit)
+		})
+	}
+	if err := g.Wait(); err != nil {
+		return err
+	}
+	return ctx.Err()
+}
+
+// visitFile chunks the file at path, opened with open, with a Chunker of pool and calls visit for every chunk
+// until the file is exhausted, visit fails or ctx is cancelled.
+func visitFile(ctx context.Context, pool *ChunkerPool, open func(path string) (fs.File, error), path string,
+	visit func(path string, c Chunk) error) error {
+	f, err := open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	ch := pool.Get(f)
+	defer pool.Put(ch)
+	for {
+		if err := ctx.Err(); err != nil {
+			return err
+		}
+		c, err := ch.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("ae: chunking %s: %w", path, err)
+		}
+		c.File = path
+		if err := visit(path, *c); err != nil {
+			return err
+		}
+	}
+}
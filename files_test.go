@@ -0,0 +1,85 @@
+package ae
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestChunkFiles(t *testing.T) {
+	opts := &Options{AverageSize: 16 * 1024, SHA256: true}
+	dir := t.TempDir()
+	var paths []string
+	for i, data := range [][]byte{testFile[:MiB], nil, testFile[MiB : MiB+100], testFile[2*MiB : 4*MiB]} {
+		p := filepath.Join(dir, string(rune('a'+i)))
+		require.NoError(t, os.WriteFile(p, data, 0o644))
+		paths = append(paths, p)
+	}
+
+	var mu sync.Mutex
+	got := make(map[string][]Chunk)
+	err := ChunkFiles(context.Background(), paths, opts, 2, func(path string, c Chunk) error {
+		mu.Lock()
+		defer mu.Unlock()
+		assert.Equal(t, path, c.File)
+		got[path] = append(got[path], c)
+		return nil
+	})
+	require.NoError(t, err)
+	assert.Len(t, got, 3)
+	for _, p := range paths {
+		data, err := os.ReadFile(p)
+		require.NoError(t, err)
+		want := getChunks(NewChunker(bytes.NewReader(data), opts))
+		require.Len(t, got[p], len(want), p)
+		for i, c := range got[p] {
+			assert.Equal(t, want[i], c.Data, p)
+		}
+	}
+
+	t.Run("first error", func(t *testing.T) {
+		errStop := errors.New("stop")
+		var mu sync.Mutex
+		visited := 0
+		err := ChunkFiles(context.Background(), paths, opts, 1, func(path string, c Chunk) error {
+			mu.Lock()
+			defer mu.Unlock()
+			visited++
+			return errStop
+		})
+		assert.Equal(t, errStop, err)
+		assert.Equal(t, 1, visited)
+
+		err = ChunkFiles(context.Background(), append(paths, filepath.Join(dir, "missing")), opts, 0, func(string, Chunk) error {
+			return nil
+		})
+		assert.ErrorIs(t, err, os.ErrNotExist)
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		var mu sync.Mutex
+		visited := 0
+		err := ChunkFiles(ctx, paths, opts, 1, func(string, Chunk) error {
+			mu.Lock()
+			defer mu.Unlock()
+			visited++
+			cancel()
+			return nil
+		})
+		assert.Equal(t, context.Canceled, err)
+		assert.Equal(t, 1, visited)
+
+		err = ChunkFiles(ctx, paths, opts, 1, func(string, Chunk) error {
+			t.Error("visited after cancellation")
+			return nil
+		})
+		assert.Equal(t, context.Canceled, err)
+	})
+}
@@ -0,0 +1,174 @@
+package ae
+
+import (
+	"encoding/hex"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+)
+
+// SyncPolicy defines how durably a FileStore persists chunks.
+type SyncPolicy uint8
+
+const (
+	// SyncNone leaves flushing to the operating system.
+	SyncNone SyncPolicy = iota
+
+	// SyncFile flushes every chunk file before it is made visible.
+	SyncFile
+
+	// SyncAll additionally flushes the directory entry of every chunk file.
+	SyncAll
+)
+
+// FileStoreOptions configure a FileStore.
+type FileStoreOptions struct {
+	// FanOut is the number of directory levels used for sharding (optional).
+	// Each level is named by the next two hex digits of the hash. It defaults to 2.
+	FanOut int
+
+	// Sync defines the durability of writes (optional).
+	Sync SyncPolicy
+}
+
+// FileStore is a ChunkStore keeping each chunk in its own file, named by the hex encoded hash.
+// Files are sharded into fan-out directories to keep directories small,
+// and written atomically so that a crash never leaves a partial chunk behind.
+type FileStore struct {
+	dir    string
+	fanOut int
+	sync   SyncPolicy
+}
+
+// NewFileStore returns a FileStore rooted at dir, which is created if necessary.
+func NewFileStore(dir string, opts *FileStoreOptions) (*FileStore, error) {
+	s := &FileStore{dir: dir, fanOut: 2}
+	if opts != nil {
+		if opts.FanOut > 0 {
+			s.fanOut = opts.FanOut
+		}
+		s.sync = opts.Sync
+	}
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// path returns the file path of the chunk with the given hash.
+func (s *FileStore) path(hash []byte) string {
+	name := hex.EncodeToString(hash)
+	elems := make([]string, 0, s.fanOut+2)
+	elems = append(elems, s.dir)
+	for i := 0; i < s.fanOut && 2*i+2 <= len(name); i++ {
+		elems = append(elems, name[2*i:2*i+2])
+	}
+	return filepath.Join(append(elems, name)...)
+}
+
+// Put stores data under hash.
+func (s *FileStore) Put(hash []byte, data []byte) error {
+	p := s.path(hash)
+	if _, err := os.Stat(p); err == nil {
+		return nil
+	}
+	return writeFileAtomic(p, data, s.sync)
+}
+
+// writeFileAtomic writes data to the file p, creating its directory if necessary.
+// The data is written to a temporary file first and renamed, so that p never holds partial data.
+func writeFileAtomic(p string, data []byte, sync SyncPolicy) error {
+	dir := filepath.Dir(p)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	f, err := os.CreateTemp(dir, ".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if sync >= SyncFile {
+		if err := f.Sync(); err != nil {
+			f.Close()
+			return err
+		}
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(f.Name(), p); err != nil {
+		return err
+	}
+	if sync >= SyncAll {
+		return syncDir(dir)
+	}
+	return nil
+}
+
+// Get returns the data stored under hash.
+func (s *FileStore) Get(hash []byte) ([]byte, error) {
+	data, err := os.ReadFile(s.path(hash))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, ErrChunkNotFound
+	}
+	return data, err
+}
+
+// Has reports whether a chunk is stored under hash.
+func (s *FileStore) Has(hash []byte) (bool, error) {
+	_, err := os.Stat(s.path(hash))
+	if errors.Is(err, fs.ErrNotExist) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Delete removes the chunk stored under hash.
+func (s *FileStore) Delete(hash []byte) error {
+	err := os.Remove(s.path(hash))
+	if errors.Is(err, fs.ErrNotExist) {
+		return ErrChunkNotFound
+	}
+	return err
+}
+
+// Walk calls fn for every chunk in the store.
+func (s *FileStore) Walk(fn func(hash []byte, size int64) error) error {
+	return filepath.WalkDir(s.dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return nil
+		}
+		hash, err := hex.DecodeString(d.Name())
+		if err != nil || s.path(hash) != path {
+			// temporary or foreign file
+			return nil
+		}
+		info, err := d.Info()
+		if errors.Is(err, fs.ErrNotExist) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		return fn(hash, info.Size())
+	})
+}
+
+// syncDir flushes the directory entries of dir.
+func syncDir(dir string) error {
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
@@ -0,0 +1,96 @@
+package ae
+
+import (
+	"crypto/sha256"
+	"github.com/stretchr/testify/assert"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+// testChunkStore runs the tests common to all ChunkStore implementations.
+func testChunkStore(t *testing.T, s ChunkStore) {
+	data := []byte("hello world")
+	sum := sha256.Sum256(data)
+	hash := sum[:]
+
+	ok, err := s.Has(hash)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	_, err = s.Get(hash)
+	assert.Equal(t, ErrChunkNotFound, err)
+	assert.Equal(t, ErrChunkNotFound, s.Delete(hash))
+
+	assert.NoError(t, s.Put(hash, data))
+	assert.NoError(t, s.Put(hash, data))
+	ok, err = s.Has(hash)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	stored, err := s.Get(hash)
+	assert.NoError(t, err)
+	assert.Equal(t, data, stored)
+
+	assert.NoError(t, s.Delete(hash))
+	ok, err = s.Has(hash)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+
+	t.Run("concurrent puts", func(t *testing.T) {
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				data := []byte{byte(i % 2)}
+				sum := sha256.Sum256(data)
+				assert.NoError(t, s.Put(sum[:], data))
+				stored, err := s.Get(sum[:])
+				assert.NoError(t, err)
+				assert.Equal(t, data, stored)
+			}(i)
+		}
+		wg.Wait()
+	})
+}
+
+func TestFileStore(t *testing.T) {
+	for _, policy := range []SyncPolicy{SyncNone, SyncFile, SyncAll} {
+		dir := t.TempDir()
+		s, err := NewFileStore(dir, &FileStoreOptions{Sync: policy})
+		assert.NoError(t, err)
+		testChunkStore(t, s)
+	}
+
+	t.Run("sharding", func(t *testing.T) {
+		dir := t.TempDir()
+		s, err := NewFileStore(dir, &FileStoreOptions{FanOut: 3})
+		assert.NoError(t, err)
+		assert.NoError(t, s.Put([]byte{0xab, 0xcd, 0xef, 0x01}, []byte("foo")))
+		data, err := os.ReadFile(filepath.Join(dir, "ab", "cd", "ef", "abcdef01"))
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("foo"), data)
+
+		entries, err := os.ReadDir(filepath.Join(dir, "ab", "cd", "ef"))
+		assert.NoError(t, err)
+		assert.Len(t, entries, 1)
+	})
+}
+
+func TestFileStore_Walk(t *testing.T) {
+	dir := t.TempDir()
+	s, err := NewFileStore(dir, nil)
+	assert.NoError(t, err)
+	want := map[string]int64{}
+	for i := 0; i < 10; i++ {
+		assert.NoError(t, s.Put(testHash(i), make([]byte, i)))
+		want[string(testHash(i))] = int64(i)
+	}
+
+	got := map[string]int64{}
+	assert.NoError(t, s.Walk(func(hash []byte, size int64) error {
+		got[string(hash)] = size
+		return nil
+	}))
+	assert.Equal(t, want, got)
+}
@@ -0,0 +1,194 @@
+package ae
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+)
+
+// frameMagic prefixes every stream of frames.
+var frameMagic = []byte("AEfr")
+
+// frameVersion is the version of the framing format.
+const frameVersion = 1
+
+// maxFrameSize is the largest chunk a FrameReader accepts, which protects it from allocating
+// arbitrary amounts of memory for corrupt streams.
+const maxFrameSize = 1 << 32
+
+// ErrInvalidFrame is returned when reading a malformed stream of frames.
+var ErrInvalidFrame = errors.New("ae: invalid frame")
+
+// FrameWriter encodes chunks as a stream of length-prefixed frames, e.g. for piping them between
+// processes or over sockets. Each frame holds the length of the chunk as a varint, the digest of the chunk
+// if the stream is hash-tagged, and the data. FrameReader decodes the stream.
+type FrameWriter struct {
+	w        io.Writer
+	hashName string
+	hash     hash.Hash
+	buf      []byte
+	err      error
+}
+
+// NewFrameWriter writes the header of a stream to w. If hashName is not empty, the stream is hash-tagged
+// with the registered hash of that name, and the reader verifies every chunk.
+func NewFrameWriter(w io.Writer, hashName string) (*FrameWriter, error) {
+	fw := &FrameWriter{w: w, hashName: hashName}
+	if hashName != "" {
+		fn, err := LookupHash(hashName)
+		if err != nil {
+			return nil, err
+		}
+		fw.hash = fn()
+	}
+	buf := append([]byte(nil), frameMagic...)
+	buf = append(buf, frameVersion)
+	buf = appendUvarint(buf, uint64(len(hashName)))
+	buf = append(buf, hashName...)
+	if _, err := w.Write(buf); err != nil {
+		return nil, err
+	}
+	return fw, nil
+}
+
+// WriteChunk writes c as a frame. The digest is taken from c.Sum if it was produced by the hash of the stream
+// and computed otherwise. It does not retain c.
+func (fw *FrameWriter) WriteChunk(c *Chunk) error {
+	if fw.err != nil {
+		return fw.err
+	}
+	if len(c.Data) == 0 {
+		return errors.New("ae: cannot frame an empty chunk")
+	}
+	fw.buf = appendUvarint(fw.buf[:0], uint64(len(c.Data)))
+	if fw.hash != nil {
+		if c.Sum != nil && c.hashName == fw.hashName {
+			fw.buf = append(fw.buf, c.Sum...)
+		} else {
+			fw.hash.Reset()
+			fw.hash.Write(c.Data)
+			fw.buf = fw.hash.Sum(fw.buf)
+		}
+	}
+	if _, fw.err = fw.w.Write(fw.buf); fw.err != nil {
+		return fw.err
+	}
+	_, fw.err = fw.w.Write(c.Data)
+	return fw.err
+}
+
+// Close terminates the stream. It does not close the underlying writer.
+func (fw *FrameWriter) Close() error {
+	if fw.err != nil {
+		return fw.err
+	}
+	_, fw.err = fw.w.Write([]byte{0})
+	if fw.err == nil {
+		fw.err = errors.New("ae: frame writer is closed")
+		return nil
+	}
+	return fw.err
+}
+
+// FrameReader decodes a stream of frames written by FrameWriter.
+type FrameReader struct {
+	r        *bufio.Reader
+	hashName string
+	hash     hash.Hash
+	offset   int64
+	done     bool
+}
+
+// NewFrameReader reads the header of a stream from r.
+// The reader may read beyond the end of the stream.
+func NewFrameReader(r io.Reader) (*FrameReader, error) {
+	br, ok := r.(*bufio.Reader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+	header := make([]byte, len(frameMagic)+1)
+	if _, err := io.ReadFull(br, header); err != nil {
+		return nil, ErrInvalidFrame
+	}
+	if !bytes.Equal(header[:len(frameMagic)], frameMagic) {
+		return nil, ErrInvalidFrame
+	}
+	if v := header[len(frameMagic)]; v != frameVersion {
+		return nil, fmt.Errorf("ae: unsupported frame version %d", v)
+	}
+	nameLen, err := binary.ReadUvarint(br)
+	if err != nil || nameLen > 255 {
+		return nil, ErrInvalidFrame
+	}
+	name := make([]byte, nameLen)
+	if _, err := io.ReadFull(br, name); err != nil {
+		return nil, ErrInvalidFrame
+	}
+
+	fr := &FrameReader{r: br, hashName: string(name)}
+	if fr.hashName != "" {
+		fn, err := LookupHash(fr.hashName)
+		if err != nil {
+			return nil, err
+		}
+		fr.hash = fn()
+	}
+	return fr, nil
+}
+
+// HashName returns the name of the hash the stream is tagged with, or "" if it is not.
+func (fr *FrameReader) HashName() string {
+	return fr.hashName
+}
+
+// Next returns the next chunk of the stream, or io.EOF once the stream has been terminated.
+// For a hash-tagged stream, the chunk is verified against its digest, which is returned in Sum.
+// If the stream ends without being terminated, io.ErrUnexpectedEOF is returned.
+// The chunk may be handed back with ReleaseChunk.
+func (fr *FrameReader) Next() (*Chunk, error) {
+	if fr.done {
+		return nil, io.EOF
+	}
+	length, err := binary.ReadUvarint(fr.r)
+	if err == io.EOF {
+		return nil, io.ErrUnexpectedEOF
+	}
+	if err != nil {
+		return nil, ErrInvalidFrame
+	}
+	if length == 0 {
+		fr.done = true
+		return nil, io.EOF
+	}
+	if length > maxFrameSize || length > uint64(maxInt) {
+		return nil, ErrInvalidFrame
+	}
+
+	c := &Chunk{Offset: fr.offset}
+	if fr.hash != nil {
+		c.Sum = make([]byte, fr.hash.Size())
+		if _, err := io.ReadFull(fr.r, c.Sum); err != nil {
+			return nil, io.ErrUnexpectedEOF
+		}
+		c.hashName = fr.hashName
+	}
+	c.Data = getBuf(int(length))
+	if _, err := io.ReadFull(fr.r, c.Data); err != nil {
+		putBuf(c.Data)
+		return nil, io.ErrUnexpectedEOF
+	}
+	if fr.hash != nil {
+		fr.hash.Reset()
+		fr.hash.Write(c.Data)
+		if !bytes.Equal(fr.hash.Sum(nil), c.Sum) {
+			putBuf(c.Data)
+			return nil, fmt.Errorf("%w at offset %d", ErrCorruptChunk, fr.offset)
+		}
+	}
+	fr.offset += int64(length)
+	return c, nil
+}
@@ -0,0 +1,121 @@
+package ae
+
+import (
+	"bytes"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"testing"
+)
+
+// readFrames returns the chunks of a stream of frames.
+func readFrames(t *testing.T, stream []byte) ([]*Chunk, error) {
+	t.Helper()
+	fr, err := NewFrameReader(bytes.NewReader(stream))
+	if err != nil {
+		return nil, err
+	}
+	var chunks []*Chunk
+	for {
+		c, err := fr.Next()
+		if err == io.EOF {
+			return chunks, nil
+		}
+		if err != nil {
+			return chunks, err
+		}
+		chunks = append(chunks, c)
+	}
+}
+
+func TestFrameWriter(t *testing.T) {
+	input := testFile[:2*MiB]
+	chunks := getChunks(NewChunker(bytes.NewReader(input), &Options{AverageSize: 64 * 1024}))
+
+	// frame writes the chunks of input, chunked with opts, as a stream tagged with hashName.
+	frame := func(hashName string, opts *Options) []byte {
+		var buf bytes.Buffer
+		fw, err := NewFrameWriter(&buf, hashName)
+		assert.NoError(t, err)
+		_, err = CopyChunks(fw, NewChunker(bytes.NewReader(input), opts))
+		assert.NoError(t, err)
+		assert.NoError(t, fw.Close())
+		assert.Error(t, fw.WriteChunk(&Chunk{Data: []byte{1}}))
+		return buf.Bytes()
+	}
+
+	for _, tc := range []struct {
+		name, hashName string
+		opts           *Options
+	}{
+		{"untagged", "", &Options{AverageSize: 64 * 1024}},
+		{"tagged", "sha256", &Options{AverageSize: 64 * 1024}},
+		{"tagged with digests of the chunker", "sha256", &Options{AverageSize: 64 * 1024, SHA256: true}},
+		{"tagged with another hash than the chunker", "blake3", &Options{AverageSize: 64 * 1024, SHA256: true}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			stream := frame(tc.hashName, tc.opts)
+			decoded, err := readFrames(t, stream)
+			assert.NoError(t, err)
+			assert.Len(t, decoded, len(chunks))
+			var offset int64
+			for i, c := range decoded {
+				assert.Equal(t, offset, c.Offset)
+				assert.Equal(t, chunks[i], c.Data)
+				if tc.hashName == "" {
+					assert.Nil(t, c.Sum)
+				} else {
+					fn, _ := LookupHash(tc.hashName)
+					h := fn()
+					h.Write(c.Data)
+					assert.Equal(t, h.Sum(nil), c.Sum)
+				}
+				offset += int64(len(c.Data))
+			}
+		})
+	}
+
+	t.Run("corrupt chunk", func(t *testing.T) {
+		stream := frame("sha256", &Options{AverageSize: 64 * 1024})
+		stream[len(stream)-2] ^= 1
+		decoded, err := readFrames(t, stream)
+		assert.True(t, errors.Is(err, ErrCorruptChunk))
+		assert.Len(t, decoded, len(chunks)-1)
+	})
+
+	t.Run("truncated stream", func(t *testing.T) {
+		stream := frame("", &Options{AverageSize: 64 * 1024})
+		_, err := readFrames(t, stream[:len(stream)-1])
+		assert.Equal(t, io.ErrUnexpectedEOF, err)
+		_, err = readFrames(t, stream[:len(stream)-100])
+		assert.Equal(t, io.ErrUnexpectedEOF, err)
+	})
+
+	t.Run("empty stream", func(t *testing.T) {
+		var buf bytes.Buffer
+		fw, err := NewFrameWriter(&buf, "")
+		assert.NoError(t, err)
+		assert.Error(t, fw.WriteChunk(&Chunk{}))
+		assert.NoError(t, fw.Close())
+		decoded, err := readFrames(t, buf.Bytes())
+		assert.NoError(t, err)
+		assert.Empty(t, decoded)
+	})
+
+	t.Run("invalid header", func(t *testing.T) {
+		_, err := NewFrameWriter(io.Discard, "foo")
+		assert.Error(t, err)
+		_, err = readFrames(t, []byte("AEmf\x01\x00"))
+		assert.Equal(t, ErrInvalidFrame, err)
+		_, err = readFrames(t, []byte("AEfr\x02\x00"))
+		assert.Error(t, err)
+		_, err = readFrames(t, []byte("AEfr\x01\x03foo"))
+		assert.Error(t, err)
+	})
+
+	t.Run("oversized frame", func(t *testing.T) {
+		stream := append([]byte("AEfr\x01\x00"), appendUvarint(nil, maxFrameSize+1)...)
+		_, err := readFrames(t, stream)
+		assert.Equal(t, ErrInvalidFrame, err)
+	})
+}
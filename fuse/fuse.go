@@ -0,0 +1,139 @@
+//go:build fuse
+
+// Package fuse mounts files chunked by ae as a read-only file system, so that backups can be browsed directly.
+// The files are described by their manifests, their chunks are read from an ae.ChunkStore on demand.
+//
+// The package depends on FUSE (Linux, macOS or FreeBSD) and is therefore only built with the tag fuse.
+package fuse
+
+import (
+	bazil "bazil.org/fuse"
+	bazilfs "bazil.org/fuse/fs"
+	"context"
+	"errors"
+	"fmt"
+	ae "github.com/mg98/ae-chunker-go"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strings"
+	"syscall"
+)
+
+// FS is the file system of the files described by a set of manifests.
+// It implements the FS interface of bazil.org/fuse/fs.
+type FS struct {
+	root *dir
+}
+
+// New returns the file system of the files described by manifests, keyed by their slash-separated paths.
+// Directories are implied by the paths. The chunks of the files are read from s.
+func New(manifests map[string]*ae.Manifest, s ae.ChunkStore) (*FS, error) {
+	root := newDir()
+	for name, m := range manifests {
+		clean := path.Clean("/" + name)[1:]
+		if clean == "" {
+			return nil, fmt.Errorf("fuse: invalid path %q", name)
+		}
+		d := root
+		elems := strings.Split(clean, "/")
+		for _, elem := range elems[:len(elems)-1] {
+			child, ok := d.children[elem]
+			if !ok {
+				child = newDir()
+				d.children[elem] = child
+			}
+			if d, ok = child.(*dir); !ok {
+				return nil, fmt.Errorf("fuse: %q is both a file and a directory", elem)
+			}
+		}
+		base := elems[len(elems)-1]
+		if _, ok := d.children[base]; ok {
+			return nil, fmt.Errorf("fuse: duplicate path %q", name)
+		}
+		d.children[base] = &file{m: m, r: ae.NewManifestReader(m, s)}
+	}
+	return &FS{root: root}, nil
+}
+
+// Root implements bazilfs.FS.
+func (f *FS) Root() (bazilfs.Node, error) {
+	return f.root, nil
+}
+
+// Mount mounts f read-only at mountpoint and serves it until it is unmounted, e.g. with fusermount -u.
+func Mount(mountpoint string, f *FS) error {
+	c, err := bazil.Mount(mountpoint, bazil.ReadOnly(), bazil.FSName("ae"), bazil.Subtype("aefs"))
+	if err != nil {
+		return err
+	}
+	defer c.Close()
+	if err := bazilfs.Serve(c, f); err != nil {
+		return err
+	}
+	<-c.Ready
+	return c.MountError
+}
+
+// dir is a directory of the file system.
+type dir struct {
+	children map[string]bazilfs.Node
+}
+
+// newDir returns an empty directory.
+func newDir() *dir {
+	return &dir{children: make(map[string]bazilfs.Node)}
+}
+
+// Attr implements bazilfs.Node.
+func (d *dir) Attr(ctx context.Context, a *bazil.Attr) error {
+	a.Mode = os.ModeDir | 0o555
+	return nil
+}
+
+// Lookup implements bazilfs.NodeStringLookuper.
+func (d *dir) Lookup(ctx context.Context, name string) (bazilfs.Node, error) {
+	if child, ok := d.children[name]; ok {
+		return child, nil
+	}
+	return nil, syscall.ENOENT
+}
+
+// ReadDirAll implements bazilfs.HandleReadDirAller.
+func (d *dir) ReadDirAll(ctx context.Context) ([]bazil.Dirent, error) {
+	entries := make([]bazil.Dirent, 0, len(d.children))
+	for name, child := range d.children {
+		typ := bazil.DT_File
+		if _, ok := child.(*dir); ok {
+			typ = bazil.DT_Dir
+		}
+		entries = append(entries, bazil.Dirent{Name: name, Type: typ})
+	}
+	sort.Slice(entries, func(i, j int) bool { return entries[i].Name < entries[j].Name })
+	return entries, nil
+}
+
+// file is a file of the file system, read from the chunk store on demand.
+type file struct {
+	m *ae.Manifest
+	r io.ReaderAt
+}
+
+// Attr implements bazilfs.Node.
+func (f *file) Attr(ctx context.Context, a *bazil.Attr) error {
+	a.Mode = 0o444
+	a.Size = uint64(f.m.Size)
+	return nil
+}
+
+// Read implements bazilfs.HandleReader.
+func (f *file) Read(ctx context.Context, req *bazil.ReadRequest, resp *bazil.ReadResponse) error {
+	buf := make([]byte, req.Size)
+	n, err := f.r.ReadAt(buf, req.Offset)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return err
+	}
+	resp.Data = buf[:n]
+	return nil
+}
@@ -0,0 +1,59 @@
+//go:build fuse
+
+package fuse
+
+import (
+	bazil "bazil.org/fuse"
+	"bytes"
+	"context"
+	ae "github.com/mg98/ae-chunker-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"math/rand"
+	"os"
+	"syscall"
+	"testing"
+)
+
+func TestFS(t *testing.T) {
+	ctx := context.Background()
+	data := make([]byte, 1<<20)
+	rand.New(rand.NewSource(1)).Read(data)
+	s := ae.NewMemoryStore(0)
+	m, err := ae.StoreFile(bytes.NewReader(data), s, &ae.Options{AverageSize: 16 * 1024})
+	require.NoError(t, err)
+
+	f, err := New(map[string]*ae.Manifest{"backup/2024/data.bin": m, "/top.bin": m}, s)
+	require.NoError(t, err)
+	root, err := f.Root()
+	require.NoError(t, err)
+
+	entries, err := root.(*dir).ReadDirAll(ctx)
+	require.NoError(t, err)
+	assert.Equal(t, []bazil.Dirent{{Name: "backup", Type: bazil.DT_Dir}, {Name: "top.bin", Type: bazil.DT_File}}, entries)
+
+	node, err := root.(*dir).Lookup(ctx, "backup")
+	require.NoError(t, err)
+	node, err = node.(*dir).Lookup(ctx, "2024")
+	require.NoError(t, err)
+	node, err = node.(*dir).Lookup(ctx, "data.bin")
+	require.NoError(t, err)
+	_, err = root.(*dir).Lookup(ctx, "missing")
+	assert.Equal(t, syscall.ENOENT, err)
+
+	var attr bazil.Attr
+	require.NoError(t, node.Attr(ctx, &attr))
+	assert.Equal(t, uint64(len(data)), attr.Size)
+	assert.Equal(t, os.FileMode(0o444), attr.Mode)
+
+	var resp bazil.ReadResponse
+	require.NoError(t, node.(*file).Read(ctx, &bazil.ReadRequest{Offset: 300000, Size: 4096}, &resp))
+	assert.Equal(t, data[300000:304096], resp.Data)
+	require.NoError(t, node.(*file).Read(ctx, &bazil.ReadRequest{Offset: int64(len(data)) - 10, Size: 4096}, &resp))
+	assert.Equal(t, data[len(data)-10:], resp.Data)
+
+	_, err = New(map[string]*ae.Manifest{"a": m, "a/b": m}, s)
+	assert.Error(t, err)
+	_, err = New(map[string]*ae.Manifest{"/": m}, s)
+	assert.Error(t, err)
+}
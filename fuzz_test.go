@@ -0,0 +1,74 @@
+package ae
+
+import (
+	"bytes"
+	"math/rand"
+	"testing"
+	"testing/iotest"
+)
+
+// FuzzChunker checks the invariants of the Chunker for arbitrary inputs and parameters:
+// the chunks add up to the input, respect the size bounds, and do not depend on how the input is read.
+// Seed inputs live in testdata/fuzz/FuzzChunker, new findings can be added there.
+func FuzzChunker(f *testing.F) {
+	f.Add([]byte{0x41, 0x16, 0xcd, 0x32, 0x91, 0xb6, 0x4f, 0x12, 0x19, 0x73, 0xea, 0x17, 0x22, 0xf0}, uint16(4), uint16(0), false)
+	f.Add(bytes.Repeat([]byte{0}, 1000), uint16(100), uint16(300), true)
+	random := make([]byte, 4*1024)
+	rand.New(rand.NewSource(1)).Read(random)
+	f.Add(random, uint16(512), uint16(0), false)
+	f.Add(random, uint16(128), uint16(150), true)
+
+	f.Fuzz(func(t *testing.T, input []byte, avgSize, maxSize uint16, min bool) {
+		if avgSize == 0 {
+			return
+		}
+		opts := &Options{AverageSize: int64(avgSize), MaxSize: int64(maxSize)}
+		if min {
+			opts.Mode = MIN
+		}
+		p := newParams(opts)
+		lower := p.minSize
+		if p.maxSize < lower {
+			lower = p.maxSize
+		}
+
+		chunks := getChunks(NewChunker(bytes.NewReader(input), opts))
+		var joined []byte
+		for i, c := range chunks {
+			if len(c) == 0 || int64(len(c)) > p.maxSize {
+				t.Fatalf("chunk %d of %d bytes exceeds the bounds (max %d)", i, len(c), p.maxSize)
+			}
+			if i < len(chunks)-1 && int64(len(c)) < lower {
+				t.Fatalf("chunk %d of %d bytes is shorter than the minimum size %d", i, len(c), lower)
+			}
+			joined = append(joined, c...)
+		}
+		if !bytes.Equal(input, joined) {
+			t.Fatalf("chunks add up to %d bytes instead of the input of %d bytes", len(joined), len(input))
+		}
+
+		bytewise := getChunks(NewChunker(iotest.OneByteReader(bytes.NewReader(input)), opts))
+		if len(bytewise) != len(chunks) {
+			t.Fatalf("reading byte by byte yields %d chunks instead of %d", len(bytewise), len(chunks))
+		}
+		for i := range chunks {
+			if !bytes.Equal(chunks[i], bytewise[i]) {
+				t.Fatalf("reading byte by byte changes chunk %d", i)
+			}
+		}
+
+		lc := NewLazyChunker(bytes.NewReader(input), opts)
+		for i := range chunks {
+			cr, err := lc.Next()
+			if err != nil {
+				t.Fatalf("lazy chunker fails at chunk %d: %v", i, err)
+			}
+			if cr.Len() != int64(len(chunks[i])) {
+				t.Fatalf("lazy chunk %d has %d bytes instead of %d", i, cr.Len(), len(chunks[i]))
+			}
+		}
+		if _, err := lc.Next(); err == nil {
+			t.Fatal("lazy chunker yields more chunks")
+		}
+	})
+}
@@ -0,0 +1,78 @@
+package ae
+
+// GCReport is the result of CollectGarbage.
+type GCReport struct {
+	// Chunks is the number of chunks found in the store.
+	Chunks int64
+
+	// Bytes is the stored size of all chunks found.
+	Bytes int64
+
+	// LiveChunks is the number of chunks referenced by the live manifests, including their parity shards.
+	LiveChunks int64
+
+	// Garbage is the number of unreferenced chunks.
+	Garbage int64
+
+	// ReclaimableBytes is the stored size of the unreferenced chunks.
+	ReclaimableBytes int64
+
+	// Deleted is the number of chunks actually deleted, i.e. zero in a dry run.
+	Deleted int64
+}
+
+// CollectGarbage deletes all chunks from s that are not referenced by any of the live manifests,
+// either as chunks or as the parity shards added by AddParity.
+// If dryRun is set, the chunks are only counted. The store must be a ChunkWalker;
+// stores that hide the hashes of their chunks, such as an EncryptedStore, cannot be collected.
+//
+// The collection must not run concurrently with backups writing to the same store,
+// as chunks written in the meantime are not referenced by any of the live manifests yet.
+func CollectGarbage(s ChunkStore, live []*Manifest, dryRun bool) (*GCReport, error) {
+	// mark
+	marked := make(map[string]bool)
+	for _, m := range live {
+		storedHashes(m, func(hash []byte) {
+			marked[string(hash)] = true
+		})
+	}
+
+	report := &GCReport{}
+	var garbage [][]byte
+	err := walkStore(s, func(hash []byte, size int64) error {
+		report.Chunks++
+		report.Bytes += size
+		if marked[string(hash)] {
+			report.LiveChunks++
+			return nil
+		}
+		report.Garbage++
+		report.ReclaimableBytes += size
+		garbage = append(garbage, append([]byte(nil), hash...))
+		return nil
+	})
+	if err != nil || dryRun {
+		return report, err
+	}
+
+	// sweep
+	for _, hash := range garbage {
+		if err := s.Delete(hash); err != nil && err != ErrChunkNotFound {
+			return report, err
+		}
+		report.Deleted++
+	}
+	return report, nil
+}
+
+// storedHashes calls fn with the hash of every chunk and parity shard of m, in order, including repeats.
+func storedHashes(m *Manifest, fn func(hash []byte)) {
+	for _, ref := range m.Chunks {
+		fn(ref.Hash)
+	}
+	for _, g := range m.Parity {
+		for _, shard := range g.Shards {
+			fn(shard)
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package ae
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestCollectGarbage(t *testing.T) {
+	opts := &Options{AverageSize: 64 * 1024}
+	a, b := testFile[:MiB], testFile[MiB:2*MiB]
+	ma, err := BuildManifest(bytes.NewReader(a), opts)
+	assert.NoError(t, err)
+	mb, err := BuildManifest(bytes.NewReader(b), opts)
+	assert.NoError(t, err)
+
+	for name, newStore := range map[string]func() ChunkStore{
+		"memory": func() ChunkStore { return NewMemoryStore(0) },
+		"file": func() ChunkStore {
+			s, err := NewFileStore(t.TempDir(), nil)
+			assert.NoError(t, err)
+			return s
+		},
+	} {
+		t.Run(name, func(t *testing.T) {
+			s := newStore()
+			for _, m := range []*Manifest{ma, mb} {
+				input := a
+				if m == mb {
+					input = b
+				}
+				for _, ref := range m.Chunks {
+					assert.NoError(t, s.Put(ref.Hash, input[ref.Offset:ref.Offset+ref.Length]))
+				}
+			}
+
+			report, err := CollectGarbage(s, []*Manifest{ma}, true)
+			assert.NoError(t, err)
+			assert.Equal(t, int64(len(ma.Chunks)+len(mb.Chunks)), report.Chunks)
+			assert.Equal(t, int64(2*MiB), report.Bytes)
+			assert.Equal(t, int64(len(ma.Chunks)), report.LiveChunks)
+			assert.Equal(t, int64(len(mb.Chunks)), report.Garbage)
+			assert.Equal(t, int64(MiB), report.ReclaimableBytes)
+			assert.Zero(t, report.Deleted)
+			ok, _ := s.Has(mb.Chunks[0].Hash)
+			assert.True(t, ok)
+
+			report, err = CollectGarbage(s, []*Manifest{ma}, false)
+			assert.NoError(t, err)
+			assert.Equal(t, int64(len(mb.Chunks)), report.Deleted)
+			for _, ref := range mb.Chunks {
+				ok, _ := s.Has(ref.Hash)
+				assert.False(t, ok)
+			}
+			var buf bytes.Buffer
+			assert.NoError(t, Reassemble(&buf, ma, s))
+			assert.Equal(t, a, buf.Bytes())
+		})
+	}
+
+	t.Run("parity", func(t *testing.T) {
+		m, s := storeInput(t, a, opts)
+		assert.NoError(t, AddParity(m, s, 4, 2))
+		stored := s.Len()
+
+		report, err := CollectGarbage(s, []*Manifest{m}, false)
+		assert.NoError(t, err)
+		assert.Zero(t, report.Garbage)
+		assert.Equal(t, int64(stored), report.LiveChunks)
+		assert.Equal(t, stored, s.Len())
+
+		assert.NoError(t, s.Delete(m.Chunks[0].Hash))
+		n, err := RepairChunks(m, s)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, n)
+	})
+
+	t.Run("unsupported store", func(t *testing.T) {
+		_, err := CollectGarbage(NewEncryptedStore(NewMemoryStore(0), nil), []*Manifest{ma}, true)
+		assert.Equal(t, ErrWalkUnsupported, err)
+	})
+}
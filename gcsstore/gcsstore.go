@@ -0,0 +1,248 @@
+// Package gcsstore implements an ae.ChunkStore on top of the JSON API of Google Cloud Storage,
+// so that chunks can be pushed directly to a bucket.
+package gcsstore
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	ae "github.com/mg98/ae-chunker-go"
+	"github.com/mg98/ae-chunker-go/internal/retry"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Options configure a Store.
+type Options struct {
+	// Endpoint is the base URL of the service (optional). It defaults to "https://storage.googleapis.com".
+	Endpoint string
+
+	// Bucket holding the chunks.
+	Bucket string
+
+	// Prefix prepended to the object names (optional), e.g. "chunks/".
+	Prefix string
+
+	// Token is an OAuth 2.0 access token authorizing the requests (optional).
+	// Requests are anonymous if neither Token nor TokenSource is set.
+	Token string
+
+	// TokenSource returns the access token for every request (optional), so that expiring tokens can be refreshed.
+	// It takes precedence over Token.
+	TokenSource func() (string, error)
+
+	// Concurrency bounds the number of requests in flight (optional), so that the store can be shared
+	// by parallel uploads. It defaults to 16.
+	Concurrency int
+
+	// Retries is the number of times a failed request is retried (optional). It defaults to 3.
+	// Only network errors, throttling and server errors are retried. A negative value disables retries.
+	Retries int
+
+	// Client sends the requests (optional). It defaults to http.DefaultClient.
+	Client *http.Client
+}
+
+// Error is an error response of the service.
+type Error struct {
+	StatusCode int
+	Message    string
+}
+
+func (e *Error) Error() string {
+	if e.Message == "" {
+		return fmt.Sprintf("gcsstore: %s", http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("gcsstore: %s", e.Message)
+}
+
+// errorResponse is the body of an error response.
+type errorResponse struct {
+	Error struct {
+		Message string `json:"message"`
+	} `json:"error"`
+}
+
+// Store is an ae.ChunkStore keeping each chunk in an object named by the hex encoded hash.
+type Store struct {
+	endpoint    *url.URL
+	bucket      string
+	prefix      string
+	tokenSource func() (string, error)
+	client      *retry.Client
+}
+
+var _ ae.ChunkStore = (*Store)(nil)
+
+// New returns a Store for the bucket described by opts.
+func New(opts Options) (*Store, error) {
+	rawEndpoint := opts.Endpoint
+	if rawEndpoint == "" {
+		rawEndpoint = "https://storage.googleapis.com"
+	}
+	endpoint, err := url.Parse(rawEndpoint)
+	if err != nil {
+		return nil, err
+	}
+	if endpoint.Scheme == "" || endpoint.Host == "" {
+		return nil, fmt.Errorf("gcsstore: invalid endpoint %q", rawEndpoint)
+	}
+	if opts.Bucket == "" {
+		return nil, errors.New("gcsstore: missing bucket")
+	}
+	tokenSource := opts.TokenSource
+	if tokenSource == nil {
+		token := opts.Token
+		tokenSource = func() (string, error) { return token, nil }
+	}
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = 16
+	}
+
+	return &Store{
+		endpoint:    endpoint,
+		bucket:      opts.Bucket,
+		prefix:      opts.Prefix,
+		tokenSource: tokenSource,
+		client:      retry.New(opts.Client, opts.Retries, concurrency),
+	}, nil
+}
+
+// Put uploads data under hash.
+func (s *Store) Put(hash []byte, data []byte) error {
+	u := s.apiURL("/upload/storage/v1/b/", s.bucket, "/o")
+	u.RawQuery = url.Values{"uploadType": {"media"}, "name": {s.name(hash)}}.Encode()
+	_, err := s.request(http.MethodPost, u, data)
+	return err
+}
+
+// Get downloads the data stored under hash.
+func (s *Store) Get(hash []byte) ([]byte, error) {
+	u := s.objectURL(hash)
+	u.RawQuery = "alt=media"
+	return s.request(http.MethodGet, u, nil)
+}
+
+// Has reports whether a chunk is stored under hash.
+func (s *Store) Has(hash []byte) (bool, error) {
+	u := s.objectURL(hash)
+	u.RawQuery = "fields=name"
+	_, err := s.request(http.MethodGet, u, nil)
+	if err == ae.ErrChunkNotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Delete removes the chunk stored under hash.
+func (s *Store) Delete(hash []byte) error {
+	_, err := s.request(http.MethodDelete, s.objectURL(hash), nil)
+	return err
+}
+
+// listResult is the response to a list request.
+type listResult struct {
+	Items []struct {
+		Name string `json:"name"`
+		Size int64  `json:"size,string"`
+	} `json:"items"`
+	NextPageToken string `json:"nextPageToken"`
+}
+
+// Walk calls fn for every chunk in the store. Objects under the prefix that are not named by a hash are skipped.
+func (s *Store) Walk(fn func(hash []byte, size int64) error) error {
+	var token string
+	for {
+		query := url.Values{"prefix": {s.prefix}, "fields": {"items(name,size),nextPageToken"}}
+		if token != "" {
+			query.Set("pageToken", token)
+		}
+		u := s.apiURL("/storage/v1/b/", s.bucket, "/o")
+		u.RawQuery = query.Encode()
+		data, err := s.request(http.MethodGet, u, nil)
+		if err != nil {
+			return err
+		}
+		var result listResult
+		if err := json.Unmarshal(data, &result); err != nil {
+			return fmt.Errorf("gcsstore: invalid listing: %w", err)
+		}
+		for _, obj := range result.Items {
+			hash, err := hex.DecodeString(strings.TrimPrefix(obj.Name, s.prefix))
+			if err != nil || len(hash) == 0 {
+				continue
+			}
+			if err := fn(hash, obj.Size); err != nil {
+				return err
+			}
+		}
+		if result.NextPageToken == "" {
+			return nil
+		}
+		token = result.NextPageToken
+	}
+}
+
+// name returns the name of the object of hash.
+func (s *Store) name(hash []byte) string {
+	return s.prefix + hex.EncodeToString(hash)
+}
+
+// objectURL returns the URL of the object of hash.
+func (s *Store) objectURL(hash []byte) *url.URL {
+	return s.apiURL("/storage/v1/b/", s.bucket, "/o/", s.name(hash))
+}
+
+// apiURL returns the URL of the endpoint with the path elements appended.
+// Every other element, starting with the second, is a name that is escaped as a single path segment.
+func (s *Store) apiURL(elems ...string) *url.URL {
+	u := *s.endpoint
+	base := strings.TrimSuffix(u.Path, "/")
+	u.Path, u.RawPath = base, base
+	for i, elem := range elems {
+		u.Path += elem
+		if i%2 == 1 {
+			elem = url.PathEscape(elem)
+		}
+		u.RawPath += elem
+	}
+	return &u
+}
+
+// request sends a request with an optional body to u, retrying on transient errors, and returns the response body.
+// A missing object is reported as ae.ErrChunkNotFound.
+func (s *Store) request(method string, u *url.URL, body []byte) ([]byte, error) {
+	token, err := s.tokenSource()
+	if err != nil {
+		return nil, fmt.Errorf("gcsstore: obtaining token: %w", err)
+	}
+	resp, err := s.client.Do(func() (*http.Request, error) {
+		req, err := retry.NewRequest(method, u.String(), body)
+		if err != nil {
+			return nil, err
+		}
+		if body != nil {
+			req.Header.Set("Content-Type", "application/octet-stream")
+		}
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		return req, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		// Uploads into a missing bucket are not mistaken for missing chunks.
+		if resp.StatusCode == http.StatusNotFound && method != http.MethodPost {
+			return nil, ae.ErrChunkNotFound
+		}
+		var body errorResponse
+		json.Unmarshal(resp.Body, &body)
+		return nil, &Error{StatusCode: resp.StatusCode, Message: body.Error.Message}
+	}
+	return resp.Body, nil
+}
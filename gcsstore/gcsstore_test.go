@@ -0,0 +1,218 @@
+package gcsstore
+
+import (
+	"encoding/json"
+	"errors"
+	ae "github.com/mg98/ae-chunker-go"
+	"github.com/mg98/ae-chunker-go/internal/retry/retrytest"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeGCS is a minimal in-memory implementation of the JSON API of Cloud Storage.
+type fakeGCS struct {
+	*retrytest.Flaky
+
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func (f *fakeGCS) serve(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if r.Header.Get("Authorization") != "Bearer token" {
+		w.WriteHeader(http.StatusUnauthorized)
+		io.WriteString(w, `{"error":{"code":401,"message":"Invalid Credentials"}}`)
+		return
+	}
+	query := r.URL.Query()
+	switch {
+	case r.Method == http.MethodPost && r.URL.Path == "/upload/storage/v1/b/bucket/o":
+		if query.Get("uploadType") != "media" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		data, _ := io.ReadAll(r.Body)
+		f.objects[query.Get("name")] = data
+		json.NewEncoder(w).Encode(map[string]string{"name": query.Get("name")})
+	case r.Method == http.MethodGet && r.URL.Path == "/storage/v1/b/bucket/o":
+		f.list(w, r)
+	case strings.HasPrefix(r.URL.EscapedPath(), "/storage/v1/b/bucket/o/"):
+		name := strings.TrimPrefix(r.URL.Path, "/storage/v1/b/bucket/o/")
+		if strings.Contains(strings.TrimPrefix(r.URL.EscapedPath(), "/storage/v1/b/bucket/o/"), "/") {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		data, ok := f.objects[name]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			io.WriteString(w, `{"error":{"code":404,"message":"No such object: bucket/`+name+`"}}`)
+			return
+		}
+		switch {
+		case r.Method == http.MethodDelete:
+			delete(f.objects, name)
+			w.WriteHeader(http.StatusNoContent)
+		case query.Get("alt") == "media":
+			w.Write(data)
+		default:
+			json.NewEncoder(w).Encode(map[string]string{"name": name})
+		}
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+// list answers a list request with pages of two objects.
+func (f *fakeGCS) list(w http.ResponseWriter, r *http.Request) {
+	var names []string
+	for k := range f.objects {
+		if strings.HasPrefix(k, r.URL.Query().Get("prefix")) && k > r.URL.Query().Get("pageToken") {
+			names = append(names, k)
+		}
+	}
+	sort.Strings(names)
+	var result struct {
+		Items         []map[string]string `json:"items,omitempty"`
+		NextPageToken string              `json:"nextPageToken,omitempty"`
+	}
+	for i, k := range names {
+		if i == 2 {
+			result.NextPageToken = names[i-1]
+			break
+		}
+		result.Items = append(result.Items, map[string]string{"name": k, "size": strconv.Itoa(len(f.objects[k]))})
+	}
+	json.NewEncoder(w).Encode(result)
+}
+
+func newTestStore(t *testing.T, opts Options) (*Store, *fakeGCS) {
+	f := &fakeGCS{objects: make(map[string][]byte)}
+	f.Flaky = &retrytest.Flaky{Handler: http.HandlerFunc(f.serve), Status: http.StatusServiceUnavailable,
+		Body: `{"error":{"code":503,"message":"Backend Error"}}`}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	opts.Endpoint = srv.URL
+	opts.Bucket = "bucket"
+	if opts.Token == "" && opts.TokenSource == nil {
+		opts.Token = "token"
+	}
+	s, err := New(opts)
+	assert.NoError(t, err)
+	s.client.Backoff = 0
+	return s, f
+}
+
+func TestStore(t *testing.T) {
+	s, f := newTestStore(t, Options{Prefix: "chunks/"})
+	hash := []byte{0xca, 0xfe}
+
+	ok, err := s.Has(hash)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	_, err = s.Get(hash)
+	assert.Equal(t, ae.ErrChunkNotFound, err)
+	assert.Equal(t, ae.ErrChunkNotFound, s.Delete(hash))
+
+	assert.NoError(t, s.Put(hash, []byte("hello world")))
+	assert.Equal(t, []byte("hello world"), f.objects["chunks/cafe"])
+	ok, err = s.Has(hash)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	data, err := s.Get(hash)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("hello world"), data)
+
+	assert.NoError(t, s.Delete(hash))
+	assert.Empty(t, f.objects)
+
+	t.Run("concurrent", func(t *testing.T) {
+		var wg sync.WaitGroup
+		for i := 0; i < 50; i++ {
+			wg.Add(1)
+			go func(i int) {
+				defer wg.Done()
+				assert.NoError(t, s.Put([]byte{byte(i)}, []byte{byte(i)}))
+			}(i)
+		}
+		wg.Wait()
+		assert.Len(t, f.objects, 50)
+	})
+}
+
+func TestStore_Walk(t *testing.T) {
+	s, f := newTestStore(t, Options{Prefix: "chunks/"})
+	want := map[string]int64{}
+	for i := 0; i < 5; i++ {
+		hash := []byte{byte(i), 0xff}
+		assert.NoError(t, s.Put(hash, make([]byte, i)))
+		want[string(hash)] = int64(i)
+	}
+	f.objects["chunks/README"] = []byte("foreign object")
+	f.objects["other/abcd"] = []byte("outside of prefix")
+
+	got := map[string]int64{}
+	assert.NoError(t, s.Walk(func(hash []byte, size int64) error {
+		got[string(hash)] = size
+		return nil
+	}))
+	assert.Equal(t, want, got)
+}
+
+func TestStore_retries(t *testing.T) {
+	s, f := newTestStore(t, Options{Retries: 2})
+
+	f.Failures = 2
+	assert.NoError(t, s.Put([]byte{1}, []byte("foo")))
+	assert.Equal(t, 3, f.Requests)
+
+	f.Failures = 3
+	f.Requests = 0
+	_, err := s.Get([]byte{1})
+	assert.Equal(t, &Error{StatusCode: http.StatusServiceUnavailable, Message: "Backend Error"}, err)
+	assert.Equal(t, 3, f.Requests)
+
+	t.Run("client errors are not retried", func(t *testing.T) {
+		s, f := newTestStore(t, Options{Token: "expired"})
+		err := s.Put([]byte{1}, []byte("foo"))
+		assert.Equal(t, &Error{StatusCode: http.StatusUnauthorized, Message: "Invalid Credentials"}, err)
+		assert.Equal(t, 1, f.Requests)
+	})
+}
+
+func TestStore_TokenSource(t *testing.T) {
+	calls := 0
+	s, _ := newTestStore(t, Options{TokenSource: func() (string, error) {
+		calls++
+		return "token", nil
+	}})
+	assert.NoError(t, s.Put([]byte{1}, []byte("foo")))
+	_, err := s.Get([]byte{1})
+	assert.NoError(t, err)
+	assert.Equal(t, 2, calls)
+
+	errToken := errors.New("no credentials")
+	s, f := newTestStore(t, Options{TokenSource: func() (string, error) { return "", errToken }})
+	assert.ErrorIs(t, s.Put([]byte{1}, []byte("foo")), errToken)
+	assert.Zero(t, f.Requests)
+}
+
+func TestNew(t *testing.T) {
+	s, err := New(Options{Bucket: "bucket"})
+	assert.NoError(t, err)
+	assert.Equal(t, "https://storage.googleapis.com/storage/v1/b/bucket/o/ab", s.objectURL([]byte{0xab}).String())
+	s, err = New(Options{Bucket: "bucket", Prefix: "a b/"})
+	assert.NoError(t, err)
+	assert.Equal(t, "https://storage.googleapis.com/storage/v1/b/bucket/o/a%20b%2Fab", s.objectURL([]byte{0xab}).String())
+	_, err = New(Options{Endpoint: "localhost:4443", Bucket: "bucket"})
+	assert.Error(t, err)
+	_, err = New(Options{})
+	assert.Error(t, err)
+}
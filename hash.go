@@ -0,0 +1,102 @@
+package ae
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/sha512"
+	"fmt"
+	"hash"
+	"lukechampine.com/blake3"
+	"sort"
+	"sync"
+)
+
+var (
+	hashesMu sync.RWMutex
+	hashes   = map[string]func() hash.Hash{
+		"sha1":       sha1.New,
+		"sha256":     sha256.New,
+		"sha512":     sha512.New,
+		"sha512/256": sha512.New512_256,
+		"blake3":     BLAKE3,
+	}
+)
+
+// BLAKE3 returns a new BLAKE3 hash with 256-bit digests.
+// It can be passed to Options.Hasher directly.
+func BLAKE3() hash.Hash {
+	return blake3.New(32, nil)
+}
+
+// KeyedBLAKE3 returns a constructor for BLAKE3 hashes in keyed mode, which requires a 32-byte key.
+// Keyed digests prevent parties without the key from probing for the existence of chunks.
+func KeyedBLAKE3(key []byte) (func() hash.Hash, error) {
+	if len(key) != 32 {
+		return nil, fmt.Errorf("ae: BLAKE3 key must be 32 bytes, got %d", len(key))
+	}
+	key = append([]byte(nil), key...)
+	return func() hash.Hash { return blake3.New(32, key) }, nil
+}
+
+// HMAC returns a constructor for HMACs keyed with key over the hash registered under name, e.g. "sha256".
+// Like keyed BLAKE3, HMAC digests as chunk identities keep parties without the key from probing a shared store
+// for chunks of data they know, while chunks fingerprinted with the same key still deduplicate.
+func HMAC(name string, key []byte) (func() hash.Hash, error) {
+	if len(key) == 0 {
+		return nil, fmt.Errorf("ae: HMAC key must not be empty")
+	}
+	fn, err := LookupHash(name)
+	if err != nil {
+		return nil, err
+	}
+	key = append([]byte(nil), key...)
+	return func() hash.Hash { return hmac.New(fn, key) }, nil
+}
+
+// RegisterHMAC registers the HMAC keyed with key over the hash registered under base as name.
+// A hosted service registers a name per tenant, e.g. "hmac-sha256/alice", so that the chunks of every tenant
+// are identified by digests only the tenant can compute, and selects it by HashName. Manifests record the name,
+// so that they are verified on reassembly with the key registered, without the key being part of them.
+func RegisterHMAC(name, base string, key []byte) error {
+	fn, err := HMAC(base, key)
+	if err != nil {
+		return err
+	}
+	RegisterHash(name, fn)
+	return nil
+}
+
+// RegisterHash makes a hash constructor available by name, e.g. for use in configuration files.
+// Registering a name twice replaces the previous constructor.
+// Any func() hash.Hash can be passed to Options.Hasher directly though, including keyed ones such as
+//
+//	func() hash.Hash { return hmac.New(sha256.New, key) }
+func RegisterHash(name string, fn func() hash.Hash) {
+	hashesMu.Lock()
+	defer hashesMu.Unlock()
+	hashes[name] = fn
+}
+
+// LookupHash returns the hash constructor registered under name.
+func LookupHash(name string) (func() hash.Hash, error) {
+	hashesMu.RLock()
+	defer hashesMu.RUnlock()
+	fn, ok := hashes[name]
+	if !ok {
+		return nil, fmt.Errorf("ae: unknown hash %q", name)
+	}
+	return fn, nil
+}
+
+// Hashes returns the names of all registered hashes in alphabetical order.
+func Hashes() []string {
+	hashesMu.RLock()
+	defer hashesMu.RUnlock()
+	names := make([]string, 0, len(hashes))
+	for name := range hashes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
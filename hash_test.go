@@ -0,0 +1,94 @@
+package ae
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/stretchr/testify/assert"
+	"hash"
+	"hash/crc32"
+	"testing"
+)
+
+func TestLookupHash(t *testing.T) {
+	t.Run("builtin", func(t *testing.T) {
+		fn, err := LookupHash("sha1")
+		assert.NoError(t, err)
+		c := NewChunker(bytes.NewReader(testFile[:MiB]), &Options{AverageSize: 64 * 1024, Hasher: fn})
+		chunk, err := c.Next()
+		assert.NoError(t, err)
+		sum := sha1.Sum(chunk.Data)
+		assert.Equal(t, sum[:], chunk.Sum)
+	})
+
+	t.Run("registered", func(t *testing.T) {
+		RegisterHash("crc32", func() hash.Hash { return crc32.NewIEEE() })
+		fn, err := LookupHash("crc32")
+		assert.NoError(t, err)
+		assert.Equal(t, 4, fn().Size())
+		assert.Contains(t, Hashes(), "crc32")
+	})
+
+	t.Run("blake3", func(t *testing.T) {
+		fn, err := LookupHash("blake3")
+		assert.NoError(t, err)
+		h := fn()
+		h.Write([]byte("abc"))
+		// official test vector
+		assert.Equal(t, "6437b3ac38465133ffb63b75273a8db548c558465d79db03fd359c6cd5bd9d85", hex.EncodeToString(h.Sum(nil)))
+	})
+
+	t.Run("keyed blake3", func(t *testing.T) {
+		_, err := KeyedBLAKE3(make([]byte, 16))
+		assert.Error(t, err)
+
+		key := bytes.Repeat([]byte{1}, 32)
+		fn, err := KeyedBLAKE3(key)
+		assert.NoError(t, err)
+		c := NewChunker(bytes.NewReader(testFile[:MiB]), &Options{AverageSize: 64 * 1024, Hasher: fn})
+		chunk, err := c.Next()
+		assert.NoError(t, err)
+
+		h := BLAKE3()
+		h.Write(chunk.Data)
+		assert.Len(t, chunk.Sum, 32)
+		assert.NotEqual(t, h.Sum(nil), chunk.Sum)
+	})
+
+	t.Run("hmac", func(t *testing.T) {
+		_, err := HMAC("sha256", nil)
+		assert.Error(t, err)
+		_, err = HMAC("foo", []byte("key"))
+		assert.Error(t, err)
+
+		assert.NoError(t, RegisterHMAC("hmac-sha256/alice", "sha256", []byte("alice")))
+		assert.NoError(t, RegisterHMAC("hmac-sha256/bob", "sha256", []byte("bob")))
+		input := testFile[:MiB]
+		alice, err := BuildManifest(bytes.NewReader(input), &Options{AverageSize: 64 * 1024, HashName: "hmac-sha256/alice"})
+		assert.NoError(t, err)
+		bob, err := BuildManifest(bytes.NewReader(input), &Options{AverageSize: 64 * 1024, HashName: "hmac-sha256/bob"})
+		assert.NoError(t, err)
+		assert.Equal(t, "hmac-sha256/alice", alice.Parameters.Hash)
+
+		mac := hmac.New(sha256.New, []byte("alice"))
+		mac.Write(input[:alice.Chunks[0].Length])
+		assert.Equal(t, mac.Sum(nil), alice.Chunks[0].Hash)
+		// The same data yields the same chunks under different identities.
+		assert.Equal(t, len(alice.Chunks), len(bob.Chunks))
+		assert.NotEqual(t, alice.Chunks[0].Hash, bob.Chunks[0].Hash)
+
+		s := NewMemoryStore(0)
+		_, err = StoreFile(bytes.NewReader(input), s, alice.Parameters.Options())
+		assert.NoError(t, err)
+		var out bytes.Buffer
+		assert.NoError(t, Reassemble(&out, alice, s))
+		assert.Equal(t, input, out.Bytes())
+	})
+
+	t.Run("unknown", func(t *testing.T) {
+		_, err := LookupHash("foo")
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,293 @@
+package ae
+
+import (
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"github.com/mg98/ae-chunker-go/internal/retry"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// HTTPStoreOptions configure an HTTPStore.
+type HTTPStoreOptions struct {
+	// FanOut is the number of directory levels the files are sharded by, as with FileStoreOptions (optional).
+	// It defaults to 2.
+	FanOut int
+
+	// Packs are the IDs of the packs written by a PackingStore to read the chunks from (optional),
+	// as returned by PackingStore.Packs. If set, chunks are fetched from ranges of the packs instead of separate files.
+	Packs [][]byte
+
+	// Cache keeps the fetched chunks locally (optional), e.g. a DiskCache or a bounded MemoryStore.
+	Cache ChunkStore
+
+	// Retries is the number of times a failed request is retried (optional). It defaults to 3.
+	// Only network errors, throttling and server errors are retried. A negative value disables retries.
+	Retries int
+
+	// Client sends the requests (optional). It defaults to http.DefaultClient.
+	Client *http.Client
+}
+
+// HTTPStore is a read-only ChunkStore fetching chunks from a static HTTP server, such as a CDN,
+// that serves the directory of a FileStore. If the directory holds packs written by a PackingStore,
+// each chunk is fetched from its pack by a range request.
+type HTTPStore struct {
+	base   *url.URL
+	fanOut int
+	cache  ChunkStore
+	client *retry.Client
+
+	// codec decodes the chunks in packs, whichever codec they were compressed with.
+	codec *CompressedStore
+
+	// index locates the chunks in packs. It is nil unless packs are used.
+	index map[string]packLoc
+}
+
+// httpStatusError is an unexpected status of a response.
+type httpStatusError struct {
+	method     string
+	url        string
+	statusCode int
+}
+
+func (e *httpStatusError) Error() string {
+	return fmt.Sprintf("ae: %s %s: %s", e.method, e.url, http.StatusText(e.statusCode))
+}
+
+// NewHTTPStore returns an HTTPStore fetching chunks from below baseURL.
+// If packs are given, their indexes are loaded by range requests.
+func NewHTTPStore(baseURL string, opts *HTTPStoreOptions) (*HTTPStore, error) {
+	o := HTTPStoreOptions{}
+	if opts != nil {
+		o = *opts
+	}
+	base, err := url.Parse(baseURL)
+	if err != nil {
+		return nil, err
+	}
+	if base.Scheme != "http" && base.Scheme != "https" || base.Host == "" {
+		return nil, fmt.Errorf("ae: invalid base URL %q", baseURL)
+	}
+	codec, err := NewCompressedStore(nil, CodecNone)
+	if err != nil {
+		return nil, err
+	}
+	s := &HTTPStore{base: base, fanOut: 2, cache: o.Cache, client: retry.New(o.Client, o.Retries, 0), codec: codec}
+	if o.FanOut > 0 {
+		s.fanOut = o.FanOut
+	}
+	if len(o.Packs) > 0 {
+		s.index = make(map[string]packLoc)
+		for _, id := range o.Packs {
+			if err := s.loadPack(id); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return s, nil
+}
+
+// loadPack adds the chunks of the pack id to the index.
+// The length of the index is read from the end of the pack first, followed by the index itself.
+func (s *HTTPStore) loadPack(id []byte) error {
+	u := s.url(id)
+	trailer, size, err := s.fetch(http.MethodGet, u, "bytes=-4")
+	if err != nil {
+		return err
+	}
+	if len(trailer) != 4 {
+		return fmt.Errorf("ae: reading pack %x: %w", id, ErrInvalidPack)
+	}
+	n := int64(binary.LittleEndian.Uint32(trailer)) + 4
+	if n > size {
+		return fmt.Errorf("ae: reading pack %x: %w", id, ErrInvalidPack)
+	}
+	tail, _, err := s.fetch(http.MethodGet, u, "bytes=-"+strconv.FormatInt(n, 10))
+	if err != nil {
+		return err
+	}
+	entries, err := parsePackIndex(tail, size)
+	if err != nil {
+		return fmt.Errorf("ae: reading pack %x: %w", id, err)
+	}
+	for _, e := range entries {
+		s.index[e.hash] = packLoc{pack: string(id), offset: e.offset, length: e.length}
+	}
+	return nil
+}
+
+// url returns the URL of the file named by hash.
+func (s *HTTPStore) url(hash []byte) string {
+	name := hex.EncodeToString(hash)
+	elems := []string{strings.TrimSuffix(s.base.String(), "/")}
+	for i := 0; i < s.fanOut && 2*i+2 <= len(name); i++ {
+		elems = append(elems, name[2*i:2*i+2])
+	}
+	return strings.Join(append(elems, name), "/")
+}
+
+// Put returns ErrReadOnly.
+func (s *HTTPStore) Put(hash []byte, data []byte) error {
+	return ErrReadOnly
+}
+
+// Delete returns ErrReadOnly.
+func (s *HTTPStore) Delete(hash []byte) error {
+	return ErrReadOnly
+}
+
+// Get returns the data stored under hash, from the cache if possible.
+func (s *HTTPStore) Get(hash []byte) ([]byte, error) {
+	if s.cache != nil {
+		if data, err := s.cache.Get(hash); err == nil {
+			return data, nil
+		}
+	}
+	data, err := s.download(hash)
+	if err != nil {
+		return nil, err
+	}
+	if s.cache != nil {
+		if err := s.cache.Put(hash, data); err != nil {
+			return nil, err
+		}
+	}
+	return data, nil
+}
+
+// download fetches the data stored under hash from its file or its pack.
+func (s *HTTPStore) download(hash []byte) ([]byte, error) {
+	if s.index == nil {
+		data, _, err := s.fetch(http.MethodGet, s.url(hash), "")
+		return data, err
+	}
+	loc, ok := s.index[string(hash)]
+	if !ok {
+		return nil, ErrChunkNotFound
+	}
+	// Every chunk in a pack starts with its codec, so an empty one is corrupt.
+	if loc.length == 0 {
+		return nil, ErrCorruptChunk
+	}
+	stored, _, err := s.fetch(http.MethodGet, s.url([]byte(loc.pack)), fmt.Sprintf("bytes=%d-%d", loc.offset, loc.offset+loc.length-1))
+	if err != nil {
+		return nil, err
+	}
+	if int64(len(stored)) != loc.length {
+		return nil, ErrInvalidPack
+	}
+	return s.codec.decode(stored)
+}
+
+// Has reports whether a chunk is stored under hash.
+func (s *HTTPStore) Has(hash []byte) (bool, error) {
+	if s.index != nil {
+		_, ok := s.index[string(hash)]
+		return ok, nil
+	}
+	if s.cache != nil {
+		if ok, err := s.cache.Has(hash); err == nil && ok {
+			return true, nil
+		}
+	}
+	_, _, err := s.fetch(http.MethodHead, s.url(hash), "")
+	if err == ErrChunkNotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Walk calls fn for every chunk in the packs with its hash and size within its pack.
+// Without packs, the chunks cannot be enumerated and ErrWalkUnsupported is returned.
+func (s *HTTPStore) Walk(fn func(hash []byte, size int64) error) error {
+	if s.index == nil {
+		return ErrWalkUnsupported
+	}
+	for hash, loc := range s.index {
+		if err := fn([]byte(hash), loc.length); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// fetch requests u, or only the byte range given by rangeHeader if it is not empty, retrying on transient errors.
+// It returns the body of the response and the size of the whole file. A missing file is reported as ErrChunkNotFound.
+func (s *HTTPStore) fetch(method string, u string, rangeHeader string) ([]byte, int64, error) {
+	resp, err := s.client.Do(func() (*http.Request, error) {
+		req, err := retry.NewRequest(method, u, nil)
+		if err != nil {
+			return nil, err
+		}
+		if rangeHeader != "" {
+			req.Header.Set("Range", rangeHeader)
+		}
+		return req, nil
+	})
+	if err != nil {
+		return nil, 0, err
+	}
+	switch resp.StatusCode {
+	case http.StatusOK:
+		data := resp.Body
+		size := int64(len(data))
+		// The server ignored the range, so it is cut out of the whole file.
+		if rangeHeader != "" {
+			if data, err = cutRange(data, rangeHeader); err != nil {
+				return nil, 0, err
+			}
+		}
+		return data, size, nil
+	case http.StatusPartialContent:
+		// Content-Range: bytes first-last/size
+		contentRange := resp.Header.Get("Content-Range")
+		i := strings.LastIndexByte(contentRange, '/')
+		size, err := strconv.ParseInt(contentRange[i+1:], 10, 64)
+		if i < 0 || err != nil {
+			return nil, 0, fmt.Errorf("ae: %s %s: invalid Content-Range %q", method, u, contentRange)
+		}
+		return resp.Body, size, nil
+	case http.StatusNotFound, http.StatusGone:
+		return nil, 0, ErrChunkNotFound
+	default:
+		return nil, 0, &httpStatusError{method: method, url: u, statusCode: resp.StatusCode}
+	}
+}
+
+// cutRange returns the bytes of data in the range given by the value of a Range header,
+// which is a single range as sent by HTTPStore.
+func cutRange(data []byte, rangeHeader string) ([]byte, error) {
+	spec := strings.TrimPrefix(rangeHeader, "bytes=")
+	dash := strings.IndexByte(spec, '-')
+	if dash < 0 {
+		return nil, fmt.Errorf("ae: invalid range %q", rangeHeader)
+	}
+	n := int64(len(data))
+	if dash == 0 {
+		suffix, err := strconv.ParseInt(spec[1:], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("ae: invalid range %q", rangeHeader)
+		}
+		if suffix > n {
+			suffix = n
+		}
+		return data[n-suffix:], nil
+	}
+	first, err1 := strconv.ParseInt(spec[:dash], 10, 64)
+	last, err2 := strconv.ParseInt(spec[dash+1:], 10, 64)
+	if err1 != nil || err2 != nil || first > last {
+		return nil, fmt.Errorf("ae: invalid range %q", rangeHeader)
+	}
+	if first >= n {
+		return nil, nil
+	}
+	if last >= n {
+		last = n - 1
+	}
+	return data[first : last+1], nil
+}
@@ -0,0 +1,148 @@
+package ae
+
+import (
+	"bytes"
+	"github.com/mg98/ae-chunker-go/internal/retry/retrytest"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// fileServer serves a directory, recording the requests and optionally failing or ignoring ranges.
+type fileServer struct {
+	*retrytest.Flaky
+	handler http.Handler
+
+	mu     sync.Mutex
+	ranges int
+
+	// noRanges makes the server ignore Range headers.
+	noRanges bool
+}
+
+func (f *fileServer) serve(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	if r.Header.Get("Range") != "" {
+		f.ranges++
+	}
+	if f.noRanges {
+		r.Header.Del("Range")
+	}
+	f.mu.Unlock()
+	f.handler.ServeHTTP(w, r)
+}
+
+func newFileServer(t *testing.T, dir string) (*httptest.Server, *fileServer) {
+	f := &fileServer{handler: http.FileServer(http.Dir(dir))}
+	f.Flaky = &retrytest.Flaky{Handler: http.HandlerFunc(f.serve), Status: http.StatusBadGateway}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	return srv, f
+}
+
+func TestHTTPStore(t *testing.T) {
+	input := testFile[:MiB]
+	dir := t.TempDir()
+	fileStore, err := NewFileStore(dir, nil)
+	require.NoError(t, err)
+	m, err := StoreFile(bytes.NewReader(input), fileStore, &Options{AverageSize: 64 * 1024})
+	require.NoError(t, err)
+	srv, f := newFileServer(t, dir)
+
+	cache := NewMemoryStore(0)
+	s, err := NewHTTPStore(srv.URL+"/", &HTTPStoreOptions{Cache: cache})
+	require.NoError(t, err)
+	var buf bytes.Buffer
+	require.NoError(t, Reassemble(&buf, m, s))
+	assert.Equal(t, input, buf.Bytes())
+	assert.Equal(t, len(m.Chunks), f.Requests)
+	assert.Equal(t, len(m.Chunks), countChunks(t, cache))
+
+	// The second time, all chunks come from the cache.
+	buf.Reset()
+	require.NoError(t, Reassemble(&buf, m, s))
+	assert.Equal(t, input, buf.Bytes())
+	assert.Equal(t, len(m.Chunks), f.Requests)
+
+	ok, err := s.Has(m.Chunks[0].Hash)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	ok, err = s.Has([]byte{0xca, 0xfe})
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	_, err = s.Get([]byte{0xca, 0xfe})
+	assert.Equal(t, ErrChunkNotFound, err)
+	assert.Equal(t, ErrReadOnly, s.Put([]byte{1}, []byte("foo")))
+	assert.Equal(t, ErrReadOnly, s.Delete(m.Chunks[0].Hash))
+	assert.Equal(t, ErrWalkUnsupported, s.Walk(func(hash []byte, size int64) error { return nil }))
+
+	t.Run("retries", func(t *testing.T) {
+		s, err := NewHTTPStore(srv.URL, &HTTPStoreOptions{Retries: 2})
+		require.NoError(t, err)
+		s.client.Backoff = 0
+		f.Failures, f.Requests = 2, 0
+		_, err = s.Get(m.Chunks[0].Hash)
+		assert.NoError(t, err)
+		assert.Equal(t, 3, f.Requests)
+
+		f.Failures, f.Requests = 3, 0
+		_, err = s.Get(m.Chunks[0].Hash)
+		assert.EqualError(t, err, "ae: GET "+s.url(m.Chunks[0].Hash)+": Bad Gateway")
+		assert.Equal(t, 3, f.Requests)
+	})
+}
+
+func TestHTTPStore_packs(t *testing.T) {
+	input := testFile[:4*MiB]
+	dir := t.TempDir()
+	fileStore, err := NewFileStore(dir, nil)
+	require.NoError(t, err)
+	p, err := NewPackingStore(fileStore, &PackOptions{TargetSize: 256 * 1024, Codec: CodecZstd})
+	require.NoError(t, err)
+	m, err := StoreFile(bytes.NewReader(input), p, &Options{AverageSize: 16 * 1024})
+	require.NoError(t, err)
+	require.NoError(t, p.Flush())
+	packs := p.Packs()
+	assert.Greater(t, len(packs), 4)
+
+	for _, noRanges := range []bool{false, true} {
+		srv, f := newFileServer(t, dir)
+		f.noRanges = noRanges
+		s, err := NewHTTPStore(srv.URL, &HTTPStoreOptions{Packs: packs})
+		require.NoError(t, err, "noRanges: %v", noRanges)
+		assert.Equal(t, 2*len(packs), f.Requests)
+		assert.Equal(t, len(m.Chunks), countChunks(t, s))
+
+		var buf bytes.Buffer
+		require.NoError(t, Reassemble(&buf, m, s))
+		assert.Equal(t, input, buf.Bytes())
+		assert.Equal(t, f.Requests, f.ranges)
+		ok, err := s.Has(m.Chunks[0].Hash)
+		assert.NoError(t, err)
+		assert.True(t, ok)
+		_, err = s.Get([]byte{0xca, 0xfe})
+		assert.Equal(t, ErrChunkNotFound, err)
+	}
+
+	t.Run("invalid pack", func(t *testing.T) {
+		srv, _ := newFileServer(t, dir)
+		_, err := NewHTTPStore(srv.URL, &HTTPStoreOptions{Packs: [][]byte{{0xca, 0xfe}}})
+		assert.Equal(t, ErrChunkNotFound, err)
+		require.NoError(t, fileStore.Put([]byte{0xca, 0xfe}, []byte("not a pack")))
+		_, err = NewHTTPStore(srv.URL, &HTTPStoreOptions{Packs: [][]byte{{0xca, 0xfe}}})
+		assert.ErrorIs(t, err, ErrInvalidPack)
+	})
+}
+
+func TestNewHTTPStore(t *testing.T) {
+	_, err := NewHTTPStore("ftp://example.com/chunks", nil)
+	assert.Error(t, err)
+	_, err = NewHTTPStore("/chunks", nil)
+	assert.Error(t, err)
+	s, err := NewHTTPStore("https://cdn.example.com/chunks/", &HTTPStoreOptions{FanOut: 1})
+	require.NoError(t, err)
+	assert.Equal(t, "https://cdn.example.com/chunks/ca/cafe", s.url([]byte{0xca, 0xfe}))
+}
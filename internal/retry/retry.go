@@ -0,0 +1,112 @@
+// Package retry sends the HTTP requests of the remote chunk stores, retrying them on transient errors.
+// The stores only build and sign the requests and decode the responses.
+package retry
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"time"
+)
+
+// DefaultRetries is the number of retries of a store whose Retries option is zero.
+const DefaultRetries = 3
+
+// Client sends HTTP requests, retrying those failing with a network error, throttling or a server error.
+type Client struct {
+	// HTTP sends the requests.
+	HTTP *http.Client
+
+	// Retries is the number of times a failed request is retried.
+	Retries int
+
+	// Backoff is the delay before the first retry, doubling with every further one.
+	Backoff time.Duration
+
+	// sem bounds the number of requests in flight. It is nil if they are not bounded.
+	sem chan struct{}
+}
+
+// New returns a Client sending the requests with client, or http.DefaultClient if it is nil,
+// and keeping at most concurrency requests in flight if it is positive.
+// retries is the Retries option of a store: zero selects DefaultRetries and a negative value disables retries.
+func New(client *http.Client, retries int, concurrency int) *Client {
+	if client == nil {
+		client = http.DefaultClient
+	}
+	if retries == 0 {
+		retries = DefaultRetries
+	} else if retries < 0 {
+		retries = 0
+	}
+	c := &Client{HTTP: client, Retries: retries, Backoff: 100 * time.Millisecond}
+	if concurrency > 0 {
+		c.sem = make(chan struct{}, concurrency)
+	}
+	return c
+}
+
+// Response is a response with its body read.
+type Response struct {
+	StatusCode int
+	Header     http.Header
+	Body       []byte
+}
+
+// Do sends the request returned by newRequest, which is called again for every attempt, and returns the response.
+// Network errors and responses with the status 429 or 5xx are retried. Any other response is returned
+// whatever its status, as is the last one once the retries are exhausted, for the caller to decode.
+// An error of newRequest is returned as it is.
+func (c *Client) Do(newRequest func() (*http.Request, error)) (*Response, error) {
+	if c.sem != nil {
+		c.sem <- struct{}{}
+		defer func() { <-c.sem }()
+	}
+
+	backoff := c.Backoff
+	for attempt := 0; ; attempt++ {
+		req, err := newRequest()
+		if err != nil {
+			return nil, err
+		}
+		resp, err := c.send(req)
+		if attempt >= c.Retries || err == nil && !Transient(resp.StatusCode) {
+			return resp, err
+		}
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+// send performs a single request.
+func (c *Client) send(req *http.Request) (*Response, error) {
+	resp, err := c.HTTP.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+	return &Response{StatusCode: resp.StatusCode, Header: resp.Header, Body: body}, nil
+}
+
+// Transient reports whether a response with the status code is worth retrying, i.e. throttling or a server error.
+func Transient(statusCode int) bool {
+	return statusCode == http.StatusTooManyRequests || statusCode >= 500
+}
+
+// NewRequest returns a request with an optional body.
+func NewRequest(method string, url string, body []byte) (*http.Request, error) {
+	req, err := http.NewRequestWithContext(context.Background(), method, url, bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.ContentLength = int64(len(body))
+	if body == nil {
+		req.Body = http.NoBody
+	}
+	return req, nil
+}
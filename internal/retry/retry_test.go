@@ -0,0 +1,57 @@
+package retry
+
+import (
+	"github.com/mg98/ae-chunker-go/internal/retry/retrytest"
+	"github.com/stretchr/testify/assert"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+	assert.Equal(t, DefaultRetries, New(nil, 0, 0).Retries)
+	assert.Equal(t, 0, New(nil, -1, 0).Retries)
+	assert.Equal(t, 5, New(nil, 5, 0).Retries)
+	assert.Equal(t, http.DefaultClient, New(nil, 0, 0).HTTP)
+}
+
+func TestClient_Do(t *testing.T) {
+	f := &retrytest.Flaky{
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("X-Method", r.Method)
+			w.WriteHeader(http.StatusTeapot)
+			w.Write([]byte("body"))
+		}),
+		Status: http.StatusTooManyRequests,
+		Body:   "slow down",
+	}
+	srv := httptest.NewServer(f)
+	defer srv.Close()
+	c := New(nil, 2, 1)
+	c.Backoff = 0
+	newRequest := func() (*http.Request, error) { return NewRequest(http.MethodPut, srv.URL, []byte("foo")) }
+
+	f.Failures = 2
+	resp, err := c.Do(newRequest)
+	assert.NoError(t, err)
+	assert.Equal(t, &Response{StatusCode: http.StatusTeapot, Header: resp.Header, Body: []byte("body")}, resp)
+	assert.Equal(t, http.MethodPut, resp.Header.Get("X-Method"))
+	assert.Equal(t, 3, f.Requests)
+
+	f.Failures, f.Requests = 3, 0
+	resp, err = c.Do(newRequest)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusTooManyRequests, resp.StatusCode)
+	assert.Equal(t, []byte("slow down"), resp.Body)
+	assert.Equal(t, 3, f.Requests)
+
+	t.Run("network errors", func(t *testing.T) {
+		attempts := 0
+		_, err := c.Do(func() (*http.Request, error) {
+			attempts++
+			return NewRequest(http.MethodGet, "http://127.0.0.1:0", nil)
+		})
+		assert.Error(t, err)
+		assert.Equal(t, 3, attempts)
+	})
+}
@@ -0,0 +1,40 @@
+// Package retrytest provides a flaky HTTP handler for testing the retries of the remote chunk stores.
+package retrytest
+
+import (
+	"io"
+	"net/http"
+	"sync"
+)
+
+// Flaky wraps a handler, counting the requests and answering the first ones with a server error.
+type Flaky struct {
+	// Handler serves the requests that do not fail.
+	Handler http.Handler
+
+	// Status and Body answer the failing requests.
+	Status int
+	Body   string
+
+	mu sync.Mutex
+
+	// Requests is the number of requests received.
+	Requests int
+
+	// Failures is the number of requests still to be answered with a server error.
+	Failures int
+}
+
+func (f *Flaky) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	f.Requests++
+	if f.Failures > 0 {
+		f.Failures--
+		f.mu.Unlock()
+		w.WriteHeader(f.Status)
+		io.WriteString(w, f.Body)
+		return
+	}
+	f.mu.Unlock()
+	f.Handler.ServeHTTP(w, r)
+}
@@ -0,0 +1,151 @@
+package ae
+
+import (
+	"io"
+)
+
+// scanBufSize is the size of the blocks in which the LazyChunker scans its source.
+const scanBufSize = 64 * 1024
+
+// ChunkReader is a lazy view of a single chunk.
+// Its data is only read from the source when it is consumed.
+type ChunkReader struct {
+	*io.SectionReader
+
+	// offset of the chunk in the source.
+	offset int64
+}
+
+// Len returns the size of the chunk in bytes.
+func (cr *ChunkReader) Len() int64 {
+	return cr.Size()
+}
+
+// Offset returns the offset of the chunk in the source.
+func (cr *ChunkReader) Offset() int64 {
+	return cr.offset
+}
+
+// LazyChunker finds chunk boundaries in a random access source without materializing the chunks.
+// Memory usage is independent of the chunk sizes, which makes it suitable for very large MaxSize values.
+// Only the size and mode related fields of Options are considered.
+type LazyChunker struct {
+	params
+
+	// src to be chunked.
+	src io.ReaderAt
+
+	// offset of the next chunk in src.
+	offset int64
+
+	// buf caches the block of src that is currently scanned.
+	buf []byte
+
+	// bufOffset is the offset of buf in src.
+	bufOffset int64
+
+	// err is the first error returned by src.
+	err error
+}
+
+// NewLazyChunker returns a LazyChunker for src.
+func NewLazyChunker(src io.ReaderAt, opts *Options) *LazyChunker {
+	return &LazyChunker{
+		params: newParams(opts),
+		src:    src,
+		buf:    make([]byte, 0, scanBufSize),
+	}
+}
+
+// Next returns a reader over the next chunk of the source or io.EOF once the source is exhausted.
+func (lc *LazyChunker) Next() (*ChunkReader, error) {
+	n, err := lc.nextLen()
+	if err != nil {
+		return nil, err
+	}
+	cr := &ChunkReader{io.NewSectionReader(lc.src, lc.offset, n), lc.offset}
+	lc.offset += n
+	return cr, nil
+}
+
+// nextLen scans the source from the current offset and returns the length of the next chunk.
+// It mirrors cutPoint, but only ever keeps a single block of the source in memory.
+func (lc *LazyChunker) nextLen() (int64, error) {
+	first, ok, err := lc.byteAt(0)
+	if err != nil {
+		return 0, err
+	}
+	if !ok {
+		return 0, io.EOF
+	}
+
+	if lc.maxSize <= lc.minSize+lc.windowSize {
+		return lc.available(lc.maxSize)
+	}
+	if _, ok, err := lc.byteAt(lc.minSize + lc.windowSize); err != nil {
+		return 0, err
+	} else if !ok {
+		return lc.available(lc.minSize + lc.windowSize)
+	}
+
+	var markerPos int64
+	marker := first
+	for i := lc.minSize; ; i++ {
+		if i == lc.maxSize {
+			return i, nil
+		}
+		b, ok, err := lc.byteAt(i)
+		if err != nil {
+			return 0, err
+		}
+		if !ok {
+			return i, nil
+		}
+		if lc.isExtreme(b, marker) {
+			markerPos, marker = i, b
+		}
+		if i-markerPos == lc.windowSize {
+			return i, nil
+		}
+	}
+}
+
+// available returns how many of the next n bytes are present in the source.
+func (lc *LazyChunker) available(n int64) (int64, error) {
+	lo, hi := int64(0), n
+	for lo < hi {
+		mid := lo + (hi-lo+1)/2
+		_, ok, err := lc.byteAt(mid - 1)
+		if err != nil {
+			return 0, err
+		}
+		if ok {
+			lo = mid
+		} else {
+			hi = mid - 1
+		}
+	}
+	return lo, nil
+}
+
+// byteAt returns the byte at position i relative to the current offset
+// and whether it exists at all.
+func (lc *LazyChunker) byteAt(i int64) (byte, bool, error) {
+	pos := lc.offset + i
+	if pos < lc.bufOffset || pos >= lc.bufOffset+int64(len(lc.buf)) {
+		if lc.err != nil {
+			return 0, false, lc.err
+		}
+		n, err := lc.src.ReadAt(lc.buf[:cap(lc.buf)], pos)
+		lc.buf = lc.buf[:n]
+		lc.bufOffset = pos
+		if err != nil && err != io.EOF {
+			lc.err = err
+			return 0, false, err
+		}
+		if n == 0 {
+			return 0, false, nil
+		}
+	}
+	return lc.buf[pos-lc.bufOffset], true, nil
+}
@@ -0,0 +1,54 @@
+package ae
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"testing"
+)
+
+func getChunkReaders(t *testing.T, lc *LazyChunker) []*ChunkReader {
+	var crs []*ChunkReader
+	for {
+		cr, err := lc.Next()
+		if err == io.EOF {
+			break
+		}
+		assert.NoError(t, err)
+		crs = append(crs, cr)
+	}
+	return crs
+}
+
+func TestLazyChunker_Next(t *testing.T) {
+	for name, opts := range map[string]*Options{
+		"AE_MAX":                   {AverageSize: 256 * 1024, Mode: MAX},
+		"AE_MIN":                   {AverageSize: 256 * 1024, Mode: MIN},
+		"max size":                 {AverageSize: 256 * 1024, MaxSize: 300 * 1024},
+		"max size less than avg":   {AverageSize: 256 * 1024, MaxSize: 200 * 1024},
+		"max size less than min":   {AverageSize: 256 * 1024, MaxSize: 1024},
+		"window size << 256":       {AverageSize: 171},
+		"avg size larger than all": {AverageSize: 16 * MiB},
+	} {
+		t.Run(name+" yields same boundaries as Chunker", func(t *testing.T) {
+			input := testFile[:8*MiB+123]
+			chunks := getChunks(NewChunker(bytes.NewReader(input), opts))
+			crs := getChunkReaders(t, NewLazyChunker(bytes.NewReader(input), opts))
+			assert.Equal(t, len(chunks), len(crs))
+
+			var offset int64
+			for i := 0; i < len(chunks) && i < len(crs); i++ {
+				assert.Equal(t, offset, crs[i].Offset())
+				assert.Equal(t, int64(len(chunks[i])), crs[i].Len())
+				data, err := io.ReadAll(crs[i])
+				assert.NoError(t, err)
+				assert.Equal(t, chunks[i], data)
+				offset += crs[i].Len()
+			}
+		})
+	}
+
+	t.Run("zero byte source", func(t *testing.T) {
+		assert.Empty(t, getChunkReaders(t, NewLazyChunker(bytes.NewReader(nil), &Options{AverageSize: 1024})))
+	})
+}
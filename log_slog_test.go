@@ -0,0 +1,28 @@
+//go:build go1.21
+
+package ae
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"log/slog"
+	"testing"
+)
+
+func TestOptions_Logger_slog(t *testing.T) {
+	data := make([]byte, 1000)
+	for i := range data {
+		data[i] = byte(i / 4)
+	}
+	var out bytes.Buffer
+	logger := slog.New(slog.NewTextHandler(&out, &slog.HandlerOptions{Level: slog.LevelDebug}))
+	getChunks(NewChunker(bytes.NewReader(data), &Options{AverageSize: 100, MaxSize: 300, Logger: logger}))
+	assert.Contains(t, out.String(), `level=DEBUG msg="ae: chunker created" averageSize=100`)
+	assert.Contains(t, out.String(), `msg="ae: chunk truncated at max size" offset=0 maxSize=300`)
+
+	// Nothing is logged above debug level.
+	out.Reset()
+	logger = slog.New(slog.NewTextHandler(&out, nil))
+	getChunks(NewChunker(bytes.NewReader(testFile[:MiB]), &Options{Logger: logger}))
+	assert.Empty(t, out.String())
+}
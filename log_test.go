@@ -0,0 +1,51 @@
+package ae
+
+import (
+	"bytes"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"testing"
+	"testing/iotest"
+)
+
+// recordingLogger is a Logger recording the messages logged.
+type recordingLogger struct {
+	messages []string
+	args     [][]any
+}
+
+func (l *recordingLogger) Debug(msg string, args ...any) {
+	l.messages = append(l.messages, msg)
+	l.args = append(l.args, args)
+}
+
+func TestOptions_Logger(t *testing.T) {
+	data := make([]byte, 1000)
+	for i := range data {
+		data[i] = byte(i / 4)
+	}
+	l := &recordingLogger{}
+	getChunks(NewChunker(bytes.NewReader(data), &Options{AverageSize: 100, MaxSize: 300, Logger: l}))
+	assert.Equal(t, []string{
+		"ae: chunker created",
+		"ae: chunk truncated at max size",
+		"ae: chunk truncated at max size",
+		"ae: chunk truncated at max size",
+	}, l.messages)
+	assert.Equal(t, []any{"averageSize", int64(100), "minSize", int64(42), "maxSize", int64(300),
+		"windowSize", int64(58), "mode", "max", "hash", ""}, l.args[0])
+	assert.Equal(t, []any{"offset", int64(300), "maxSize", int64(300)}, l.args[2])
+
+	// Fixed-size chunks are not truncated.
+	l = &recordingLogger{}
+	getChunks(NewChunker(bytes.NewReader(data), &Options{AverageSize: 300, Algorithm: Fixed, Logger: l}))
+	assert.Equal(t, []string{"ae: chunker created"}, l.messages)
+
+	l = &recordingLogger{}
+	r := io.MultiReader(bytes.NewReader(data), iotest.ErrReader(errors.New("disk on fire")))
+	_, err := NewChunker(r, &Options{Logger: l}).Next()
+	assert.Error(t, err)
+	assert.Equal(t, []string{"ae: chunker created", "ae: reading input failed"}, l.messages)
+	assert.Equal(t, []any{"offset", int64(1000), "error", err}, l.args[1])
+}
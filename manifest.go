@@ -0,0 +1,323 @@
+package ae
+
+import (
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// Parameters are the chunking parameters of a Manifest.
+// They suffice to chunk the same data identically again.
+type Parameters struct {
+	// AverageSize of the chunks.
+	AverageSize int64
+
+	// MaxSize of the chunks.
+	MaxSize int64
+
+	// Mode of the algorithm.
+	Mode Extremum
+
+	// Hash is the registered name of the hash used to fingerprint the chunks.
+	Hash string
+
+	// Alignment of the cut points, cf. Options.Alignment (optional).
+	Alignment int64
+
+	// AlignTolerance is the distance up to which cut points are moved to a multiple of Alignment.
+	// Unlike Options.AlignTolerance, it is recorded with its default resolved.
+	AlignTolerance int64
+
+	// SnapTolerance is the distance up to which cut points are snapped to a Delimiter, cf. Options.SnapTolerance (optional).
+	SnapTolerance int64
+
+	// Delimiter terminating the records if SnapTolerance is set.
+	Delimiter byte
+
+	// RuneSafe keeps UTF-8 sequences within chunks, cf. Options.RuneSafe (optional).
+	RuneSafe bool
+
+	// Tar states that the file is a tar stream, every entry of which starts a new chunk (optional).
+	Tar bool
+}
+
+// Options returns the Options corresponding to p.
+func (p Parameters) Options() *Options {
+	return &Options{
+		AverageSize: p.AverageSize,
+		MaxSize:     p.MaxSize,
+		Mode:        p.Mode,
+		HashName:    p.Hash,
+
+		Alignment:      p.Alignment,
+		AlignTolerance: p.AlignTolerance,
+		SnapTolerance:  p.SnapTolerance,
+		Delimiter:      p.Delimiter,
+		RuneSafe:       p.RuneSafe,
+		Tar:            p.Tar,
+	}
+}
+
+// parameters returns the Parameters the Chunker chunks with, defaults resolved.
+func (ch *Chunker) parameters() Parameters {
+	return Parameters{
+		AverageSize: ch.avgSize,
+		MaxSize:     ch.maxSize,
+		Mode:        ch.extremum,
+		Hash:        ch.hashName,
+
+		Alignment:      ch.alignment,
+		AlignTolerance: ch.alignTolerance,
+		SnapTolerance:  ch.snapTolerance,
+		Delimiter:      ch.delimiter,
+		RuneSafe:       ch.runeSafe,
+		Tar:            ch.tar,
+	}
+}
+
+// ChunkRef refers to a single chunk of a file.
+type ChunkRef struct {
+	// Offset of the chunk in the file.
+	Offset int64
+
+	// Length of the chunk in bytes.
+	Length int64
+
+	// Hash of the chunk.
+	Hash []byte
+}
+
+// Manifest describes how a file is composed of chunks.
+type Manifest struct {
+	// Size of the file in bytes.
+	Size int64
+
+	// Parameters the file has been chunked with.
+	Parameters Parameters
+
+	// Chunks of the file in order.
+	Chunks []ChunkRef
+
+	// Parity describes the erasure coding of the chunks, if added by AddParity (optional).
+	Parity []ParityGroup
+}
+
+// BuildManifest chunks r and returns its Manifest.
+// The hash is selected by opts.HashName (or opts.SHA256) and defaults to SHA-256,
+// opts.Hasher is not supported since it cannot be recorded in the manifest.
+// Neither are opts.Boundaries, which would keep the chunks from being derived from the file again, e.g. by Verify.
+func BuildManifest(r io.Reader, opts *Options) (*Manifest, error) {
+	return buildManifest(r, opts, nil)
+}
+
+// StoreFile chunks r like BuildManifest and puts every chunk into s.
+// The file can then be restored from the returned Manifest with Reassemble.
+func StoreFile(r io.Reader, s ChunkStore, opts *Options) (*Manifest, error) {
+	return buildManifest(r, opts, func(c *Chunk) error {
+		if err := s.Put(c.Sum, c.Data); err != nil {
+			return fmt.Errorf("ae: storing chunk at offset %d: %w", c.Offset, err)
+		}
+		return nil
+	})
+}
+
+// buildManifest implements BuildManifest, calling fn for every chunk if not nil.
+func buildManifest(r io.Reader, opts *Options, fn func(c *Chunk) error) (*Manifest, error) {
+	o, err := manifestOptions(opts)
+	if err != nil {
+		return nil, err
+	}
+	ch := NewChunker(r, o)
+	m := &Manifest{Parameters: ch.parameters()}
+	for {
+		c, err := ch.Next()
+		if err == io.EOF {
+			return m, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		if fn != nil {
+			if err := fn(c); err != nil {
+				return nil, err
+			}
+		}
+		m.Chunks = append(m.Chunks, ChunkRef{Offset: c.Offset, Length: int64(len(c.Data)), Hash: c.Sum})
+		m.Size += int64(len(c.Data))
+		ReleaseChunk(c)
+	}
+}
+
+// manifestOptions returns a copy of opts fit for recording the chunks in a Manifest, hashed with SHA-256 by default.
+func manifestOptions(opts *Options) (*Options, error) {
+	o := Options{}
+	if opts != nil {
+		o = *opts
+	}
+	o.Hasher = nil
+	if len(o.Boundaries) > 0 {
+		return nil, errBoundaries
+	}
+	if o.HashName == "" {
+		o.HashName = "sha256"
+	}
+	return &o, nil
+}
+
+// errBoundaries is returned when recording the chunks cut at explicit Boundaries, which cannot be recorded themselves.
+var errBoundaries = errors.New("ae: chunks cut at explicit Boundaries cannot be derived from the input again")
+
+// manifestVersion is the version of the serialization format of Manifest.
+const manifestVersion = 1
+
+// manifestJSONParamsVersion is the version of the JSON representation of a Manifest whose parameters include any
+// beyond those of version 1, which readers of version 1 would ignore and then chunk the input differently.
+const manifestJSONParamsVersion = 2
+
+// manifestJSON is the JSON representation of a Manifest.
+type manifestJSON struct {
+	Version    int            `json:"version"`
+	Size       int64          `json:"size"`
+	Parameters parametersJSON `json:"parameters"`
+	Chunks     []chunkRefJSON `json:"chunks"`
+	Parity     []parityJSON   `json:"parity,omitempty"`
+}
+
+type parametersJSON struct {
+	AverageSize    int64    `json:"averageSize"`
+	MaxSize        int64    `json:"maxSize"`
+	Mode           Extremum `json:"mode"`
+	Hash           string   `json:"hash"`
+	Alignment      int64    `json:"alignment,omitempty"`
+	AlignTolerance int64    `json:"alignTolerance,omitempty"`
+	SnapTolerance  int64    `json:"snapTolerance,omitempty"`
+	Delimiter      byte     `json:"delimiter,omitempty"`
+	RuneSafe       bool     `json:"runeSafe,omitempty"`
+	Tar            bool     `json:"tar,omitempty"`
+}
+
+// toJSON returns the JSON representation of p.
+func (p Parameters) toJSON() parametersJSON {
+	return parametersJSON{
+		AverageSize:    p.AverageSize,
+		MaxSize:        p.MaxSize,
+		Mode:           p.Mode,
+		Hash:           p.Hash,
+		Alignment:      p.Alignment,
+		AlignTolerance: p.AlignTolerance,
+		SnapTolerance:  p.SnapTolerance,
+		Delimiter:      p.Delimiter,
+		RuneSafe:       p.RuneSafe,
+		Tar:            p.Tar,
+	}
+}
+
+// parameters returns the Parameters represented by pj.
+func (pj parametersJSON) parameters() Parameters {
+	return Parameters{
+		AverageSize:    pj.AverageSize,
+		MaxSize:        pj.MaxSize,
+		Mode:           pj.Mode,
+		Hash:           pj.Hash,
+		Alignment:      pj.Alignment,
+		AlignTolerance: pj.AlignTolerance,
+		SnapTolerance:  pj.SnapTolerance,
+		Delimiter:      pj.Delimiter,
+		RuneSafe:       pj.RuneSafe,
+		Tar:            pj.Tar,
+	}
+}
+
+type chunkRefJSON struct {
+	Offset int64  `json:"offset"`
+	Length int64  `json:"length"`
+	Hash   string `json:"hash"`
+}
+
+type parityJSON struct {
+	First     int      `json:"first"`
+	Chunks    int      `json:"chunks"`
+	ShardSize int64    `json:"shardSize"`
+	Shards    []string `json:"shards"`
+}
+
+// MarshalJSON encodes the manifest as JSON, tagged with the version of the format.
+// Hashes are encoded in hex. Manifests with parameters beyond those of version 1 are written as version 2.
+func (m *Manifest) MarshalJSON() ([]byte, error) {
+	mj := manifestJSON{
+		Version:    manifestVersion,
+		Size:       m.Size,
+		Parameters: m.Parameters.toJSON(),
+		Chunks:     make([]chunkRefJSON, len(m.Chunks)),
+	}
+	if hasParams(&m.Parameters) {
+		mj.Version = manifestJSONParamsVersion
+	}
+	for i, ref := range m.Chunks {
+		mj.Chunks[i] = chunkRefJSON{Offset: ref.Offset, Length: ref.Length, Hash: hex.EncodeToString(ref.Hash)}
+	}
+	for _, g := range m.Parity {
+		pj := parityJSON{First: g.First, Chunks: g.Chunks, ShardSize: g.ShardSize, Shards: make([]string, len(g.Shards))}
+		for i, shard := range g.Shards {
+			pj.Shards[i] = hex.EncodeToString(shard)
+		}
+		mj.Parity = append(mj.Parity, pj)
+	}
+	return json.Marshal(mj)
+}
+
+// UnmarshalJSON decodes a manifest written by MarshalJSON of this or an earlier version of the package.
+func (m *Manifest) UnmarshalJSON(data []byte) error {
+	var mj manifestJSON
+	if err := json.Unmarshal(data, &mj); err != nil {
+		return err
+	}
+	if mj.Version < 1 || mj.Version > manifestJSONParamsVersion {
+		return fmt.Errorf("ae: unsupported manifest version %d", mj.Version)
+	}
+
+	chunks := make([]ChunkRef, len(mj.Chunks))
+	for i, rj := range mj.Chunks {
+		h, err := hex.DecodeString(rj.Hash)
+		if err != nil {
+			return fmt.Errorf("ae: invalid hash of chunk %d: %w", i, err)
+		}
+		chunks[i] = ChunkRef{Offset: rj.Offset, Length: rj.Length, Hash: h}
+	}
+	var parity []ParityGroup
+	for i, pj := range mj.Parity {
+		g := ParityGroup{First: pj.First, Chunks: pj.Chunks, ShardSize: pj.ShardSize, Shards: make([][]byte, len(pj.Shards))}
+		for j, shard := range pj.Shards {
+			h, err := hex.DecodeString(shard)
+			if err != nil {
+				return fmt.Errorf("ae: invalid hash of shard %d of parity group %d: %w", j, i, err)
+			}
+			g.Shards[j] = h
+		}
+		parity = append(parity, g)
+	}
+	*m = Manifest{
+		Size:       mj.Size,
+		Parameters: mj.Parameters.parameters(),
+		Chunks:     chunks,
+		Parity:     parity,
+	}
+	return m.Validate()
+}
+
+// Validate checks that the chunks of m are contiguous and add up to its size.
+func (m *Manifest) Validate() error {
+	var offset int64
+	for i, ref := range m.Chunks {
+		if ref.Offset != offset || ref.Length <= 0 {
+			return fmt.Errorf("ae: chunk %d of manifest is out of place", i)
+		}
+		offset += ref.Length
+	}
+	if offset != m.Size {
+		return fmt.Errorf("ae: chunks of manifest add up to %d bytes instead of %d", offset, m.Size)
+	}
+	return nil
+}
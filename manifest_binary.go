@@ -0,0 +1,381 @@
+package ae
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+)
+
+// manifestMagic prefixes every manifest in binary encoding.
+var manifestMagic = []byte("AEmf")
+
+// manifestParityVersion is the version of the binary encoding of manifests with parity,
+// which follows the chunks with the parity groups.
+const manifestParityVersion = 2
+
+// manifestParamsVersion is the version of the binary encoding of manifests with optional parameters,
+// which follow the digest size as tagged values (cf. paramFields). The chunks are always followed by the parity groups.
+const manifestParamsVersion = 3
+
+// paramField is an optional parameter in the encodings of manifestParamsVersion and of the chunker state.
+type paramField struct {
+	// tag identifying the parameter in the encoding, never zero.
+	tag uint64
+
+	// get returns the value of the parameter in p, zero if it is unset.
+	get func(p *Parameters) uint64
+
+	// set sets the parameter in p to v.
+	set func(p *Parameters, v uint64)
+}
+
+// paramFields are the optional parameters. Unset ones are left out of the encodings,
+// and decoders reject unknown tags, since the chunks depend on them.
+var paramFields = []paramField{
+	{1, func(p *Parameters) uint64 { return uint64(p.Alignment) }, func(p *Parameters, v uint64) { p.Alignment = int64(v) }},
+	{2, func(p *Parameters) uint64 { return uint64(p.AlignTolerance) }, func(p *Parameters, v uint64) { p.AlignTolerance = int64(v) }},
+	{3, func(p *Parameters) uint64 { return uint64(p.SnapTolerance) }, func(p *Parameters, v uint64) { p.SnapTolerance = int64(v) }},
+	{4, func(p *Parameters) uint64 { return uint64(p.Delimiter) }, func(p *Parameters, v uint64) { p.Delimiter = byte(v) }},
+	{5, func(p *Parameters) uint64 { return boolParam(p.RuneSafe) }, func(p *Parameters, v uint64) { p.RuneSafe = v != 0 }},
+	{6, func(p *Parameters) uint64 { return boolParam(p.Tar) }, func(p *Parameters, v uint64) { p.Tar = v != 0 }},
+}
+
+// boolParam returns the value of a boolean parameter in the encoding.
+func boolParam(b bool) uint64 {
+	if b {
+		return 1
+	}
+	return 0
+}
+
+// hasParams reports whether any of the optional parameters is set in p.
+func hasParams(p *Parameters) bool {
+	for _, f := range paramFields {
+		if f.get(p) != 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// appendParams appends the optional parameters set in p to b, terminated by a zero tag.
+func appendParams(b []byte, p *Parameters) []byte {
+	for _, f := range paramFields {
+		if v := f.get(p); v != 0 {
+			b = appendUvarint(b, f.tag)
+			b = appendUvarint(b, v)
+		}
+	}
+	return append(b, 0)
+}
+
+// readParams reads the optional parameters written by appendParams into p.
+func readParams(r io.ByteReader, p *Parameters) error {
+	for {
+		tag, err := binary.ReadUvarint(r)
+		if err != nil {
+			return err
+		}
+		if tag == 0 {
+			return nil
+		}
+		v, err := binary.ReadUvarint(r)
+		if err != nil {
+			return err
+		}
+		known := false
+		for _, f := range paramFields {
+			if f.tag == tag {
+				f.set(p, v)
+				known = true
+			}
+		}
+		if !known || v > math.MaxInt64 {
+			return fmt.Errorf("ae: unsupported parameter %d", tag)
+		}
+	}
+}
+
+// ErrInvalidManifest is returned when decoding a malformed binary manifest.
+var ErrInvalidManifest = errors.New("ae: invalid binary manifest")
+
+// ManifestEncoder writes a manifest in compact binary encoding, one chunk at a time.
+// Offsets and the file size are implied by the chunk lengths, which are encoded as varints,
+// and digests are stored raw. This keeps manifests of large datasets small,
+// and they never have to be held in memory as a whole.
+type ManifestEncoder struct {
+	w          io.Writer
+	version    byte
+	digestSize int
+	buf        []byte
+	err        error
+}
+
+// NewManifestEncoder writes the header of a manifest with parameters p to w.
+// All digests to be encoded must be digestSize bytes long.
+func NewManifestEncoder(w io.Writer, p Parameters, digestSize int) (*ManifestEncoder, error) {
+	version := byte(manifestVersion)
+	if hasParams(&p) {
+		version = manifestParamsVersion
+	}
+	buf := append([]byte(nil), manifestMagic...)
+	buf = append(buf, version)
+	buf = appendUvarint(buf, uint64(p.AverageSize))
+	buf = appendUvarint(buf, uint64(p.MaxSize))
+	buf = append(buf, byte(p.Mode))
+	buf = appendUvarint(buf, uint64(len(p.Hash)))
+	buf = append(buf, p.Hash...)
+	buf = appendUvarint(buf, uint64(digestSize))
+	if version == manifestParamsVersion {
+		buf = appendParams(buf, &p)
+	}
+	if _, err := w.Write(buf); err != nil {
+		return nil, err
+	}
+	return &ManifestEncoder{w: w, version: version, digestSize: digestSize}, nil
+}
+
+// Encode writes ref. Chunks must be encoded in order.
+func (e *ManifestEncoder) Encode(ref ChunkRef) error {
+	if e.err != nil {
+		return e.err
+	}
+	if ref.Length <= 0 {
+		return fmt.Errorf("ae: chunk length must be positive, got %d", ref.Length)
+	}
+	if len(ref.Hash) != e.digestSize {
+		return fmt.Errorf("ae: digest size must be %d, got %d", e.digestSize, len(ref.Hash))
+	}
+	e.buf = appendUvarint(e.buf[:0], uint64(ref.Length))
+	e.buf = append(e.buf, ref.Hash...)
+	_, e.err = e.w.Write(e.buf)
+	return e.err
+}
+
+// Close terminates the manifest. It does not close the underlying writer.
+func (e *ManifestEncoder) Close() error {
+	if e.err != nil {
+		return e.err
+	}
+	end := []byte{0}
+	if e.version == manifestParamsVersion {
+		// no parity groups
+		end = append(end, 0)
+	}
+	_, e.err = e.w.Write(end)
+	if e.err == nil {
+		e.err = errors.New("ae: manifest encoder is closed")
+		return nil
+	}
+	return e.err
+}
+
+// ManifestDecoder reads a manifest in the binary encoding of ManifestEncoder, one chunk at a time.
+type ManifestDecoder struct {
+	r          *bufio.Reader
+	params     Parameters
+	version    byte
+	digestSize int
+	offset     int64
+	done       bool
+}
+
+// NewManifestDecoder reads the header of a manifest from r.
+// The decoder may read beyond the end of the manifest.
+func NewManifestDecoder(r io.Reader) (*ManifestDecoder, error) {
+	br, ok := r.(*bufio.Reader)
+	if !ok {
+		br = bufio.NewReader(r)
+	}
+	d := &ManifestDecoder{r: br}
+
+	header := make([]byte, len(manifestMagic)+1)
+	if _, err := io.ReadFull(br, header); err != nil {
+		return nil, ErrInvalidManifest
+	}
+	if !bytes.Equal(header[:len(manifestMagic)], manifestMagic) {
+		return nil, ErrInvalidManifest
+	}
+	d.version = header[len(manifestMagic)]
+	if d.version != manifestVersion && d.version != manifestParityVersion && d.version != manifestParamsVersion {
+		return nil, fmt.Errorf("ae: unsupported manifest version %d", d.version)
+	}
+
+	var values [2]uint64
+	for i := range values {
+		v, err := binary.ReadUvarint(br)
+		if err != nil {
+			return nil, ErrInvalidManifest
+		}
+		values[i] = v
+	}
+	mode, err := br.ReadByte()
+	if err != nil || (Extremum(mode) != MAX && Extremum(mode) != MIN) {
+		return nil, ErrInvalidManifest
+	}
+	nameLen, err := binary.ReadUvarint(br)
+	if err != nil || nameLen > 255 {
+		return nil, ErrInvalidManifest
+	}
+	name := make([]byte, nameLen)
+	if _, err := io.ReadFull(br, name); err != nil {
+		return nil, ErrInvalidManifest
+	}
+	digestSize, err := binary.ReadUvarint(br)
+	if err != nil || digestSize > 1024 {
+		return nil, ErrInvalidManifest
+	}
+
+	d.params = Parameters{
+		AverageSize: int64(values[0]),
+		MaxSize:     int64(values[1]),
+		Mode:        Extremum(mode),
+		Hash:        string(name),
+	}
+	d.digestSize = int(digestSize)
+	if d.version == manifestParamsVersion {
+		if err := readParams(br, &d.params); err != nil {
+			return nil, ErrInvalidManifest
+		}
+	}
+	return d, nil
+}
+
+// Parameters returns the parameters recorded in the manifest.
+func (d *ManifestDecoder) Parameters() Parameters {
+	return d.params
+}
+
+// Next returns the next chunk of the manifest or io.EOF after the last one.
+func (d *ManifestDecoder) Next() (ChunkRef, error) {
+	if d.done {
+		return ChunkRef{}, io.EOF
+	}
+	length, err := binary.ReadUvarint(d.r)
+	if err != nil {
+		return ChunkRef{}, ErrInvalidManifest
+	}
+	if length == 0 {
+		d.done = true
+		return ChunkRef{}, io.EOF
+	}
+	ref := ChunkRef{Offset: d.offset, Length: int64(length), Hash: make([]byte, d.digestSize)}
+	if _, err := io.ReadFull(d.r, ref.Hash); err != nil {
+		return ChunkRef{}, ErrInvalidManifest
+	}
+	d.offset += ref.Length
+	return ref, nil
+}
+
+// MarshalBinary encodes the manifest in the binary encoding of ManifestEncoder.
+func (m *Manifest) MarshalBinary() ([]byte, error) {
+	digestSize := 0
+	if len(m.Chunks) > 0 {
+		digestSize = len(m.Chunks[0].Hash)
+	}
+	var buf bytes.Buffer
+	e, err := NewManifestEncoder(&buf, m.Parameters, digestSize)
+	if err != nil {
+		return nil, err
+	}
+	for _, ref := range m.Chunks {
+		if err := e.Encode(ref); err != nil {
+			return nil, err
+		}
+	}
+	if err := e.Close(); err != nil {
+		return nil, err
+	}
+	if len(m.Parity) == 0 {
+		return buf.Bytes(), nil
+	}
+
+	b := buf.Bytes()
+	if e.version == manifestParamsVersion {
+		// Close wrote an empty list of parity groups.
+		b = b[:len(b)-1]
+	} else {
+		b[len(manifestMagic)] = manifestParityVersion
+	}
+	b = appendUvarint(b, uint64(len(m.Parity)))
+	for _, g := range m.Parity {
+		b = appendUvarint(b, uint64(g.First))
+		b = appendUvarint(b, uint64(g.Chunks))
+		b = appendUvarint(b, uint64(g.ShardSize))
+		b = appendUvarint(b, uint64(len(g.Shards)))
+		for _, shard := range g.Shards {
+			if len(shard) != digestSize {
+				return nil, fmt.Errorf("ae: digest size must be %d, got %d", digestSize, len(shard))
+			}
+			b = append(b, shard...)
+		}
+	}
+	return b, nil
+}
+
+// UnmarshalBinary decodes a manifest in the binary encoding of ManifestEncoder.
+func (m *Manifest) UnmarshalBinary(data []byte) error {
+	d, err := NewManifestDecoder(bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	decoded := Manifest{Parameters: d.Parameters()}
+	for {
+		ref, err := d.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		decoded.Chunks = append(decoded.Chunks, ref)
+		decoded.Size += ref.Length
+	}
+	if d.version == manifestParityVersion || d.version == manifestParamsVersion {
+		parity, err := d.parity()
+		if err != nil {
+			return err
+		}
+		decoded.Parity = parity
+	}
+	if d.r.Buffered() > 0 {
+		return ErrInvalidManifest
+	}
+	*m = decoded
+	return nil
+}
+
+// parity reads the parity groups following the chunks in the encodings of versions manifestParityVersion
+// and manifestParamsVersion.
+func (d *ManifestDecoder) parity() ([]ParityGroup, error) {
+	n, err := binary.ReadUvarint(d.r)
+	if err != nil {
+		return nil, ErrInvalidManifest
+	}
+	var parity []ParityGroup
+	for i := uint64(0); i < n; i++ {
+		var values [4]uint64
+		for j := range values {
+			if values[j], err = binary.ReadUvarint(d.r); err != nil {
+				return nil, ErrInvalidManifest
+			}
+		}
+		if values[3] > 256 {
+			return nil, ErrInvalidManifest
+		}
+		g := ParityGroup{First: int(values[0]), Chunks: int(values[1]), ShardSize: int64(values[2])}
+		for j := uint64(0); j < values[3]; j++ {
+			shard := make([]byte, d.digestSize)
+			if _, err := io.ReadFull(d.r, shard); err != nil {
+				return nil, ErrInvalidManifest
+			}
+			g.Shards = append(g.Shards, shard)
+		}
+		parity = append(parity, g)
+	}
+	return parity, nil
+}
@@ -0,0 +1,99 @@
+package ae
+
+import (
+	"bytes"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"testing"
+)
+
+func TestManifest_Binary(t *testing.T) {
+	m, err := BuildManifest(bytes.NewReader(testFile[:8*MiB]), &Options{AverageSize: 8 * 1024, HashName: "blake3"})
+	assert.NoError(t, err)
+
+	t.Run("round trip", func(t *testing.T) {
+		data, err := m.MarshalBinary()
+		assert.NoError(t, err)
+		var decoded Manifest
+		assert.NoError(t, decoded.UnmarshalBinary(data))
+		assert.Equal(t, m, &decoded)
+
+		jsonData, err := json.Marshal(m)
+		assert.NoError(t, err)
+		assert.Less(t, len(data), len(jsonData)/2)
+	})
+
+	t.Run("streaming", func(t *testing.T) {
+		var buf bytes.Buffer
+		e, err := NewManifestEncoder(&buf, m.Parameters, 32)
+		assert.NoError(t, err)
+		for _, ref := range m.Chunks {
+			assert.NoError(t, e.Encode(ref))
+		}
+		assert.Error(t, e.Encode(ChunkRef{Length: 1, Hash: []byte{1}}))
+		assert.NoError(t, e.Close())
+		assert.Error(t, e.Encode(m.Chunks[0]))
+		buf.WriteString("trailing data")
+
+		d, err := NewManifestDecoder(&buf)
+		assert.NoError(t, err)
+		assert.Equal(t, m.Parameters, d.Parameters())
+		for _, ref := range m.Chunks {
+			decoded, err := d.Next()
+			assert.NoError(t, err)
+			assert.Equal(t, ref, decoded)
+		}
+		_, err = d.Next()
+		assert.Equal(t, io.EOF, err)
+	})
+
+	t.Run("empty manifest", func(t *testing.T) {
+		empty := &Manifest{Parameters: m.Parameters}
+		data, err := empty.MarshalBinary()
+		assert.NoError(t, err)
+		var decoded Manifest
+		assert.NoError(t, decoded.UnmarshalBinary(data))
+		assert.Equal(t, empty, &decoded)
+	})
+
+	t.Run("parameters", func(t *testing.T) {
+		withParams := *m
+		withParams.Parameters.Alignment, withParams.Parameters.AlignTolerance = 4096, 512
+		withParams.Parameters.SnapTolerance, withParams.Parameters.Delimiter = 1024, '\n'
+		withParams.Parameters.RuneSafe, withParams.Parameters.Tar = true, true
+		data, err := withParams.MarshalBinary()
+		assert.NoError(t, err)
+		assert.Equal(t, byte(manifestParamsVersion), data[len(manifestMagic)])
+		var decoded Manifest
+		assert.NoError(t, decoded.UnmarshalBinary(data))
+		assert.Equal(t, &withParams, &decoded)
+
+		withParams.Parity = []ParityGroup{{First: 0, Chunks: 1, ShardSize: m.Chunks[0].Length, Shards: [][]byte{m.Chunks[1].Hash}}}
+		data, err = withParams.MarshalBinary()
+		assert.NoError(t, err)
+		assert.Equal(t, byte(manifestParamsVersion), data[len(manifestMagic)])
+		decoded = Manifest{}
+		assert.NoError(t, decoded.UnmarshalBinary(data))
+		assert.Equal(t, &withParams, &decoded)
+
+		// Unknown parameters would change the chunks, so they are rejected.
+		var buf bytes.Buffer
+		_, err = NewManifestEncoder(&buf, withParams.Parameters, 32)
+		assert.NoError(t, err)
+		header := buf.Bytes()
+		header = append(header[:len(header)-1], 99, 1, 0, 0, 0)
+		_, err = NewManifestDecoder(bytes.NewReader(header))
+		assert.Equal(t, ErrInvalidManifest, err)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		data, err := m.MarshalBinary()
+		assert.NoError(t, err)
+		var decoded Manifest
+		assert.Error(t, decoded.UnmarshalBinary(data[:len(data)-1]))
+		assert.Error(t, decoded.UnmarshalBinary(append(data, 0)))
+		assert.Error(t, decoded.UnmarshalBinary([]byte("AEmf")))
+		assert.Error(t, decoded.UnmarshalBinary(nil))
+	})
+}
@@ -0,0 +1,133 @@
+package ae
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestBuildManifest(t *testing.T) {
+	input := testFile[:4*MiB]
+
+	t.Run("describes the input", func(t *testing.T) {
+		m, err := BuildManifest(bytes.NewReader(input), &Options{AverageSize: 64 * 1024, Mode: MIN})
+		assert.NoError(t, err)
+		assert.Equal(t, int64(len(input)), m.Size)
+		assert.Equal(t, Parameters{AverageSize: 64 * 1024, MaxSize: 128 * 1024, Mode: MIN, Hash: "sha256"}, m.Parameters)
+
+		chunks := getChunks(NewChunker(bytes.NewReader(input), m.Parameters.Options()))
+		assert.Len(t, m.Chunks, len(chunks))
+		var offset int64
+		for i, ref := range m.Chunks {
+			assert.Equal(t, offset, ref.Offset)
+			assert.Equal(t, int64(len(chunks[i])), ref.Length)
+			sum := sha256.Sum256(chunks[i])
+			assert.Equal(t, sum[:], ref.Hash)
+			offset += ref.Length
+		}
+	})
+
+	t.Run("empty input", func(t *testing.T) {
+		m, err := BuildManifest(bytes.NewReader(nil), &Options{HashName: "blake3"})
+		assert.NoError(t, err)
+		assert.Zero(t, m.Size)
+		assert.Empty(t, m.Chunks)
+		assert.Equal(t, "blake3", m.Parameters.Hash)
+	})
+
+	t.Run("explicit boundaries", func(t *testing.T) {
+		_, err := BuildManifest(bytes.NewReader(testFile[:MiB]), &Options{AverageSize: 64 * 1024, Boundaries: []int64{100}})
+		assert.Error(t, err)
+	})
+
+	t.Run("unknown hash", func(t *testing.T) {
+		_, err := BuildManifest(bytes.NewReader(input), &Options{HashName: "foo"})
+		assert.Error(t, err)
+	})
+}
+
+// readOnlyStore is a ChunkStore rejecting all writes.
+type readOnlyStore struct {
+	ChunkStore
+}
+
+var errReadOnly = errors.New("read-only store")
+
+func (readOnlyStore) Put(hash []byte, data []byte) error {
+	return errReadOnly
+}
+
+func TestStoreFile(t *testing.T) {
+	input := testFile[:4*MiB]
+	opts := &Options{AverageSize: 64 * 1024}
+
+	s := NewMemoryStore(0)
+	m, err := StoreFile(bytes.NewReader(input), s, opts)
+	assert.NoError(t, err)
+	built, err := BuildManifest(bytes.NewReader(input), opts)
+	assert.NoError(t, err)
+	assert.Equal(t, built, m)
+	assert.Equal(t, len(m.Chunks), s.Len())
+
+	var buf bytes.Buffer
+	assert.NoError(t, Reassemble(&buf, m, s))
+	assert.Equal(t, input, buf.Bytes())
+
+	_, err = StoreFile(bytes.NewReader(input), readOnlyStore{s}, opts)
+	assert.True(t, errors.Is(err, errReadOnly))
+}
+
+func TestManifest_JSON(t *testing.T) {
+	m, err := BuildManifest(bytes.NewReader(testFile[:MiB]), &Options{AverageSize: 64 * 1024, Mode: MIN})
+	assert.NoError(t, err)
+
+	t.Run("round trip", func(t *testing.T) {
+		data, err := json.Marshal(m)
+		assert.NoError(t, err)
+		var decoded Manifest
+		assert.NoError(t, json.Unmarshal(data, &decoded))
+		assert.Equal(t, m, &decoded)
+	})
+
+	t.Run("format", func(t *testing.T) {
+		data := `{"version":1,"size":5,"parameters":{"averageSize":4,"maxSize":8,"mode":"min","hash":"sha256"},` +
+			`"chunks":[{"offset":0,"length":3,"hash":"0a0b"},{"offset":3,"length":2,"hash":"0c"}]}`
+		var decoded Manifest
+		assert.NoError(t, json.Unmarshal([]byte(data), &decoded))
+		assert.Equal(t, Manifest{
+			Size:       5,
+			Parameters: Parameters{AverageSize: 4, MaxSize: 8, Mode: MIN, Hash: "sha256"},
+			Chunks:     []ChunkRef{{0, 3, []byte{10, 11}}, {3, 2, []byte{12}}},
+		}, decoded)
+
+		encoded, err := json.Marshal(&decoded)
+		assert.NoError(t, err)
+		assert.JSONEq(t, data, string(encoded))
+
+		// Parameters unknown to version 1 make it version 2, so that readers of version 1 reject the manifest.
+		data = `{"version":2,"size":5,"parameters":{"averageSize":4,"maxSize":8,"mode":"min","hash":"sha256","alignment":4},` +
+			`"chunks":[{"offset":0,"length":3,"hash":"0a0b"},{"offset":3,"length":2,"hash":"0c"}]}`
+		decoded.Parameters.Alignment = 4
+		encoded, err = json.Marshal(&decoded)
+		assert.NoError(t, err)
+		assert.JSONEq(t, data, string(encoded))
+		var aligned Manifest
+		assert.NoError(t, json.Unmarshal([]byte(data), &aligned))
+		assert.Equal(t, decoded, aligned)
+	})
+
+	t.Run("invalid", func(t *testing.T) {
+		for _, data := range []string{
+			`{"version":3,"size":0,"parameters":{"mode":"max"},"chunks":[]}`,
+			`{"version":1,"size":0,"parameters":{"mode":"foo"},"chunks":[]}`,
+			`{"version":1,"size":5,"parameters":{"mode":"max"},"chunks":[{"offset":0,"length":3,"hash":"00"}]}`,
+			`{"version":1,"size":3,"parameters":{"mode":"max"},"chunks":[{"offset":0,"length":3,"hash":"xx"}]}`,
+		} {
+			var decoded Manifest
+			assert.Error(t, json.Unmarshal([]byte(data), &decoded), data)
+		}
+	})
+}
@@ -0,0 +1,53 @@
+package ae
+
+import (
+	"context"
+	"sync"
+)
+
+// memBudget is a counting semaphore over bytes of memory.
+type memBudget struct {
+	mu   sync.Mutex
+	free int64
+
+	// wait is closed and replaced whenever memory is released.
+	wait chan struct{}
+}
+
+func newMemBudget(size int64) *memBudget {
+	return &memBudget{free: size}
+}
+
+// acquire blocks until n bytes are available or ctx is done.
+func (b *memBudget) acquire(ctx context.Context, n int64) error {
+	for {
+		b.mu.Lock()
+		if b.free >= n {
+			b.free -= n
+			b.mu.Unlock()
+			return nil
+		}
+		if b.wait == nil {
+			b.wait = make(chan struct{})
+		}
+		wait := b.wait
+		b.mu.Unlock()
+
+		select {
+		case <-wait:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// release returns n bytes to the budget.
+func (b *memBudget) release(n int64) {
+	b.mu.Lock()
+	b.free += n
+	if b.wait != nil {
+		close(b.wait)
+		b.wait = nil
+	}
+	b.mu.Unlock()
+}
@@ -0,0 +1,64 @@
+package ae
+
+import (
+	"bytes"
+	"context"
+	"github.com/stretchr/testify/assert"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestMaxMemory(t *testing.T) {
+	input := testFile[:4*MiB]
+
+	t.Run("too small for max size", func(t *testing.T) {
+		c := NewChunker(bytes.NewReader(input), &Options{AverageSize: 64 * 1024, MaxSize: 128 * 1024, MaxMemory: 255 * 1024})
+		_, err := c.Next()
+		assert.Equal(t, ErrMemoryLimit, err)
+	})
+
+	t.Run("read buffer is bounded", func(t *testing.T) {
+		c := NewChunker(bytes.NewReader(input), &Options{AverageSize: 1024, MaxMemory: 8 * 1024})
+		var data []byte
+		for _, chunk := range getChunks(c) {
+			assert.LessOrEqual(t, cap(c.buf), 4*1024)
+			data = append(data, chunk...)
+		}
+		assert.Equal(t, input, data)
+	})
+
+	t.Run("pipeline blocks on chunks in flight", func(t *testing.T) {
+		const maxMemory = 1024 * 1024
+		var inFlight, peak int64
+		var data []byte
+		p := &Pipeline{
+			Workers: 4,
+			Sink: sinkFunc(func(c *Chunk) error {
+				time.Sleep(time.Millisecond)
+				if n := atomic.LoadInt64(&inFlight); n > peak {
+					peak = n
+				}
+				atomic.AddInt64(&inFlight, -int64(len(c.Data)))
+				data = append(data, c.Data...)
+				return nil
+			}),
+		}
+		c := NewChunker(&countingReader{r: bytes.NewReader(input), n: &inFlight}, &Options{AverageSize: 64 * 1024, MaxMemory: maxMemory})
+		assert.NoError(t, p.Run(context.Background(), c))
+		assert.Equal(t, input, data)
+		assert.LessOrEqual(t, peak, int64(maxMemory))
+	})
+}
+
+// countingReader adds the number of bytes read to n.
+type countingReader struct {
+	r *bytes.Reader
+	n *int64
+}
+
+func (cr *countingReader) Read(p []byte) (int, error) {
+	n, err := cr.r.Read(p)
+	atomic.AddInt64(cr.n, int64(n))
+	return n, err
+}
@@ -0,0 +1,129 @@
+package ae
+
+import (
+	"container/list"
+	"sync"
+)
+
+// MemoryStore is a ChunkStore keeping chunks in memory.
+// If bounded in size, the least recently used chunks are evicted to make room for new ones,
+// which makes it suitable as a read cache in front of slower stores.
+type MemoryStore struct {
+	mu sync.Mutex
+
+	// maxBytes bounds the total size of the stored chunks, or zero if unbounded.
+	maxBytes int64
+
+	// size is the total size of the stored chunks.
+	size int64
+
+	// entries maps hashes to their element in lru.
+	entries map[string]*list.Element
+
+	// lru orders the entries from most to least recently used.
+	lru *list.List
+}
+
+// memEntry is a chunk held by a MemoryStore.
+type memEntry struct {
+	key  string
+	data []byte
+}
+
+// NewMemoryStore returns a MemoryStore holding at most maxBytes of chunk data.
+// If maxBytes is zero or negative, the store is unbounded.
+func NewMemoryStore(maxBytes int64) *MemoryStore {
+	return &MemoryStore{
+		maxBytes: maxBytes,
+		entries:  make(map[string]*list.Element),
+		lru:      list.New(),
+	}
+}
+
+// Put stores a copy of data under hash, evicting the least recently used chunks if needed.
+// Chunks larger than the size bound are not stored at all.
+func (s *MemoryStore) Put(hash []byte, data []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if e, ok := s.entries[string(hash)]; ok {
+		s.lru.MoveToFront(e)
+		return nil
+	}
+	if s.maxBytes > 0 && int64(len(data)) > s.maxBytes {
+		return nil
+	}
+	for s.maxBytes > 0 && s.size+int64(len(data)) > s.maxBytes {
+		s.remove(s.lru.Back())
+	}
+	entry := &memEntry{key: string(hash), data: append([]byte(nil), data...)}
+	s.entries[entry.key] = s.lru.PushFront(entry)
+	s.size += int64(len(data))
+	return nil
+}
+
+// Get returns the data stored under hash.
+// The returned slice is shared with the store and must not be modified.
+func (s *MemoryStore) Get(hash []byte) ([]byte, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[string(hash)]
+	if !ok {
+		return nil, ErrChunkNotFound
+	}
+	s.lru.MoveToFront(e)
+	return e.Value.(*memEntry).data, nil
+}
+
+// Has reports whether a chunk is stored under hash.
+func (s *MemoryStore) Has(hash []byte) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	_, ok := s.entries[string(hash)]
+	return ok, nil
+}
+
+// Delete removes the chunk stored under hash.
+func (s *MemoryStore) Delete(hash []byte) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	e, ok := s.entries[string(hash)]
+	if !ok {
+		return ErrChunkNotFound
+	}
+	s.remove(e)
+	return nil
+}
+
+// Walk calls fn for every chunk in the store, from the most to the least recently used.
+func (s *MemoryStore) Walk(fn func(hash []byte, size int64) error) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for e := s.lru.Front(); e != nil; e = e.Next() {
+		entry := e.Value.(*memEntry)
+		if err := fn([]byte(entry.key), int64(len(entry.data))); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Len returns the number of stored chunks.
+func (s *MemoryStore) Len() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return len(s.entries)
+}
+
+// Size returns the total size of the stored chunks in bytes.
+func (s *MemoryStore) Size() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.size
+}
+
+// remove drops the entry e from the store.
+func (s *MemoryStore) remove(e *list.Element) {
+	entry := s.lru.Remove(e).(*memEntry)
+	delete(s.entries, entry.key)
+	s.size -= int64(len(entry.data))
+}
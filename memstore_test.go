@@ -0,0 +1,48 @@
+package ae
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestMemoryStore(t *testing.T) {
+	testChunkStore(t, NewMemoryStore(0))
+
+	t.Run("eviction", func(t *testing.T) {
+		s := NewMemoryStore(10)
+		assert.NoError(t, s.Put([]byte{1}, make([]byte, 4)))
+		assert.NoError(t, s.Put([]byte{2}, make([]byte, 4)))
+		// touch the first chunk so that the second one is evicted
+		_, err := s.Get([]byte{1})
+		assert.NoError(t, err)
+		assert.NoError(t, s.Put([]byte{3}, make([]byte, 4)))
+
+		assert.Equal(t, 2, s.Len())
+		assert.Equal(t, int64(8), s.Size())
+		ok, _ := s.Has([]byte{1})
+		assert.True(t, ok)
+		ok, _ = s.Has([]byte{2})
+		assert.False(t, ok)
+		ok, _ = s.Has([]byte{3})
+		assert.True(t, ok)
+	})
+
+	t.Run("oversized chunk", func(t *testing.T) {
+		s := NewMemoryStore(10)
+		assert.NoError(t, s.Put([]byte{1}, make([]byte, 4)))
+		assert.NoError(t, s.Put([]byte{2}, make([]byte, 11)))
+		assert.Equal(t, 1, s.Len())
+		ok, _ := s.Has([]byte{2})
+		assert.False(t, ok)
+	})
+
+	t.Run("copies data", func(t *testing.T) {
+		s := NewMemoryStore(0)
+		data := []byte("foo")
+		assert.NoError(t, s.Put([]byte{1}, data))
+		data[0] = 'b'
+		stored, err := s.Get([]byte{1})
+		assert.NoError(t, err)
+		assert.Equal(t, []byte("foo"), stored)
+	})
+}
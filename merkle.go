@@ -0,0 +1,140 @@
+package ae
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"hash"
+)
+
+// ErrInvalidProof is returned if a MerkleProof does not prove the inclusion of a chunk.
+var ErrInvalidProof = errors.New("ae: invalid Merkle proof")
+
+// MerkleProof proves that a chunk is part of a manifest with a given Merkle root.
+type MerkleProof struct {
+	// Index of the chunk in the manifest.
+	Index int
+
+	// Chunks is the number of chunks in the manifest.
+	Chunks int
+
+	// Hash is the registered name of the hash the tree is built with.
+	Hash string
+
+	// Path holds the hashes of the sibling subtrees from the leaf up to the root.
+	Path [][]byte
+}
+
+// MerkleRoot returns the root of the Merkle tree over the chunk hashes of m.
+// The tree is built as in Certificate Transparency (RFC 6962) with the hash of the manifest,
+// the leaves being the chunk hashes in order.
+func (m *Manifest) MerkleRoot() ([]byte, error) {
+	h, err := LookupHash(m.Parameters.Hash)
+	if err != nil {
+		return nil, err
+	}
+	if len(m.Chunks) == 0 {
+		return h().Sum(nil), nil
+	}
+	return merkleTreeHash(h(), m.Chunks), nil
+}
+
+// MerkleProof returns the proof of inclusion of the i-th chunk of m in its Merkle root.
+func (m *Manifest) MerkleProof(i int) (*MerkleProof, error) {
+	if i < 0 || i >= len(m.Chunks) {
+		return nil, fmt.Errorf("ae: chunk index %d out of range", i)
+	}
+	h, err := LookupHash(m.Parameters.Hash)
+	if err != nil {
+		return nil, err
+	}
+	return &MerkleProof{
+		Index:  i,
+		Chunks: len(m.Chunks),
+		Hash:   m.Parameters.Hash,
+		Path:   merklePath(h(), i, m.Chunks),
+	}, nil
+}
+
+// Verify checks that the chunk with the hash chunkHash is part of a manifest with the Merkle root root.
+func (p *MerkleProof) Verify(root, chunkHash []byte) error {
+	fn, err := LookupHash(p.Hash)
+	if err != nil {
+		return err
+	}
+	if p.Index < 0 || p.Index >= p.Chunks {
+		return ErrInvalidProof
+	}
+	h := fn()
+
+	// cf. RFC 9162, Section 2.1.3.2
+	index, last := p.Index, p.Chunks-1
+	r := merkleLeaf(h, chunkHash)
+	for _, sibling := range p.Path {
+		if last == 0 {
+			return ErrInvalidProof
+		}
+		if index%2 == 1 || index == last {
+			r = merkleNode(h, sibling, r)
+			for index%2 == 0 && index != 0 {
+				index >>= 1
+				last >>= 1
+			}
+		} else {
+			r = merkleNode(h, r, sibling)
+		}
+		index >>= 1
+		last >>= 1
+	}
+	if last != 0 || !bytes.Equal(r, root) {
+		return ErrInvalidProof
+	}
+	return nil
+}
+
+// merkleTreeHash returns the root hash of the tree over the non-empty chunks.
+func merkleTreeHash(h hash.Hash, chunks []ChunkRef) []byte {
+	if len(chunks) == 1 {
+		return merkleLeaf(h, chunks[0].Hash)
+	}
+	k := merkleSplit(len(chunks))
+	return merkleNode(h, merkleTreeHash(h, chunks[:k]), merkleTreeHash(h, chunks[k:]))
+}
+
+// merklePath returns the audit path of the i-th leaf in the tree over chunks.
+func merklePath(h hash.Hash, i int, chunks []ChunkRef) [][]byte {
+	if len(chunks) == 1 {
+		return nil
+	}
+	k := merkleSplit(len(chunks))
+	if i < k {
+		return append(merklePath(h, i, chunks[:k]), merkleTreeHash(h, chunks[k:]))
+	}
+	return append(merklePath(h, i-k, chunks[k:]), merkleTreeHash(h, chunks[:k]))
+}
+
+// merkleSplit returns the largest power of two smaller than n, which must be at least 2.
+func merkleSplit(n int) int {
+	k := 1
+	for k<<1 < n {
+		k <<= 1
+	}
+	return k
+}
+
+// merkleLeaf returns the hash of a leaf, domain-separated from inner nodes.
+func merkleLeaf(h hash.Hash, data []byte) []byte {
+	h.Reset()
+	h.Write([]byte{0})
+	h.Write(data)
+	return h.Sum(nil)
+}
+
+// merkleNode returns the hash of an inner node with the given children.
+func merkleNode(h hash.Hash, left, right []byte) []byte {
+	h.Reset()
+	h.Write([]byte{1})
+	h.Write(left)
+	h.Write(right)
+	return h.Sum(nil)
+}
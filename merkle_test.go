@@ -0,0 +1,76 @@
+package ae
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// testManifest returns a manifest of n chunks with the hashes of testHash.
+func testManifest(n int) *Manifest {
+	m := &Manifest{Parameters: Parameters{Hash: "sha256"}}
+	for i := 0; i < n; i++ {
+		m.Chunks = append(m.Chunks, ChunkRef{Offset: int64(i), Length: 1, Hash: testHash(i)})
+	}
+	m.Size = int64(n)
+	return m
+}
+
+func TestManifest_MerkleRoot(t *testing.T) {
+	t.Run("empty", func(t *testing.T) {
+		root, err := testManifest(0).MerkleRoot()
+		assert.NoError(t, err)
+		sum := sha256.Sum256(nil)
+		assert.Equal(t, sum[:], root)
+	})
+
+	t.Run("structure", func(t *testing.T) {
+		h := sha256.New()
+		leaf := func(i int) []byte { return merkleLeaf(h, testHash(i)) }
+		node := func(l, r []byte) []byte { return merkleNode(h, l, r) }
+
+		root, err := testManifest(1).MerkleRoot()
+		assert.NoError(t, err)
+		assert.Equal(t, leaf(0), root)
+
+		root, err = testManifest(3).MerkleRoot()
+		assert.NoError(t, err)
+		assert.Equal(t, node(node(leaf(0), leaf(1)), leaf(2)), root)
+
+		root, err = testManifest(5).MerkleRoot()
+		assert.NoError(t, err)
+		assert.Equal(t, hex.EncodeToString(node(node(node(leaf(0), leaf(1)), node(leaf(2), leaf(3))), leaf(4))),
+			hex.EncodeToString(root))
+	})
+
+	t.Run("unknown hash", func(t *testing.T) {
+		m := testManifest(1)
+		m.Parameters.Hash = "foo"
+		_, err := m.MerkleRoot()
+		assert.Error(t, err)
+	})
+}
+
+func TestManifest_MerkleProof(t *testing.T) {
+	for _, n := range []int{1, 2, 3, 7, 8, 13} {
+		m := testManifest(n)
+		root, err := m.MerkleRoot()
+		assert.NoError(t, err)
+
+		for i := 0; i < n; i++ {
+			p, err := m.MerkleProof(i)
+			assert.NoError(t, err)
+			assert.NoError(t, p.Verify(root, testHash(i)), "chunk %d of %d", i, n)
+			assert.Equal(t, ErrInvalidProof, p.Verify(root, testHash(n)), "chunk %d of %d", i, n)
+			if n > 1 {
+				wrong := *p
+				wrong.Index = (i + 1) % n
+				assert.Equal(t, ErrInvalidProof, wrong.Verify(root, testHash(i)), "chunk %d of %d", i, n)
+			}
+		}
+	}
+
+	_, err := testManifest(3).MerkleProof(3)
+	assert.Error(t, err)
+}
@@ -0,0 +1,67 @@
+package ae
+
+// Counter is a metric that only goes up.
+// It is satisfied by prometheus.Counter.
+type Counter interface {
+	Add(v float64)
+}
+
+// Observer is a metric that records a distribution of values.
+// It is satisfied by prometheus.Histogram and prometheus.Summary.
+type Observer interface {
+	Observe(v float64)
+}
+
+// Metrics are the instruments a Chunker updates as it emits chunks. Every field is optional.
+// They are shaped after the Prometheus client, so that counters and histograms
+// created with it and registered with a prometheus.Registerer can be assigned directly:
+//
+//	chunkSize := prometheus.NewHistogram(prometheus.HistogramOpts{
+//		Name:    "ae_chunk_size_bytes",
+//		Buckets: ae.SizeBuckets(opts),
+//	})
+//	reg.MustRegister(chunkSize)
+//	opts.Metrics = &ae.Metrics{ChunkSize: chunkSize}
+//
+// The same Metrics may be shared by several Chunkers if the instruments are safe for concurrent use.
+type Metrics struct {
+	// Bytes counts the bytes emitted as part of chunks.
+	Bytes Counter
+
+	// Chunks counts the chunks emitted.
+	Chunks Counter
+
+	// Truncated counts the chunks cut at MaxSize, except those of the Fixed algorithm. Relative to Chunks, it is the truncation rate,
+	// which should stay low; otherwise MaxSize is too small for the data.
+	Truncated Counter
+
+	// ChunkSize observes the size in bytes of every chunk.
+	ChunkSize Observer
+}
+
+// observe records a chunk of n bytes that ended for the given reason.
+func (m *Metrics) observe(n int, reason CutReason) {
+	if m.Bytes != nil {
+		m.Bytes.Add(float64(n))
+	}
+	if m.Chunks != nil {
+		m.Chunks.Add(1)
+	}
+	if m.Truncated != nil && reason == CutMaxSize {
+		m.Truncated.Add(1)
+	}
+	if m.ChunkSize != nil {
+		m.ChunkSize.Observe(float64(n))
+	}
+}
+
+// SizeBuckets returns histogram buckets suited to the chunk sizes produced with opts.
+// They double from an eighth of the average size up to the maximum size.
+func SizeBuckets(opts *Options) []float64 {
+	p := newParams(opts)
+	var buckets []float64
+	for b := float64(p.avgSize) / 8; b < float64(p.maxSize); b *= 2 {
+		buckets = append(buckets, b)
+	}
+	return append(buckets, float64(p.maxSize))
+}
@@ -0,0 +1,52 @@
+package ae
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// testCounter is a Counter recording its total.
+type testCounter float64
+
+func (c *testCounter) Add(v float64) { *c += testCounter(v) }
+
+// testObserver is an Observer recording all values.
+type testObserver []float64
+
+func (o *testObserver) Observe(v float64) { *o = append(*o, v) }
+
+func TestMetrics(t *testing.T) {
+	t.Run("strictly increasing bytes are truncated", func(t *testing.T) {
+		data := make([]byte, 1000)
+		for i := range data {
+			data[i] = byte(i / 4)
+		}
+		var bytesChunked, chunks, truncated testCounter
+		var sizes testObserver
+		opts := &Options{AverageSize: 100, MaxSize: 300, Metrics: &Metrics{
+			Bytes:     &bytesChunked,
+			Chunks:    &chunks,
+			Truncated: &truncated,
+			ChunkSize: &sizes,
+		}}
+		getChunks(NewChunker(bytes.NewReader(data), opts))
+
+		assert.Equal(t, testCounter(1000), bytesChunked)
+		assert.Equal(t, testCounter(4), chunks)
+		assert.Equal(t, testCounter(3), truncated)
+		assert.Equal(t, testObserver{300, 300, 300, 100}, sizes)
+	})
+
+	t.Run("partial metrics", func(t *testing.T) {
+		var chunks testCounter
+		input := testFile[:MiB]
+		opts := &Options{AverageSize: 4096, Metrics: &Metrics{Chunks: &chunks}}
+		assert.Equal(t, float64(len(getChunks(NewChunker(bytes.NewReader(input), opts)))), float64(chunks))
+	})
+}
+
+func TestSizeBuckets(t *testing.T) {
+	assert.Equal(t, []float64{128, 256, 512, 1024, 2048}, SizeBuckets(&Options{AverageSize: 1024}))
+	assert.Equal(t, []float64{128, 256, 512, 1024, 1500}, SizeBuckets(&Options{AverageSize: 1024, MaxSize: 1500}))
+}
@@ -0,0 +1,59 @@
+package ae
+
+import (
+	"fmt"
+	"io"
+)
+
+// NamedReader is a file to be chunked by a MultiChunker.
+type NamedReader struct {
+	// Name of the file, which the chunks of the file carry in Chunk.File.
+	Name string
+
+	// Reader of the content of the file.
+	Reader io.Reader
+}
+
+// MultiChunker chunks several files in sequence like a Chunker reading them concatenated, e.g. with io.MultiReader,
+// except that every file starts afresh: no chunk spans two files, and the chunks of a file are the same
+// wherever it occurs in the sequence. Every chunk is attributed to its file by Chunk.File,
+// its Offset is relative to the start of the file, and the last chunk of every file is Final.
+// Empty files yield no chunks. The buffers are shared by all files.
+type MultiChunker struct {
+	ch      *Chunker
+	readers []NamedReader
+
+	// current is the index of the file being chunked.
+	current int
+}
+
+// NewMultiChunker returns a MultiChunker chunking the given files in order with opts.
+func NewMultiChunker(opts *Options, readers ...NamedReader) *MultiChunker {
+	var r io.Reader
+	if len(readers) > 0 {
+		r = readers[0].Reader
+	}
+	return &MultiChunker{ch: NewChunker(r, opts), readers: readers}
+}
+
+// Next returns the next chunk of the files or io.EOF once all of them are exhausted.
+// Errors reading a file are returned along with its name.
+func (m *MultiChunker) Next() (*Chunk, error) {
+	for m.current < len(m.readers) {
+		c, err := m.ch.Next()
+		if err == io.EOF {
+			m.current++
+			if m.current < len(m.readers) {
+				m.ch.reset(m.readers[m.current].Reader)
+			}
+			continue
+		}
+		name := m.readers[m.current].Name
+		if err != nil {
+			return nil, fmt.Errorf("ae: chunking %s: %w", name, err)
+		}
+		c.File = name
+		return c, nil
+	}
+	return nil, io.EOF
+}
@@ -0,0 +1,60 @@
+package ae
+
+import (
+	"bytes"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"testing"
+	"testing/iotest"
+)
+
+func TestMultiChunker(t *testing.T) {
+	opts := &Options{AverageSize: 16 * 1024, SHA256: true}
+	files := map[string][]byte{
+		"a":     testFile[:MiB],
+		"empty": nil,
+		"b":     testFile[3*MiB : 3*MiB+100],
+		"c":     testFile[:MiB/2],
+	}
+	order := []string{"a", "empty", "b", "c"}
+	var readers []NamedReader
+	for _, name := range order {
+		readers = append(readers, NamedReader{Name: name, Reader: bytes.NewReader(files[name])})
+	}
+
+	m := NewMultiChunker(opts, readers...)
+	got := make(map[string][]*Chunk)
+	for {
+		c, err := m.Next()
+		if err == io.EOF {
+			break
+		}
+		assert.NoError(t, err)
+		got[c.File] = append(got[c.File], c)
+	}
+	assert.Len(t, got, 3)
+
+	for name, chunks := range got {
+		ch := NewChunker(bytes.NewReader(files[name]), opts)
+		for i, c := range chunks {
+			want, err := ch.Next()
+			assert.NoError(t, err)
+			assert.Equal(t, want.Offset, c.Offset, name)
+			assert.Equal(t, want.Sum, c.Sum, name)
+			assert.Equal(t, i == len(chunks)-1, c.Final, name)
+		}
+		_, err := ch.Next()
+		assert.Equal(t, io.EOF, err, name)
+	}
+	// The chunks of c are a prefix of those of a, as neither is affected by the files before it.
+	assert.Equal(t, got["a"][0].Sum, got["c"][0].Sum)
+
+	_, err := NewMultiChunker(opts).Next()
+	assert.Equal(t, io.EOF, err)
+
+	failing := iotest.ErrReader(errors.New("disk on fire"))
+	m = NewMultiChunker(opts, NamedReader{Name: "a", Reader: bytes.NewReader(nil)}, NamedReader{Name: "broken", Reader: failing})
+	_, err = m.Next()
+	assert.EqualError(t, err, "ae: chunking broken: disk on fire")
+}
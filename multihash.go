@@ -0,0 +1,66 @@
+package ae
+
+import (
+	"encoding/base32"
+	"encoding/binary"
+	"fmt"
+)
+
+// multihashCodes maps registered hash names to their multicodec codes.
+var multihashCodes = map[string]uint64{
+	"sha1":   0x11,
+	"sha256": 0x12,
+	"sha512": 0x13,
+	"blake3": 0x1e,
+}
+
+// rawCodec is the multicodec code of raw binary data.
+const rawCodec = 0x55
+
+// cidEncoding is the base32 alphabet of multibase "b" (lowercase, no padding).
+var cidEncoding = base32.NewEncoding("abcdefghijklmnopqrstuvwxyz234567").WithPadding(base32.NoPadding)
+
+// Multihash returns the digest of the chunk encoded as multihash.
+// This requires the hash to be configured by Options.HashName (or Options.SHA256)
+// and known to the multicodec table, i.e. one of sha1, sha256, sha512 or blake3.
+func (c *Chunk) Multihash() ([]byte, error) {
+	if c.Sum == nil {
+		return nil, fmt.Errorf("ae: chunk has no digest")
+	}
+	return multihash(c.hashName, c.Sum)
+}
+
+// multihash encodes digest of the registered hash name as multihash.
+func multihash(name string, digest []byte) ([]byte, error) {
+	code, ok := multihashCodes[name]
+	if !ok {
+		return nil, fmt.Errorf("ae: no multihash code for hash %q", name)
+	}
+	mh := make([]byte, 0, 2*binary.MaxVarintLen64+len(digest))
+	mh = appendUvarint(mh, code)
+	mh = appendUvarint(mh, uint64(len(digest)))
+	return append(mh, digest...), nil
+}
+
+// CID returns the content identifier (CIDv1, raw codec, base32) of the chunk,
+// as used for raw blocks in IPFS. It has the same requirements as Multihash.
+func (c *Chunk) CID() (string, error) {
+	mh, err := c.Multihash()
+	if err != nil {
+		return "", err
+	}
+	return "b" + cidEncoding.EncodeToString(cidV1(rawCodec, mh)), nil
+}
+
+// cidV1 returns the binary CIDv1 of content with the multicodec code codec and the multihash mh.
+func cidV1(codec uint64, mh []byte) []byte {
+	b := appendUvarint(nil, 1)
+	b = appendUvarint(b, codec)
+	return append(b, mh...)
+}
+
+// appendUvarint appends the varint encoding of v to b.
+func appendUvarint(b []byte, v uint64) []byte {
+	var buf [binary.MaxVarintLen64]byte
+	return append(b, buf[:binary.PutUvarint(buf[:], v)]...)
+}
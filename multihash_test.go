@@ -0,0 +1,45 @@
+package ae
+
+import (
+	"bytes"
+	"encoding/hex"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestChunk_CID(t *testing.T) {
+	nextChunk := func(opts *Options) *Chunk {
+		opts.AverageSize = 1024
+		c, err := NewChunker(bytes.NewReader([]byte("hello world")), opts).Next()
+		assert.NoError(t, err)
+		return c
+	}
+
+	t.Run("sha256", func(t *testing.T) {
+		c := nextChunk(&Options{SHA256: true})
+		mh, err := c.Multihash()
+		assert.NoError(t, err)
+		assert.Equal(t, "1220b94d27b9934d3e08a52e52d7da7dabfac484efe37a5380ee9088f7ace2efcde9", hex.EncodeToString(mh))
+		cid, err := c.CID()
+		assert.NoError(t, err)
+		assert.Equal(t, "bafkreifzjut3te2nhyekklss27nh3k72ysco7y32koao5eei66wof36n5e", cid)
+	})
+
+	t.Run("blake3", func(t *testing.T) {
+		cid, err := nextChunk(&Options{HashName: "blake3"}).CID()
+		assert.NoError(t, err)
+		assert.Equal(t, "bafkr4igxjga67jykbseaxdmmdgc5a5o3zp3htom2l6mrjznk7fvyggu6eq", cid)
+	})
+
+	t.Run("unknown hash", func(t *testing.T) {
+		_, err := nextChunk(&Options{Hasher: BLAKE3}).CID()
+		assert.Error(t, err)
+		_, err = nextChunk(&Options{}).Multihash()
+		assert.Error(t, err)
+	})
+
+	t.Run("unregistered hash name", func(t *testing.T) {
+		_, err := NewChunker(bytes.NewReader([]byte("hello world")), &Options{HashName: "foo"}).Next()
+		assert.Error(t, err)
+	})
+}
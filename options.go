@@ -0,0 +1,285 @@
+package ae
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// errUnencodable is returned when encoding Options that depend on values of the running program.
+var errUnencodable = errors.New("ae: options with a Hasher cannot be encoded, use HashName instead")
+
+// Validate checks that opts are consistent, so that they chunk as configured rather than being corrected silently.
+// Zero values select the defaults as usual. Options read by UnmarshalJSON or UnmarshalText are validated already.
+func (opts *Options) Validate() error {
+	if opts.AverageSize < 0 {
+		return fmt.Errorf("ae: invalid options: negative AverageSize %d", opts.AverageSize)
+	}
+	if opts.MaxSize < 0 {
+		return fmt.Errorf("ae: invalid options: negative MaxSize %d", opts.MaxSize)
+	}
+	if _, err := opts.Algorithm.MarshalText(); err != nil {
+		return err
+	}
+	if _, err := opts.Mode.MarshalText(); err != nil {
+		return err
+	}
+	p := newParams(opts)
+	if opts.MaxSize > 0 && opts.Algorithm != Fixed && opts.MaxSize < p.avgSize {
+		return fmt.Errorf("ae: invalid options: MaxSize %d is less than the AverageSize %d", opts.MaxSize, p.avgSize)
+	}
+	if p.maxSize > maxInt {
+		return ErrSizeOverflow
+	}
+	if opts.Hasher != nil && (opts.HashName != "" || opts.SHA256) {
+		return errors.New("ae: invalid options: both Hasher and HashName are set")
+	}
+	if opts.HashName != "" {
+		if _, err := LookupHash(opts.HashName); err != nil {
+			return err
+		}
+	}
+	if opts.MaxMemory < 0 {
+		return fmt.Errorf("ae: invalid options: negative MaxMemory %d", opts.MaxMemory)
+	}
+	if opts.MaxMemory > 0 && opts.MaxMemory < 2*p.maxSize {
+		return ErrMemoryLimit
+	}
+	if opts.MaxChunkAge < 0 {
+		return fmt.Errorf("ae: invalid options: negative MaxChunkAge %s", opts.MaxChunkAge)
+	}
+	if opts.MaxTotalBytes < 0 {
+		return fmt.Errorf("ae: invalid options: negative MaxTotalBytes %d", opts.MaxTotalBytes)
+	}
+	for i := 1; i < len(opts.Boundaries); i++ {
+		if opts.Boundaries[i] <= opts.Boundaries[i-1] {
+			return errors.New("ae: invalid options: Boundaries are not in increasing order")
+		}
+	}
+	if opts.SnapTolerance < 0 {
+		return fmt.Errorf("ae: invalid options: negative SnapTolerance %d", opts.SnapTolerance)
+	}
+	if opts.Alignment < 0 || opts.AlignTolerance < 0 {
+		return errors.New("ae: invalid options: negative Alignment or AlignTolerance")
+	}
+	if opts.AlignTolerance > 0 && opts.Alignment == 0 {
+		return errors.New("ae: invalid options: AlignTolerance without Alignment")
+	}
+	return nil
+}
+
+// optionsJSON is the JSON representation of Options.
+// Options that only exist in the running program, i.e. Hasher, Limiter, Trace, Metrics and Logger, are left out,
+// as are the Boundaries, which belong to a single input, and MaxChunkAge, whose boundaries depend on timing.
+type optionsJSON struct {
+	AverageSize    int64     `json:"averageSize,omitempty"`
+	Algorithm      Algorithm `json:"algorithm"`
+	Mode           Extremum  `json:"mode"`
+	MaxSize        int64     `json:"maxSize,omitempty"`
+	Hash           string    `json:"hash,omitempty"`
+	MaxMemory      int64     `json:"maxMemory,omitempty"`
+	MaxTotalBytes  int64     `json:"maxTotalBytes,omitempty"`
+	Tar            bool      `json:"tar,omitempty"`
+	SnapTolerance  int64     `json:"snapTolerance,omitempty"`
+	Delimiter      byte      `json:"delimiter,omitempty"`
+	RuneSafe       bool      `json:"runeSafe,omitempty"`
+	Alignment      int64     `json:"alignment,omitempty"`
+	AlignTolerance int64     `json:"alignTolerance,omitempty"`
+	Entropy        bool      `json:"entropy,omitempty"`
+}
+
+// MarshalJSON encodes the options that determine how an input is chunked, such that
+// UnmarshalJSON restores options chunking any input identically. SHA256 is encoded as the HashName "sha256".
+// Options with a Hasher cannot be encoded.
+func (opts Options) MarshalJSON() ([]byte, error) {
+	if opts.Hasher != nil {
+		return nil, errUnencodable
+	}
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	oj := optionsJSON{
+		AverageSize:    opts.AverageSize,
+		Algorithm:      opts.Algorithm,
+		Mode:           opts.Mode,
+		MaxSize:        opts.MaxSize,
+		Hash:           opts.HashName,
+		MaxMemory:      opts.MaxMemory,
+		MaxTotalBytes:  opts.MaxTotalBytes,
+		Tar:            opts.Tar,
+		SnapTolerance:  opts.SnapTolerance,
+		Delimiter:      opts.Delimiter,
+		RuneSafe:       opts.RuneSafe,
+		Alignment:      opts.Alignment,
+		AlignTolerance: opts.AlignTolerance,
+		Entropy:        opts.Entropy,
+	}
+	if oj.Hash == "" && opts.SHA256 {
+		oj.Hash = "sha256"
+	}
+	return json.Marshal(oj)
+}
+
+// UnmarshalJSON decodes options written by MarshalJSON. Unlike most JSON decoding, it is strict:
+// unknown fields, e.g. misspelled ones, are rejected, and the options must pass Validate.
+func (opts *Options) UnmarshalJSON(data []byte) error {
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.DisallowUnknownFields()
+	var oj optionsJSON
+	if err := dec.Decode(&oj); err != nil {
+		return fmt.Errorf("ae: invalid options: %w", err)
+	}
+	o := Options{
+		AverageSize:    oj.AverageSize,
+		Algorithm:      oj.Algorithm,
+		Mode:           oj.Mode,
+		MaxSize:        oj.MaxSize,
+		HashName:       oj.Hash,
+		MaxMemory:      oj.MaxMemory,
+		MaxTotalBytes:  oj.MaxTotalBytes,
+		Tar:            oj.Tar,
+		SnapTolerance:  oj.SnapTolerance,
+		Delimiter:      oj.Delimiter,
+		RuneSafe:       oj.RuneSafe,
+		Alignment:      oj.Alignment,
+		AlignTolerance: oj.AlignTolerance,
+		Entropy:        oj.Entropy,
+	}
+	if err := o.Validate(); err != nil {
+		return err
+	}
+	*opts = o
+	return nil
+}
+
+// optionKey is an option in the text encoding of Options.
+type optionKey struct {
+	// name of the option, as in the JSON encoding.
+	name string
+
+	// format returns the value of the option in opts, or "" if it is unset.
+	format func(opts *Options) string
+
+	// parse sets the option in opts to the value s.
+	parse func(opts *Options, s string) error
+}
+
+// optionKeys are the options of the text encoding in the order they are written in.
+var optionKeys = []optionKey{
+	{"algorithm", func(o *Options) string { return o.Algorithm.String() }, func(o *Options, s string) error {
+		return o.Algorithm.UnmarshalText([]byte(s))
+	}},
+	{"mode", func(o *Options) string { return o.Mode.String() }, func(o *Options, s string) error {
+		return o.Mode.UnmarshalText([]byte(s))
+	}},
+	intKey("averageSize", func(o *Options) *int64 { return &o.AverageSize }),
+	intKey("maxSize", func(o *Options) *int64 { return &o.MaxSize }),
+	{"hash", func(o *Options) string { return o.HashName }, func(o *Options, s string) error {
+		o.HashName = s
+		return nil
+	}},
+	intKey("maxMemory", func(o *Options) *int64 { return &o.MaxMemory }),
+	intKey("maxTotalBytes", func(o *Options) *int64 { return &o.MaxTotalBytes }),
+	boolKey("tar", func(o *Options) *bool { return &o.Tar }),
+	intKey("snapTolerance", func(o *Options) *int64 { return &o.SnapTolerance }),
+	{"delimiter", func(o *Options) string {
+		if o.Delimiter == 0 {
+			return ""
+		}
+		return strconv.Itoa(int(o.Delimiter))
+	}, func(o *Options, s string) error {
+		n, err := strconv.ParseUint(s, 10, 8)
+		o.Delimiter = byte(n)
+		return err
+	}},
+	boolKey("runeSafe", func(o *Options) *bool { return &o.RuneSafe }),
+	intKey("alignment", func(o *Options) *int64 { return &o.Alignment }),
+	intKey("alignTolerance", func(o *Options) *int64 { return &o.AlignTolerance }),
+	boolKey("entropy", func(o *Options) *bool { return &o.Entropy }),
+}
+
+// intKey returns the optionKey of the integer option field.
+func intKey(name string, field func(o *Options) *int64) optionKey {
+	return optionKey{name, func(o *Options) string {
+		if *field(o) == 0 {
+			return ""
+		}
+		return strconv.FormatInt(*field(o), 10)
+	}, func(o *Options, s string) error {
+		n, err := strconv.ParseInt(s, 10, 64)
+		*field(o) = n
+		return err
+	}}
+}
+
+// boolKey returns the optionKey of the boolean option field.
+func boolKey(name string, field func(o *Options) *bool) optionKey {
+	return optionKey{name, func(o *Options) string {
+		if !*field(o) {
+			return ""
+		}
+		return "true"
+	}, func(o *Options, s string) error {
+		b, err := strconv.ParseBool(s)
+		*field(o) = b
+		return err
+	}}
+}
+
+// MarshalText encodes the same options as MarshalJSON as space-separated name=value pairs, omitting unset ones,
+// e.g. "algorithm=ae mode=max averageSize=65536 hash=sha256". It suits command-line flags and environment variables.
+func (opts Options) MarshalText() ([]byte, error) {
+	if opts.Hasher != nil {
+		return nil, errUnencodable
+	}
+	if err := opts.Validate(); err != nil {
+		return nil, err
+	}
+	if opts.HashName == "" && opts.SHA256 {
+		opts.HashName = "sha256"
+	}
+	var pairs []string
+	for _, key := range optionKeys {
+		if v := key.format(&opts); v != "" {
+			pairs = append(pairs, key.name+"="+v)
+		}
+	}
+	return []byte(strings.Join(pairs, " ")), nil
+}
+
+// UnmarshalText decodes options written by MarshalText. As with UnmarshalJSON, unknown
+// and repeated names are rejected, and the options must pass Validate.
+func (opts *Options) UnmarshalText(text []byte) error {
+	var o Options
+	seen := make(map[string]bool)
+	for _, pair := range strings.Fields(string(text)) {
+		name, value, ok := strings.Cut(pair, "=")
+		if !ok {
+			return fmt.Errorf("ae: invalid options: %q is not a name=value pair", pair)
+		}
+		if seen[name] {
+			return fmt.Errorf("ae: invalid options: %s is given twice", name)
+		}
+		seen[name] = true
+		var key *optionKey
+		for i := range optionKeys {
+			if optionKeys[i].name == name {
+				key = &optionKeys[i]
+			}
+		}
+		if key == nil {
+			return fmt.Errorf("ae: invalid options: unknown option %q", name)
+		}
+		if err := key.parse(&o, value); err != nil {
+			return fmt.Errorf("ae: invalid options: %s: %w", name, err)
+		}
+	}
+	if err := o.Validate(); err != nil {
+		return err
+	}
+	*opts = o
+	return nil
+}
@@ -0,0 +1,92 @@
+package ae
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestOptions_Validate(t *testing.T) {
+	assert.NoError(t, (&Options{}).Validate())
+	assert.NoError(t, (&Options{AverageSize: 100, MaxSize: 300, HashName: "sha256", MaxMemory: 600}).Validate())
+	assert.NoError(t, (&Options{AverageSize: 4096, MaxSize: 1024, Algorithm: Fixed}).Validate())
+	assert.Equal(t, ErrMemoryLimit, (&Options{AverageSize: 100, MaxMemory: 100}).Validate())
+	for name, opts := range map[string]*Options{
+		"negative average size": {AverageSize: -1},
+		"negative max size":     {MaxSize: -1},
+		"small max size":        {AverageSize: 4096, MaxSize: 1024},
+		"invalid mode":          {Mode: 2},
+		"invalid algorithm":     {Algorithm: 2},
+		"unknown hash":          {HashName: "md4"},
+		"two hashes":            {Hasher: sha256.New, SHA256: true},
+		"unordered boundaries":  {Boundaries: []int64{10, 10}},
+		"negative tolerance":    {SnapTolerance: -1},
+		"negative quota":        {MaxTotalBytes: -1},
+		"tolerance only":        {AlignTolerance: 10},
+	} {
+		assert.Error(t, opts.Validate(), name)
+	}
+}
+
+func TestOptions_MarshalJSON(t *testing.T) {
+	opts := &Options{
+		AverageSize: 16 * 1024, MaxSize: 64 * 1024, Mode: MIN, SHA256: true,
+		SnapTolerance: 256, Delimiter: '\n', Alignment: 4096,
+	}
+	data, err := json.Marshal(opts)
+	require.NoError(t, err)
+	assert.JSONEq(t, `{"averageSize":16384,"algorithm":"ae","mode":"min","maxSize":65536,"hash":"sha256",
+		"snapTolerance":256,"delimiter":10,"alignment":4096}`, string(data))
+
+	var decoded Options
+	require.NoError(t, json.Unmarshal(data, &decoded))
+	opts.SHA256, opts.HashName = false, "sha256"
+	assert.Equal(t, *opts, decoded)
+
+	// The decoded options chunk identically.
+	input := testFile[:4*MiB]
+	m1, err := BuildManifest(bytes.NewReader(input), opts)
+	require.NoError(t, err)
+	m2, err := BuildManifest(bytes.NewReader(input), &decoded)
+	require.NoError(t, err)
+	assert.Equal(t, m1, m2)
+
+	for name, data := range map[string]string{
+		"unknown field": `{"averageSize":16384,"avgSize":1}`,
+		"invalid mode":  `{"mode":"median"}`,
+		"invalid":       `{"maxSize":1,"averageSize":16384}`,
+		"malformed":     `{"averageSize":`,
+	} {
+		assert.Error(t, json.Unmarshal([]byte(data), &decoded), name)
+	}
+	_, err = json.Marshal(&Options{Hasher: sha256.New})
+	assert.Error(t, err)
+}
+
+func TestOptions_MarshalText(t *testing.T) {
+	opts := &Options{AverageSize: 16 * 1024, HashName: "sha256", Tar: true, Delimiter: '\n', SnapTolerance: 100}
+	text, err := opts.MarshalText()
+	require.NoError(t, err)
+	assert.Equal(t, "algorithm=ae mode=max averageSize=16384 hash=sha256 tar=true snapTolerance=100 delimiter=10", string(text))
+
+	var decoded Options
+	require.NoError(t, decoded.UnmarshalText(text))
+	assert.Equal(t, *opts, decoded)
+	require.NoError(t, decoded.UnmarshalText([]byte("averageSize=4096  algorithm=fixed\n")))
+	assert.Equal(t, Options{AverageSize: 4096, Algorithm: Fixed}, decoded)
+
+	for _, text := range []string{
+		"averageSize",
+		"averageSize=1 averageSize=2",
+		"avgSize=1",
+		"averageSize=lots",
+		"runeSafe=maybe",
+		"delimiter=256",
+		"averageSize=4096 maxSize=1",
+	} {
+		assert.Error(t, decoded.UnmarshalText([]byte(text)), text)
+	}
+}
@@ -0,0 +1,585 @@
+package ae
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"sort"
+	"sync"
+)
+
+// packMagic and packVersion start every pack.
+var packMagic = []byte("AEpk")
+
+const packVersion = 1
+
+// tombstoneMagic and packVersion start the tombstones, which record the chunks deleted from stored packs.
+var tombstoneMagic = []byte("AEtb")
+
+// defaultPackSize is the default PackOptions.TargetSize.
+const defaultPackSize = 16 << 20
+
+// ErrInvalidPack is returned when reading a malformed pack.
+var ErrInvalidPack = errors.New("ae: invalid pack")
+
+// PackOptions configure a PackingStore.
+type PackOptions struct {
+	// TargetSize of a pack in bytes (optional). A pack is written once its chunks exceed it. It defaults to 16 MiB.
+	TargetSize int64
+
+	// Codec compressing the chunks in the pack (optional).
+	Codec Codec
+}
+
+// packLoc locates a chunk in a pack.
+type packLoc struct {
+	// pack is the ID of the pack, or empty if the chunk is in the pack being filled.
+	pack string
+
+	offset, length int64
+}
+
+// packInfo describes a stored pack.
+type packInfo struct {
+	// size of the pack in bytes.
+	size int64
+
+	// garbage is the number of bytes taken up by deleted chunks.
+	garbage int64
+}
+
+// PackingStore is a ChunkStore that groups chunks into packs of a target size before writing them to an underlying store,
+// because object stores and file systems cope poorly with millions of small objects.
+// A pack holds the compressed chunks, followed by an index of their hashes and locations.
+// Packs are stored under the SHA-256 hash of their content.
+//
+// Chunks are buffered until their pack is full; Flush writes the pack early and must be called before the store is dropped.
+// Deleted chunks remain in their pack until Repack rewrites it. Flush and Repack record them in tombstones,
+// which are stored alongside the packs, so that they stay deleted when the store is reopened in the meantime.
+type PackingStore struct {
+	mu    sync.Mutex
+	store ChunkStore
+	size  int64
+	codec *CompressedStore
+
+	// index locates every chunk by its hash.
+	index map[string]packLoc
+
+	// packs maps the IDs of the stored packs to their description.
+	packs map[string]*packInfo
+
+	// deleted holds the hashes of the chunks deleted from each stored pack that has not been rewritten yet.
+	deleted map[string]map[string]bool
+
+	// tombstones are the IDs of the stored tombstones, and dirty is set if deleted changed since they were written.
+	tombstones []string
+	dirty      bool
+
+	// written collects the IDs of the packs written while Repack copies chunks, which are kept
+	// even if they were selected for rewriting, as a pack may come out identical to an old one.
+	written map[string]bool
+
+	// pending is the pack being filled, and pendingHashes the hashes of its chunks in order.
+	pending       []byte
+	pendingHashes []string
+
+	// cache holds the packs read recently.
+	cache *MemoryStore
+}
+
+// NewPackingStore returns a PackingStore writing packs to s.
+// If s is a ChunkWalker, the indexes of the packs already stored in it are loaded, which reads all of them.
+func NewPackingStore(s ChunkStore, opts *PackOptions) (*PackingStore, error) {
+	o := PackOptions{}
+	if opts != nil {
+		o = *opts
+	}
+	if o.TargetSize <= 0 {
+		o.TargetSize = defaultPackSize
+	}
+	codec, err := NewCompressedStore(nil, o.Codec)
+	if err != nil {
+		return nil, err
+	}
+	p := &PackingStore{
+		store:   s,
+		size:    o.TargetSize,
+		codec:   codec,
+		index:   make(map[string]packLoc),
+		packs:   make(map[string]*packInfo),
+		deleted: make(map[string]map[string]bool),
+		cache:   NewMemoryStore(2 * o.TargetSize),
+	}
+	var ids []string
+	err = walkStore(s, func(hash []byte, size int64) error {
+		ids = append(ids, string(hash))
+		return nil
+	})
+	if err != nil && err != ErrWalkUnsupported {
+		return nil, err
+	}
+	// The packs are indexed once all tombstones are read, as a deleted chunk may have been stored again in another pack.
+	type pack struct {
+		id      string
+		size    int64
+		entries []packEntry
+	}
+	var packs []pack
+	for _, id := range ids {
+		data, err := s.Get([]byte(id))
+		if err != nil {
+			return nil, err
+		}
+		if bytes.HasPrefix(data, tombstoneMagic) {
+			if err := p.readTombstones(data); err != nil {
+				return nil, err
+			}
+			p.tombstones = append(p.tombstones, id)
+			continue
+		}
+		entries, err := readPackIndex(data)
+		if err != nil {
+			return nil, err
+		}
+		packs = append(packs, pack{id: id, size: int64(len(data)), entries: entries})
+	}
+	for _, pk := range packs {
+		p.addPack(pk.id, pk.size, pk.entries)
+	}
+	// Tombstones of packs rewritten in the meantime are dropped when the tombstones are written next.
+	for id := range p.deleted {
+		if p.packs[id] == nil {
+			delete(p.deleted, id)
+			p.dirty = true
+		}
+	}
+	if len(p.tombstones) > 1 {
+		p.dirty = true
+	}
+	return p, nil
+}
+
+// addPack adds the chunks in the pack of the given size to the index, leaving out the deleted ones.
+func (p *PackingStore) addPack(id string, size int64, entries []packEntry) {
+	info := &packInfo{size: size}
+	p.packs[id] = info
+	for _, e := range entries {
+		if p.deleted[id][e.hash] {
+			info.garbage += e.length
+			continue
+		}
+		p.index[e.hash] = packLoc{pack: id, offset: e.offset, length: e.length}
+	}
+}
+
+// Put adds data to the pack being filled, which is written once it reaches the target size.
+func (p *PackingStore) Put(hash []byte, data []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if _, ok := p.index[string(hash)]; ok {
+		return nil
+	}
+	return p.add(string(hash), p.codec.encode(data))
+}
+
+// add appends a chunk as stored in a pack to the pending pack, flushing it if full.
+func (p *PackingStore) add(hash string, stored []byte) error {
+	if len(p.pending) == 0 {
+		p.pending = append(append(p.pending, packMagic...), packVersion)
+	}
+	p.index[hash] = packLoc{offset: int64(len(p.pending)), length: int64(len(stored))}
+	p.pending = append(p.pending, stored...)
+	p.pendingHashes = append(p.pendingHashes, hash)
+	if int64(len(p.pending)) >= p.size {
+		return p.flush()
+	}
+	return nil
+}
+
+// Get returns the data stored under hash, reading its pack from the underlying store if necessary.
+func (p *PackingStore) Get(hash []byte) ([]byte, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	loc, ok := p.index[string(hash)]
+	if !ok {
+		return nil, ErrChunkNotFound
+	}
+	var pack []byte
+	if loc.pack == "" {
+		pack = p.pending
+	} else {
+		var err error
+		if pack, err = p.cache.Get([]byte(loc.pack)); err != nil {
+			if pack, err = p.store.Get([]byte(loc.pack)); err != nil {
+				return nil, err
+			}
+			p.cache.Put([]byte(loc.pack), pack)
+		}
+	}
+	if loc.offset+loc.length > int64(len(pack)) {
+		return nil, ErrInvalidPack
+	}
+	data, err := p.codec.decode(pack[loc.offset : loc.offset+loc.length])
+	if err != nil {
+		return nil, err
+	}
+	// Uncompressed chunks share the memory of the pack, which may be reused.
+	return append([]byte(nil), data...), nil
+}
+
+// Has reports whether a chunk is stored under hash.
+func (p *PackingStore) Has(hash []byte) (bool, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	_, ok := p.index[string(hash)]
+	return ok, nil
+}
+
+// Delete removes the chunk stored under hash from the index. Its space is reclaimed by Repack,
+// and the deletion is persisted by Flush or Repack.
+func (p *PackingStore) Delete(hash []byte) error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	loc, ok := p.index[string(hash)]
+	if !ok {
+		return ErrChunkNotFound
+	}
+	delete(p.index, string(hash))
+	if info := p.packs[loc.pack]; info != nil {
+		info.garbage += loc.length
+		if p.deleted[loc.pack] == nil {
+			p.deleted[loc.pack] = make(map[string]bool)
+		}
+		p.deleted[loc.pack][string(hash)] = true
+		p.dirty = true
+	}
+	return nil
+}
+
+// Walk calls fn for every chunk with its hash and size within its pack.
+func (p *PackingStore) Walk(fn func(hash []byte, size int64) error) error {
+	p.mu.Lock()
+	type entry struct {
+		hash string
+		size int64
+	}
+	entries := make([]entry, 0, len(p.index))
+	for hash, loc := range p.index {
+		entries = append(entries, entry{hash, loc.length})
+	}
+	p.mu.Unlock()
+	for _, e := range entries {
+		if err := fn([]byte(e.hash), e.size); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Packs returns the IDs of the packs in the underlying store, sorted, e.g. to publish them for an HTTPStore.
+// The pack being filled is not included until it is flushed.
+func (p *PackingStore) Packs() [][]byte {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	ids := make([]string, 0, len(p.packs))
+	for id := range p.packs {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	packs := make([][]byte, len(ids))
+	for i, id := range ids {
+		packs[i] = []byte(id)
+	}
+	return packs
+}
+
+// Flush writes the pack being filled to the underlying store, even if it has not reached the target size,
+// and the tombstones of the chunks deleted since they were last written.
+func (p *PackingStore) Flush() error {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.flush(); err != nil {
+		return err
+	}
+	return p.writeTombstones()
+}
+
+// flush implements Flush.
+func (p *PackingStore) flush() error {
+	if len(p.pendingHashes) == 0 {
+		return nil
+	}
+	// Chunks deleted before the pack is written are left out of its index.
+	var entries []packEntry
+	var live int64
+	seen := make(map[string]bool, len(p.pendingHashes))
+	for _, hash := range p.pendingHashes {
+		loc, ok := p.index[hash]
+		if !ok || loc.pack != "" || seen[hash] {
+			continue
+		}
+		seen[hash] = true
+		entries = append(entries, packEntry{hash: hash, offset: loc.offset, length: loc.length})
+		live += loc.length
+	}
+	pack := appendPackIndex(p.pending, entries)
+	sum := sha256.Sum256(pack)
+	id := string(sum[:])
+	if err := p.store.Put(sum[:], pack); err != nil {
+		return err
+	}
+	for _, e := range entries {
+		p.index[e.hash] = packLoc{pack: id, offset: e.offset, length: e.length}
+	}
+	if p.written != nil {
+		p.written[id] = true
+	}
+	p.packs[id] = &packInfo{size: int64(len(pack)), garbage: int64(len(p.pending)-len(packMagic)-1) - live}
+	// A pack identical to one that chunks were deleted from brings them back.
+	if p.deleted[id] != nil {
+		delete(p.deleted, id)
+		p.dirty = true
+	}
+	p.pending = nil
+	p.pendingHashes = p.pendingHashes[:0]
+	return nil
+}
+
+// Repack rewrites the packs in which deleted chunks take up more than maxGarbage of the space, e.g. 0.2,
+// and merges packs smaller than half the target size. It returns the number of packs removed from the underlying store.
+// The chunks deleted from the packs that are kept are recorded in tombstones. Repack holds the store for its duration.
+func (p *PackingStore) Repack(maxGarbage float64) (int, error) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if err := p.flush(); err != nil {
+		return 0, err
+	}
+
+	var ids, small []string
+	for id, info := range p.packs {
+		switch {
+		case float64(info.garbage) > maxGarbage*float64(info.size):
+			ids = append(ids, id)
+		case info.size < p.size/2:
+			small = append(small, id)
+		}
+	}
+	if len(small) > 1 || len(ids) > 0 {
+		ids = append(ids, small...)
+	}
+	sort.Strings(ids)
+	if len(ids) == 0 {
+		return 0, p.writeTombstones()
+	}
+
+	// The live chunks are copied into new packs, before the old ones are deleted.
+	p.written = make(map[string]bool)
+	defer func() { p.written = nil }()
+	for _, id := range ids {
+		pack, err := p.store.Get([]byte(id))
+		if err != nil {
+			return 0, err
+		}
+		entries, err := readPackIndex(pack)
+		if err != nil {
+			return 0, err
+		}
+		for _, e := range entries {
+			if loc, ok := p.index[e.hash]; ok && loc.pack == id {
+				if err := p.add(e.hash, pack[e.offset:e.offset+e.length]); err != nil {
+					return 0, err
+				}
+			}
+		}
+	}
+	if err := p.flush(); err != nil {
+		return 0, err
+	}
+	removed := 0
+	for _, id := range ids {
+		if p.written[id] {
+			continue
+		}
+		if err := p.store.Delete([]byte(id)); err != nil && err != ErrChunkNotFound {
+			return removed, err
+		}
+		removed++
+		delete(p.packs, id)
+		p.cache.Delete([]byte(id))
+		if p.deleted[id] != nil {
+			delete(p.deleted, id)
+			p.dirty = true
+		}
+	}
+	return removed, p.writeTombstones()
+}
+
+// writeTombstones stores the chunks deleted from the stored packs, if they changed since they were last written,
+// replacing the tombstones stored before. Once no pack holds deleted chunks, no tombstones are stored.
+func (p *PackingStore) writeTombstones() error {
+	if !p.dirty {
+		return nil
+	}
+	var id string
+	if len(p.deleted) > 0 {
+		data := appendTombstones(append(append([]byte(nil), tombstoneMagic...), packVersion), p.deleted)
+		sum := sha256.Sum256(data)
+		id = string(sum[:])
+		if err := p.store.Put(sum[:], data); err != nil {
+			return err
+		}
+	}
+	for _, old := range p.tombstones {
+		if old == id {
+			continue
+		}
+		if err := p.store.Delete([]byte(old)); err != nil && err != ErrChunkNotFound {
+			return err
+		}
+	}
+	p.tombstones = p.tombstones[:0]
+	if id != "" {
+		p.tombstones = append(p.tombstones, id)
+	}
+	p.dirty = false
+	return nil
+}
+
+// appendTombstones appends the hashes of the chunks deleted from each pack, sorted by the IDs of the packs.
+func appendTombstones(b []byte, deleted map[string]map[string]bool) []byte {
+	ids := make([]string, 0, len(deleted))
+	for id := range deleted {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	b = appendUvarint(b, uint64(len(ids)))
+	for _, id := range ids {
+		hashes := make([]string, 0, len(deleted[id]))
+		for hash := range deleted[id] {
+			hashes = append(hashes, hash)
+		}
+		sort.Strings(hashes)
+		b = appendUvarint(b, uint64(len(id)))
+		b = append(b, id...)
+		b = appendUvarint(b, uint64(len(hashes)))
+		for _, hash := range hashes {
+			b = appendUvarint(b, uint64(len(hash)))
+			b = append(b, hash...)
+		}
+	}
+	return b
+}
+
+// readTombstones adds the chunks recorded as deleted in the tombstones data to p.deleted.
+func (p *PackingStore) readTombstones(data []byte) error {
+	if len(data) <= len(tombstoneMagic) || data[len(tombstoneMagic)] != packVersion {
+		return ErrInvalidPack
+	}
+	r := bytes.NewReader(data[len(tombstoneMagic)+1:])
+	readString := func() (string, error) {
+		n, err := binary.ReadUvarint(r)
+		if err != nil || n > uint64(r.Len()) {
+			return "", ErrInvalidPack
+		}
+		b := make([]byte, n)
+		r.Read(b)
+		return string(b), nil
+	}
+	packs, err := binary.ReadUvarint(r)
+	if err != nil || packs > uint64(r.Len()) {
+		return ErrInvalidPack
+	}
+	for i := uint64(0); i < packs; i++ {
+		id, err := readString()
+		if err != nil {
+			return err
+		}
+		count, err := binary.ReadUvarint(r)
+		if err != nil || count > uint64(r.Len()) {
+			return ErrInvalidPack
+		}
+		if p.deleted[id] == nil {
+			p.deleted[id] = make(map[string]bool)
+		}
+		for j := uint64(0); j < count; j++ {
+			hash, err := readString()
+			if err != nil {
+				return err
+			}
+			p.deleted[id][hash] = true
+		}
+	}
+	if r.Len() > 0 {
+		return ErrInvalidPack
+	}
+	return nil
+}
+
+// packEntry is the index entry of a chunk in a pack.
+type packEntry struct {
+	hash           string
+	offset, length int64
+}
+
+// appendPackIndex appends the index of the chunks in a pack to it,
+// followed by the length of the index as 32-bit little endian integer.
+func appendPackIndex(pack []byte, entries []packEntry) []byte {
+	n := len(pack)
+	pack = appendUvarint(pack, uint64(len(entries)))
+	for _, e := range entries {
+		pack = appendUvarint(pack, uint64(len(e.hash)))
+		pack = append(pack, e.hash...)
+		pack = appendUvarint(pack, uint64(e.offset))
+		pack = appendUvarint(pack, uint64(e.length))
+	}
+	var trailer [4]byte
+	binary.LittleEndian.PutUint32(trailer[:], uint32(len(pack)-n))
+	return append(pack, trailer[:]...)
+}
+
+// readPackIndex returns the index of the chunks in a pack.
+func readPackIndex(pack []byte) ([]packEntry, error) {
+	if len(pack) <= len(packMagic) || !bytes.HasPrefix(pack, packMagic) || pack[len(packMagic)] != packVersion {
+		return nil, ErrInvalidPack
+	}
+	return parsePackIndex(pack, int64(len(pack)))
+}
+
+// parsePackIndex returns the index of the chunks in a pack of the given size from its tail,
+// i.e. the last bytes of the pack, which must include the index and its length.
+func parsePackIndex(tail []byte, packSize int64) ([]packEntry, error) {
+	header := int64(len(packMagic) + 1)
+	if len(tail) < 4 || packSize < header+4 {
+		return nil, ErrInvalidPack
+	}
+	size := int64(binary.LittleEndian.Uint32(tail[len(tail)-4:]))
+	end := packSize - 4
+	if size > end-header || size > int64(len(tail))-4 {
+		return nil, ErrInvalidPack
+	}
+	r := bytes.NewReader(tail[int64(len(tail))-4-size : len(tail)-4])
+	count, err := binary.ReadUvarint(r)
+	if err != nil || count > uint64(size) {
+		return nil, ErrInvalidPack
+	}
+	entries := make([]packEntry, 0, count)
+	for i := uint64(0); i < count; i++ {
+		hashLen, err := binary.ReadUvarint(r)
+		if err != nil || hashLen > uint64(r.Len()) {
+			return nil, ErrInvalidPack
+		}
+		hash := make([]byte, hashLen)
+		r.Read(hash)
+		offset, err := binary.ReadUvarint(r)
+		if err != nil {
+			return nil, ErrInvalidPack
+		}
+		length, err := binary.ReadUvarint(r)
+		if err != nil || offset < uint64(header) || offset > uint64(end-size) || length > uint64(end-size)-offset {
+			return nil, ErrInvalidPack
+		}
+		entries = append(entries, packEntry{hash: string(hash), offset: int64(offset), length: int64(length)})
+	}
+	return entries, nil
+}
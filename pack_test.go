@@ -0,0 +1,154 @@
+package ae
+
+import (
+	"bytes"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+// countChunks returns the number of chunks in s.
+func countChunks(t *testing.T, s ChunkStore) int {
+	var n int
+	require.NoError(t, walkStore(s, func(hash []byte, size int64) error {
+		n++
+		return nil
+	}))
+	return n
+}
+
+func TestPackingStore(t *testing.T) {
+	input := testFile[:4*MiB]
+	backend := NewMemoryStore(0)
+	p, err := NewPackingStore(backend, &PackOptions{TargetSize: 256 * 1024, Codec: CodecZstd})
+	require.NoError(t, err)
+	m, err := StoreFile(bytes.NewReader(input), p, &Options{AverageSize: 8 * 1024})
+	require.NoError(t, err)
+
+	// The pending pack is readable before it is written.
+	var buf bytes.Buffer
+	require.NoError(t, Reassemble(&buf, m, p))
+	assert.Equal(t, input, buf.Bytes())
+	require.NoError(t, p.Flush())
+	packs := countChunks(t, backend)
+	assert.InDelta(t, 16, packs, 1)
+	assert.Equal(t, len(m.Chunks), countChunks(t, p))
+
+	t.Run("reopen", func(t *testing.T) {
+		reopened, err := NewPackingStore(backend, nil)
+		require.NoError(t, err)
+		buf.Reset()
+		require.NoError(t, Reassemble(&buf, m, reopened))
+		assert.Equal(t, input, buf.Bytes())
+	})
+
+	t.Run("repack", func(t *testing.T) {
+		// Keeping the first half of the file turns the rest into garbage.
+		half := &Manifest{Size: m.Size, Parameters: m.Parameters, Chunks: m.Chunks[:len(m.Chunks)/2]}
+		report, err := CollectGarbage(p, []*Manifest{half}, false)
+		require.NoError(t, err)
+		assert.Equal(t, int64(len(m.Chunks)-len(half.Chunks)), report.Deleted)
+		assert.Equal(t, packs, countChunks(t, backend))
+
+		removed, err := p.Repack(0)
+		require.NoError(t, err)
+		assert.Greater(t, removed, 0)
+		assert.Less(t, countChunks(t, backend), packs)
+
+		reopened, err := NewPackingStore(backend, nil)
+		require.NoError(t, err)
+		assert.Equal(t, len(half.Chunks), countChunks(t, reopened))
+		for _, ref := range half.Chunks {
+			data, err := reopened.Get(ref.Hash)
+			require.NoError(t, err)
+			assert.Equal(t, input[ref.Offset:ref.Offset+ref.Length], data)
+		}
+
+		removed, err = p.Repack(0)
+		require.NoError(t, err)
+		assert.Zero(t, removed)
+	})
+
+	t.Run("tombstones", func(t *testing.T) {
+		backend := NewMemoryStore(0)
+		p, err := NewPackingStore(backend, &PackOptions{TargetSize: 256 * 1024})
+		require.NoError(t, err)
+		m, err := StoreFile(bytes.NewReader(input), p, &Options{AverageSize: 8 * 1024})
+		require.NoError(t, err)
+		require.NoError(t, p.Flush())
+		packs := len(p.Packs())
+
+		// A few deleted chunks do not make a pack worth rewriting, so they are recorded in tombstones.
+		deleted := m.Chunks[:3]
+		for _, ref := range deleted {
+			require.NoError(t, p.Delete(ref.Hash))
+		}
+		removed, err := p.Repack(0.2)
+		require.NoError(t, err)
+		assert.Zero(t, removed)
+		assert.Equal(t, packs+1, countChunks(t, backend))
+
+		reopened, err := NewPackingStore(backend, nil)
+		require.NoError(t, err)
+		assert.Equal(t, len(m.Chunks)-len(deleted), countChunks(t, reopened))
+		_, err = reopened.Get(deleted[0].Hash)
+		assert.Equal(t, ErrChunkNotFound, err)
+
+		// A deleted chunk stored again survives reopening, although its old copy is still recorded as deleted.
+		ref := deleted[0]
+		require.NoError(t, reopened.Put(ref.Hash, input[ref.Offset:ref.Offset+ref.Length]))
+		require.NoError(t, reopened.Flush())
+		reopened, err = NewPackingStore(backend, nil)
+		require.NoError(t, err)
+		data, err := reopened.Get(ref.Hash)
+		require.NoError(t, err)
+		assert.Equal(t, input[ref.Offset:ref.Offset+ref.Length], data)
+
+		// Once the pack is rewritten, the tombstones are removed.
+		_, err = reopened.Repack(0)
+		require.NoError(t, err)
+		assert.Equal(t, len(reopened.Packs()), countChunks(t, backend))
+		reopened, err = NewPackingStore(backend, nil)
+		require.NoError(t, err)
+		assert.Equal(t, len(m.Chunks)-len(deleted)+1, countChunks(t, reopened))
+
+		require.NoError(t, backend.Put([]byte("id"), []byte("AEtb\x01\x05")))
+		_, err = NewPackingStore(backend, nil)
+		assert.True(t, errors.Is(err, ErrInvalidPack))
+	})
+
+	t.Run("invalid pack", func(t *testing.T) {
+		corrupt := NewMemoryStore(0)
+		require.NoError(t, corrupt.Put([]byte("id"), []byte("AEpk\x01garbage")))
+		_, err := NewPackingStore(corrupt, nil)
+		assert.True(t, errors.Is(err, ErrInvalidPack))
+	})
+}
+
+func TestPackingStore_RepackUnchanged(t *testing.T) {
+	// The small pack of a has no garbage, so rewriting it along with the pack of b yields the same pack.
+	fileStore, err := NewFileStore(t.TempDir(), nil)
+	require.NoError(t, err)
+	p, err := NewPackingStore(fileStore, nil)
+	require.NoError(t, err)
+	require.NoError(t, p.Put([]byte("a"), []byte("chunk a")))
+	require.NoError(t, p.Flush())
+	require.NoError(t, p.Put([]byte("b"), []byte("chunk b")))
+	require.NoError(t, p.Flush())
+	require.NoError(t, p.Delete([]byte("b")))
+
+	removed, err := p.Repack(0.2)
+	require.NoError(t, err)
+	assert.Equal(t, 1, removed)
+	data, err := p.Get([]byte("a"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("chunk a"), data)
+	assert.Len(t, p.Packs(), 1)
+
+	reopened, err := NewPackingStore(fileStore, nil)
+	require.NoError(t, err)
+	data, err = reopened.Get([]byte("a"))
+	require.NoError(t, err)
+	assert.Equal(t, []byte("chunk a"), data)
+}
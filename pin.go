@@ -0,0 +1,85 @@
+package ae
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+)
+
+// Pin protects the chunks of m from garbage collection under name, a slash-separated path as by fs.ValidPath,
+// until it is unpinned, even once no saved manifest or snapshot refers to them any more.
+// A pin under the same name is replaced. The chunks of m must be in the store already.
+func (r *Repository) Pin(name string, m *Manifest) error {
+	p, err := namedPath(filepath.Join(r.dir, repoPinsDir), name)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	if err := r.Lock(); err != nil {
+		return err
+	}
+	err = r.store.Flush()
+	if err == nil {
+		err = writeFileAtomic(p, data, SyncFile)
+	}
+	if uerr := r.Unlock(); err == nil {
+		err = uerr
+	}
+	return err
+}
+
+// Unpin removes the pin under name. Its chunks are removed by CollectGarbage unless referred to otherwise.
+func (r *Repository) Unpin(name string) error {
+	p, err := namedPath(filepath.Join(r.dir, repoPinsDir), name)
+	if err != nil {
+		return err
+	}
+	if err := r.Lock(); err != nil {
+		return err
+	}
+	err = os.Remove(p)
+	if uerr := r.Unlock(); err == nil {
+		err = uerr
+	}
+	return err
+}
+
+// Pins returns the names of all pins in lexical order.
+func (r *Repository) Pins() ([]string, error) {
+	return listManifests(filepath.Join(r.dir, repoPinsDir))
+}
+
+// pinned returns the manifest pinned under name.
+func (r *Repository) pinned(name string) (*Manifest, error) {
+	p, err := namedPath(filepath.Join(r.dir, repoPinsDir), name)
+	if err != nil {
+		return nil, err
+	}
+	return readManifestFile(p, name)
+}
+
+// RefCounts returns the number of references to every chunk in use, keyed by its hash as a string.
+// Every saved manifest, file in a snapshot and pin counts once per chunk or parity shard it refers to,
+// however often the chunk occurs in it. Chunks without references are left out; CollectGarbage deletes them.
+// The counts are not kept up to date but derived by reading every manifest, snapshot and pin of the repository,
+// as CollectGarbage does, so callers asking about several chunks should count once and look them all up.
+func (r *Repository) RefCounts() (map[string]int, error) {
+	live, err := r.liveManifests(nil)
+	if err != nil {
+		return nil, err
+	}
+	counts := make(map[string]int)
+	for _, m := range live {
+		seen := make(map[string]bool, len(m.Chunks))
+		storedHashes(m, func(hash []byte) {
+			if !seen[string(hash)] {
+				seen[string(hash)] = true
+				counts[string(hash)]++
+			}
+		})
+	}
+	return counts, nil
+}
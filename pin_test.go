@@ -0,0 +1,83 @@
+package ae
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/fs"
+	"path/filepath"
+	"testing"
+)
+
+func TestRepository_Pin(t *testing.T) {
+	r, err := InitRepository(filepath.Join(t.TempDir(), "repo"), RepositoryConfig{Parameters: Parameters{AverageSize: 16 * 1024}, PackSize: 256 * 1024})
+	require.NoError(t, err)
+	a, err := r.StoreFile("a", bytes.NewReader(testFile[:MiB]))
+	require.NoError(t, err)
+	b, err := r.StoreFile("b", bytes.NewReader(testFile[:MiB/2]))
+	require.NoError(t, err)
+	_, err = r.StoreFile("c", bytes.NewReader(testFile[2*MiB:3*MiB]))
+	require.NoError(t, err)
+
+	counts, err := r.RefCounts()
+	require.NoError(t, err)
+	assert.Equal(t, 2, counts[string(a.Chunks[0].Hash)])
+	assert.Equal(t, 2, counts[string(b.Chunks[0].Hash)])
+	assert.Equal(t, 1, counts[string(a.Chunks[len(a.Chunks)-1].Hash)])
+
+	require.NoError(t, r.Pin("release/1.0", a))
+	pins, err := r.Pins()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"release/1.0"}, pins)
+	counts, err = r.RefCounts()
+	require.NoError(t, err)
+	assert.Equal(t, 3, counts[string(a.Chunks[0].Hash)])
+
+	// The chunks of the pinned manifest survive its deletion, the others do not.
+	require.NoError(t, r.DeleteManifest("a"))
+	require.NoError(t, r.DeleteManifest("b"))
+	require.NoError(t, r.DeleteManifest("c"))
+	report, err := r.CollectGarbage(false)
+	require.NoError(t, err)
+	assert.Greater(t, report.Deleted, int64(0))
+	assert.Equal(t, int64(len(a.Chunks)), report.LiveChunks)
+	var buf bytes.Buffer
+	require.NoError(t, Reassemble(&buf, a, r.Store()))
+	assert.Equal(t, testFile[:MiB], buf.Bytes())
+
+	require.NoError(t, r.Unpin("release/1.0"))
+	assert.ErrorIs(t, r.Unpin("release/1.0"), fs.ErrNotExist)
+	report, err = r.CollectGarbage(true)
+	require.NoError(t, err)
+	assert.Equal(t, int64(len(a.Chunks)), report.Garbage)
+	counts, err = r.RefCounts()
+	require.NoError(t, err)
+	assert.Empty(t, counts)
+
+	assert.Error(t, r.Pin("../escape", a))
+}
+
+func TestRepository_Parity(t *testing.T) {
+	r, err := InitRepository(filepath.Join(t.TempDir(), "repo"), RepositoryConfig{Parameters: Parameters{AverageSize: 16 * 1024}, PackSize: 256 * 1024})
+	require.NoError(t, err)
+	m, err := r.StoreFile("a", bytes.NewReader(testFile[:MiB]))
+	require.NoError(t, err)
+	require.NoError(t, r.Lock())
+	require.NoError(t, AddParity(m, r.Store(), 4, 2))
+	require.NoError(t, r.Unlock())
+	require.NoError(t, r.SaveManifest("a", m))
+
+	counts, err := r.RefCounts()
+	require.NoError(t, err)
+	assert.Equal(t, 1, counts[string(m.Parity[0].Shards[0])])
+	report, err := r.CollectGarbage(false)
+	require.NoError(t, err)
+	assert.Zero(t, report.Garbage)
+	for _, g := range m.Parity {
+		for _, shard := range g.Shards {
+			ok, err := r.Store().Has(shard)
+			require.NoError(t, err)
+			assert.True(t, ok)
+		}
+	}
+}
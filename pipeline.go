@@ -0,0 +1,162 @@
+package ae
+
+import (
+	"context"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+	"hash"
+	"io"
+	"runtime"
+	"sync"
+)
+
+// Pipeline chunks an input, hashes the chunks concurrently and hands them over to a ChunkSink.
+// The sink receives the chunks in input order.
+// If the Chunker was configured with MaxMemory, reading blocks while the chunks in flight exhaust their share of it.
+type Pipeline struct {
+	// Sink receives the processed chunks.
+	Sink ChunkSink
+
+	// Hasher constructs the hash used to fingerprint each chunk (optional).
+	Hasher func() hash.Hash
+
+	// Workers is the number of concurrent hashing goroutines (optional).
+	// It defaults to GOMAXPROCS.
+	Workers int
+
+	// QueueSize bounds the number of chunks in flight between the stages (optional).
+	// Once as many chunks wait for a lagging Sink, reading blocks, which bounds the memory held.
+	// It defaults to Workers.
+	QueueSize int
+
+	// TracerProvider provides the tracer of the OpenTelemetry spans of Run (optional).
+	// It defaults to the global one, which records nothing unless configured.
+	// Run is a span of its own, with a child span for chunking, hashing and passing on to the Sink every chunk.
+	TracerProvider trace.TracerProvider
+}
+
+// pipelineJob is a chunk travelling through the Pipeline.
+type pipelineJob struct {
+	chunk *Chunk
+
+	// done is closed once the chunk has been hashed.
+	done chan struct{}
+}
+
+// Run processes all chunks of ch. It stops at the first error of any stage,
+// or when ctx is cancelled, and returns that error.
+func (p *Pipeline) Run(ctx context.Context, ch *Chunker) (err error) {
+	workers := p.Workers
+	if workers <= 0 {
+		workers = runtime.GOMAXPROCS(0)
+	}
+	queueSize := p.QueueSize
+	if queueSize <= 0 {
+		queueSize = workers
+	}
+
+	tr := tracer(p.TracerProvider)
+	ctx, span := tr.Start(ctx, "ae.Pipeline.Run", trace.WithAttributes(attribute.Int("ae.workers", workers)))
+	defer func() { endSpan(span, err) }()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	ordered := make(chan *pipelineJob, queueSize)
+	jobs := make(chan *pipelineJob, queueSize)
+	readErr := make(chan error, 1)
+
+	var budget *memBudget
+	if ch.maxMemory > 0 {
+		budget = newMemBudget(ch.maxMemory / 2)
+	}
+
+	go func() {
+		defer close(ordered)
+		defer close(jobs)
+		for ctx.Err() == nil {
+			_, span := tr.Start(ctx, "ae.chunk")
+			c, err := ch.Next()
+			if c != nil {
+				span.SetAttributes(chunkAttributes(c)...)
+			}
+			endSpan(span, err)
+			if err != nil {
+				if err != io.EOF {
+					readErr <- err
+				}
+				return
+			}
+			if budget != nil {
+				if err := budget.acquire(ctx, int64(len(c.Data))); err != nil {
+					return
+				}
+			}
+			j := &pipelineJob{chunk: c, done: make(chan struct{})}
+			select {
+			case ordered <- j:
+			case <-ctx.Done():
+				return
+			}
+			jobs <- j
+		}
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < workers; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			var h hash.Hash
+			if p.Hasher != nil {
+				h = p.Hasher()
+			}
+			for j := range jobs {
+				if h != nil {
+					_, span := tr.Start(ctx, "ae.hash", trace.WithAttributes(chunkAttributes(j.chunk)...))
+					h.Reset()
+					h.Write(j.chunk.Data)
+					j.chunk.Sum = h.Sum(nil)
+					span.End()
+				}
+				close(j.done)
+			}
+		}()
+	}
+	defer wg.Wait()
+
+	err = p.drain(ctx, tr, ordered, budget)
+	if err != nil {
+		cancel()
+		for range ordered {
+		}
+		return err
+	}
+	select {
+	case err = <-readErr:
+		return err
+	default:
+		return ctx.Err()
+	}
+}
+
+// drain passes the hashed chunks on to the sink in order.
+func (p *Pipeline) drain(ctx context.Context, tr trace.Tracer, ordered <-chan *pipelineJob, budget *memBudget) error {
+	for j := range ordered {
+		select {
+		case <-j.done:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		n := int64(len(j.chunk.Data))
+		_, span := tr.Start(ctx, "ae.sink", trace.WithAttributes(chunkAttributes(j.chunk)...))
+		err := p.Sink.WriteChunk(j.chunk)
+		endSpan(span, err)
+		if err != nil {
+			return err
+		}
+		if budget != nil {
+			budget.release(n)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package ae
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+// sinkFunc adapts a function to the ChunkSink interface.
+type sinkFunc func(c *Chunk) error
+
+func (f sinkFunc) WriteChunk(c *Chunk) error { return f(c) }
+
+func TestPipeline_Run(t *testing.T) {
+	input := testFile[:8*MiB]
+	opts := &Options{AverageSize: 64 * 1024}
+
+	t.Run("chunks arrive hashed and in order", func(t *testing.T) {
+		var data []byte
+		p := &Pipeline{
+			Hasher:  sha256.New,
+			Workers: 4,
+			Sink: sinkFunc(func(c *Chunk) error {
+				assert.Equal(t, int64(len(data)), c.Offset)
+				sum := sha256.Sum256(c.Data)
+				assert.Equal(t, sum[:], c.Sum)
+				data = append(data, c.Data...)
+				return nil
+			}),
+		}
+		assert.NoError(t, p.Run(context.Background(), NewChunker(bytes.NewReader(input), opts)))
+		assert.Equal(t, input, data)
+	})
+
+	t.Run("sink errors are propagated", func(t *testing.T) {
+		errFoo := errors.New("foo")
+		var calls int
+		p := &Pipeline{Sink: sinkFunc(func(c *Chunk) error {
+			calls++
+			if calls == 3 {
+				return errFoo
+			}
+			return nil
+		})}
+		assert.Equal(t, errFoo, p.Run(context.Background(), NewChunker(bytes.NewReader(input), opts)))
+		assert.Equal(t, 3, calls)
+	})
+
+	t.Run("reader errors are propagated", func(t *testing.T) {
+		errFoo := errors.New("foo")
+		p := &Pipeline{Sink: sinkFunc(func(c *Chunk) error { return nil })}
+		r := &errReader{bytes.NewReader(input), errFoo}
+		assert.Equal(t, errFoo, p.Run(context.Background(), NewChunker(r, opts)))
+	})
+
+	t.Run("cancellation", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		p := &Pipeline{Sink: sinkFunc(func(c *Chunk) error {
+			cancel()
+			return nil
+		})}
+		assert.Equal(t, context.Canceled, p.Run(ctx, NewChunker(bytes.NewReader(input), opts)))
+	})
+}
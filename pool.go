@@ -0,0 +1,83 @@
+package ae
+
+import (
+	"io"
+	"math/bits"
+	"sync"
+)
+
+// bufPools holds reusable chunk buffers, bucketed by power-of-two capacities.
+var bufPools [bits.UintSize]sync.Pool
+
+// getBuf returns a buffer of length n, reusing a released one if possible.
+func getBuf(n int) []byte {
+	if n == 0 {
+		return []byte{}
+	}
+	class := bits.Len(uint(n - 1))
+	if class >= len(bufPools) {
+		return make([]byte, n)
+	}
+	if b, ok := bufPools[class].Get().(*[]byte); ok {
+		return (*b)[:n]
+	}
+	return make([]byte, n, 1<<class)
+}
+
+// putBuf hands b over to the pool for reuse.
+// Only buffers whose capacity is a power of two fit a class, whether getBuf allocated them or not;
+// others are dropped.
+func putBuf(b []byte) {
+	c := cap(b)
+	if c == 0 || c&(c-1) != 0 {
+		return
+	}
+	b = b[:0]
+	bufPools[bits.Len(uint(c-1))].Put(&b)
+}
+
+// ReleaseChunk returns the memory of c to the Chunker for reuse.
+// It is meant for long-running processes to reduce GC pressure,
+// and c must not be used anymore once it has been released.
+func ReleaseChunk(c *Chunk) {
+	if c == nil {
+		return
+	}
+	putBuf(c.Data)
+	c.Data = nil
+	c.Sum = nil
+}
+
+// ChunkerPool hands out Chunkers that share one configuration.
+// The parameters are derived only once and Chunkers are recycled along with their buffers,
+// which makes it cheap to chunk many inputs concurrently. It is safe for concurrent use.
+type ChunkerPool struct {
+	params params
+	opts   Options
+	pool   sync.Pool
+}
+
+// NewChunkerPool returns a ChunkerPool creating Chunkers configured by opts.
+func NewChunkerPool(opts *Options) *ChunkerPool {
+	p := &ChunkerPool{params: newParams(opts)}
+	if opts != nil {
+		p.opts = *opts
+	}
+	return p
+}
+
+// Get returns a Chunker for r.
+func (p *ChunkerPool) Get(r io.Reader) *Chunker {
+	if ch, ok := p.pool.Get().(*Chunker); ok {
+		ch.reset(r)
+		return ch
+	}
+	return newChunker(r, p.params, &p.opts)
+}
+
+// Put returns ch to the pool once it is not used anymore.
+// Chunks that have been emitted by ch remain valid.
+func (p *ChunkerPool) Put(ch *Chunker) {
+	ch.reset(nil)
+	p.pool.Put(ch)
+}
@@ -0,0 +1,71 @@
+package ae
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"sync"
+	"testing"
+)
+
+func TestReleaseChunk(t *testing.T) {
+	t.Run("chunks stay intact with recycling", func(t *testing.T) {
+		c := NewChunker(bytes.NewReader(testFile[:4*MiB]), &Options{AverageSize: 64 * 1024})
+		var data []byte
+		for {
+			chunk, err := c.Next()
+			if err == io.EOF {
+				break
+			}
+			assert.NoError(t, err)
+			data = append(data, chunk.Data...)
+			ReleaseChunk(chunk)
+			assert.Nil(t, chunk.Data)
+		}
+		assert.Equal(t, testFile[:4*MiB], data)
+	})
+
+	t.Run("foreign buffers are ignored", func(t *testing.T) {
+		ReleaseChunk(nil)
+		ReleaseChunk(&Chunk{Data: make([]byte, 3)})
+	})
+
+	t.Run("buffers are bucketed by size", func(t *testing.T) {
+		b := getBuf(1000)
+		assert.Len(t, b, 1000)
+		assert.Equal(t, 1024, cap(b))
+		assert.Empty(t, getBuf(0))
+	})
+}
+
+func TestChunkerPool(t *testing.T) {
+	opts := &Options{AverageSize: 64 * 1024, Hasher: sha256.New}
+	p := NewChunkerPool(opts)
+	expected := getChunks(NewChunker(bytes.NewReader(testFile[:2*MiB]), opts))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for j := 0; j < 4; j++ {
+				ch := p.Get(bytes.NewReader(testFile[:2*MiB]))
+				var chunks [][]byte
+				for {
+					c, err := ch.Next()
+					if err == io.EOF {
+						break
+					}
+					assert.NoError(t, err)
+					sum := sha256.Sum256(c.Data)
+					assert.Equal(t, sum[:], c.Sum)
+					chunks = append(chunks, c.Data)
+				}
+				p.Put(ch)
+				assert.Equal(t, expected, chunks)
+			}
+		}()
+	}
+	wg.Wait()
+}
@@ -0,0 +1,244 @@
+// Package rdiff implements remote differential synchronization in the style of rsync on top of the chunker.
+//
+// The receiver computes the Signature of its old version of a file and sends it to the sender.
+// The sender chunks the new version against the signature and returns a Delta,
+// which holds the data of the new chunks only and refers to the old file for everything else.
+// The receiver finally applies the delta with Patch to obtain the new version.
+package rdiff
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	ae "github.com/mg98/ae-chunker-go"
+	"io"
+)
+
+// Signature describes the old version of a file by the hashes of its chunks.
+// It is simply the manifest of the file and can be transferred in any of its encodings.
+type Signature = ae.Manifest
+
+// NewSignature chunks the old version of a file read from r.
+// The hash defaults to SHA-256 as with ae.BuildManifest.
+func NewSignature(r io.Reader, opts *ae.Options) (*Signature, error) {
+	return ae.BuildManifest(r, opts)
+}
+
+// OpKind is the type of an Op.
+type OpKind uint8
+
+const (
+	// Copy takes data from the old file.
+	Copy OpKind = iota + 1
+
+	// Literal provides new data.
+	Literal
+)
+
+// Op is a single instruction of a Delta.
+type Op struct {
+	// Kind of the instruction.
+	Kind OpKind
+
+	// Offset in the old file to copy from (Copy only).
+	Offset int64
+
+	// Length of the data to copy (Copy only).
+	Length int64
+
+	// Data to be written (Literal only).
+	Data []byte
+}
+
+// Delta describes how to construct the new version of a file from the old one.
+type Delta struct {
+	// Size of the new file in bytes.
+	Size int64
+
+	// Ops to be applied in order.
+	Ops []Op
+}
+
+// LiteralBytes returns the number of bytes carried by the delta itself, i.e. those that need to be transferred.
+func (d *Delta) LiteralBytes() int64 {
+	var n int64
+	for _, op := range d.Ops {
+		if op.Kind == Literal {
+			n += int64(len(op.Data))
+		}
+	}
+	return n
+}
+
+// NewDelta chunks the new version of a file read from r with the parameters of sig
+// and returns the delta against the old version described by sig.
+// Adjacent instructions are merged where possible.
+func NewDelta(r io.Reader, sig *Signature) (*Delta, error) {
+	if _, err := ae.LookupHash(sig.Parameters.Hash); err != nil {
+		return nil, err
+	}
+	old := make(map[string]ae.ChunkRef, len(sig.Chunks))
+	for _, ref := range sig.Chunks {
+		if _, ok := old[string(ref.Hash)]; !ok {
+			old[string(ref.Hash)] = ref
+		}
+	}
+
+	d := &Delta{}
+	ch := ae.NewChunker(r, sig.Parameters.Options())
+	for {
+		c, err := ch.Next()
+		if err == io.EOF {
+			return d, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		d.Size += int64(len(c.Data))
+		if ref, ok := old[string(c.Sum)]; ok && ref.Length == int64(len(c.Data)) {
+			d.appendCopy(ref.Offset, ref.Length)
+		} else {
+			d.appendLiteral(c.Data)
+		}
+		ae.ReleaseChunk(c)
+	}
+}
+
+// appendCopy adds an instruction to copy length bytes at offset of the old file.
+func (d *Delta) appendCopy(offset, length int64) {
+	if n := len(d.Ops); n > 0 && d.Ops[n-1].Kind == Copy && d.Ops[n-1].Offset+d.Ops[n-1].Length == offset {
+		d.Ops[n-1].Length += length
+		return
+	}
+	d.Ops = append(d.Ops, Op{Kind: Copy, Offset: offset, Length: length})
+}
+
+// appendLiteral adds an instruction to write a copy of data.
+func (d *Delta) appendLiteral(data []byte) {
+	if n := len(d.Ops); n > 0 && d.Ops[n-1].Kind == Literal {
+		d.Ops[n-1].Data = append(d.Ops[n-1].Data, data...)
+		return
+	}
+	d.Ops = append(d.Ops, Op{Kind: Literal, Data: append([]byte(nil), data...)})
+}
+
+// ErrSizeMismatch is returned by Patch if the patched file does not have the size recorded in the delta.
+var ErrSizeMismatch = errors.New("rdiff: patched file does not match the size of the delta")
+
+// Patch writes the new version of a file to w by applying d to the old version.
+func Patch(w io.Writer, old io.ReaderAt, d *Delta) error {
+	var n int64
+	for _, op := range d.Ops {
+		switch op.Kind {
+		case Copy:
+			written, err := io.Copy(w, io.NewSectionReader(old, op.Offset, op.Length))
+			n += written
+			if err != nil {
+				return err
+			}
+			if written != op.Length {
+				return fmt.Errorf("rdiff: old file ends before offset %d", op.Offset+op.Length)
+			}
+		case Literal:
+			written, err := w.Write(op.Data)
+			n += int64(written)
+			if err != nil {
+				return err
+			}
+		default:
+			return fmt.Errorf("rdiff: invalid instruction %d", op.Kind)
+		}
+	}
+	if n != d.Size {
+		return ErrSizeMismatch
+	}
+	return nil
+}
+
+// deltaMagic prefixes every delta in binary encoding.
+var deltaMagic = []byte("AEdt")
+
+// deltaVersion is the version of the binary encoding of Delta.
+const deltaVersion = 1
+
+// ErrInvalidDelta is returned when decoding a malformed binary delta.
+var ErrInvalidDelta = errors.New("rdiff: invalid binary delta")
+
+// MarshalBinary encodes the delta for transfer.
+// Offsets and lengths are encoded as varints, literals are stored raw.
+func (d *Delta) MarshalBinary() ([]byte, error) {
+	buf := append([]byte(nil), deltaMagic...)
+	buf = append(buf, deltaVersion)
+	buf = appendUvarint(buf, uint64(d.Size))
+	for _, op := range d.Ops {
+		switch op.Kind {
+		case Copy:
+			buf = append(buf, byte(Copy))
+			buf = appendUvarint(buf, uint64(op.Offset))
+			buf = appendUvarint(buf, uint64(op.Length))
+		case Literal:
+			buf = append(buf, byte(Literal))
+			buf = appendUvarint(buf, uint64(len(op.Data)))
+			buf = append(buf, op.Data...)
+		default:
+			return nil, fmt.Errorf("rdiff: invalid instruction %d", op.Kind)
+		}
+	}
+	return append(buf, 0), nil
+}
+
+// UnmarshalBinary decodes a delta in the encoding of MarshalBinary.
+func (d *Delta) UnmarshalBinary(data []byte) error {
+	r := bytes.NewReader(data)
+	header := make([]byte, len(deltaMagic)+1)
+	if _, err := io.ReadFull(r, header); err != nil || !bytes.Equal(header[:len(deltaMagic)], deltaMagic) {
+		return ErrInvalidDelta
+	}
+	if header[len(deltaMagic)] != deltaVersion {
+		return fmt.Errorf("rdiff: unsupported delta version %d", header[len(deltaMagic)])
+	}
+	size, err := binary.ReadUvarint(r)
+	if err != nil {
+		return ErrInvalidDelta
+	}
+
+	decoded := Delta{Size: int64(size)}
+	for {
+		kind, err := r.ReadByte()
+		if err != nil {
+			return ErrInvalidDelta
+		}
+		switch OpKind(kind) {
+		case 0:
+			if r.Len() > 0 {
+				return ErrInvalidDelta
+			}
+			*d = decoded
+			return nil
+		case Copy:
+			offset, err1 := binary.ReadUvarint(r)
+			length, err2 := binary.ReadUvarint(r)
+			if err1 != nil || err2 != nil {
+				return ErrInvalidDelta
+			}
+			decoded.Ops = append(decoded.Ops, Op{Kind: Copy, Offset: int64(offset), Length: int64(length)})
+		case Literal:
+			length, err := binary.ReadUvarint(r)
+			if err != nil || length > uint64(r.Len()) {
+				return ErrInvalidDelta
+			}
+			op := Op{Kind: Literal, Data: make([]byte, length)}
+			io.ReadFull(r, op.Data)
+			decoded.Ops = append(decoded.Ops, op)
+		default:
+			return ErrInvalidDelta
+		}
+	}
+}
+
+// appendUvarint appends the varint encoding of v to buf.
+func appendUvarint(buf []byte, v uint64) []byte {
+	var tmp [binary.MaxVarintLen64]byte
+	return append(buf, tmp[:binary.PutUvarint(tmp[:], v)]...)
+}
@@ -0,0 +1,89 @@
+package rdiff
+
+import (
+	"bytes"
+	ae "github.com/mg98/ae-chunker-go"
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"testing"
+)
+
+func randBytes(n int) []byte {
+	data := make([]byte, n)
+	rand.New(rand.NewSource(1)).Read(data)
+	return data
+}
+
+// sync transfers new to the side of old and returns the patched file and the delta.
+func sync(t *testing.T, old, new []byte) ([]byte, *Delta) {
+	sig, err := NewSignature(bytes.NewReader(old), &ae.Options{AverageSize: 16 * 1024})
+	assert.NoError(t, err)
+	d, err := NewDelta(bytes.NewReader(new), sig)
+	assert.NoError(t, err)
+
+	data, err := d.MarshalBinary()
+	assert.NoError(t, err)
+	var received Delta
+	assert.NoError(t, received.UnmarshalBinary(data))
+	assert.Equal(t, d, &received)
+
+	var buf bytes.Buffer
+	assert.NoError(t, Patch(&buf, bytes.NewReader(old), &received))
+	return buf.Bytes(), d
+}
+
+func TestSync(t *testing.T) {
+	old := randBytes(1024 * 1024)
+
+	t.Run("unchanged", func(t *testing.T) {
+		patched, d := sync(t, old, old)
+		assert.Equal(t, old, patched)
+		assert.Zero(t, d.LiteralBytes())
+		assert.Equal(t, []Op{{Kind: Copy, Offset: 0, Length: int64(len(old))}}, d.Ops)
+	})
+
+	t.Run("inserted", func(t *testing.T) {
+		new := append(append(append([]byte{}, old[:500000]...), []byte("hello world")...), old[500000:]...)
+		patched, d := sync(t, old, new)
+		assert.Equal(t, new, patched)
+		assert.Less(t, d.LiteralBytes(), int64(len(new)/10))
+	})
+
+	t.Run("replaced", func(t *testing.T) {
+		new := randBytes(2 * 1024 * 1024)[1024*1024:]
+		patched, d := sync(t, old, new)
+		assert.Equal(t, new, patched)
+		assert.Equal(t, int64(len(new)), d.LiteralBytes())
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		patched, d := sync(t, old, nil)
+		assert.Empty(t, patched)
+		assert.Empty(t, d.Ops)
+	})
+}
+
+func TestPatch(t *testing.T) {
+	t.Run("size mismatch", func(t *testing.T) {
+		d := &Delta{Size: 10, Ops: []Op{{Kind: Literal, Data: []byte("foo")}}}
+		assert.Equal(t, ErrSizeMismatch, Patch(&bytes.Buffer{}, bytes.NewReader(nil), d))
+	})
+
+	t.Run("old file too short", func(t *testing.T) {
+		d := &Delta{Size: 10, Ops: []Op{{Kind: Copy, Offset: 5, Length: 10}}}
+		assert.Error(t, Patch(&bytes.Buffer{}, bytes.NewReader(make([]byte, 10)), d))
+	})
+}
+
+func TestDelta_UnmarshalBinary(t *testing.T) {
+	d := &Delta{Size: 13, Ops: []Op{{Kind: Copy, Offset: 100, Length: 10}, {Kind: Literal, Data: []byte("foo")}}}
+	data, err := d.MarshalBinary()
+	assert.NoError(t, err)
+
+	for i := 0; i < len(data); i++ {
+		var decoded Delta
+		assert.Error(t, decoded.UnmarshalBinary(data[:i]))
+	}
+	var decoded Delta
+	assert.Equal(t, ErrInvalidDelta, decoded.UnmarshalBinary(append(data, 0)))
+}
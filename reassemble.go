@@ -0,0 +1,141 @@
+package ae
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"sort"
+)
+
+// ErrCorruptChunk is returned if a chunk fetched from a ChunkStore does not match its reference in the manifest.
+var ErrCorruptChunk = errors.New("ae: corrupt chunk")
+
+// Reassemble writes the file described by m to w, fetching its chunks from s.
+// Every chunk is verified against its length and hash before it is written.
+func Reassemble(w io.Writer, m *Manifest, s ChunkStore) error {
+	hasher, err := manifestHasher(m)
+	if err != nil {
+		return err
+	}
+	h := hasher()
+	for i, ref := range m.Chunks {
+		data, err := fetchChunk(s, h, i, ref)
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(data); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ReassembleAt returns an io.ReaderAt over the file described by m, fetching its chunks from s on demand.
+// As with Reassemble, every chunk is verified before its data is returned.
+// The returned reader is safe for concurrent use.
+func ReassembleAt(m *Manifest, s ChunkStore) (io.ReaderAt, error) {
+	hasher, err := manifestHasher(m)
+	if err != nil {
+		return nil, err
+	}
+	return &manifestReaderAt{m: m, store: s, hasher: hasher}, nil
+}
+
+// manifestReaderCacheSize bounds the chunk data cached by a reader returned by NewManifestReader.
+const manifestReaderCacheSize = 16 << 20
+
+// NewManifestReader is like ReassembleAt, but keeps the most recently read chunks in a cache of 16 MiB,
+// which suits many small reads of neighboring ranges, e.g. HTTP range requests served with http.ServeContent
+// and io.NewSectionReader. An invalid manifest is reported by ReadAt.
+func NewManifestReader(m *Manifest, s ChunkStore) io.ReaderAt {
+	hasher, err := manifestHasher(m)
+	if err != nil {
+		return errReaderAt{err}
+	}
+	return &manifestReaderAt{m: m, store: s, hasher: hasher, cache: NewMemoryStore(manifestReaderCacheSize)}
+}
+
+// errReaderAt is an io.ReaderAt failing with err.
+type errReaderAt struct {
+	err error
+}
+
+// ReadAt implements io.ReaderAt.
+func (r errReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	return 0, r.err
+}
+
+// manifestReaderAt reads the file described by a manifest from a ChunkStore.
+type manifestReaderAt struct {
+	m      *Manifest
+	store  ChunkStore
+	hasher func() hash.Hash
+
+	// cache holds the chunks read recently, which have been verified already (optional).
+	cache *MemoryStore
+}
+
+// ReadAt implements io.ReaderAt.
+func (r *manifestReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	if off < 0 {
+		return 0, errors.New("ae: negative offset")
+	}
+	chunks := r.m.Chunks
+	i := sort.Search(len(chunks), func(i int) bool {
+		return chunks[i].Offset+chunks[i].Length > off
+	})
+	h := r.hasher()
+	n := 0
+	for ; n < len(p) && i < len(chunks); i++ {
+		data, err := r.chunk(h, i)
+		if err != nil {
+			return n, err
+		}
+		n += copy(p[n:], data[off+int64(n)-chunks[i].Offset:])
+	}
+	if n < len(p) {
+		return n, io.EOF
+	}
+	return n, nil
+}
+
+// chunk returns the verified data of the i-th chunk, from the cache if possible.
+func (r *manifestReaderAt) chunk(h hash.Hash, i int) ([]byte, error) {
+	ref := r.m.Chunks[i]
+	if r.cache == nil {
+		return fetchChunk(r.store, h, i, ref)
+	}
+	if data, err := r.cache.Get(ref.Hash); err == nil {
+		return data, nil
+	}
+	data, err := fetchChunk(r.store, h, i, ref)
+	if err != nil {
+		return nil, err
+	}
+	r.cache.Put(ref.Hash, data)
+	return data, nil
+}
+
+// manifestHasher validates m and returns the constructor of the hash its chunks are fingerprinted with.
+func manifestHasher(m *Manifest) (func() hash.Hash, error) {
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+	return LookupHash(m.Parameters.Hash)
+}
+
+// fetchChunk gets the i-th chunk of a manifest from s and verifies it against ref using h.
+func fetchChunk(s ChunkStore, h hash.Hash, i int, ref ChunkRef) ([]byte, error) {
+	data, err := s.Get(ref.Hash)
+	if err != nil {
+		return nil, fmt.Errorf("ae: fetching chunk %d: %w", i, err)
+	}
+	h.Reset()
+	h.Write(data)
+	if int64(len(data)) != ref.Length || !bytes.Equal(h.Sum(nil), ref.Hash) {
+		return nil, fmt.Errorf("%w %d at offset %d", ErrCorruptChunk, i, ref.Offset)
+	}
+	return data, nil
+}
@@ -0,0 +1,132 @@
+package ae
+
+import (
+	"bytes"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"testing"
+)
+
+// storeInput chunks input into a MemoryStore and returns its manifest.
+func storeInput(t *testing.T, input []byte, opts *Options) (*Manifest, *MemoryStore) {
+	m, err := BuildManifest(bytes.NewReader(input), opts)
+	assert.NoError(t, err)
+	s := NewMemoryStore(0)
+	for _, ref := range m.Chunks {
+		assert.NoError(t, s.Put(ref.Hash, input[ref.Offset:ref.Offset+ref.Length]))
+	}
+	return m, s
+}
+
+func TestReassemble(t *testing.T) {
+	input := testFile[:4*MiB]
+	m, s := storeInput(t, input, &Options{AverageSize: 64 * 1024})
+
+	t.Run("round trip", func(t *testing.T) {
+		var buf bytes.Buffer
+		assert.NoError(t, Reassemble(&buf, m, s))
+		assert.Equal(t, input, buf.Bytes())
+	})
+
+	t.Run("missing chunk", func(t *testing.T) {
+		missing := NewMemoryStore(0)
+		for _, ref := range m.Chunks[:len(m.Chunks)-1] {
+			data, _ := s.Get(ref.Hash)
+			missing.Put(ref.Hash, data)
+		}
+		err := Reassemble(io.Discard, m, missing)
+		assert.True(t, errors.Is(err, ErrChunkNotFound))
+	})
+
+	t.Run("corrupt chunk", func(t *testing.T) {
+		corrupt := NewMemoryStore(0)
+		for i, ref := range m.Chunks {
+			data, _ := s.Get(ref.Hash)
+			if i == 3 {
+				data = append([]byte{}, data...)
+				data[0]++
+			}
+			corrupt.Put(ref.Hash, data)
+		}
+		err := Reassemble(io.Discard, m, corrupt)
+		assert.True(t, errors.Is(err, ErrCorruptChunk))
+	})
+}
+
+func TestReassembleAt(t *testing.T) {
+	input := testFile[:4*MiB]
+	m, s := storeInput(t, input, &Options{AverageSize: 64 * 1024, HashName: "blake3"})
+	r, err := ReassembleAt(m, s)
+	assert.NoError(t, err)
+
+	t.Run("spanning chunks", func(t *testing.T) {
+		off := m.Chunks[2].Offset + 10
+		p := make([]byte, m.Chunks[2].Length+m.Chunks[3].Length)
+		n, err := r.ReadAt(p, off)
+		assert.NoError(t, err)
+		assert.Equal(t, len(p), n)
+		assert.Equal(t, input[off:off+int64(n)], p)
+	})
+
+	t.Run("whole file", func(t *testing.T) {
+		p := make([]byte, len(input))
+		n, err := r.ReadAt(p, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, len(input), n)
+		assert.Equal(t, input, p)
+	})
+
+	t.Run("past the end", func(t *testing.T) {
+		p := make([]byte, 100)
+		n, err := r.ReadAt(p, m.Size-10)
+		assert.Equal(t, io.EOF, err)
+		assert.Equal(t, 10, n)
+		assert.Equal(t, input[len(input)-10:], p[:n])
+
+		n, err = r.ReadAt(p, m.Size+10)
+		assert.Equal(t, io.EOF, err)
+		assert.Zero(t, n)
+	})
+
+	t.Run("section reader", func(t *testing.T) {
+		data, err := io.ReadAll(io.NewSectionReader(r, 0, m.Size))
+		assert.NoError(t, err)
+		assert.Equal(t, input, data)
+	})
+}
+
+// getCountingStore counts the calls to Get of the underlying store.
+type getCountingStore struct {
+	ChunkStore
+	gets int
+}
+
+func (s *getCountingStore) Get(hash []byte) ([]byte, error) {
+	s.gets++
+	return s.ChunkStore.Get(hash)
+}
+
+func TestNewManifestReader(t *testing.T) {
+	input := testFile[:4*MiB]
+	m, s := storeInput(t, input, &Options{AverageSize: 64 * 1024})
+	counting := &getCountingStore{ChunkStore: s}
+	r := NewManifestReader(m, counting)
+
+	// Reading a chunk in small pieces fetches it once.
+	ref := m.Chunks[3]
+	for off := ref.Offset; off < ref.Offset+ref.Length; off += 100 {
+		p := make([]byte, 100)
+		n, err := r.ReadAt(p, off)
+		assert.NoError(t, err)
+		assert.Equal(t, input[off:off+int64(n)], p[:n])
+	}
+	assert.Equal(t, 2, counting.gets)
+
+	data, err := io.ReadAll(io.NewSectionReader(r, 0, m.Size))
+	assert.NoError(t, err)
+	assert.Equal(t, input, data)
+
+	_, err = NewManifestReader(&Manifest{Parameters: Parameters{Hash: "nope"}}, s).ReadAt(make([]byte, 1), 0)
+	assert.Error(t, err)
+}
@@ -0,0 +1,120 @@
+package ae
+
+import (
+	"io"
+	"math"
+	"sort"
+)
+
+// Range is a range of bytes of a file.
+type Range struct {
+	// Offset of the first byte.
+	Offset int64
+
+	// Length of the range in bytes.
+	Length int64
+}
+
+// RechunkRange returns the manifest of the file r that results from editing the file described by old
+// in the modified ranges, without reading the rest of it. The edits must be in place, i.e. all bytes outside of
+// modified are at the same offsets as before, except that the file may grow or shrink at its end,
+// which must then be covered by a modified range as well, e.g. the appended bytes.
+// Of a tar stream, the headers are read in addition to find its entries, which must stay in place as well.
+//
+// Chunking starts over at the last boundary of old that cannot depend on the modified bytes
+// and stops as soon as a boundary of old is reached again behind them, from where on the chunks of old are reused.
+// The result is the manifest that BuildManifest would return for r with the parameters of old.
+// The chunks new to it, as reported by DiffManifests, still have to be stored.
+func RechunkRange(old *Manifest, r io.ReaderAt, modified []Range) (*Manifest, error) {
+	if err := old.Validate(); err != nil {
+		return nil, err
+	}
+	opts := old.Parameters.Options()
+	if opts.Tar {
+		// The chunks are cut at the entries, which cannot be found by parsing the stream from a chunk on.
+		entries, err := tarEntries(r)
+		if err != nil {
+			return nil, err
+		}
+		opts.Tar, opts.Boundaries = false, entries
+	}
+	maxSize := newParams(opts).maxSize
+	ranges := mergeRanges(modified)
+
+	m := &Manifest{Parameters: old.Parameters}
+	// next is the index of the first chunk of old not dealt with yet.
+	next := 0
+	for _, rg := range ranges {
+		// The cut point of a chunk depends at most on the maxSize bytes from its start, and on whether the file ends there.
+		first := next + sort.Search(len(old.Chunks)-next, func(i int) bool {
+			return old.Chunks[next+i].Offset+maxSize >= rg.Offset
+		})
+		m.Chunks = append(m.Chunks, old.Chunks[next:first]...)
+		start := m.end()
+
+		ch := NewChunker(io.NewSectionReader(r, start, math.MaxInt64-start), opts)
+		// Offsets continue those of the file, which alignment depends on.
+		ch.offset = start
+		for {
+			c, err := ch.Next()
+			if err == io.EOF {
+				// The file ends before the chunking got in sync again, so nothing of old remains.
+				m.Size = m.end()
+				return m, nil
+			} else if err != nil {
+				return nil, err
+			}
+			ref := ChunkRef{Offset: c.Offset, Length: int64(len(c.Data)), Hash: c.Sum}
+			ReleaseChunk(c)
+			m.Chunks = append(m.Chunks, ref)
+			end := ref.Offset + ref.Length
+			if end < rg.Offset+rg.Length {
+				continue
+			}
+			// Behind the modified range, the chunking is in sync with old again once a boundary matches.
+			i := sort.Search(len(old.Chunks), func(i int) bool {
+				return old.Chunks[i].Offset >= end
+			})
+			if i < len(old.Chunks) && old.Chunks[i].Offset == end {
+				next = i
+				break
+			}
+		}
+	}
+	m.Chunks = append(m.Chunks, old.Chunks[next:]...)
+	m.Size = m.end()
+	return m, nil
+}
+
+// end returns the offset just past the last chunk of m.
+func (m *Manifest) end() int64 {
+	if len(m.Chunks) == 0 {
+		return 0
+	}
+	last := m.Chunks[len(m.Chunks)-1]
+	return last.Offset + last.Length
+}
+
+// mergeRanges returns the non-empty ranges sorted by offset, with overlapping and adjacent ones merged.
+func mergeRanges(ranges []Range) []Range {
+	var sorted []Range
+	for _, rg := range ranges {
+		if rg.Length > 0 {
+			sorted = append(sorted, rg)
+		}
+	}
+	sort.Slice(sorted, func(i, j int) bool {
+		return sorted[i].Offset < sorted[j].Offset
+	})
+	var merged []Range
+	for _, rg := range sorted {
+		if last := len(merged) - 1; last >= 0 && rg.Offset <= merged[last].Offset+merged[last].Length {
+			if end := rg.Offset + rg.Length; end > merged[last].Offset+merged[last].Length {
+				merged[last].Length = end - merged[last].Offset
+			}
+			continue
+		}
+		merged = append(merged, rg)
+	}
+	return merged
+}
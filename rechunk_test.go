@@ -0,0 +1,97 @@
+package ae
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"testing"
+)
+
+// countingReaderAt counts the bytes read from an io.ReaderAt.
+type countingReaderAt struct {
+	r io.ReaderAt
+	n int64
+}
+
+func (r *countingReaderAt) ReadAt(p []byte, off int64) (int, error) {
+	n, err := r.r.ReadAt(p, off)
+	r.n += int64(n)
+	return n, err
+}
+
+func TestRechunkRange(t *testing.T) {
+	// A generous maximum size lets the boundaries get in sync quickly after an edit, as fewer chunks are cut at it.
+	opts := &Options{AverageSize: 16 * 1024, MaxSize: 256 * 1024}
+	input := testFile[:16*MiB]
+	old, err := BuildManifest(bytes.NewReader(input), opts)
+	require.NoError(t, err)
+
+	overwrite := func(data []byte, off int64, p []byte) []byte {
+		out := append([]byte{}, data...)
+		copy(out[off:], p)
+		return out
+	}
+	for _, tc := range []struct {
+		name     string
+		data     []byte
+		modified []Range
+	}{
+		{"none", input, nil},
+		{"single byte", overwrite(input, 3*MiB, []byte{^input[3*MiB]}), []Range{{Offset: 3 * MiB, Length: 1}}},
+		{"several", overwrite(overwrite(input, 100, testFile[20*MiB:20*MiB+5000]), 6*MiB, testFile[30*MiB:30*MiB+5000]),
+			[]Range{{Offset: 6 * MiB, Length: 5000}, {Offset: 100, Length: 5000}, {Offset: 200, Length: 10}}},
+		{"start", overwrite(input, 0, []byte("head")), []Range{{Offset: 0, Length: 4}}},
+		{"appended", append(append([]byte{}, input...), testFile[40*MiB:41*MiB]...), []Range{{Offset: 16 * MiB, Length: MiB}}},
+		{"truncated", input[:15*MiB+123], []Range{{Offset: 15*MiB + 123, Length: MiB - 123}}},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			r := &countingReaderAt{r: bytes.NewReader(tc.data)}
+			m, err := RechunkRange(old, r, tc.modified)
+			require.NoError(t, err)
+			expected, err := BuildManifest(bytes.NewReader(tc.data), opts)
+			require.NoError(t, err)
+			assert.Equal(t, expected, m)
+			// Only a fraction of the file is read.
+			assert.Less(t, r.n, int64(len(tc.data)/2))
+		})
+	}
+
+	t.Run("alignment", func(t *testing.T) {
+		opts := &Options{AverageSize: 16 * 1024, MaxSize: 256 * 1024, Alignment: 4096}
+		old, err := BuildManifest(bytes.NewReader(input), opts)
+		require.NoError(t, err)
+		data := overwrite(input, 3*MiB+1000, []byte("edit"))
+		m, err := RechunkRange(old, bytes.NewReader(data), []Range{{Offset: 3*MiB + 1000, Length: 4}})
+		require.NoError(t, err)
+		expected, err := BuildManifest(bytes.NewReader(data), opts)
+		require.NoError(t, err)
+		assert.Equal(t, expected, m)
+	})
+
+	t.Run("tar", func(t *testing.T) {
+		archive := tarArchive(t, testFile[:300*1024], testFile[MiB:MiB+777], testFile[2*MiB:5*MiB], nil, testFile[6*MiB:6*MiB+5000])
+		opts := &Options{AverageSize: 16 * 1024, MaxSize: 256 * 1024, Tar: true}
+		old, err := BuildManifest(bytes.NewReader(archive), opts)
+		require.NoError(t, err)
+		for _, off := range []int64{100 * 1024, 300*1024 + 1200, 2 * MiB} {
+			data := overwrite(archive, off, []byte("edit"))
+			r := &countingReaderAt{r: bytes.NewReader(data)}
+			m, err := RechunkRange(old, r, []Range{{Offset: off, Length: 4}})
+			require.NoError(t, err)
+			expected, err := BuildManifest(bytes.NewReader(data), opts)
+			require.NoError(t, err)
+			assert.Equal(t, expected, m)
+			assert.Less(t, r.n, int64(len(data)/2))
+		}
+	})
+
+	_, err = RechunkRange(&Manifest{Size: 1}, bytes.NewReader(nil), nil)
+	assert.Error(t, err)
+}
+
+func TestMergeRanges(t *testing.T) {
+	assert.Equal(t, []Range{{Offset: 0, Length: 15}, {Offset: 20, Length: 1}}, mergeRanges([]Range{
+		{Offset: 20, Length: 1}, {Offset: 10, Length: 5}, {Offset: 0, Length: 10}, {Offset: 2, Length: 3}, {Offset: 30},
+	}))
+}
@@ -0,0 +1,37 @@
+package ae
+
+import (
+	"unicode/utf8"
+)
+
+// snap moves the cut point n within pending to just after the nearest delimiter at most tolerance bytes away.
+// Of two delimiters at the same distance, the earlier one wins. If there is none, n is returned as is.
+func snap(pending []byte, n int, delimiter byte, tolerance int64) int {
+	for d := 0; int64(d) <= tolerance; d++ {
+		if n-d < 1 && n+d > len(pending) {
+			break
+		}
+		if n-d >= 1 && pending[n-d-1] == delimiter {
+			return n - d
+		}
+		if d > 0 && n+d <= len(pending) && pending[n+d-1] == delimiter {
+			return n + d
+		}
+	}
+	return n
+}
+
+// runeSafe returns the length of chunk without a UTF-8 sequence that is cut off at its end.
+// It thus shortens the chunk by at most 3 bytes, but never empties it.
+func runeSafe(chunk []byte) int {
+	n := len(chunk)
+	for i := n - 1; i >= 0 && i > n-utf8.UTFMax; i-- {
+		if utf8.RuneStart(chunk[i]) {
+			if i > 0 && !utf8.FullRune(chunk[i:]) {
+				return i
+			}
+			break
+		}
+	}
+	return n
+}
@@ -0,0 +1,133 @@
+package ae
+
+import (
+	"bytes"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"math/rand"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"unicode/utf8"
+)
+
+func TestOptions_SnapTolerance(t *testing.T) {
+	var input []byte
+	rnd := rand.New(rand.NewSource(1))
+	for i := 0; len(input) < 4*int(MiB); i++ {
+		input = append(input, fmt.Sprintf(`{"id":%d,"value":"%x"}`+"\n", i, rnd.Int63n(1<<(rnd.Intn(60)+1)))...)
+	}
+
+	opts := &Options{AverageSize: 16 * 1024, SnapTolerance: 256, Delimiter: '\n'}
+	chunks := getChunks(NewChunker(bytes.NewReader(input), opts))
+	assert.Equal(t, input, bytes.Join(chunks, nil))
+	for _, c := range chunks {
+		assert.Equal(t, byte('\n'), c[len(c)-1])
+		assert.LessOrEqual(t, int64(len(c)), newParams(opts).maxSize)
+	}
+
+	// Without snapping, records are split.
+	var split int
+	for _, c := range getChunks(NewChunker(bytes.NewReader(input), &Options{AverageSize: 16 * 1024})) {
+		if c[len(c)-1] != '\n' {
+			split++
+		}
+	}
+	assert.Greater(t, split, 0)
+}
+
+func TestSnap(t *testing.T) {
+	input := []byte("aaa\nbbbbbbbb\ncc\n")
+	for _, tc := range []struct {
+		n         int
+		tolerance int64
+		want      int
+	}{
+		{4, 0, 4},
+		{6, 2, 4},
+		{6, 1, 6},
+		{11, 2, 13},
+		{9, 4, 13},
+		{8, 4, 4},
+		{2, 10, 4},
+		{16, 3, 16},
+		{15, 0, 15},
+	} {
+		assert.Equal(t, tc.want, snap(input, tc.n, '\n', tc.tolerance), "n=%d tolerance=%d", tc.n, tc.tolerance)
+	}
+}
+
+func TestOptions_RuneSafe(t *testing.T) {
+	text := []rune("aé€😀")
+	rnd := rand.New(rand.NewSource(1))
+	var b strings.Builder
+	for b.Len() < int(MiB) {
+		b.WriteRune(text[rnd.Intn(len(text))])
+	}
+	input := []byte(b.String())
+
+	chunks := getChunks(NewChunker(bytes.NewReader(input), &Options{AverageSize: 1024, RuneSafe: true}))
+	assert.Equal(t, input, bytes.Join(chunks, nil))
+	for _, c := range chunks {
+		assert.True(t, utf8.Valid(c))
+	}
+
+	var invalid int
+	for _, c := range getChunks(NewChunker(bytes.NewReader(input), &Options{AverageSize: 1024})) {
+		if !utf8.Valid(c) {
+			invalid++
+		}
+	}
+	assert.Greater(t, invalid, 0)
+}
+
+func TestRuneSafe(t *testing.T) {
+	assert.Equal(t, 3, runeSafe([]byte("abc")))
+	assert.Equal(t, 1, runeSafe([]byte("a\xe2\x82")))
+	assert.Equal(t, 4, runeSafe([]byte("a\xe2\x82\xac")))
+	assert.Equal(t, 1, runeSafe([]byte("a\xf0\x9f\x98")))
+	assert.Equal(t, 3, runeSafe([]byte("\xf0\x9f\x98")))
+	assert.Equal(t, 4, runeSafe([]byte("\x80\x80\x80\x80")))
+}
+
+func TestOptions_finalChunk(t *testing.T) {
+	// The input fills exactly one chunk of MaxSize bytes, rising so that it has no extremum, and offers
+	// a cut point near its end, which must only be taken if the input goes on, whether the reader returns
+	// io.EOF along with the last bytes or with the next read.
+	opts := &Options{AverageSize: 64, SnapTolerance: 16, Delimiter: '\n', RuneSafe: true}
+	maxSize := newParams(opts).maxSize
+	input := make([]byte, maxSize)
+	for i := range input {
+		input[i] = byte(16 + i)
+	}
+	input[maxSize-8] = '\n'
+	copy(input[maxSize-2:], "\xe2\x82")
+
+	chunks := func(r io.Reader) []Chunk {
+		var chunks []Chunk
+		c := NewChunker(r, opts)
+		for {
+			chunk, err := c.Next()
+			if err == io.EOF {
+				return chunks
+			}
+			assert.NoError(t, err)
+			chunks = append(chunks, *chunk)
+		}
+	}
+	want := []Chunk{{Data: input, Reason: CutEOF, Final: true}}
+	assert.Equal(t, want, chunks(bytes.NewReader(input)))
+	assert.Equal(t, want, chunks(iotest.DataErrReader(bytes.NewReader(input))))
+	assert.Equal(t, want, chunks(iotest.OneByteReader(bytes.NewReader(input))))
+
+	// Once the input goes on, the cut point is snapped to the delimiter.
+	longer := append(append([]byte(nil), input...), "bb"...)
+	for _, r := range []io.Reader{bytes.NewReader(longer), iotest.DataErrReader(bytes.NewReader(longer))} {
+		got := chunks(r)
+		if assert.Len(t, got, 2) {
+			assert.Equal(t, longer[:maxSize-7], got[0].Data)
+			assert.Equal(t, longer[maxSize-7:], got[1].Data)
+		}
+	}
+}
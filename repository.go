@@ -0,0 +1,466 @@
+package ae
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrRepositoryLocked is returned if another writer holds the lock of a Repository.
+var ErrRepositoryLocked = errors.New("ae: repository is locked by another writer")
+
+// The layout of a repository below its root directory.
+const (
+	repoConfigFile   = "config.json"
+	repoLockFile     = "lock"
+	repoDataDir      = "data"
+	repoManifestsDir = "manifests"
+	repoPinsDir      = "pins"
+
+	// repoManifestSuffix is appended to the names of the manifest files.
+	repoManifestSuffix = ".json"
+)
+
+// repoConfigVersion is the version of the layout and config of a Repository.
+const repoConfigVersion = 1
+
+// repoConfigParamsVersion is the version of configs with parameters beyond those of version 1,
+// so that readers of version 1 reject them instead of chunking differently.
+const repoConfigParamsVersion = 2
+
+// RepositoryConfig is the configuration of a Repository, fixed when it is initialized.
+type RepositoryConfig struct {
+	// Parameters all files are chunked with. The hash defaults to SHA-256.
+	Parameters Parameters
+
+	// PackSize is the target size of the packs holding the chunks (optional). It defaults to 16 MiB.
+	PackSize int64
+
+	// Codec compressing the chunks (optional).
+	Codec Codec
+}
+
+// repoConfigJSON is the JSON representation of a RepositoryConfig.
+type repoConfigJSON struct {
+	Version    int            `json:"version"`
+	Parameters parametersJSON `json:"parameters"`
+	PackSize   int64          `json:"packSize"`
+	Codec      string         `json:"codec"`
+}
+
+// Repository is a directory holding files as manifests along with their chunks, grouped into packs,
+// and the configuration they are chunked with. It is safe for concurrent use.
+//
+// Writers take a lock file, so that only one process writes to a repository at a time.
+// A lock left behind by a crashed process is taken over if it ran on the same host, or removed with BreakLock.
+// Within a process, any number of writes may run concurrently.
+type Repository struct {
+	dir    string
+	config RepositoryConfig
+	store  *PackingStore
+
+	// mu guards writers, the number of writes holding the lock.
+	mu      sync.Mutex
+	writers int
+}
+
+// InitRepository creates a Repository in dir with the configuration cfg.
+// It fails if dir already holds a repository.
+func InitRepository(dir string, cfg RepositoryConfig) (*Repository, error) {
+	if cfg.Parameters.Hash == "" {
+		cfg.Parameters.Hash = "sha256"
+	}
+	cfg.Parameters = NewChunker(nil, cfg.Parameters.Options()).parameters()
+	if cfg.PackSize <= 0 {
+		cfg.PackSize = defaultPackSize
+	}
+	if _, err := LookupHash(cfg.Parameters.Hash); err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+	cj := repoConfigJSON{
+		Version:    repoConfigVersion,
+		Parameters: cfg.Parameters.toJSON(),
+		PackSize:   cfg.PackSize,
+		Codec:      cfg.Codec.String(),
+	}
+	if hasParams(&cfg.Parameters) {
+		cj.Version = repoConfigParamsVersion
+	}
+	data, err := json.MarshalIndent(cj, "", "  ")
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.OpenFile(filepath.Join(dir, repoConfigFile), os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := f.Write(append(data, '\n')); err != nil {
+		f.Close()
+		return nil, err
+	}
+	if err := f.Close(); err != nil {
+		return nil, err
+	}
+	return OpenRepository(dir)
+}
+
+// OpenRepository opens the Repository in dir, loading the indexes of its packs.
+func OpenRepository(dir string) (*Repository, error) {
+	data, err := os.ReadFile(filepath.Join(dir, repoConfigFile))
+	if err != nil {
+		return nil, err
+	}
+	var cj repoConfigJSON
+	if err := json.Unmarshal(data, &cj); err != nil {
+		return nil, fmt.Errorf("ae: reading repository config: %w", err)
+	}
+	if cj.Version != repoConfigVersion && cj.Version != repoConfigParamsVersion {
+		return nil, fmt.Errorf("ae: unsupported repository version %d", cj.Version)
+	}
+	codec, err := parseCodec(cj.Codec)
+	if err != nil {
+		return nil, err
+	}
+	cfg := RepositoryConfig{
+		Parameters: cj.Parameters.parameters(),
+		PackSize:   cj.PackSize,
+		Codec:      codec,
+	}
+	fstore, err := NewFileStore(filepath.Join(dir, repoDataDir), &FileStoreOptions{Sync: SyncFile})
+	if err != nil {
+		return nil, err
+	}
+	store, err := NewPackingStore(fstore, &PackOptions{TargetSize: cfg.PackSize, Codec: cfg.Codec})
+	if err != nil {
+		return nil, err
+	}
+	return &Repository{dir: dir, config: cfg, store: store}, nil
+}
+
+// parseCodec returns the codec named name.
+func parseCodec(name string) (Codec, error) {
+	for c := CodecNone; c <= CodecLZ4; c++ {
+		if c.String() == name {
+			return c, nil
+		}
+	}
+	return 0, fmt.Errorf("ae: unknown codec %q", name)
+}
+
+// Config returns the configuration of the repository.
+func (r *Repository) Config() RepositoryConfig {
+	return r.config
+}
+
+// Options returns the Options files are chunked with.
+func (r *Repository) Options() *Options {
+	return r.config.Parameters.Options()
+}
+
+// Store returns the store holding the chunks. Writes to it must be made while holding the lock.
+func (r *Repository) Store() ChunkStore {
+	return r.store
+}
+
+// Lock takes the writer lock of the repository, or returns ErrRepositoryLocked if another process holds it.
+// Every call must be matched by a call to Unlock. Calls within the process share the lock.
+//
+// The lock file records the host and PID of its holder. A lock left behind by a process of this host that
+// no longer runs is taken over; one of another host must be removed with BreakLock.
+func (r *Repository) Lock() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.writers == 0 {
+		p := filepath.Join(r.dir, repoLockFile)
+		f, err := os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		if errors.Is(err, fs.ErrExist) && r.staleLock() {
+			if err := os.Remove(p); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return err
+			}
+			f, err = os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o644)
+		}
+		if errors.Is(err, fs.ErrExist) {
+			return ErrRepositoryLocked
+		}
+		if err != nil {
+			return err
+		}
+		host, _ := os.Hostname()
+		fmt.Fprintf(f, "%s %d\n", host, os.Getpid())
+		if err := f.Close(); err != nil {
+			return err
+		}
+	}
+	r.writers++
+	return nil
+}
+
+// staleLock reports whether the lock file was left behind by a process of this host that no longer runs.
+func (r *Repository) staleLock() bool {
+	data, err := os.ReadFile(filepath.Join(r.dir, repoLockFile))
+	if err != nil {
+		return false
+	}
+	var host string
+	var pid int
+	if _, err := fmt.Sscanf(string(data), "%s %d", &host, &pid); err != nil {
+		return false
+	}
+	self, _ := os.Hostname()
+	return host == self && pid != os.Getpid() && !processAlive(pid)
+}
+
+// BreakLock removes the lock file whoever holds it, e.g. after a writer on another host crashed.
+// It must only be called when no other process writes to the repository, which could corrupt it otherwise.
+func (r *Repository) BreakLock() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.writers > 0 {
+		return errors.New("ae: repository is locked by this process")
+	}
+	err := os.Remove(filepath.Join(r.dir, repoLockFile))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	return err
+}
+
+// Unlock releases the writer lock taken by Lock. Once the last writer of the process unlocks,
+// the pending pack is written and the lock file is removed.
+func (r *Repository) Unlock() error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if r.writers == 0 {
+		return errors.New("ae: repository is not locked")
+	}
+	r.writers--
+	if r.writers > 0 {
+		return nil
+	}
+	err := r.store.Flush()
+	if rerr := os.Remove(filepath.Join(r.dir, repoLockFile)); err == nil {
+		err = rerr
+	}
+	return err
+}
+
+// StoreFile chunks rd into the repository and saves its manifest under name.
+func (r *Repository) StoreFile(name string, rd io.Reader) (m *Manifest, err error) {
+	if err := r.Lock(); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if uerr := r.Unlock(); err == nil && uerr != nil {
+			m, err = nil, uerr
+		}
+	}()
+	if m, err = StoreFile(rd, r.store, r.Options()); err != nil {
+		return nil, err
+	}
+	// The chunks must be written before the manifest referring to them.
+	if err := r.store.Flush(); err != nil {
+		return nil, err
+	}
+	if err := r.saveManifest(name, m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Restore writes the file saved under name to w, verifying every chunk.
+func (r *Repository) Restore(name string, w io.Writer) error {
+	m, err := r.Manifest(name)
+	if err != nil {
+		return err
+	}
+	return Reassemble(w, m, r.store)
+}
+
+// manifestPath returns the path of the manifest saved under name, a slash-separated path as by fs.ValidPath.
+func (r *Repository) manifestPath(name string) (string, error) {
+	return namedPath(filepath.Join(r.dir, repoManifestsDir), name)
+}
+
+// namedPath returns the path of the manifest file below root named by name, a slash-separated path as by fs.ValidPath.
+func namedPath(root, name string) (string, error) {
+	if !fs.ValidPath(name) || name == "." {
+		return "", fmt.Errorf("ae: invalid manifest name %q", name)
+	}
+	return filepath.Join(root, filepath.FromSlash(name)+repoManifestSuffix), nil
+}
+
+// SaveManifest saves m under name, replacing any manifest saved under it before.
+// The chunks of m must be in the store already.
+func (r *Repository) SaveManifest(name string, m *Manifest) error {
+	if err := r.Lock(); err != nil {
+		return err
+	}
+	err := r.store.Flush()
+	if err == nil {
+		err = r.saveManifest(name, m)
+	}
+	if uerr := r.Unlock(); err == nil {
+		err = uerr
+	}
+	return err
+}
+
+// saveManifest implements SaveManifest while holding the lock.
+func (r *Repository) saveManifest(name string, m *Manifest) error {
+	p, err := r.manifestPath(name)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(m)
+	if err != nil {
+		return err
+	}
+	return writeFileAtomic(p, data, SyncFile)
+}
+
+// Manifest returns the manifest saved under name.
+func (r *Repository) Manifest(name string) (*Manifest, error) {
+	p, err := r.manifestPath(name)
+	if err != nil {
+		return nil, err
+	}
+	return readManifestFile(p, name)
+}
+
+// readManifestFile reads the manifest saved under name from the file p.
+func readManifestFile(p, name string) (*Manifest, error) {
+	data, err := os.ReadFile(p)
+	if err != nil {
+		return nil, err
+	}
+	m := &Manifest{}
+	if err := json.Unmarshal(data, m); err != nil {
+		return nil, fmt.Errorf("ae: reading manifest %s: %w", name, err)
+	}
+	return m, nil
+}
+
+// Manifests returns the names of all saved manifests in lexical order.
+func (r *Repository) Manifests() ([]string, error) {
+	return listManifests(filepath.Join(r.dir, repoManifestsDir))
+}
+
+// listManifests returns the names of the manifest files below root in lexical order.
+func listManifests(root string) ([]string, error) {
+	var names []string
+	err := filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if errors.Is(err, fs.ErrNotExist) && p == root {
+			return nil
+		}
+		if err != nil || d.IsDir() || !strings.HasSuffix(p, repoManifestSuffix) || strings.HasPrefix(d.Name(), ".") {
+			return err
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		names = append(names, strings.TrimSuffix(filepath.ToSlash(rel), repoManifestSuffix))
+		return nil
+	})
+	sort.Strings(names)
+	return names, err
+}
+
+// DeleteManifest deletes the manifest saved under name. Its chunks are removed by CollectGarbage.
+func (r *Repository) DeleteManifest(name string) error {
+	p, err := r.manifestPath(name)
+	if err != nil {
+		return err
+	}
+	if err := r.Lock(); err != nil {
+		return err
+	}
+	err = os.Remove(p)
+	if uerr := r.Unlock(); err == nil {
+		err = uerr
+	}
+	return err
+}
+
+// CollectGarbage deletes the chunks not referenced by any saved manifest, snapshot or pin and repacks the packs
+// in which deleted chunks take up more than a fifth of the space. If dryRun is set, the chunks are only counted.
+// Other processes reading the repository must reopen it afterwards, as packs may have moved.
+func (r *Repository) CollectGarbage(dryRun bool) (report *GCReport, err error) {
+	if err := r.Lock(); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if uerr := r.Unlock(); err == nil {
+			err = uerr
+		}
+	}()
+	return r.collectGarbage(nil, dryRun)
+}
+
+// collectGarbage implements CollectGarbage while holding the lock,
+// treating the snapshots with the IDs in removed as deleted.
+func (r *Repository) collectGarbage(removed map[string]bool, dryRun bool) (*GCReport, error) {
+	live, err := r.liveManifests(removed)
+	if err != nil {
+		return nil, err
+	}
+	report, err := CollectGarbage(r.store, live, dryRun)
+	if err != nil || dryRun {
+		return report, err
+	}
+	_, err = r.store.Repack(0.2)
+	return report, err
+}
+
+// liveManifests returns the saved manifests, those of the files in the snapshots and the pinned ones,
+// leaving out the snapshots with the IDs in removed.
+func (r *Repository) liveManifests(removed map[string]bool) ([]*Manifest, error) {
+	names, err := r.Manifests()
+	if err != nil {
+		return nil, err
+	}
+	live := make([]*Manifest, 0, len(names))
+	for _, name := range names {
+		m, err := r.Manifest(name)
+		if err != nil {
+			return nil, err
+		}
+		live = append(live, m)
+	}
+	snapshots, err := r.Snapshots()
+	if err != nil {
+		return nil, err
+	}
+	for _, s := range snapshots {
+		if removed[s.ID] {
+			continue
+		}
+		for _, e := range s.Entries {
+			if e.Manifest != nil {
+				live = append(live, e.Manifest)
+			}
+		}
+	}
+	pins, err := r.Pins()
+	if err != nil {
+		return nil, err
+	}
+	for _, name := range pins {
+		m, err := r.pinned(name)
+		if err != nil {
+			return nil, err
+		}
+		live = append(live, m)
+	}
+	return live, nil
+}
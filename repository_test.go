@@ -0,0 +1,154 @@
+package ae
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+)
+
+func TestRepository(t *testing.T) {
+	dir := t.TempDir()
+	r, err := InitRepository(dir, RepositoryConfig{Parameters: Parameters{AverageSize: 16 * 1024}, PackSize: 512 * 1024, Codec: CodecZstd})
+	require.NoError(t, err)
+	_, err = InitRepository(dir, RepositoryConfig{})
+	assert.ErrorIs(t, err, fs.ErrExist)
+
+	files := map[string][]byte{
+		"a":         testFile[:MiB],
+		"dir/b":     testFile[MiB/2 : 2*MiB],
+		"dir/sub/c": testFile[3*MiB : 3*MiB+1000],
+	}
+	// Each file is stored on its own, so that its chunks end up in packs of their own.
+	for _, name := range []string{"a", "dir/b", "dir/sub/c"} {
+		_, err := r.StoreFile(name, bytes.NewReader(files[name]))
+		require.NoError(t, err)
+	}
+	_, err = os.Stat(filepath.Join(dir, repoLockFile))
+	assert.ErrorIs(t, err, fs.ErrNotExist)
+
+	r, err = OpenRepository(dir)
+	require.NoError(t, err)
+	assert.Equal(t, Parameters{AverageSize: 16 * 1024, MaxSize: 32 * 1024, Hash: "sha256"}, r.Config().Parameters)
+	assert.Equal(t, CodecZstd, r.Config().Codec)
+	names, err := r.Manifests()
+	require.NoError(t, err)
+	assert.Equal(t, []string{"a", "dir/b", "dir/sub/c"}, names)
+	for name, data := range files {
+		var buf bytes.Buffer
+		require.NoError(t, r.Restore(name, &buf))
+		assert.Equal(t, data, buf.Bytes(), name)
+	}
+
+	t.Run("lock", func(t *testing.T) {
+		other, err := OpenRepository(dir)
+		require.NoError(t, err)
+		require.NoError(t, other.Lock())
+		_, err = r.StoreFile("d", bytes.NewReader(testFile[:100]))
+		assert.ErrorIs(t, err, ErrRepositoryLocked)
+		require.NoError(t, other.Unlock())
+		assert.Error(t, other.Unlock())
+	})
+
+	t.Run("stale lock", func(t *testing.T) {
+		// A lock of a process of this host that no longer runs is taken over.
+		const dead = 1<<31 - 1
+		if processAlive(dead) {
+			t.Skip("processes cannot be told apart on this platform")
+		}
+		host, err := os.Hostname()
+		require.NoError(t, err)
+		lock := filepath.Join(dir, repoLockFile)
+		require.NoError(t, os.WriteFile(lock, []byte(fmt.Sprintf("%s %d\n", host, dead)), 0o644))
+		require.NoError(t, r.Lock())
+		require.NoError(t, r.Unlock())
+
+		// A lock of another host is only removed by BreakLock.
+		require.NoError(t, os.WriteFile(lock, []byte(fmt.Sprintf("elsewhere %d\n", dead)), 0o644))
+		assert.ErrorIs(t, r.Lock(), ErrRepositoryLocked)
+		require.NoError(t, r.BreakLock())
+		require.NoError(t, r.Lock())
+		assert.Error(t, r.BreakLock())
+		require.NoError(t, r.Unlock())
+		_, err = os.Stat(lock)
+		assert.ErrorIs(t, err, fs.ErrNotExist)
+	})
+
+	t.Run("garbage", func(t *testing.T) {
+		require.NoError(t, r.DeleteManifest("dir/b"))
+		report, err := r.CollectGarbage(true)
+		require.NoError(t, err)
+		assert.Greater(t, report.Garbage, int64(0))
+		assert.Zero(t, report.Deleted)
+
+		report, err = r.CollectGarbage(false)
+		require.NoError(t, err)
+		assert.Equal(t, report.Garbage, report.Deleted)
+		delete(files, "dir/b")
+		for name, data := range files {
+			var buf bytes.Buffer
+			require.NoError(t, r.Restore(name, &buf), name)
+			assert.Equal(t, data, buf.Bytes(), name)
+		}
+
+		r, err := OpenRepository(dir)
+		require.NoError(t, err)
+		report, err = r.CollectGarbage(true)
+		require.NoError(t, err)
+		assert.Zero(t, report.Garbage)
+		for name, data := range files {
+			var buf bytes.Buffer
+			require.NoError(t, r.Restore(name, &buf), name)
+			assert.Equal(t, data, buf.Bytes(), name)
+		}
+	})
+
+	t.Run("concurrent writers", func(t *testing.T) {
+		var wg sync.WaitGroup
+		for i := int64(0); i < 4; i++ {
+			wg.Add(1)
+			go func(i int64) {
+				defer wg.Done()
+				_, err := r.StoreFile(fmt.Sprintf("concurrent/%d", i), bytes.NewReader(testFile[i*MiB:i*MiB+MiB/2]))
+				assert.NoError(t, err)
+			}(i)
+		}
+		wg.Wait()
+		for i := int64(0); i < 4; i++ {
+			var buf bytes.Buffer
+			require.NoError(t, r.Restore(fmt.Sprintf("concurrent/%d", i), &buf))
+			assert.Equal(t, testFile[i*MiB:i*MiB+MiB/2], buf.Bytes())
+		}
+	})
+
+	_, err = r.Manifest("../escape")
+	assert.Error(t, err)
+}
+
+func TestRepository_configVersion(t *testing.T) {
+	for _, tc := range []struct {
+		params  Parameters
+		version int
+	}{
+		{Parameters{AverageSize: 16 * 1024}, repoConfigVersion},
+		{Parameters{AverageSize: 16 * 1024, Alignment: 4096}, repoConfigParamsVersion},
+	} {
+		dir := t.TempDir()
+		_, err := InitRepository(dir, RepositoryConfig{Parameters: tc.params})
+		require.NoError(t, err)
+		data, err := os.ReadFile(filepath.Join(dir, repoConfigFile))
+		require.NoError(t, err)
+		var cj repoConfigJSON
+		require.NoError(t, json.Unmarshal(data, &cj))
+		assert.Equal(t, tc.version, cj.Version)
+		r, err := OpenRepository(dir)
+		require.NoError(t, err)
+		assert.Equal(t, tc.params.Alignment, r.Config().Parameters.Alignment)
+	}
+}
@@ -0,0 +1,70 @@
+// Package restic provides the chunker in the shape of restic's chunker package (github.com/restic/chunker),
+// so that backup tools structured around that API can switch to AE chunking by changing the import
+// and the construction of the Chunker:
+//
+//	chnker := restic.New(rd, &ae.Options{AverageSize: 1 << 20, MaxSize: 8 << 20})
+//	buf := make([]byte, 8<<20)
+//	for {
+//		chunk, err := chnker.Next(buf)
+//		if err == io.EOF {
+//			break
+//		}
+//		...
+//	}
+//
+// Chunks are the same as those of ae.Chunker with the same options.
+package restic
+
+import (
+	ae "github.com/mg98/ae-chunker-go"
+	"io"
+)
+
+// Chunk is a chunk of the input, with the fields of restic's Chunk.
+type Chunk struct {
+	// Start is the offset of the chunk in the input.
+	Start uint
+
+	// Length of the chunk in bytes.
+	Length uint
+
+	// Cut is the fingerprint of the window at the cut point in restic.
+	// AE does not compute a rolling hash, so it is always 0.
+	Cut uint64
+
+	// Data of the chunk, stored in the buffer passed to Next if it is large enough.
+	Data []byte
+}
+
+// Chunker splits a reader into chunks like restic's Chunker.
+type Chunker struct {
+	ch *ae.Chunker
+}
+
+// New returns a Chunker splitting rd with opts.
+// Unlike restic, the parameters are given by ae.Options instead of a polynomial.
+func New(rd io.Reader, opts *ae.Options) *Chunker {
+	return &Chunker{ch: ae.NewChunker(rd, opts)}
+}
+
+// Reset restarts the Chunker with a new reader and options.
+func (c *Chunker) Reset(rd io.Reader, opts *ae.Options) {
+	c.ch = ae.NewChunker(rd, opts)
+}
+
+// Next returns the next chunk of the input, or io.EOF once the input is exhausted.
+// The data is copied into buf, which is grown as needed; to avoid allocations,
+// buf should have the capacity of the maximum chunk size.
+func (c *Chunker) Next(buf []byte) (Chunk, error) {
+	ch, err := c.ch.Next()
+	if err != nil {
+		return Chunk{}, err
+	}
+	chunk := Chunk{
+		Start:  uint(ch.Offset),
+		Length: uint(len(ch.Data)),
+		Data:   append(buf[:0], ch.Data...),
+	}
+	ae.ReleaseChunk(ch)
+	return chunk, nil
+}
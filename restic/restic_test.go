@@ -0,0 +1,47 @@
+package restic
+
+import (
+	"bytes"
+	ae "github.com/mg98/ae-chunker-go"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"math/rand"
+	"testing"
+)
+
+func TestChunker(t *testing.T) {
+	data := make([]byte, 4<<20)
+	rand.New(rand.NewSource(1)).Read(data)
+	opts := &ae.Options{AverageSize: 64 << 10, MaxSize: 256 << 10}
+
+	want, err := ae.BuildManifest(bytes.NewReader(data), opts)
+	assert.NoError(t, err)
+
+	c := New(bytes.NewReader(data), opts)
+	buf := make([]byte, 256<<10)
+	var got []Chunk
+	for {
+		chunk, err := c.Next(buf)
+		if err == io.EOF {
+			break
+		}
+		assert.NoError(t, err)
+		assert.Equal(t, data[chunk.Start:chunk.Start+chunk.Length], chunk.Data)
+		assert.Equal(t, &buf[0], &chunk.Data[0], "buffer is not reused")
+		got = append(got, chunk)
+	}
+	assert.Len(t, got, len(want.Chunks))
+	for i, ref := range want.Chunks {
+		assert.Equal(t, uint(ref.Offset), got[i].Start)
+		assert.Equal(t, uint(ref.Length), got[i].Length)
+	}
+
+	t.Run("reset", func(t *testing.T) {
+		c.Reset(bytes.NewReader(data[:1000]), nil)
+		chunk, err := c.Next(nil)
+		assert.NoError(t, err)
+		assert.Equal(t, Chunk{Start: 0, Length: 1000, Data: data[:1000]}, chunk)
+		_, err = c.Next(nil)
+		assert.Equal(t, io.EOF, err)
+	})
+}
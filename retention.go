@@ -0,0 +1,128 @@
+package ae
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"time"
+)
+
+// RetentionPolicy decides which snapshots of a Repository to keep.
+// A snapshot is kept if any of the rules applies to it. A zero policy keeps all snapshots.
+type RetentionPolicy struct {
+	// KeepLast keeps the given number of most recent snapshots.
+	KeepLast int
+
+	// KeepHourly, KeepDaily, KeepWeekly, KeepMonthly and KeepYearly keep the most recent snapshot
+	// of each of the given number of most recent hours, days, ISO weeks, months and years that have a snapshot.
+	KeepHourly  int
+	KeepDaily   int
+	KeepWeekly  int
+	KeepMonthly int
+	KeepYearly  int
+
+	// KeepTags keeps all snapshots labeled with any of the tags.
+	KeepTags []string
+}
+
+// isZero reports whether the policy has no rules.
+func (p RetentionPolicy) isZero() bool {
+	return p.KeepLast == 0 && p.KeepHourly == 0 && p.KeepDaily == 0 && p.KeepWeekly == 0 &&
+		p.KeepMonthly == 0 && p.KeepYearly == 0 && len(p.KeepTags) == 0
+}
+
+// retentionBucket is a rule of a RetentionPolicy keeping one snapshot per period.
+type retentionBucket struct {
+	n      int
+	period func(t time.Time) string
+}
+
+// Apply divides snapshots into those to keep and those to remove by the policy, both from the newest to the oldest.
+func (p RetentionPolicy) Apply(snapshots []*Snapshot) (keep, remove []*Snapshot) {
+	sorted := append([]*Snapshot(nil), snapshots...)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Time.After(sorted[j].Time) })
+	if p.isZero() {
+		return sorted, nil
+	}
+
+	buckets := []retentionBucket{
+		{p.KeepHourly, func(t time.Time) string { return t.Format("2006-01-02 15") }},
+		{p.KeepDaily, func(t time.Time) string { return t.Format("2006-01-02") }},
+		{p.KeepWeekly, func(t time.Time) string {
+			year, week := t.ISOWeek()
+			return fmt.Sprintf("%d-W%02d", year, week)
+		}},
+		{p.KeepMonthly, func(t time.Time) string { return t.Format("2006-01") }},
+		{p.KeepYearly, func(t time.Time) string { return t.Format("2006") }},
+	}
+	last := make([]string, len(buckets))
+	tags := make(map[string]bool, len(p.KeepTags))
+	for _, tag := range p.KeepTags {
+		tags[tag] = true
+	}
+	for i, s := range sorted {
+		kept := i < p.KeepLast
+		for _, tag := range s.Tags {
+			kept = kept || tags[tag]
+		}
+		// The newest snapshot of every period is kept, as long as the rule has periods left.
+		for j := range buckets {
+			b := &buckets[j]
+			if period := b.period(s.Time); b.n > 0 && period != last[j] {
+				last[j] = period
+				b.n--
+				kept = true
+			}
+		}
+		if kept {
+			keep = append(keep, s)
+		} else {
+			remove = append(remove, s)
+		}
+	}
+	return keep, remove
+}
+
+// PruneReport is the result of Repository.Prune.
+type PruneReport struct {
+	// Keep are the snapshots kept by the policy, from the newest to the oldest.
+	Keep []*Snapshot
+
+	// Remove are the snapshots removed by the policy, from the newest to the oldest.
+	Remove []*Snapshot
+
+	// GC reports the chunks no longer referenced once the snapshots are removed.
+	GC *GCReport
+}
+
+// Prune removes the snapshots that the policy does not keep and collects the chunks only they referred to,
+// as CollectGarbage does. If dryRun is set, nothing is removed, but the report tells what would be.
+func (r *Repository) Prune(p RetentionPolicy, dryRun bool) (report *PruneReport, err error) {
+	if err := r.Lock(); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if uerr := r.Unlock(); err == nil {
+			err = uerr
+		}
+	}()
+	snapshots, err := r.Snapshots()
+	if err != nil {
+		return nil, err
+	}
+	report = &PruneReport{}
+	report.Keep, report.Remove = p.Apply(snapshots)
+	removed := make(map[string]bool, len(report.Remove))
+	for _, s := range report.Remove {
+		removed[s.ID] = true
+		if !dryRun {
+			if err := os.Remove(r.snapshotPath(s.ID)); err != nil {
+				return nil, err
+			}
+		}
+	}
+	if report.GC, err = r.collectGarbage(removed, dryRun); err != nil {
+		return nil, err
+	}
+	return report, nil
+}
@@ -0,0 +1,118 @@
+package ae
+
+import (
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestRetentionPolicy_Apply(t *testing.T) {
+	// Two snapshots a day, at 06:00 and 18:00, over 60 days from 2024-02-01 to Sunday, 2024-03-31.
+	end := time.Date(2024, 3, 31, 18, 0, 0, 0, time.UTC)
+	var snapshots []*Snapshot
+	for i := 0; i < 120; i++ {
+		at := end.Add(-time.Duration(i) * 12 * time.Hour)
+		snapshots = append(snapshots, &Snapshot{ID: at.Format(time.RFC3339), Time: at})
+	}
+	snapshots[100].Tags = []string{"release"}
+	ids := func(snapshots []*Snapshot) []string {
+		var ids []string
+		for _, s := range snapshots {
+			ids = append(ids, s.ID)
+		}
+		return ids
+	}
+
+	keep, remove := RetentionPolicy{}.Apply(snapshots)
+	assert.Len(t, keep, 120)
+	assert.Empty(t, remove)
+
+	keep, remove = RetentionPolicy{KeepLast: 3}.Apply(snapshots)
+	assert.Equal(t, []string{"2024-03-31T18:00:00Z", "2024-03-31T06:00:00Z", "2024-03-30T18:00:00Z"}, ids(keep))
+	assert.Len(t, remove, 117)
+
+	keep, _ = RetentionPolicy{KeepDaily: 2, KeepWeekly: 2, KeepMonthly: 3, KeepTags: []string{"release"}}.Apply(snapshots)
+	assert.Equal(t, []string{
+		"2024-03-31T18:00:00Z", // daily, weekly and monthly
+		"2024-03-30T18:00:00Z", // daily
+		"2024-03-24T18:00:00Z", // weekly
+		"2024-02-29T18:00:00Z", // monthly
+		snapshots[100].ID,      // tagged
+	}, ids(keep))
+
+	// The order of the input does not matter.
+	reversed := make([]*Snapshot, len(snapshots))
+	for i, s := range snapshots {
+		reversed[len(snapshots)-1-i] = s
+	}
+	keep2, _ := RetentionPolicy{KeepDaily: 2, KeepWeekly: 2, KeepMonthly: 3, KeepTags: []string{"release"}}.Apply(reversed)
+	assert.Equal(t, ids(keep), ids(keep2))
+}
+
+func TestRepository_Prune(t *testing.T) {
+	r, err := InitRepository(filepath.Join(t.TempDir(), "repo"), RepositoryConfig{Parameters: Parameters{AverageSize: 16 * 1024}, PackSize: 256 * 1024})
+	require.NoError(t, err)
+	for i := 0; i < 3; i++ {
+		_, err := r.CreateSnapshot(fstest.MapFS{"file": {Data: testFile[int64(i)*MiB : int64(i+1)*MiB]}})
+		require.NoError(t, err)
+	}
+
+	report, err := r.Prune(RetentionPolicy{KeepLast: 1}, true)
+	require.NoError(t, err)
+	assert.Len(t, report.Keep, 1)
+	assert.Len(t, report.Remove, 2)
+	assert.Greater(t, report.GC.Garbage, int64(0))
+	assert.Zero(t, report.GC.Deleted)
+	snapshots, err := r.Snapshots()
+	require.NoError(t, err)
+	assert.Len(t, snapshots, 3)
+
+	report2, err := r.Prune(RetentionPolicy{KeepLast: 1}, false)
+	require.NoError(t, err)
+	assert.Equal(t, report.GC.Garbage, report2.GC.Deleted)
+	snapshots, err = r.Snapshots()
+	require.NoError(t, err)
+	require.Len(t, snapshots, 1)
+	assert.Equal(t, report.Keep[0].ID, snapshots[0].ID)
+	require.NoError(t, r.RestoreSnapshot(snapshots[0], t.TempDir()))
+}
+
+func TestRepository_PruneKeepsChunks(t *testing.T) {
+	// Every snapshot writes a small pack of its own, except for the last one, which has the same file as the one before.
+	// Repacking the pack of the kept snapshots along with those of the removed ones writes the same pack again.
+	dir := filepath.Join(t.TempDir(), "repo")
+	r, err := InitRepository(dir, RepositoryConfig{Parameters: Parameters{AverageSize: 4096}, PackSize: 256 * 1024})
+	require.NoError(t, err)
+	files := make(map[string][]byte)
+	for _, i := range []int64{0, 1, 2, 2} {
+		data := testFile[i*50000 : (i+1)*50000]
+		s, err := r.CreateSnapshot(fstest.MapFS{"file": {Data: data}})
+		require.NoError(t, err)
+		files[s.ID] = data
+	}
+
+	report, err := r.Prune(RetentionPolicy{KeepLast: 2}, false)
+	require.NoError(t, err)
+	assert.Len(t, report.Keep, 2)
+	assert.Greater(t, report.GC.Deleted, int64(0))
+	for _, open := range []bool{false, true} {
+		if open {
+			r, err = OpenRepository(dir)
+			require.NoError(t, err)
+		}
+		snapshots, err := r.Snapshots()
+		require.NoError(t, err)
+		require.Len(t, snapshots, 2)
+		for _, s := range snapshots {
+			out := t.TempDir()
+			require.NoError(t, r.RestoreSnapshot(s, out))
+			data, err := os.ReadFile(filepath.Join(out, "file"))
+			require.NoError(t, err)
+			assert.Equal(t, files[s.ID], data)
+		}
+	}
+}
@@ -0,0 +1,230 @@
+// Package s3store implements an ae.ChunkStore on top of the S3 API,
+// so that chunks can be pushed directly to Amazon S3 or any compatible object storage.
+package s3store
+
+import (
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	ae "github.com/mg98/ae-chunker-go"
+	"github.com/mg98/ae-chunker-go/internal/retry"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// Options configure a Store.
+type Options struct {
+	// Endpoint is the base URL of the service, e.g. "https://s3.eu-central-1.amazonaws.com".
+	Endpoint string
+
+	// Region the requests are signed for (optional). It defaults to "us-east-1".
+	Region string
+
+	// Bucket holding the chunks.
+	Bucket string
+
+	// Prefix prepended to the object keys (optional), e.g. "chunks/".
+	Prefix string
+
+	// AccessKeyID identifies the credentials (optional). Requests are anonymous if empty.
+	AccessKeyID string
+
+	// SecretAccessKey of the credentials (optional).
+	SecretAccessKey string
+
+	// SessionToken of temporary credentials (optional).
+	SessionToken string
+
+	// Concurrency bounds the number of requests in flight (optional). It defaults to 16.
+	Concurrency int
+
+	// Retries is the number of times a failed request is retried (optional). It defaults to 3.
+	// Only network errors, throttling and server errors are retried. A negative value disables retries.
+	Retries int
+
+	// Client sends the requests (optional). It defaults to http.DefaultClient.
+	Client *http.Client
+}
+
+// Error is an error response of the service.
+type Error struct {
+	StatusCode int
+	Code       string `xml:"Code"`
+	Message    string `xml:"Message"`
+}
+
+func (e *Error) Error() string {
+	if e.Code == "" {
+		return fmt.Sprintf("s3store: %s", http.StatusText(e.StatusCode))
+	}
+	return fmt.Sprintf("s3store: %s: %s", e.Code, e.Message)
+}
+
+// Store is an ae.ChunkStore keeping each chunk in an object named by the hex encoded hash.
+type Store struct {
+	endpoint *url.URL
+	bucket   string
+	prefix   string
+	signer   signer
+	client   *retry.Client
+}
+
+var _ ae.ChunkStore = (*Store)(nil)
+
+// New returns a Store for the bucket described by opts.
+func New(opts Options) (*Store, error) {
+	endpoint, err := url.Parse(opts.Endpoint)
+	if err != nil {
+		return nil, err
+	}
+	if endpoint.Scheme == "" || endpoint.Host == "" {
+		return nil, fmt.Errorf("s3store: invalid endpoint %q", opts.Endpoint)
+	}
+	if opts.Bucket == "" {
+		return nil, errors.New("s3store: missing bucket")
+	}
+	region := opts.Region
+	if region == "" {
+		region = "us-east-1"
+	}
+	concurrency := opts.Concurrency
+	if concurrency <= 0 {
+		concurrency = 16
+	}
+
+	return &Store{
+		endpoint: endpoint,
+		bucket:   opts.Bucket,
+		prefix:   opts.Prefix,
+		signer: signer{
+			accessKeyID:     opts.AccessKeyID,
+			secretAccessKey: opts.SecretAccessKey,
+			sessionToken:    opts.SessionToken,
+			region:          region,
+		},
+		client: retry.New(opts.Client, opts.Retries, concurrency),
+	}, nil
+}
+
+// Put uploads data under hash.
+func (s *Store) Put(hash []byte, data []byte) error {
+	_, err := s.do(http.MethodPut, hash, data)
+	return err
+}
+
+// Get downloads the data stored under hash.
+func (s *Store) Get(hash []byte) ([]byte, error) {
+	return s.do(http.MethodGet, hash, nil)
+}
+
+// Has reports whether a chunk is stored under hash.
+func (s *Store) Has(hash []byte) (bool, error) {
+	_, err := s.do(http.MethodHead, hash, nil)
+	if err == ae.ErrChunkNotFound {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// Delete removes the chunk stored under hash.
+// As S3 does not report deletions of missing objects, this takes an additional request to check for its existence.
+func (s *Store) Delete(hash []byte) error {
+	if _, err := s.do(http.MethodHead, hash, nil); err != nil {
+		return err
+	}
+	_, err := s.do(http.MethodDelete, hash, nil)
+	return err
+}
+
+// listResult is the response to a ListObjectsV2 request.
+type listResult struct {
+	Contents []struct {
+		Key  string `xml:"Key"`
+		Size int64  `xml:"Size"`
+	} `xml:"Contents"`
+	IsTruncated           bool   `xml:"IsTruncated"`
+	NextContinuationToken string `xml:"NextContinuationToken"`
+}
+
+// Walk calls fn for every chunk in the store. Objects under the prefix that are not named by a hash are skipped.
+func (s *Store) Walk(fn func(hash []byte, size int64) error) error {
+	var token string
+	for {
+		query := url.Values{"list-type": {"2"}, "prefix": {s.prefix}}
+		if token != "" {
+			query.Set("continuation-token", token)
+		}
+		u := s.bucketURL()
+		u.RawQuery = query.Encode()
+		data, err := s.request(http.MethodGet, u, nil)
+		if err != nil {
+			return err
+		}
+		var result listResult
+		if err := xml.Unmarshal(data, &result); err != nil {
+			return fmt.Errorf("s3store: invalid listing: %w", err)
+		}
+		for _, obj := range result.Contents {
+			hash, err := hex.DecodeString(strings.TrimPrefix(obj.Key, s.prefix))
+			if err != nil || len(hash) == 0 {
+				continue
+			}
+			if err := fn(hash, obj.Size); err != nil {
+				return err
+			}
+		}
+		if !result.IsTruncated || result.NextContinuationToken == "" {
+			return nil
+		}
+		token = result.NextContinuationToken
+	}
+}
+
+// do sends a request for the object of hash with an optional body and returns the response body.
+// A missing object is reported as ae.ErrChunkNotFound.
+func (s *Store) do(method string, hash []byte, body []byte) ([]byte, error) {
+	u := s.bucketURL()
+	u.Path += "/" + s.prefix + hex.EncodeToString(hash)
+	return s.request(method, u, body)
+}
+
+// bucketURL returns the URL of the bucket.
+func (s *Store) bucketURL() *url.URL {
+	u := *s.endpoint
+	u.Path = strings.TrimSuffix(u.Path, "/") + "/" + s.bucket
+	return &u
+}
+
+// request sends a request with an optional body to u, retrying on transient errors, and returns the response body.
+func (s *Store) request(method string, u *url.URL, body []byte) ([]byte, error) {
+	payloadHash := emptyHash
+	if body != nil {
+		payloadHash = hashHex(body)
+	}
+	resp, err := s.client.Do(func() (*http.Request, error) {
+		req, err := retry.NewRequest(method, u.String(), body)
+		if err != nil {
+			return nil, err
+		}
+		s.signer.sign(req, payloadHash, time.Now())
+		return req, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	if resp.StatusCode >= 300 {
+		e := &Error{StatusCode: resp.StatusCode}
+		xml.Unmarshal(resp.Body, e)
+		if e.StatusCode == http.StatusNotFound && e.Code != "NoSuchBucket" {
+			return nil, ae.ErrChunkNotFound
+		}
+		return nil, e
+	}
+	if method == http.MethodHead {
+		return nil, nil
+	}
+	return resp.Body, nil
+}
@@ -0,0 +1,171 @@
+package s3store
+
+import (
+	"fmt"
+	ae "github.com/mg98/ae-chunker-go"
+	"github.com/mg98/ae-chunker-go/internal/retry/retrytest"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// fakeS3 is a minimal in-memory implementation of the S3 object API.
+type fakeS3 struct {
+	*retrytest.Flaky
+
+	mu      sync.Mutex
+	objects map[string][]byte
+}
+
+func (f *fakeS3) serve(w http.ResponseWriter, r *http.Request) {
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=key/") {
+		w.WriteHeader(http.StatusForbidden)
+		io.WriteString(w, "<Error><Code>AccessDenied</Code><Message>Access Denied</Message></Error>")
+		return
+	}
+	if r.Method == http.MethodGet && r.URL.Query().Get("list-type") == "2" {
+		f.list(w, r)
+		return
+	}
+	switch r.Method {
+	case http.MethodPut:
+		data, _ := io.ReadAll(r.Body)
+		f.objects[r.URL.Path] = data
+	case http.MethodGet, http.MethodHead:
+		data, ok := f.objects[r.URL.Path]
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write(data)
+	case http.MethodDelete:
+		delete(f.objects, r.URL.Path)
+		w.WriteHeader(http.StatusNoContent)
+	}
+}
+
+// list answers a ListObjectsV2 request with pages of two objects.
+func (f *fakeS3) list(w http.ResponseWriter, r *http.Request) {
+	prefix := r.URL.Path + "/" + r.URL.Query().Get("prefix")
+	var keys []string
+	for k := range f.objects {
+		if strings.HasPrefix(k, prefix) && k > r.URL.Path+"/"+r.URL.Query().Get("continuation-token") {
+			keys = append(keys, k)
+		}
+	}
+	sort.Strings(keys)
+	io.WriteString(w, "<ListBucketResult>")
+	for i, k := range keys {
+		if i == 2 {
+			fmt.Fprintf(w, "<IsTruncated>true</IsTruncated><NextContinuationToken>%s</NextContinuationToken>",
+				strings.TrimPrefix(keys[i-1], r.URL.Path+"/"))
+			break
+		}
+		fmt.Fprintf(w, "<Contents><Key>%s</Key><Size>%d</Size></Contents>",
+			strings.TrimPrefix(k, r.URL.Path+"/"), len(f.objects[k]))
+	}
+	io.WriteString(w, "</ListBucketResult>")
+}
+
+func newTestStore(t *testing.T, opts Options) (*Store, *fakeS3) {
+	f := &fakeS3{objects: make(map[string][]byte)}
+	f.Flaky = &retrytest.Flaky{Handler: http.HandlerFunc(f.serve), Status: http.StatusServiceUnavailable,
+		Body: "<Error><Code>SlowDown</Code><Message>Please reduce your request rate.</Message></Error>"}
+	srv := httptest.NewServer(f)
+	t.Cleanup(srv.Close)
+	opts.Endpoint = srv.URL
+	opts.Bucket = "bucket"
+	if opts.AccessKeyID == "" {
+		opts.AccessKeyID = "key"
+		opts.SecretAccessKey = "secret"
+	}
+	s, err := New(opts)
+	assert.NoError(t, err)
+	s.client.Backoff = 0
+	return s, f
+}
+
+func TestStore(t *testing.T) {
+	s, f := newTestStore(t, Options{Prefix: "chunks/"})
+	hash := []byte{0xca, 0xfe}
+
+	ok, err := s.Has(hash)
+	assert.NoError(t, err)
+	assert.False(t, ok)
+	_, err = s.Get(hash)
+	assert.Equal(t, ae.ErrChunkNotFound, err)
+	assert.Equal(t, ae.ErrChunkNotFound, s.Delete(hash))
+
+	assert.NoError(t, s.Put(hash, []byte("hello world")))
+	assert.Equal(t, []byte("hello world"), f.objects["/bucket/chunks/cafe"])
+	ok, err = s.Has(hash)
+	assert.NoError(t, err)
+	assert.True(t, ok)
+	data, err := s.Get(hash)
+	assert.NoError(t, err)
+	assert.Equal(t, []byte("hello world"), data)
+
+	assert.NoError(t, s.Delete(hash))
+	assert.Empty(t, f.objects)
+
+	t.Run("empty chunk", func(t *testing.T) {
+		assert.NoError(t, s.Put(hash, []byte{}))
+		data, err := s.Get(hash)
+		assert.NoError(t, err)
+		assert.Empty(t, data)
+	})
+}
+
+func TestStore_Walk(t *testing.T) {
+	s, f := newTestStore(t, Options{Prefix: "chunks/"})
+	want := map[string]int64{}
+	for i := 0; i < 5; i++ {
+		hash := []byte{byte(i), 0xff}
+		assert.NoError(t, s.Put(hash, make([]byte, i)))
+		want[string(hash)] = int64(i)
+	}
+	f.objects["/bucket/chunks/README"] = []byte("foreign object")
+	f.objects["/bucket/other/abcd"] = []byte("outside of prefix")
+
+	got := map[string]int64{}
+	assert.NoError(t, s.Walk(func(hash []byte, size int64) error {
+		got[string(hash)] = size
+		return nil
+	}))
+	assert.Equal(t, want, got)
+}
+
+func TestStore_retries(t *testing.T) {
+	s, f := newTestStore(t, Options{Retries: 2})
+
+	f.Failures = 2
+	assert.NoError(t, s.Put([]byte{1}, []byte("foo")))
+	assert.Equal(t, 3, f.Requests)
+
+	f.Failures = 3
+	f.Requests = 0
+	_, err := s.Get([]byte{1})
+	assert.Equal(t, &Error{StatusCode: http.StatusServiceUnavailable, Code: "SlowDown", Message: "Please reduce your request rate."}, err)
+	assert.Equal(t, 3, f.Requests)
+
+	t.Run("client errors are not retried", func(t *testing.T) {
+		s, f := newTestStore(t, Options{AccessKeyID: "other", SecretAccessKey: "secret"})
+		err := s.Put([]byte{1}, []byte("foo"))
+		assert.Equal(t, &Error{StatusCode: http.StatusForbidden, Code: "AccessDenied", Message: "Access Denied"}, err)
+		assert.Equal(t, 1, f.Requests)
+	})
+}
+
+func TestNew(t *testing.T) {
+	_, err := New(Options{Endpoint: "localhost:9000", Bucket: "bucket"})
+	assert.Error(t, err)
+	_, err = New(Options{Endpoint: "http://localhost:9000"})
+	assert.Error(t, err)
+}
@@ -0,0 +1,75 @@
+package ae
+
+// scanLoopMax is the scalar extremum scan of AE_MAX over input, starting at minSize.
+// The scan is specialized per mode to keep the inner loop free of indirections.
+func scanLoopMax(input []byte, minSize, windowSize int) int {
+	markerPos := 0
+	marker := input[0]
+	for i := minSize; i < len(input); i++ {
+		if b := input[i]; b > marker {
+			markerPos, marker = i, b
+		} else if i == markerPos+windowSize {
+			return i
+		}
+	}
+	return len(input)
+}
+
+// scanLoopMin is the scalar extremum scan of AE_MIN over input, starting at minSize.
+func scanLoopMin(input []byte, minSize, windowSize int) int {
+	markerPos := 0
+	marker := input[0]
+	for i := minSize; i < len(input); i++ {
+		if b := input[i]; b < marker {
+			markerPos, marker = i, b
+		} else if i == markerPos+windowSize {
+			return i
+		}
+	}
+	return len(input)
+}
+
+// scanIndexed is equivalent to the scan loops, but delegates the search for the next extremum
+// within the window to index, which returns the position of the first byte in b that beats v, or -1.
+// This allows for vectorized implementations of index.
+// It relies on windowSize >= minSize, which holds for all parameters derived by newParams.
+func scanIndexed(input []byte, minSize, windowSize int, index func(b []byte, v byte) int) int {
+	markerPos := 0
+	marker := input[0]
+	for i := minSize; ; {
+		end := markerPos + windowSize + 1
+		if end > len(input) {
+			end = len(input)
+		}
+		k := index(input[i:end], marker)
+		if k < 0 {
+			if markerPos+windowSize < len(input) {
+				return markerPos + windowSize
+			}
+			return len(input)
+		}
+		markerPos = i + k
+		marker = input[markerPos]
+		i = markerPos + 1
+	}
+}
+
+// indexAboveGeneric returns the index of the first byte in b greater than v, or -1.
+func indexAboveGeneric(b []byte, v byte) int {
+	for i, c := range b {
+		if c > v {
+			return i
+		}
+	}
+	return -1
+}
+
+// indexBelowGeneric returns the index of the first byte in b less than v, or -1.
+func indexBelowGeneric(b []byte, v byte) int {
+	for i, c := range b {
+		if c < v {
+			return i
+		}
+	}
+	return -1
+}
@@ -0,0 +1,23 @@
+//go:build arm64 && !purego
+
+package ae
+
+// indexAboveNEON returns the index of the first byte in b greater than v, or -1.
+// It compares 16 bytes at a time using NEON instructions.
+//
+//go:noescape
+func indexAboveNEON(b []byte, v byte) int
+
+// indexBelowNEON returns the index of the first byte in b less than v, or -1.
+// It compares 16 bytes at a time using NEON instructions.
+//
+//go:noescape
+func indexBelowNEON(b []byte, v byte) int
+
+func scanMax(input []byte, minSize, windowSize int) int {
+	return scanIndexed(input, minSize, windowSize, indexAboveNEON)
+}
+
+func scanMin(input []byte, minSize, windowSize int) int {
+	return scanIndexed(input, minSize, windowSize, indexBelowNEON)
+}
@@ -0,0 +1,89 @@
+//go:build arm64 && !purego
+
+#include "textflag.h"
+
+// func indexAboveNEON(b []byte, v byte) int
+TEXT ·indexAboveNEON(SB), NOSPLIT, $0-40
+	MOVD  b_base+0(FP), R0
+	MOVD  b_len+8(FP), R1
+	MOVBU v+24(FP), R2
+	MOVD  R0, R3
+	VDUP  R2, V0.B16
+
+above_loop:
+	CMP   $16, R1
+	BLT   above_tail
+	VLD1  (R3), [V1.B16]
+	// Lanes not exceeding v are all ones after max(v, b) == v.
+	VUMAX V1.B16, V0.B16, V2.B16
+	VCMEQ V0.B16, V2.B16, V3.B16
+	VMOV  V3.D[0], R4
+	VMOV  V3.D[1], R5
+	AND   R4, R5, R6
+	CMN   $1, R6
+	BNE   above_tail
+	ADD   $16, R3
+	SUB   $16, R1
+	B     above_loop
+
+above_tail:
+	CBZ   R1, above_none
+	MOVBU.P 1(R3), R4
+	CMP   R2, R4
+	BHI   above_hit
+	SUB   $1, R1
+	B     above_tail
+
+above_hit:
+	SUB   $1, R3
+	SUB   R0, R3, R3
+	MOVD  R3, ret+32(FP)
+	RET
+
+above_none:
+	MOVD  $-1, R4
+	MOVD  R4, ret+32(FP)
+	RET
+
+// func indexBelowNEON(b []byte, v byte) int
+TEXT ·indexBelowNEON(SB), NOSPLIT, $0-40
+	MOVD  b_base+0(FP), R0
+	MOVD  b_len+8(FP), R1
+	MOVBU v+24(FP), R2
+	MOVD  R0, R3
+	VDUP  R2, V0.B16
+
+below_loop:
+	CMP   $16, R1
+	BLT   below_tail
+	VLD1  (R3), [V1.B16]
+	// Lanes not falling below v are all ones after min(v, b) == v.
+	VUMIN V1.B16, V0.B16, V2.B16
+	VCMEQ V0.B16, V2.B16, V3.B16
+	VMOV  V3.D[0], R4
+	VMOV  V3.D[1], R5
+	AND   R4, R5, R6
+	CMN   $1, R6
+	BNE   below_tail
+	ADD   $16, R3
+	SUB   $16, R1
+	B     below_loop
+
+below_tail:
+	CBZ   R1, below_none
+	MOVBU.P 1(R3), R4
+	CMP   R2, R4
+	BLO   below_hit
+	SUB   $1, R1
+	B     below_tail
+
+below_hit:
+	SUB   $1, R3
+	SUB   R0, R3, R3
+	MOVD  R3, ret+32(FP)
+	RET
+
+below_none:
+	MOVD  $-1, R4
+	MOVD  R4, ret+32(FP)
+	RET
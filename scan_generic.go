@@ -0,0 +1,11 @@
+//go:build !arm64 || purego
+
+package ae
+
+func scanMax(input []byte, minSize, windowSize int) int {
+	return scanLoopMax(input, minSize, windowSize)
+}
+
+func scanMin(input []byte, minSize, windowSize int) int {
+	return scanLoopMin(input, minSize, windowSize)
+}
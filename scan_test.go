@@ -0,0 +1,39 @@
+package ae
+
+import (
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestScan(t *testing.T) {
+	inputs := map[string][]byte{
+		"random":     testFile[:4*MiB],
+		"zeros":      make([]byte, 64*1024),
+		"increasing": make([]byte, 64*1024),
+	}
+	for i := range inputs["increasing"] {
+		inputs["increasing"][i] = byte(i / 256)
+	}
+
+	for name, input := range inputs {
+		for _, avgSize := range []int64{1, 2, 6, 100, 1000, 8 * 1024} {
+			p := newParams(&Options{AverageSize: avgSize})
+			minSize, w := int(p.minSize), int(p.windowSize)
+
+			t.Run(name, func(t *testing.T) {
+				for off := 0; off+int(p.maxSize) <= len(input); {
+					in := input[off : off+int(p.maxSize)]
+					expected := scanLoopMax(in, minSize, w)
+					assert.Equal(t, expected, scanIndexed(in, minSize, w, indexAboveGeneric))
+					assert.Equal(t, expected, scanMax(in, minSize, w))
+
+					expected = scanLoopMin(in, minSize, w)
+					assert.Equal(t, expected, scanIndexed(in, minSize, w, indexBelowGeneric))
+					assert.Equal(t, expected, scanMin(in, minSize, w))
+
+					off += expected + 1
+				}
+			})
+		}
+	}
+}
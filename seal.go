@@ -0,0 +1,225 @@
+package ae
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"sync"
+)
+
+// ErrUnknownKey is returned by a SealedStore for chunks sealed with a master key missing from its Keyring.
+var ErrUnknownKey = errors.New("ae: chunk is sealed with an unknown key")
+
+const (
+	// sealVersion is the version of the format of sealed chunks.
+	sealVersion = 1
+
+	// sealSaltSize is the size of the random salt the key of a sealed chunk is derived with.
+	sealSaltSize = 16
+
+	// sealNonceSize is the size of the nonce of AES-GCM.
+	sealNonceSize = 12
+
+	// sealHeaderSize is the size of the header of a sealed chunk: version, key ID, salt and nonce.
+	sealHeaderSize = 1 + 4 + sealSaltSize + sealNonceSize
+)
+
+// Keyring holds the master keys of a SealedStore, each identified by an ID recorded in the chunks it sealed.
+// New chunks are sealed with the current key; the others are kept to open the chunks sealed before a rotation.
+// It is safe for concurrent use.
+type Keyring struct {
+	mu      sync.RWMutex
+	keys    map[uint32][]byte
+	current uint32
+}
+
+// NewKeyring returns a Keyring with the 32-byte master key as its current key under the given ID.
+func NewKeyring(id uint32, key []byte) (*Keyring, error) {
+	k := &Keyring{keys: make(map[uint32][]byte)}
+	if err := k.Rotate(id, key); err != nil {
+		return nil, err
+	}
+	return k, nil
+}
+
+// Add adds a 32-byte master key under the given ID without making it current, e.g. a retired key
+// that chunks in the store may still be sealed with. Adding a different key under an existing ID fails.
+func (k *Keyring) Add(id uint32, key []byte) error {
+	if len(key) != 32 {
+		return fmt.Errorf("ae: master key must be 32 bytes, got %d", len(key))
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	if old, ok := k.keys[id]; ok && !hmac.Equal(old, key) {
+		return fmt.Errorf("ae: master key %d exists already", id)
+	}
+	k.keys[id] = append([]byte(nil), key...)
+	return nil
+}
+
+// Rotate adds a 32-byte master key under the given ID and makes it current, so that new chunks are sealed with it.
+// Chunks sealed before remain readable as long as their keys are kept; Rekey seals them with the new key.
+func (k *Keyring) Rotate(id uint32, key []byte) error {
+	if err := k.Add(id, key); err != nil {
+		return err
+	}
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.current = id
+	return nil
+}
+
+// Current returns the ID of the current master key.
+func (k *Keyring) Current() uint32 {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	return k.current
+}
+
+// key returns the master key with the given ID.
+func (k *Keyring) key(id uint32) ([]byte, bool) {
+	k.mu.RLock()
+	defer k.mu.RUnlock()
+	key, ok := k.keys[id]
+	return key, ok
+}
+
+// SealedStore is a ChunkStore that encrypts chunks with AES-256-GCM before they are written to an underlying store,
+// so that repositories can be kept on untrusted storage.
+//
+// Unlike the convergent EncryptedStore, it derives a fresh key for every chunk written from a master key
+// of its Keyring and a random salt, and encrypts with a random nonce, so that nothing about a chunk can be learned
+// from its ciphertext, even by someone who knows the data. Every sealed chunk starts with a header of
+// the format version, the ID of the master key, the salt and the nonce. The header and the hash of the chunk
+// are authenticated along with the data, so that chunks can neither be tampered with nor swapped.
+//
+// Chunks are stored under their hashes, which the storage thus learns. Keyed identities, see HMAC and KeyedBLAKE3,
+// keep the hashes from revealing the data.
+type SealedStore struct {
+	store ChunkStore
+	keys  *Keyring
+}
+
+// NewSealedStore returns a SealedStore writing to s with the master keys of keys.
+func NewSealedStore(s ChunkStore, keys *Keyring) *SealedStore {
+	return &SealedStore{store: s, keys: keys}
+}
+
+// Put seals data with the current master key and stores it in the underlying store.
+func (s *SealedStore) Put(hash []byte, data []byte) error {
+	sealed, err := s.seal(hash, data)
+	if err != nil {
+		return err
+	}
+	return s.store.Put(hash, sealed)
+}
+
+// Get fetches the chunk stored under hash from the underlying store and opens it.
+func (s *SealedStore) Get(hash []byte) ([]byte, error) {
+	sealed, err := s.store.Get(hash)
+	if err != nil {
+		return nil, err
+	}
+	data, _, err := s.open(hash, sealed)
+	return data, err
+}
+
+// Has reports whether a chunk is stored under hash.
+func (s *SealedStore) Has(hash []byte) (bool, error) {
+	return s.store.Has(hash)
+}
+
+// Delete removes the chunk stored under hash.
+func (s *SealedStore) Delete(hash []byte) error {
+	return s.store.Delete(hash)
+}
+
+// Walk calls fn for every chunk in the underlying store with the size of its sealed data.
+func (s *SealedStore) Walk(fn func(hash []byte, size int64) error) error {
+	return walkStore(s.store, fn)
+}
+
+// Rekey seals the chunk stored under hash with the current master key, unless it is sealed with it already,
+// and reports whether it did. Once every chunk is rekeyed after a rotation, the previous key can be dropped.
+// The chunk is deleted before it is stored again, so it is lost should the underlying store fail in between.
+func (s *SealedStore) Rekey(hash []byte) (bool, error) {
+	sealed, err := s.store.Get(hash)
+	if err != nil {
+		return false, err
+	}
+	data, id, err := s.open(hash, sealed)
+	if err != nil {
+		return false, err
+	}
+	if id == s.keys.Current() {
+		return false, nil
+	}
+	if sealed, err = s.seal(hash, data); err != nil {
+		return false, err
+	}
+	if err := s.store.Delete(hash); err != nil {
+		return false, err
+	}
+	return true, s.store.Put(hash, sealed)
+}
+
+// seal encrypts the chunk with the given hash and data under a key derived from the current master key.
+func (s *SealedStore) seal(hash []byte, data []byte) ([]byte, error) {
+	id := s.keys.Current()
+	header := make([]byte, sealHeaderSize)
+	header[0] = sealVersion
+	binary.BigEndian.PutUint32(header[1:5], id)
+	if _, err := rand.Read(header[5:]); err != nil {
+		return nil, err
+	}
+	aead, err := s.aead(header)
+	if err != nil {
+		return nil, err
+	}
+	return aead.Seal(header, header[sealHeaderSize-sealNonceSize:], data, sealedAD(header, hash)), nil
+}
+
+// open decrypts a sealed chunk with the given hash and returns its data and the ID of the master key it was sealed with.
+func (s *SealedStore) open(hash []byte, sealed []byte) ([]byte, uint32, error) {
+	if len(sealed) < sealHeaderSize || sealed[0] != sealVersion {
+		return nil, 0, ErrCorruptChunk
+	}
+	header := sealed[:sealHeaderSize]
+	aead, err := s.aead(header)
+	if err != nil {
+		return nil, 0, err
+	}
+	data, err := aead.Open(nil, header[sealHeaderSize-sealNonceSize:], sealed[sealHeaderSize:], sealedAD(header, hash))
+	if err != nil {
+		return nil, 0, ErrCorruptChunk
+	}
+	return data, binary.BigEndian.Uint32(header[1:5]), nil
+}
+
+// aead returns the cipher of the chunk with the given header, keyed with the key derived from the master key
+// and salt in the header.
+func (s *SealedStore) aead(header []byte) (cipher.AEAD, error) {
+	master, ok := s.keys.key(binary.BigEndian.Uint32(header[1:5]))
+	if !ok {
+		return nil, ErrUnknownKey
+	}
+	mac := hmac.New(sha256.New, master)
+	mac.Write([]byte("chunk key"))
+	mac.Write([]byte{0})
+	mac.Write(header[5 : 5+sealSaltSize])
+	block, err := aes.NewCipher(mac.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// sealedAD returns the additional data authenticated with a sealed chunk, i.e. its header and hash.
+func sealedAD(header, hash []byte) []byte {
+	return append(append([]byte{}, header...), hash...)
+}
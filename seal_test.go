@@ -0,0 +1,100 @@
+package ae
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestKeyring(t *testing.T) {
+	_, err := NewKeyring(1, make([]byte, 16))
+	assert.Error(t, err)
+
+	k, err := NewKeyring(1, bytes.Repeat([]byte{1}, 32))
+	require.NoError(t, err)
+	assert.NoError(t, k.Add(1, bytes.Repeat([]byte{1}, 32)))
+	assert.Error(t, k.Add(1, bytes.Repeat([]byte{2}, 32)))
+	assert.NoError(t, k.Add(0, bytes.Repeat([]byte{2}, 32)))
+	assert.Equal(t, uint32(1), k.Current())
+	assert.NoError(t, k.Rotate(2, bytes.Repeat([]byte{3}, 32)))
+	assert.Equal(t, uint32(2), k.Current())
+}
+
+func TestSealedStore(t *testing.T) {
+	keys, err := NewKeyring(1, bytes.Repeat([]byte{1}, 32))
+	require.NoError(t, err)
+	testChunkStore(t, NewSealedStore(NewMemoryStore(0), keys))
+
+	data := testFile[:64*1024]
+	sum := sha256.Sum256(data)
+	stored := func(s *MemoryStore, hash []byte) []byte {
+		return s.entries[string(hash)].Value.(*memEntry).data
+	}
+
+	t.Run("confidential", func(t *testing.T) {
+		a, b := NewMemoryStore(0), NewMemoryStore(0)
+		assert.NoError(t, NewSealedStore(a, keys).Put(sum[:], data))
+		assert.NoError(t, NewSealedStore(b, keys).Put(sum[:], data))
+		assert.False(t, bytes.Contains(stored(a, sum[:]), data[:32]))
+		assert.Len(t, stored(a, sum[:]), len(data)+sealHeaderSize+16)
+		// The same data seals differently every time.
+		assert.NotEqual(t, stored(a, sum[:]), stored(b, sum[:]))
+	})
+
+	t.Run("tampered", func(t *testing.T) {
+		backend := NewMemoryStore(0)
+		s := NewSealedStore(backend, keys)
+		assert.NoError(t, s.Put(sum[:], data))
+		// Every byte is authenticated, including the header.
+		for _, i := range []int{0, 2, 5, sealHeaderSize - 1, sealHeaderSize, len(data)} {
+			stored(backend, sum[:])[i]++
+			_, err := s.Get(sum[:])
+			assert.Error(t, err, i)
+			stored(backend, sum[:])[i]--
+		}
+
+		// A chunk stored under the hash of another one does not open.
+		other := sha256.Sum256(nil)
+		assert.NoError(t, backend.Put(other[:], stored(backend, sum[:])))
+		_, err := s.Get(other[:])
+		assert.Equal(t, ErrCorruptChunk, err)
+	})
+
+	t.Run("rotation", func(t *testing.T) {
+		keys, err := NewKeyring(1, bytes.Repeat([]byte{1}, 32))
+		require.NoError(t, err)
+		backend := NewMemoryStore(0)
+		s := NewSealedStore(backend, keys)
+		input := testFile[:MiB]
+		m, err := StoreFile(bytes.NewReader(input), s, &Options{AverageSize: 64 * 1024})
+		require.NoError(t, err)
+
+		require.NoError(t, keys.Rotate(2, bytes.Repeat([]byte{2}, 32)))
+		var out bytes.Buffer
+		require.NoError(t, Reassemble(&out, m, s))
+		assert.Equal(t, input, out.Bytes())
+
+		for _, ref := range m.Chunks {
+			ok, err := s.Rekey(ref.Hash)
+			require.NoError(t, err)
+			assert.True(t, ok)
+		}
+		ok, err := s.Rekey(m.Chunks[0].Hash)
+		require.NoError(t, err)
+		assert.False(t, ok)
+
+		// Without the retired key, the rekeyed chunks are still readable.
+		retired, err := NewKeyring(2, bytes.Repeat([]byte{2}, 32))
+		require.NoError(t, err)
+		out.Reset()
+		require.NoError(t, Reassemble(&out, m, NewSealedStore(backend, retired)))
+		assert.Equal(t, input, out.Bytes())
+
+		other, err := NewKeyring(3, bytes.Repeat([]byte{3}, 32))
+		require.NoError(t, err)
+		_, err = NewSealedStore(backend, other).Get(m.Chunks[0].Hash)
+		assert.Equal(t, ErrUnknownKey, err)
+	})
+}
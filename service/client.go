@@ -0,0 +1,268 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	ae "github.com/mg98/ae-chunker-go"
+	"github.com/mg98/ae-chunker-go/aepb"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"io"
+	"time"
+)
+
+// Client calls the Chunker service with the types of package ae.
+type Client struct {
+	c ChunkerClient
+
+	// Retries is the number of times Push resumes an interrupted upload (optional). It defaults to 3.
+	// A negative value disables resuming.
+	Retries int
+}
+
+// NewClient returns a Client calling the service over cc.
+func NewClient(cc grpc.ClientConnInterface) *Client {
+	return &Client{c: NewChunkerClient(cc)}
+}
+
+// Chunk streams the data of r to the server and calls fn for every chunk in order.
+// Chunking stops at the first error of r, fn or the call.
+func (c *Client) Chunk(ctx context.Context, r io.Reader, p ae.Parameters, fn func(c *ae.Chunk) error) error {
+	return c.chunk(ctx, r, &ChunkRequest{Parameters: aepb.FromParameters(p)}, fn)
+}
+
+// Store streams the data of r to the server, which keeps the chunks in its store,
+// and returns the manifest of the data. The chunks are not sent back, and p must select a hash.
+func (c *Client) Store(ctx context.Context, r io.Reader, p ae.Parameters) (*ae.Manifest, error) {
+	m := &ae.Manifest{Parameters: p}
+	counter := &countingReader{r: r}
+	req := &ChunkRequest{Parameters: aepb.FromParameters(p), Store: true, OmitData: true}
+	err := c.chunk(ctx, counter, req, func(ch *ae.Chunk) error {
+		// Without the data, the length of a chunk follows from the offset of the next one.
+		if n := len(m.Chunks); n > 0 {
+			m.Chunks[n-1].Length = ch.Offset - m.Chunks[n-1].Offset
+		}
+		m.Chunks = append(m.Chunks, ae.ChunkRef{Offset: ch.Offset, Hash: ch.Sum})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	m.Size = counter.n
+	if n := len(m.Chunks); n > 0 {
+		m.Chunks[n-1].Length = m.Size - m.Chunks[n-1].Offset
+	}
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Reassemble writes the file described by m to w, streaming it from the chunks stored on the server.
+func (c *Client) Reassemble(ctx context.Context, m *ae.Manifest, w io.Writer) error {
+	stream, err := c.c.Reassemble(ctx, &ReassembleRequest{Manifest: aepb.FromManifest(m)})
+	if err != nil {
+		return err
+	}
+	for {
+		resp, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if _, err := w.Write(resp.GetData()); err != nil {
+			return err
+		}
+	}
+}
+
+// PushReport is the result of Client.Push.
+type PushReport struct {
+	// Chunks is the number of distinct chunks of the manifest.
+	Chunks int
+
+	// Missing is the number of chunks the server did not hold at first, which were uploaded.
+	Missing int
+
+	// Resumed is the number of times the upload was resumed after an interruption.
+	Resumed int
+}
+
+// maxMissingDigests bounds the number of digests in a MissingRequest, keeping it below maxMessageSize.
+const maxMissingDigests = 16 << 10
+
+// Push uploads the chunks of m that the server does not hold, reading them from src, e.g. to replicate a backup.
+// If the upload is interrupted because the server is unavailable, Push asks the server again which chunks are missing
+// and resumes, so the chunks uploaded already are not sent again.
+func (c *Client) Push(ctx context.Context, m *ae.Manifest, src ae.ChunkStore) (*PushReport, error) {
+	if m.Parameters.Hash == "" {
+		return nil, errors.New("service: pushing chunks requires a hash")
+	}
+	var digests [][]byte
+	seen := make(map[string]bool, len(m.Chunks))
+	for _, ref := range m.Chunks {
+		if !seen[string(ref.Hash)] {
+			seen[string(ref.Hash)] = true
+			digests = append(digests, ref.Hash)
+		}
+	}
+	report := &PushReport{Chunks: len(digests)}
+	retries := c.Retries
+	if retries == 0 {
+		retries = 3
+	}
+	backoff := 100 * time.Millisecond
+	for attempt := 0; ; attempt++ {
+		missing, err := c.push(ctx, m.Parameters.Hash, digests, src)
+		if attempt == 0 {
+			report.Missing = missing
+		}
+		if err == nil || attempt >= retries || status.Code(err) != codes.Unavailable {
+			return report, err
+		}
+		report.Resumed++
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return report, ctx.Err()
+		}
+		backoff *= 2
+	}
+}
+
+// push asks the server which of the chunks with the given digests are missing and uploads those.
+// It returns the number of missing chunks.
+func (c *Client) push(ctx context.Context, hash string, digests [][]byte, src ae.ChunkStore) (int, error) {
+	var missing [][]byte
+	for len(digests) > 0 {
+		n := len(digests)
+		if n > maxMissingDigests {
+			n = maxMissingDigests
+		}
+		resp, err := c.c.Missing(ctx, &MissingRequest{Digests: digests[:n]})
+		if err != nil {
+			return 0, err
+		}
+		missing = append(missing, resp.GetDigests()...)
+		digests = digests[n:]
+	}
+	if len(missing) == 0 {
+		return 0, nil
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := c.c.Upload(ctx)
+	if err != nil {
+		return len(missing), err
+	}
+	for i, digest := range missing {
+		data, err := src.Get(digest)
+		if err != nil {
+			return len(missing), fmt.Errorf("service: reading chunk %x: %w", digest, err)
+		}
+		// Chunks larger than a message are split, the digest being sent with the first piece only.
+		req := &UploadRequest{Digest: digest}
+		if i == 0 {
+			req.Hash = hash
+		}
+		for first := true; first || len(data) > 0; first = false {
+			n := len(data)
+			if n > maxMessageSize {
+				n = maxMessageSize
+			}
+			req.Data = data[:n]
+			if err := stream.Send(req); err != nil {
+				// The cause of the failure is returned by CloseAndRecv.
+				_, err = stream.CloseAndRecv()
+				return len(missing), err
+			}
+			data = data[n:]
+			req = &UploadRequest{}
+		}
+	}
+	_, err = stream.CloseAndRecv()
+	return len(missing), err
+}
+
+// chunk calls the Chunk RPC with the data of r, sending first as the first request.
+// Requests are sent concurrently with receiving the chunks, so that the call does not stall on flow control.
+func (c *Client) chunk(ctx context.Context, r io.Reader, first *ChunkRequest, fn func(c *ae.Chunk) error) error {
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+	stream, err := c.c.Chunk(ctx)
+	if err != nil {
+		return err
+	}
+
+	readErr := make(chan error, 1)
+	go func() {
+		readErr <- send(stream, r, first, cancel)
+	}()
+
+	err = receive(stream, fn)
+	cancel()
+	if err := <-readErr; err != nil {
+		return err
+	}
+	return err
+}
+
+// send sends the data of r in requests of at most maxMessageSize bytes, starting with first.
+// If reading from r fails, it aborts the call with cancel and returns the error.
+// Errors of the stream are left to be returned by receive.
+func send(stream Chunker_ChunkClient, r io.Reader, first *ChunkRequest, cancel func()) error {
+	req := first
+	for {
+		// Sent messages must not be modified, so every request gets its own buffer.
+		buf := make([]byte, maxMessageSize)
+		n, err := io.ReadFull(r, buf)
+		if n > 0 || req == first {
+			req.Data = buf[:n]
+			if stream.Send(req) != nil {
+				return nil
+			}
+			req = &ChunkRequest{}
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			stream.CloseSend()
+			return nil
+		}
+		if err != nil {
+			cancel()
+			return err
+		}
+	}
+}
+
+// receive calls fn for every chunk received from stream until the end of the stream.
+func receive(stream Chunker_ChunkClient, fn func(c *ae.Chunk) error) error {
+	for {
+		x, err := stream.Recv()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := fn(x.ToChunk()); err != nil {
+			return err
+		}
+	}
+}
+
+// countingReader counts the bytes read from r.
+type countingReader struct {
+	r io.Reader
+	n int64
+}
+
+func (r *countingReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += int64(n)
+	return n, err
+}
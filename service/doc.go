@@ -0,0 +1,12 @@
+// Package service exposes the chunker as a gRPC service (see service.proto),
+// so that systems written in other languages can use it as a sidecar.
+//
+// The Chunk RPC splits the data streamed by the client into chunks and optionally keeps them
+// in the ChunkStore of the server; the Reassemble RPC streams a file back from its manifest.
+// The Missing and Upload RPCs transfer chunks between stores: the client learns which chunks the server lacks
+// and uploads only those. As the server stores every chunk as soon as it is complete, an interrupted upload
+// is resumed by asking again, which Client.Push does.
+// Server implements the service, and Client wraps the generated client in the types of package ae.
+package service
+
+//go:generate protoc -I .. --go_out=.. --go_opt=paths=source_relative --go-grpc_out=.. --go-grpc_opt=paths=source_relative service/service.proto
@@ -0,0 +1,221 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	ae "github.com/mg98/ae-chunker-go"
+	"github.com/mg98/ae-chunker-go/aepb"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/status"
+	"hash"
+	"io"
+)
+
+// maxMessageSize bounds the data carried by a single message, well below the default limit of gRPC of 4 MiB.
+const maxMessageSize = 1 << 20
+
+// Server implements the Chunker service. Register it with RegisterChunkerServer.
+type Server struct {
+	UnimplementedChunkerServer
+
+	// store keeps the chunks for reassembly (optional).
+	store ae.ChunkStore
+}
+
+// NewServer returns a Server keeping chunks in store.
+// If store is nil, chunks cannot be stored or reassembled.
+func NewServer(store ae.ChunkStore) *Server {
+	return &Server{store: store}
+}
+
+// Chunk splits the data of the request stream into chunks and sends them back in order.
+// Chunks are sent with their data unless omitted, so chunks larger than the message size limit
+// of the client require the limit to be raised.
+func (s *Server) Chunk(stream Chunker_ChunkServer) error {
+	req, err := stream.Recv()
+	if err == io.EOF {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	p := req.GetParameters().ToParameters()
+	if p.Hash != "" {
+		if _, err := ae.LookupHash(p.Hash); err != nil {
+			return status.Error(codes.InvalidArgument, err.Error())
+		}
+	}
+	store := req.GetStore()
+	if store && s.store == nil {
+		return status.Error(codes.FailedPrecondition, "server has no chunk store")
+	}
+	if store && p.Hash == "" {
+		return status.Error(codes.InvalidArgument, "storing chunks requires a hash")
+	}
+
+	ch := ae.NewChunker(&requestReader{stream: stream, data: req.GetData()}, p.Options())
+	for {
+		c, err := ch.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if store {
+			if err := s.store.Put(c.Sum, c.Data); err != nil {
+				return err
+			}
+		}
+		x := aepb.FromChunk(c)
+		if req.GetOmitData() {
+			x.Data = nil
+		}
+		if err := stream.Send(x); err != nil {
+			return err
+		}
+		// The data may still be referenced by the sent message, so it is only released if omitted.
+		if req.GetOmitData() {
+			ae.ReleaseChunk(c)
+		}
+	}
+}
+
+// Reassemble streams the file described by the manifest of the request, verifying every chunk.
+func (s *Server) Reassemble(req *ReassembleRequest, stream Chunker_ReassembleServer) error {
+	if s.store == nil {
+		return status.Error(codes.FailedPrecondition, "server has no chunk store")
+	}
+	if req.GetManifest() == nil {
+		return status.Error(codes.InvalidArgument, "missing manifest")
+	}
+	m, err := req.GetManifest().ToManifest()
+	if err != nil {
+		return status.Error(codes.InvalidArgument, err.Error())
+	}
+	err = ae.Reassemble(&responseWriter{stream: stream}, m, s.store)
+	switch {
+	case errors.Is(err, ae.ErrChunkNotFound):
+		return status.Error(codes.NotFound, err.Error())
+	case errors.Is(err, ae.ErrCorruptChunk):
+		return status.Error(codes.DataLoss, err.Error())
+	}
+	return err
+}
+
+// Missing returns the digests of the request that the store does not hold.
+func (s *Server) Missing(ctx context.Context, req *MissingRequest) (*MissingResponse, error) {
+	if s.store == nil {
+		return nil, status.Error(codes.FailedPrecondition, "server has no chunk store")
+	}
+	resp := &MissingResponse{}
+	for _, digest := range req.GetDigests() {
+		ok, err := s.store.Has(digest)
+		if err != nil {
+			return nil, err
+		}
+		if !ok {
+			resp.Digests = append(resp.Digests, digest)
+		}
+	}
+	return resp, nil
+}
+
+// Upload stores the chunks of the request stream, each as soon as it is complete.
+// Chunks whose data does not match their digest are rejected with DataLoss.
+func (s *Server) Upload(stream Chunker_UploadServer) error {
+	if s.store == nil {
+		return status.Error(codes.FailedPrecondition, "server has no chunk store")
+	}
+	resp := &UploadResponse{}
+	var h hash.Hash
+	var digest, data []byte
+	put := func() error {
+		if digest == nil {
+			return nil
+		}
+		h.Reset()
+		h.Write(data)
+		if !bytes.Equal(h.Sum(nil), digest) {
+			return status.Errorf(codes.DataLoss, "chunk %x does not match its digest", digest)
+		}
+		if err := s.store.Put(digest, data); err != nil {
+			return err
+		}
+		resp.Chunks++
+		resp.Bytes += uint64(len(data))
+		return nil
+	}
+	for {
+		req, err := stream.Recv()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return err
+		}
+		if h == nil {
+			newHash, err := ae.LookupHash(req.GetHash())
+			if err != nil {
+				return status.Error(codes.InvalidArgument, err.Error())
+			}
+			h = newHash()
+		}
+		if len(req.GetDigest()) == 0 {
+			if digest == nil {
+				return status.Error(codes.InvalidArgument, "missing digest")
+			}
+			data = append(data, req.GetData()...)
+			continue
+		}
+		if err := put(); err != nil {
+			return err
+		}
+		digest, data = req.GetDigest(), append([]byte(nil), req.GetData()...)
+	}
+	if err := put(); err != nil {
+		return err
+	}
+	return stream.SendAndClose(resp)
+}
+
+// requestReader reads the data of a stream of ChunkRequests.
+type requestReader struct {
+	stream Chunker_ChunkServer
+
+	// data of the current request that has not been read yet.
+	data []byte
+}
+
+func (r *requestReader) Read(p []byte) (int, error) {
+	for len(r.data) == 0 {
+		req, err := r.stream.Recv()
+		if err != nil {
+			return 0, err
+		}
+		r.data = req.GetData()
+	}
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+// responseWriter writes data as a stream of ReassembleResponses of at most maxMessageSize bytes.
+// The written data must not be modified afterwards.
+type responseWriter struct {
+	stream Chunker_ReassembleServer
+}
+
+func (w *responseWriter) Write(p []byte) (int, error) {
+	for n := 0; n < len(p); n += maxMessageSize {
+		end := n + maxMessageSize
+		if end > len(p) {
+			end = len(p)
+		}
+		if err := w.stream.Send(&ReassembleResponse{Data: p[n:end]}); err != nil {
+			return n, err
+		}
+	}
+	return len(p), nil
+}
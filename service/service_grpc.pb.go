@@ -0,0 +1,329 @@
+// Code generated by protoc-gen-go-grpc. DO NOT EDIT.
+// versions:
+// - protoc-gen-go-grpc v1.3.0
+// - protoc             (unknown)
+// source: service/service.proto
+
+// Package ae.service.v1 exposes the asymmetric extremum chunker as a gRPC service.
+
+package service
+
+import (
+	context "context"
+	aepb "github.com/mg98/ae-chunker-go/aepb"
+	grpc "google.golang.org/grpc"
+	codes "google.golang.org/grpc/codes"
+	status "google.golang.org/grpc/status"
+)
+
+// This is a compile-time assertion to ensure that this generated file
+// is compatible with the grpc package it is being compiled against.
+// Requires gRPC-Go v1.32.0 or later.
+const _ = grpc.SupportPackageIsVersion7
+
+const (
+	Chunker_Chunk_FullMethodName      = "/ae.service.v1.Chunker/Chunk"
+	Chunker_Reassemble_FullMethodName = "/ae.service.v1.Chunker/Reassemble"
+	Chunker_Missing_FullMethodName    = "/ae.service.v1.Chunker/Missing"
+	Chunker_Upload_FullMethodName     = "/ae.service.v1.Chunker/Upload"
+)
+
+// ChunkerClient is the client API for Chunker service.
+//
+// For semantics around ctx use and closing/ending streaming RPCs, please refer to https://pkg.go.dev/google.golang.org/grpc/?tab=doc#ClientConn.NewStream.
+type ChunkerClient interface {
+	// Chunk splits the data streamed by the client into chunks, which are streamed back in order.
+	Chunk(ctx context.Context, opts ...grpc.CallOption) (Chunker_ChunkClient, error)
+	// Reassemble streams the file described by a manifest from the chunks held by the server.
+	Reassemble(ctx context.Context, in *ReassembleRequest, opts ...grpc.CallOption) (Chunker_ReassembleClient, error)
+	// Missing returns which of the given chunks the server does not hold, so that clients only upload those.
+	// Asking again after an interrupted upload resumes it.
+	Missing(ctx context.Context, in *MissingRequest, opts ...grpc.CallOption) (*MissingResponse, error)
+	// Upload stores the chunks streamed by the client, verifying each against its digest.
+	// Every chunk is stored as soon as it is complete, so an interrupted upload keeps the chunks received so far.
+	Upload(ctx context.Context, opts ...grpc.CallOption) (Chunker_UploadClient, error)
+}
+
+type chunkerClient struct {
+	cc grpc.ClientConnInterface
+}
+
+func NewChunkerClient(cc grpc.ClientConnInterface) ChunkerClient {
+	return &chunkerClient{cc}
+}
+
+func (c *chunkerClient) Chunk(ctx context.Context, opts ...grpc.CallOption) (Chunker_ChunkClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Chunker_ServiceDesc.Streams[0], Chunker_Chunk_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &chunkerChunkClient{stream}
+	return x, nil
+}
+
+type Chunker_ChunkClient interface {
+	Send(*ChunkRequest) error
+	Recv() (*aepb.Chunk, error)
+	grpc.ClientStream
+}
+
+type chunkerChunkClient struct {
+	grpc.ClientStream
+}
+
+func (x *chunkerChunkClient) Send(m *ChunkRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *chunkerChunkClient) Recv() (*aepb.Chunk, error) {
+	m := new(aepb.Chunk)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *chunkerClient) Reassemble(ctx context.Context, in *ReassembleRequest, opts ...grpc.CallOption) (Chunker_ReassembleClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Chunker_ServiceDesc.Streams[1], Chunker_Reassemble_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &chunkerReassembleClient{stream}
+	if err := x.ClientStream.SendMsg(in); err != nil {
+		return nil, err
+	}
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	return x, nil
+}
+
+type Chunker_ReassembleClient interface {
+	Recv() (*ReassembleResponse, error)
+	grpc.ClientStream
+}
+
+type chunkerReassembleClient struct {
+	grpc.ClientStream
+}
+
+func (x *chunkerReassembleClient) Recv() (*ReassembleResponse, error) {
+	m := new(ReassembleResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func (c *chunkerClient) Missing(ctx context.Context, in *MissingRequest, opts ...grpc.CallOption) (*MissingResponse, error) {
+	out := new(MissingResponse)
+	err := c.cc.Invoke(ctx, Chunker_Missing_FullMethodName, in, out, opts...)
+	if err != nil {
+		return nil, err
+	}
+	return out, nil
+}
+
+func (c *chunkerClient) Upload(ctx context.Context, opts ...grpc.CallOption) (Chunker_UploadClient, error) {
+	stream, err := c.cc.NewStream(ctx, &Chunker_ServiceDesc.Streams[2], Chunker_Upload_FullMethodName, opts...)
+	if err != nil {
+		return nil, err
+	}
+	x := &chunkerUploadClient{stream}
+	return x, nil
+}
+
+type Chunker_UploadClient interface {
+	Send(*UploadRequest) error
+	CloseAndRecv() (*UploadResponse, error)
+	grpc.ClientStream
+}
+
+type chunkerUploadClient struct {
+	grpc.ClientStream
+}
+
+func (x *chunkerUploadClient) Send(m *UploadRequest) error {
+	return x.ClientStream.SendMsg(m)
+}
+
+func (x *chunkerUploadClient) CloseAndRecv() (*UploadResponse, error) {
+	if err := x.ClientStream.CloseSend(); err != nil {
+		return nil, err
+	}
+	m := new(UploadResponse)
+	if err := x.ClientStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// ChunkerServer is the server API for Chunker service.
+// All implementations must embed UnimplementedChunkerServer
+// for forward compatibility
+type ChunkerServer interface {
+	// Chunk splits the data streamed by the client into chunks, which are streamed back in order.
+	Chunk(Chunker_ChunkServer) error
+	// Reassemble streams the file described by a manifest from the chunks held by the server.
+	Reassemble(*ReassembleRequest, Chunker_ReassembleServer) error
+	// Missing returns which of the given chunks the server does not hold, so that clients only upload those.
+	// Asking again after an interrupted upload resumes it.
+	Missing(context.Context, *MissingRequest) (*MissingResponse, error)
+	// Upload stores the chunks streamed by the client, verifying each against its digest.
+	// Every chunk is stored as soon as it is complete, so an interrupted upload keeps the chunks received so far.
+	Upload(Chunker_UploadServer) error
+	mustEmbedUnimplementedChunkerServer()
+}
+
+// UnimplementedChunkerServer must be embedded to have forward compatible implementations.
+type UnimplementedChunkerServer struct {
+}
+
+func (UnimplementedChunkerServer) Chunk(Chunker_ChunkServer) error {
+	return status.Errorf(codes.Unimplemented, "method Chunk not implemented")
+}
+func (UnimplementedChunkerServer) Reassemble(*ReassembleRequest, Chunker_ReassembleServer) error {
+	return status.Errorf(codes.Unimplemented, "method Reassemble not implemented")
+}
+func (UnimplementedChunkerServer) Missing(context.Context, *MissingRequest) (*MissingResponse, error) {
+	return nil, status.Errorf(codes.Unimplemented, "method Missing not implemented")
+}
+func (UnimplementedChunkerServer) Upload(Chunker_UploadServer) error {
+	return status.Errorf(codes.Unimplemented, "method Upload not implemented")
+}
+func (UnimplementedChunkerServer) mustEmbedUnimplementedChunkerServer() {}
+
+// UnsafeChunkerServer may be embedded to opt out of forward compatibility for this service.
+// Use of this interface is not recommended, as added methods to ChunkerServer will
+// result in compilation errors.
+type UnsafeChunkerServer interface {
+	mustEmbedUnimplementedChunkerServer()
+}
+
+func RegisterChunkerServer(s grpc.ServiceRegistrar, srv ChunkerServer) {
+	s.RegisterService(&Chunker_ServiceDesc, srv)
+}
+
+func _Chunker_Chunk_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(ChunkerServer).Chunk(&chunkerChunkServer{stream})
+}
+
+type Chunker_ChunkServer interface {
+	Send(*aepb.Chunk) error
+	Recv() (*ChunkRequest, error)
+	grpc.ServerStream
+}
+
+type chunkerChunkServer struct {
+	grpc.ServerStream
+}
+
+func (x *chunkerChunkServer) Send(m *aepb.Chunk) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *chunkerChunkServer) Recv() (*ChunkRequest, error) {
+	m := new(ChunkRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+func _Chunker_Reassemble_Handler(srv interface{}, stream grpc.ServerStream) error {
+	m := new(ReassembleRequest)
+	if err := stream.RecvMsg(m); err != nil {
+		return err
+	}
+	return srv.(ChunkerServer).Reassemble(m, &chunkerReassembleServer{stream})
+}
+
+type Chunker_ReassembleServer interface {
+	Send(*ReassembleResponse) error
+	grpc.ServerStream
+}
+
+type chunkerReassembleServer struct {
+	grpc.ServerStream
+}
+
+func (x *chunkerReassembleServer) Send(m *ReassembleResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func _Chunker_Missing_Handler(srv interface{}, ctx context.Context, dec func(interface{}) error, interceptor grpc.UnaryServerInterceptor) (interface{}, error) {
+	in := new(MissingRequest)
+	if err := dec(in); err != nil {
+		return nil, err
+	}
+	if interceptor == nil {
+		return srv.(ChunkerServer).Missing(ctx, in)
+	}
+	info := &grpc.UnaryServerInfo{
+		Server:     srv,
+		FullMethod: Chunker_Missing_FullMethodName,
+	}
+	handler := func(ctx context.Context, req interface{}) (interface{}, error) {
+		return srv.(ChunkerServer).Missing(ctx, req.(*MissingRequest))
+	}
+	return interceptor(ctx, in, info, handler)
+}
+
+func _Chunker_Upload_Handler(srv interface{}, stream grpc.ServerStream) error {
+	return srv.(ChunkerServer).Upload(&chunkerUploadServer{stream})
+}
+
+type Chunker_UploadServer interface {
+	SendAndClose(*UploadResponse) error
+	Recv() (*UploadRequest, error)
+	grpc.ServerStream
+}
+
+type chunkerUploadServer struct {
+	grpc.ServerStream
+}
+
+func (x *chunkerUploadServer) SendAndClose(m *UploadResponse) error {
+	return x.ServerStream.SendMsg(m)
+}
+
+func (x *chunkerUploadServer) Recv() (*UploadRequest, error) {
+	m := new(UploadRequest)
+	if err := x.ServerStream.RecvMsg(m); err != nil {
+		return nil, err
+	}
+	return m, nil
+}
+
+// Chunker_ServiceDesc is the grpc.ServiceDesc for Chunker service.
+// It's only intended for direct use with grpc.RegisterService,
+// and not to be introspected or modified (even as a copy)
+var Chunker_ServiceDesc = grpc.ServiceDesc{
+	ServiceName: "ae.service.v1.Chunker",
+	HandlerType: (*ChunkerServer)(nil),
+	Methods: []grpc.MethodDesc{
+		{
+			MethodName: "Missing",
+			Handler:    _Chunker_Missing_Handler,
+		},
+	},
+	Streams: []grpc.StreamDesc{
+		{
+			StreamName:    "Chunk",
+			Handler:       _Chunker_Chunk_Handler,
+			ServerStreams: true,
+			ClientStreams: true,
+		},
+		{
+			StreamName:    "Reassemble",
+			Handler:       _Chunker_Reassemble_Handler,
+			ServerStreams: true,
+		},
+		{
+			StreamName:    "Upload",
+			Handler:       _Chunker_Upload_Handler,
+			ClientStreams: true,
+		},
+	},
+	Metadata: "service/service.proto",
+}
@@ -0,0 +1,212 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	ae "github.com/mg98/ae-chunker-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+	"io"
+	"math/rand"
+	"net"
+	"testing"
+)
+
+// newTestClient serves a Server with store in memory and returns a Client connected to it.
+func newTestClient(t *testing.T, store ae.ChunkStore, opts ...grpc.ServerOption) *Client {
+	lis := bufconn.Listen(1 << 20)
+	srv := grpc.NewServer(opts...)
+	RegisterChunkerServer(srv, NewServer(store))
+	go srv.Serve(lis)
+	t.Cleanup(srv.Stop)
+
+	conn, err := grpc.Dial("bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()))
+	assert.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return NewClient(conn)
+}
+
+func randBytes(n int) []byte {
+	data := make([]byte, n)
+	rand.New(rand.NewSource(1)).Read(data)
+	return data
+}
+
+// errReader fails after the data of r.
+type errReader struct {
+	r   io.Reader
+	err error
+}
+
+func (r *errReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	if err == io.EOF {
+		return n, r.err
+	}
+	return n, err
+}
+
+func TestClient_Chunk(t *testing.T) {
+	c := newTestClient(t, nil)
+	data := randBytes(3<<20 + 1234)
+	p := ae.Parameters{AverageSize: 64 << 10, Hash: "sha256"}
+
+	want, err := ae.BuildManifest(bytes.NewReader(data), p.Options())
+	assert.NoError(t, err)
+	var got []ae.ChunkRef
+	var joined []byte
+	err = c.Chunk(context.Background(), bytes.NewReader(data), p, func(ch *ae.Chunk) error {
+		got = append(got, ae.ChunkRef{Offset: ch.Offset, Length: int64(len(ch.Data)), Hash: ch.Sum})
+		joined = append(joined, ch.Data...)
+		return nil
+	})
+	assert.NoError(t, err)
+	assert.Equal(t, want.Chunks, got)
+	assert.Equal(t, data, joined)
+
+	t.Run("empty", func(t *testing.T) {
+		err := c.Chunk(context.Background(), bytes.NewReader(nil), p, func(ch *ae.Chunk) error {
+			t.Error("unexpected chunk")
+			return nil
+		})
+		assert.NoError(t, err)
+	})
+
+	t.Run("visit fails", func(t *testing.T) {
+		errVisit := errors.New("visit failed")
+		err := c.Chunk(context.Background(), bytes.NewReader(data), p, func(ch *ae.Chunk) error {
+			return errVisit
+		})
+		assert.Equal(t, errVisit, err)
+	})
+
+	t.Run("read fails", func(t *testing.T) {
+		errRead := errors.New("read failed")
+		err := c.Chunk(context.Background(), &errReader{bytes.NewReader(data[:100]), errRead}, p, func(ch *ae.Chunk) error {
+			return nil
+		})
+		assert.Equal(t, errRead, err)
+	})
+
+	t.Run("unknown hash", func(t *testing.T) {
+		err := c.Chunk(context.Background(), bytes.NewReader(data), ae.Parameters{Hash: "md4"}, func(ch *ae.Chunk) error {
+			return nil
+		})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("no store", func(t *testing.T) {
+		_, err := c.Store(context.Background(), bytes.NewReader(data), p)
+		assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+		err = c.Reassemble(context.Background(), want, io.Discard)
+		assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	})
+}
+
+func TestClient_StoreReassemble(t *testing.T) {
+	store := ae.NewMemoryStore(0)
+	c := newTestClient(t, store)
+	data := randBytes(3<<20 + 1234)
+	p := ae.Parameters{AverageSize: 256 << 10, MaxSize: 2 << 20, Hash: "sha256"}
+
+	m, err := c.Store(context.Background(), bytes.NewReader(data), p)
+	assert.NoError(t, err)
+	want, err := ae.BuildManifest(bytes.NewReader(data), p.Options())
+	assert.NoError(t, err)
+	assert.Equal(t, want, m)
+
+	var buf bytes.Buffer
+	assert.NoError(t, c.Reassemble(context.Background(), m, &buf))
+	assert.Equal(t, data, buf.Bytes())
+
+	t.Run("without hash", func(t *testing.T) {
+		_, err := c.Store(context.Background(), bytes.NewReader(data), ae.Parameters{})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("missing chunk", func(t *testing.T) {
+		assert.NoError(t, store.Delete(m.Chunks[1].Hash))
+		err := c.Reassemble(context.Background(), m, io.Discard)
+		assert.Equal(t, codes.NotFound, status.Code(err))
+	})
+}
+
+// failingStream fails with Unavailable once it has received a number of messages.
+type failingStream struct {
+	grpc.ServerStream
+	n int
+}
+
+func (s *failingStream) RecvMsg(m interface{}) error {
+	if s.n == 0 {
+		return status.Error(codes.Unavailable, "connection lost")
+	}
+	s.n--
+	return s.ServerStream.RecvMsg(m)
+}
+
+func TestClient_Push(t *testing.T) {
+	data := randBytes(6 << 20)
+	p := ae.Parameters{AverageSize: 1 << 20, MaxSize: 3 << 20, Hash: "sha256"}
+	m, err := ae.BuildManifest(bytes.NewReader(data), p.Options())
+	require.NoError(t, err)
+	src := ae.NewMemoryStore(0)
+	large := false
+	for _, ref := range m.Chunks {
+		require.NoError(t, src.Put(ref.Hash, data[ref.Offset:ref.Offset+ref.Length]))
+		large = large || ref.Length > maxMessageSize
+	}
+	require.True(t, large, "no chunk exceeds the message size")
+
+	// The first upload is interrupted after a few messages.
+	failures := 1
+	interceptor := func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if info.FullMethod == "/ae.service.v1.Chunker/Upload" && failures > 0 {
+			failures--
+			ss = &failingStream{ServerStream: ss, n: 3}
+		}
+		return handler(srv, ss)
+	}
+	dst := ae.NewMemoryStore(0)
+	require.NoError(t, dst.Put(m.Chunks[0].Hash, data[:m.Chunks[0].Length]))
+	c := newTestClient(t, dst, grpc.StreamInterceptor(interceptor))
+
+	report, err := c.Push(context.Background(), m, src)
+	require.NoError(t, err)
+	assert.Equal(t, &PushReport{Chunks: len(m.Chunks), Missing: len(m.Chunks) - 1, Resumed: 1}, report)
+	var buf bytes.Buffer
+	require.NoError(t, c.Reassemble(context.Background(), m, &buf))
+	assert.Equal(t, data, buf.Bytes())
+
+	report, err = c.Push(context.Background(), m, src)
+	require.NoError(t, err)
+	assert.Equal(t, &PushReport{Chunks: len(m.Chunks)}, report)
+
+	t.Run("corrupt chunk", func(t *testing.T) {
+		c := newTestClient(t, ae.NewMemoryStore(0))
+		corrupt := ae.NewMemoryStore(0)
+		require.NoError(t, corrupt.Put(m.Chunks[0].Hash, []byte("corrupt")))
+		_, err := c.Push(context.Background(), &ae.Manifest{Parameters: p, Chunks: m.Chunks[:1]}, corrupt)
+		assert.Equal(t, codes.DataLoss, status.Code(err))
+	})
+
+	t.Run("missing source chunk", func(t *testing.T) {
+		c := newTestClient(t, ae.NewMemoryStore(0))
+		_, err := c.Push(context.Background(), m, ae.NewMemoryStore(0))
+		assert.ErrorIs(t, err, ae.ErrChunkNotFound)
+	})
+
+	t.Run("no store", func(t *testing.T) {
+		c := newTestClient(t, nil)
+		_, err := c.Push(context.Background(), m, src)
+		assert.Equal(t, codes.FailedPrecondition, status.Code(err))
+	})
+}
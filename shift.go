@@ -0,0 +1,259 @@
+package ae
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io"
+	"math/rand"
+	"sort"
+)
+
+// EditKind is a kind of synthetic edit applied by AnalyzeShift.
+type EditKind uint8
+
+const (
+	// EditInsert inserts random bytes.
+	EditInsert EditKind = iota
+
+	// EditDelete deletes bytes.
+	EditDelete
+
+	// EditReplace overwrites bytes with random ones.
+	EditReplace
+)
+
+// String returns the name of the edit kind.
+func (k EditKind) String() string {
+	switch k {
+	case EditInsert:
+		return "insert"
+	case EditDelete:
+		return "delete"
+	case EditReplace:
+		return "replace"
+	default:
+		return fmt.Sprintf("EditKind(%d)", uint8(k))
+	}
+}
+
+// ShiftOptions configure the edits of AnalyzeShift.
+type ShiftOptions struct {
+	// Kind of the edits.
+	Kind EditKind
+
+	// Size of every edit in bytes (optional). It defaults to 1.
+	Size int
+
+	// Edits applied per trial at random offsets (optional). It defaults to 1.
+	Edits int
+
+	// Trials is the number of independently edited copies of the input (optional). It defaults to 1.
+	Trials int
+
+	// Seed of the random offsets and inserted bytes.
+	Seed int64
+}
+
+// ShiftReport summarizes how well chunk boundaries survive edits, accumulated over all trials.
+type ShiftReport struct {
+	// Boundaries is the number of cut points in the original input, excluding its end.
+	Boundaries int
+
+	// SurvivedBoundaries is the number of cut points found again at the same place of the edited content.
+	// Cut points within deleted bytes are lost.
+	SurvivedBoundaries int
+
+	// Chunks is the number of chunks in the original input.
+	Chunks int
+
+	// SurvivedChunks is the number of original chunks that occur unchanged in the edited input.
+	SurvivedChunks int
+
+	// Bytes is the size of the original input.
+	Bytes int64
+
+	// SurvivedBytes is the size of the surviving chunks, i.e. the data that deduplicates after the edits.
+	SurvivedBytes int64
+}
+
+// BoundarySurvival returns the share of the boundaries that survived the edits.
+func (r *ShiftReport) BoundarySurvival() float64 {
+	if r.Boundaries == 0 {
+		return 1
+	}
+	return float64(r.SurvivedBoundaries) / float64(r.Boundaries)
+}
+
+// ChunkSurvival returns the share of the bytes in chunks that survived the edits.
+func (r *ShiftReport) ChunkSurvival() float64 {
+	if r.Bytes == 0 {
+		return 1
+	}
+	return float64(r.SurvivedBytes) / float64(r.Bytes)
+}
+
+// edit is an edit applied to the input at an offset.
+type edit struct {
+	offset int64
+	data   []byte // inserted or replacing bytes
+	n      int64  // deleted bytes
+}
+
+// AnalyzeShift measures the robustness of the chunk boundaries against edits, the key property of
+// content-defined chunking: it chunks r with opts, applies random edits as configured by sopts,
+// chunks the result again and reports how many boundaries and chunks survived.
+// The input is held in memory.
+func AnalyzeShift(r io.Reader, opts *Options, sopts *ShiftOptions) (*ShiftReport, error) {
+	so := ShiftOptions{}
+	if sopts != nil {
+		so = *sopts
+	}
+	if so.Size <= 0 {
+		so.Size = 1
+	}
+	if so.Edits <= 0 {
+		so.Edits = 1
+	}
+	if so.Trials <= 0 {
+		so.Trials = 1
+	}
+	if so.Kind > EditReplace {
+		return nil, fmt.Errorf("ae: invalid edit kind %d", so.Kind)
+	}
+	o := Options{}
+	if opts != nil {
+		o = *opts
+	}
+	o.Hasher, o.HashName, o.SHA256 = nil, "", false
+
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	if so.Kind != EditInsert && int64(so.Edits)*int64(so.Size) > int64(len(data)) {
+		return nil, fmt.Errorf("ae: %d edits of %d bytes exceed the input of %d bytes", so.Edits, so.Size, len(data))
+	}
+	cuts, sums, err := shiftChunks(data, &o)
+	if err != nil {
+		return nil, err
+	}
+
+	report := &ShiftReport{}
+	rnd := rand.New(rand.NewSource(so.Seed))
+	for trial := 0; trial < so.Trials; trial++ {
+		edits := randomEdits(rnd, int64(len(data)), &so)
+		editedCuts, editedSums, err := shiftChunks(applyEdits(data, edits), &o)
+		if err != nil {
+			return nil, err
+		}
+
+		cutSet := make(map[int64]bool, len(editedCuts))
+		for _, c := range editedCuts {
+			cutSet[c] = true
+		}
+		for i, c := range cuts {
+			if i == len(cuts)-1 {
+				break
+			}
+			report.Boundaries++
+			if moved, ok := shiftOffset(c, edits); ok && cutSet[moved] {
+				report.SurvivedBoundaries++
+			}
+		}
+
+		sumSet := make(map[[sha256.Size]byte]bool, len(editedSums))
+		for _, s := range editedSums {
+			sumSet[s] = true
+		}
+		var start int64
+		for i, s := range sums {
+			report.Chunks++
+			report.Bytes += cuts[i] - start
+			if sumSet[s] {
+				report.SurvivedChunks++
+				report.SurvivedBytes += cuts[i] - start
+			}
+			start = cuts[i]
+		}
+	}
+	return report, nil
+}
+
+// shiftChunks chunks data and returns the end offsets and the SHA-256 digests of its chunks.
+func shiftChunks(data []byte, opts *Options) ([]int64, [][sha256.Size]byte, error) {
+	var cuts []int64
+	var sums [][sha256.Size]byte
+	ch := NewChunker(bytes.NewReader(data), opts)
+	for {
+		c, err := ch.Next()
+		if err == io.EOF {
+			return cuts, sums, nil
+		}
+		if err != nil {
+			return nil, nil, err
+		}
+		cuts = append(cuts, c.Offset+int64(len(c.Data)))
+		sums = append(sums, sha256.Sum256(c.Data))
+		ReleaseChunk(c)
+	}
+}
+
+// randomEdits returns so.Edits non-overlapping edits of an input of size bytes in ascending order.
+// The input is divided into equal strata, each of which receives one edit at a random offset.
+func randomEdits(rnd *rand.Rand, size int64, so *ShiftOptions) []edit {
+	edits := make([]edit, so.Edits)
+	stratum := size / int64(so.Edits)
+	for i := range edits {
+		span := stratum
+		if so.Kind != EditInsert {
+			span -= int64(so.Size) - 1
+		}
+		var offset int64
+		if span > 0 {
+			offset = rnd.Int63n(span)
+		}
+		e := edit{offset: int64(i)*stratum + offset}
+		switch so.Kind {
+		case EditDelete:
+			e.n = int64(so.Size)
+		case EditReplace:
+			e.n = int64(so.Size)
+			fallthrough
+		default:
+			e.data = make([]byte, so.Size)
+			rnd.Read(e.data)
+		}
+		edits[i] = e
+	}
+	sort.Slice(edits, func(i, j int) bool { return edits[i].offset < edits[j].offset })
+	return edits
+}
+
+// applyEdits returns a copy of data with edits applied, which must be in ascending order.
+func applyEdits(data []byte, edits []edit) []byte {
+	var out []byte
+	var pos int64
+	for _, e := range edits {
+		out = append(out, data[pos:e.offset]...)
+		out = append(out, e.data...)
+		pos = e.offset + e.n
+	}
+	return append(out, data[pos:]...)
+}
+
+// shiftOffset maps an offset between two bytes of the original input to the corresponding offset in
+// the edited input. It reports false if the offset lies within deleted or replaced bytes.
+func shiftOffset(offset int64, edits []edit) (int64, bool) {
+	moved := offset
+	for _, e := range edits {
+		if offset <= e.offset {
+			break
+		}
+		if offset < e.offset+e.n {
+			return 0, false
+		}
+		moved += int64(len(e.data)) - e.n
+	}
+	return moved, true
+}
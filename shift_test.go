@@ -0,0 +1,103 @@
+package ae
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"testing"
+)
+
+func TestApplyEdits(t *testing.T) {
+	data := []byte("0123456789")
+	edits := []edit{
+		{offset: 1, data: []byte("ab")},
+		{offset: 3, n: 2},
+		{offset: 7, data: []byte("xy"), n: 2},
+	}
+	assert.Equal(t, []byte("0ab1256xy9"), applyEdits(data, edits))
+
+	for _, tc := range []struct {
+		offset, moved int64
+		ok            bool
+	}{
+		{0, 0, true},
+		{1, 1, true},
+		{2, 4, true},
+		{3, 5, true},
+		{4, 0, false},
+		{5, 5, true},
+		{7, 7, true},
+		{8, 0, false},
+		{9, 9, true},
+		{10, 10, true},
+	} {
+		moved, ok := shiftOffset(tc.offset, edits)
+		assert.Equal(t, tc.ok, ok, "offset %d", tc.offset)
+		assert.Equal(t, tc.moved, moved, "offset %d", tc.offset)
+	}
+}
+
+func TestRandomEdits(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	for _, kind := range []EditKind{EditInsert, EditDelete, EditReplace} {
+		so := &ShiftOptions{Kind: kind, Size: 100, Edits: 10}
+		edits := randomEdits(rnd, 10000, so)
+		assert.Len(t, edits, 10)
+		var end int64
+		for _, e := range edits {
+			assert.GreaterOrEqual(t, e.offset, end, kind.String())
+			assert.LessOrEqual(t, e.offset+e.n, int64(10000), kind.String())
+			end = e.offset + e.n
+		}
+		edited := applyEdits(make([]byte, 10000), edits)
+		assert.Len(t, edited, map[EditKind]int{EditInsert: 11000, EditDelete: 9000, EditReplace: 10000}[kind])
+	}
+}
+
+func TestAnalyzeShift(t *testing.T) {
+	input := testFile[:4*MiB]
+	opts := &Options{AverageSize: 64 * 1024}
+	chunks := getChunks(NewChunker(bytes.NewReader(input), opts))
+
+	t.Run("replace", func(t *testing.T) {
+		r, err := AnalyzeShift(bytes.NewReader(input), opts, &ShiftOptions{Kind: EditReplace, Trials: 3, Seed: 1})
+		assert.NoError(t, err)
+		assert.Equal(t, 3*len(chunks), r.Chunks)
+		assert.Equal(t, 3*(len(chunks)-1), r.Boundaries)
+		assert.Equal(t, int64(3*len(input)), r.Bytes)
+		// A single replaced byte only affects the chunks around it.
+		assert.GreaterOrEqual(t, r.SurvivedChunks, r.Chunks-3*3)
+		assert.Greater(t, r.ChunkSurvival(), 0.9)
+		assert.Greater(t, r.BoundarySurvival(), 0.9)
+	})
+
+	t.Run("invariants", func(t *testing.T) {
+		for _, kind := range []EditKind{EditInsert, EditDelete, EditReplace} {
+			so := &ShiftOptions{Kind: kind, Size: 100, Edits: 4, Trials: 2, Seed: 2}
+			r, err := AnalyzeShift(bytes.NewReader(input), opts, so)
+			assert.NoError(t, err)
+			assert.LessOrEqual(t, r.SurvivedBoundaries, r.Boundaries)
+			assert.LessOrEqual(t, r.SurvivedChunks, r.Chunks)
+			assert.LessOrEqual(t, r.SurvivedBytes, r.Bytes)
+
+			again, err := AnalyzeShift(bytes.NewReader(input), opts, so)
+			assert.NoError(t, err)
+			assert.Equal(t, r, again, "results must be reproducible with the same seed")
+		}
+	})
+
+	t.Run("empty input", func(t *testing.T) {
+		r, err := AnalyzeShift(bytes.NewReader(nil), opts, nil)
+		assert.NoError(t, err)
+		assert.Zero(t, r.Boundaries)
+		assert.Equal(t, 1.0, r.BoundarySurvival())
+		assert.Equal(t, 1.0, r.ChunkSurvival())
+	})
+
+	t.Run("invalid options", func(t *testing.T) {
+		_, err := AnalyzeShift(bytes.NewReader(input[:100]), opts, &ShiftOptions{Kind: EditDelete, Size: 60, Edits: 2})
+		assert.Error(t, err)
+		_, err = AnalyzeShift(bytes.NewReader(input[:100]), opts, &ShiftOptions{Kind: 3})
+		assert.Error(t, err)
+	})
+}
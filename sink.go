@@ -0,0 +1,77 @@
+package ae
+
+import (
+	"context"
+	"go.opentelemetry.io/otel/trace"
+	"io"
+	"net"
+)
+
+// ChunkSink consumes the chunks emitted by a Chunker.
+type ChunkSink interface {
+	// WriteChunk processes c. The sink must not retain c once the call returns
+	// unless it takes over ownership of the chunk.
+	WriteChunk(c *Chunk) error
+}
+
+// CopyChunks feeds all chunks of ch into sink until the input is exhausted or an error occurs.
+// It returns the number of bytes passed to the sink.
+// Like a Pipeline, it records OpenTelemetry spans with the global TracerProvider.
+func CopyChunks(sink ChunkSink, ch *Chunker) (n int64, err error) {
+	tr := tracer(nil)
+	ctx, span := tr.Start(context.Background(), "ae.CopyChunks")
+	defer func() { endSpan(span, err) }()
+	for {
+		_, span := tr.Start(ctx, "ae.chunk")
+		c, err := ch.Next()
+		if c != nil {
+			span.SetAttributes(chunkAttributes(c)...)
+		}
+		endSpan(span, err)
+		if err == io.EOF {
+			return n, nil
+		}
+		if err != nil {
+			return n, err
+		}
+		_, span = tr.Start(ctx, "ae.sink", trace.WithAttributes(chunkAttributes(c)...))
+		err = sink.WriteChunk(c)
+		endSpan(span, err)
+		if err != nil {
+			return n, err
+		}
+		n += int64(len(c.Data))
+	}
+}
+
+// BuffersSink collects chunks as separate buffers for vectored I/O (writev).
+// The chunk boundaries are thus preserved without concatenating the data.
+type BuffersSink struct {
+	// Header returns the framing data to be sent ahead of each chunk (optional).
+	Header func(c *Chunk) []byte
+
+	bufs net.Buffers
+}
+
+// WriteChunk appends c (and its header) to the collected buffers.
+// The sink takes over ownership of c.Data.
+func (s *BuffersSink) WriteChunk(c *Chunk) error {
+	if s.Header != nil {
+		if h := s.Header(c); len(h) > 0 {
+			s.bufs = append(s.bufs, h)
+		}
+	}
+	s.bufs = append(s.bufs, c.Data)
+	return nil
+}
+
+// Buffers returns the collected buffers in order.
+// Writing them, e.g. with net.Buffers.WriteTo, leaves the sink itself untouched.
+func (s *BuffersSink) Buffers() net.Buffers {
+	return append(net.Buffers(nil), s.bufs...)
+}
+
+// Reset discards all collected buffers.
+func (s *BuffersSink) Reset() {
+	s.bufs = nil
+}
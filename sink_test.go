@@ -0,0 +1,57 @@
+package ae
+
+import (
+	"bytes"
+	"encoding/binary"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestBuffersSink(t *testing.T) {
+	input := testFile[:2*MiB]
+
+	t.Run("preserves chunk boundaries", func(t *testing.T) {
+		sink := &BuffersSink{}
+		n, err := CopyChunks(sink, NewChunker(bytes.NewReader(input), &Options{AverageSize: 64 * 1024}))
+		assert.NoError(t, err)
+		assert.Equal(t, int64(len(input)), n)
+
+		chunks := getChunks(NewChunker(bytes.NewReader(input), &Options{AverageSize: 64 * 1024}))
+		bufs := sink.Buffers()
+		assert.Len(t, bufs, len(chunks))
+
+		var out bytes.Buffer
+		_, err = bufs.WriteTo(&out)
+		assert.NoError(t, err)
+		assert.Equal(t, input, out.Bytes())
+		assert.Len(t, sink.Buffers(), len(chunks))
+
+		sink.Reset()
+		assert.Empty(t, sink.Buffers())
+	})
+
+	t.Run("with frame headers", func(t *testing.T) {
+		sink := &BuffersSink{Header: func(c *Chunk) []byte {
+			h := make([]byte, binary.MaxVarintLen64)
+			return h[:binary.PutUvarint(h, uint64(len(c.Data)))]
+		}}
+		_, err := CopyChunks(sink, NewChunker(bytes.NewReader(input), &Options{AverageSize: 64 * 1024}))
+		assert.NoError(t, err)
+
+		var out bytes.Buffer
+		bufs := sink.Buffers()
+		_, err = bufs.WriteTo(&out)
+		assert.NoError(t, err)
+
+		var data []byte
+		r := bytes.NewReader(out.Bytes())
+		for r.Len() > 0 {
+			n, err := binary.ReadUvarint(r)
+			assert.NoError(t, err)
+			chunk := make([]byte, n)
+			_, _ = r.Read(chunk)
+			data = append(data, chunk...)
+		}
+		assert.Equal(t, input, data)
+	})
+}
@@ -0,0 +1,119 @@
+package ae
+
+import (
+	"encoding/binary"
+	"hash/fnv"
+	"math"
+)
+
+// defaultSketchSize is the number of minimum hashes of a Sketch unless chosen otherwise.
+const defaultSketchSize = 128
+
+// Sketch is a MinHash signature of the set of chunks of a file. The share of equal minimum hashes of two sketches
+// estimates the resemblance of the files, i.e. the Jaccard similarity of their chunk sets, without their manifests
+// at hand. Deduplication systems can route files that resemble each other, but share few chunks, to delta compression.
+//
+// The error of the estimate is about 1/sqrt(n) for a sketch of n minimum hashes.
+type Sketch struct {
+	// Mins holds the minimum of each of the hash functions over the chunk hashes added.
+	Mins []uint64
+}
+
+// NewSketch returns an empty Sketch of size minimum hashes. If size is zero or negative, it defaults to 128.
+func NewSketch(size int) *Sketch {
+	if size <= 0 {
+		size = defaultSketchSize
+	}
+	s := &Sketch{Mins: make([]uint64, size)}
+	for i := range s.Mins {
+		s.Mins[i] = math.MaxUint64
+	}
+	return s
+}
+
+// SketchManifest returns a Sketch of size minimum hashes of the chunks of m.
+func SketchManifest(m *Manifest, size int) *Sketch {
+	s := NewSketch(size)
+	for _, ref := range m.Chunks {
+		s.Add(ref.Hash)
+	}
+	return s
+}
+
+// Add adds the chunk with the given hash to the set. Adding the same chunk again has no effect.
+func (s *Sketch) Add(hash []byte) {
+	var x uint64
+	if len(hash) >= 8 {
+		x = binary.LittleEndian.Uint64(hash)
+	} else {
+		h := fnv.New64a()
+		h.Write(hash)
+		x = h.Sum64()
+	}
+	// The hash functions are derived from the chunk hash by mixing it with a different seed each.
+	for i := range s.Mins {
+		if v := mix64(x ^ uint64(i+1)*0x9e3779b97f4a7c15); v < s.Mins[i] {
+			s.Mins[i] = v
+		}
+	}
+}
+
+// Empty reports whether no chunk has been added to the sketch.
+func (s *Sketch) Empty() bool {
+	return len(s.Mins) == 0 || s.Mins[0] == math.MaxUint64
+}
+
+// Resemblance estimates the Jaccard similarity of the chunk sets of s and other, from 0 for disjoint sets
+// to 1 for equal ones. Sketches of different sizes are compared by the minimum hashes they have in common.
+// Empty sketches resemble nothing.
+func (s *Sketch) Resemblance(other *Sketch) float64 {
+	n := len(s.Mins)
+	if len(other.Mins) < n {
+		n = len(other.Mins)
+	}
+	if n == 0 || s.Empty() || other.Empty() {
+		return 0
+	}
+	var equal int
+	for i := 0; i < n; i++ {
+		if s.Mins[i] == other.Mins[i] {
+			equal++
+		}
+	}
+	return float64(equal) / float64(n)
+}
+
+// SuperFeatures condenses the sketch into n features, each a hash of a group of consecutive minimum hashes.
+// Files sharing any super-feature are likely to resemble each other, so the features can be looked up in an index
+// to find candidates for delta compression, instead of comparing a sketch with all others.
+// The more features, the lower the resemblance at which candidates are found. n is at most the size of the sketch.
+func (s *Sketch) SuperFeatures(n int) []uint64 {
+	if n > len(s.Mins) {
+		n = len(s.Mins)
+	}
+	if n <= 0 || s.Empty() {
+		return nil
+	}
+	features := make([]uint64, n)
+	group := len(s.Mins) / n
+	var buf [8]byte
+	for i := range features {
+		h := fnv.New64a()
+		for _, v := range s.Mins[i*group : (i+1)*group] {
+			binary.LittleEndian.PutUint64(buf[:], v)
+			h.Write(buf[:])
+		}
+		features[i] = h.Sum64()
+	}
+	return features
+}
+
+// mix64 is the finalizer of SplitMix64, a bijection scattering the bits of x.
+func mix64(x uint64) uint64 {
+	x ^= x >> 30
+	x *= 0xbf58476d1ce4e5b9
+	x ^= x >> 27
+	x *= 0x94d049bb133111eb
+	x ^= x >> 31
+	return x
+}
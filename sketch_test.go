@@ -0,0 +1,79 @@
+package ae
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+// jaccard returns the Jaccard similarity of the chunk sets of a and b.
+func jaccard(a, b *Manifest) float64 {
+	sets := []map[string]bool{{}, {}}
+	for i, m := range []*Manifest{a, b} {
+		for _, ref := range m.Chunks {
+			sets[i][string(ref.Hash)] = true
+		}
+	}
+	var common int
+	for h := range sets[0] {
+		if sets[1][h] {
+			common++
+		}
+	}
+	return float64(common) / float64(len(sets[0])+len(sets[1])-common)
+}
+
+func TestSketch(t *testing.T) {
+	opts := &Options{AverageSize: 16 * 1024}
+	manifest := func(data []byte) *Manifest {
+		m, err := BuildManifest(bytes.NewReader(data), opts)
+		require.NoError(t, err)
+		return m
+	}
+	a := manifest(testFile[:4*MiB])
+	// b replaces a quarter of a, c shares nothing with it, and d differs in a single byte.
+	b := manifest(append(append([]byte{}, testFile[:3*MiB]...), testFile[10*MiB:11*MiB]...))
+	c := manifest(testFile[20*MiB : 24*MiB])
+	edited := append([]byte{}, testFile[:4*MiB]...)
+	edited[2*MiB] ^= 0xff
+	d := manifest(edited)
+
+	sa, sb, sc, sd := SketchManifest(a, 256), SketchManifest(b, 256), SketchManifest(c, 256), SketchManifest(d, 256)
+	assert.Equal(t, 1.0, sa.Resemblance(SketchManifest(a, 256)))
+	assert.InDelta(t, jaccard(a, b), sa.Resemblance(sb), 0.15)
+	assert.InDelta(t, 0, sa.Resemblance(sc), 0.05)
+	assert.Greater(t, sa.Resemblance(sd), 0.9)
+
+	shared := func(x, y []uint64) int {
+		var n int
+		for i := range x {
+			if x[i] == y[i] {
+				n++
+			}
+		}
+		return n
+	}
+	assert.Len(t, sa.SuperFeatures(16), 16)
+	assert.Greater(t, shared(sa.SuperFeatures(16), sd.SuperFeatures(16)), 0)
+	assert.Zero(t, shared(sa.SuperFeatures(16), sc.SuperFeatures(16)))
+
+	// Adding chunks in any order, or repeatedly, yields the same sketch.
+	s := NewSketch(256)
+	for i := len(a.Chunks) - 1; i >= 0; i-- {
+		s.Add(a.Chunks[i].Hash)
+		s.Add(a.Chunks[i].Hash)
+	}
+	assert.Equal(t, sa, s)
+
+	empty := NewSketch(0)
+	assert.Len(t, empty.Mins, defaultSketchSize)
+	assert.True(t, empty.Empty())
+	assert.Zero(t, empty.Resemblance(empty))
+	assert.Nil(t, empty.SuperFeatures(4))
+
+	// Short hashes are supported as well.
+	short := NewSketch(8)
+	short.Add([]byte{1, 2, 3, 4})
+	assert.False(t, short.Empty())
+}
@@ -0,0 +1,341 @@
+package ae
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// repoSnapshotsDir is the directory of a repository holding the snapshots.
+const repoSnapshotsDir = "snapshots"
+
+// snapshotVersion is the version of the serialization format of Snapshot.
+const snapshotVersion = 1
+
+// Snapshot is the state of a directory tree at a point in time, stored in a Repository.
+type Snapshot struct {
+	// ID identifies the snapshot within its repository. It is the hex encoded SHA-256 hash of its encoding.
+	ID string
+
+	// Time the snapshot was taken.
+	Time time.Time
+
+	// Tags label the snapshot (optional).
+	Tags []string
+
+	// Entries are the files and directories in the tree, ordered by their paths.
+	Entries []SnapshotEntry
+}
+
+// SnapshotEntry is a file or directory in a Snapshot.
+type SnapshotEntry struct {
+	// Path of the entry, slash-separated and relative to the root of the tree.
+	Path string
+
+	// Mode and permission bits of the entry.
+	Mode fs.FileMode
+
+	// ModTime is the modification time of the entry.
+	ModTime time.Time
+
+	// Manifest describes the content of a regular file. It is nil for directories.
+	Manifest *Manifest
+}
+
+// snapshotJSON is the JSON representation of a Snapshot.
+type snapshotJSON struct {
+	Version int                 `json:"version"`
+	Time    time.Time           `json:"time"`
+	Tags    []string            `json:"tags,omitempty"`
+	Entries []snapshotEntryJSON `json:"entries"`
+}
+
+type snapshotEntryJSON struct {
+	Path     string      `json:"path"`
+	Mode     fs.FileMode `json:"mode"`
+	ModTime  time.Time   `json:"modTime"`
+	Manifest *Manifest   `json:"manifest,omitempty"`
+}
+
+// CreateSnapshot stores all regular files and directories of fsys in the repository and saves a snapshot of them,
+// labeled with tags. Other kinds of files, such as symbolic links, are skipped.
+func (r *Repository) CreateSnapshot(fsys fs.FS, tags ...string) (s *Snapshot, err error) {
+	if err := r.Lock(); err != nil {
+		return nil, err
+	}
+	defer func() {
+		if uerr := r.Unlock(); err == nil && uerr != nil {
+			s, err = nil, uerr
+		}
+	}()
+
+	s = &Snapshot{Time: time.Now(), Tags: tags}
+	err = fs.WalkDir(fsys, ".", func(path string, d fs.DirEntry, err error) error {
+		if err != nil || path == "." || !d.IsDir() && !d.Type().IsRegular() {
+			return err
+		}
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		s.Entries = append(s.Entries, SnapshotEntry{Path: path, Mode: info.Mode(), ModTime: info.ModTime()})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	manifests, err := ChunkFS(fsys, r.Options(), func(path string, c Chunk) error {
+		return r.store.Put(c.Sum, c.Data)
+	})
+	if err != nil {
+		return nil, err
+	}
+	for i := range s.Entries {
+		s.Entries[i].Manifest = manifests[s.Entries[i].Path]
+	}
+
+	// The chunks must be written before the snapshot referring to them.
+	if err := r.store.Flush(); err != nil {
+		return nil, err
+	}
+	data, err := s.encode()
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	s.ID = hex.EncodeToString(sum[:])
+	if err := writeFileAtomic(r.snapshotPath(s.ID), data, SyncFile); err != nil {
+		return nil, err
+	}
+	return s, nil
+}
+
+// encode returns the JSON encoding of s.
+func (s *Snapshot) encode() ([]byte, error) {
+	sj := snapshotJSON{Version: snapshotVersion, Time: s.Time, Tags: s.Tags}
+	for _, e := range s.Entries {
+		sj.Entries = append(sj.Entries, snapshotEntryJSON{Path: e.Path, Mode: e.Mode, ModTime: e.ModTime, Manifest: e.Manifest})
+	}
+	return json.Marshal(sj)
+}
+
+// snapshotPath returns the path of the file holding the snapshot with the given ID.
+func (r *Repository) snapshotPath(id string) string {
+	return filepath.Join(r.dir, repoSnapshotsDir, id+".json")
+}
+
+// Snapshot returns the snapshot with the given ID.
+func (r *Repository) Snapshot(id string) (*Snapshot, error) {
+	if _, err := hex.DecodeString(id); err != nil || len(id) != 2*sha256.Size {
+		return nil, fmt.Errorf("ae: invalid snapshot ID %q", id)
+	}
+	data, err := os.ReadFile(r.snapshotPath(id))
+	if err != nil {
+		return nil, err
+	}
+	var sj snapshotJSON
+	if err := json.Unmarshal(data, &sj); err != nil {
+		return nil, fmt.Errorf("ae: reading snapshot %s: %w", id, err)
+	}
+	if sj.Version != snapshotVersion {
+		return nil, fmt.Errorf("ae: unsupported snapshot version %d", sj.Version)
+	}
+	s := &Snapshot{ID: id, Time: sj.Time, Tags: sj.Tags}
+	for _, e := range sj.Entries {
+		s.Entries = append(s.Entries, SnapshotEntry{Path: e.Path, Mode: e.Mode, ModTime: e.ModTime, Manifest: e.Manifest})
+	}
+	return s, nil
+}
+
+// Snapshots returns all snapshots in the repository, from the oldest to the newest.
+func (r *Repository) Snapshots() ([]*Snapshot, error) {
+	dirents, err := os.ReadDir(filepath.Join(r.dir, repoSnapshotsDir))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var snapshots []*Snapshot
+	for _, d := range dirents {
+		id := strings.TrimSuffix(d.Name(), ".json")
+		if d.IsDir() || id == d.Name() || strings.HasPrefix(id, ".") {
+			continue
+		}
+		s, err := r.Snapshot(id)
+		if err != nil {
+			return nil, err
+		}
+		snapshots = append(snapshots, s)
+	}
+	sort.SliceStable(snapshots, func(i, j int) bool { return snapshots[i].Time.Before(snapshots[j].Time) })
+	return snapshots, nil
+}
+
+// DeleteSnapshot deletes the snapshot with the given ID. Its chunks are removed by CollectGarbage.
+func (r *Repository) DeleteSnapshot(id string) error {
+	if _, err := r.Snapshot(id); err != nil {
+		return err
+	}
+	if err := r.Lock(); err != nil {
+		return err
+	}
+	err := os.Remove(r.snapshotPath(id))
+	if uerr := r.Unlock(); err == nil {
+		err = uerr
+	}
+	return err
+}
+
+// RestoreSnapshot restores the files and directories of s into dir, which is created if necessary,
+// along with their permissions and modification times. Every chunk is verified.
+func (r *Repository) RestoreSnapshot(s *Snapshot, dir string) error {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	for _, e := range s.Entries {
+		if !fs.ValidPath(e.Path) {
+			return fmt.Errorf("ae: invalid path %q in snapshot", e.Path)
+		}
+		p := filepath.Join(dir, filepath.FromSlash(e.Path))
+		if e.Mode.IsDir() {
+			// Directories stay writable until their content is restored.
+			if err := os.MkdirAll(p, 0o700); err != nil {
+				return err
+			}
+			continue
+		}
+		if err := r.restoreFile(e, p); err != nil {
+			return err
+		}
+	}
+	// Directories are finished bottom-up, as restoring their content changes their modification time.
+	for i := len(s.Entries) - 1; i >= 0; i-- {
+		e := s.Entries[i]
+		if !e.Mode.IsDir() {
+			continue
+		}
+		p := filepath.Join(dir, filepath.FromSlash(e.Path))
+		if err := os.Chmod(p, e.Mode.Perm()); err != nil {
+			return err
+		}
+		if err := os.Chtimes(p, e.ModTime, e.ModTime); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// restoreFile restores the regular file e to p.
+func (r *Repository) restoreFile(e SnapshotEntry, p string) error {
+	if e.Manifest == nil {
+		return fmt.Errorf("ae: missing manifest of %s in snapshot", e.Path)
+	}
+	f, err := os.OpenFile(p, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, e.Mode.Perm())
+	if err != nil {
+		return err
+	}
+	if err := ReassembleSparse(f, e.Manifest, r.store); err != nil {
+		f.Close()
+		return fmt.Errorf("ae: restoring %s: %w", e.Path, err)
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(p, e.Mode.Perm()); err != nil {
+		return err
+	}
+	return os.Chtimes(p, e.ModTime, e.ModTime)
+}
+
+// ChangeKind is the kind of a SnapshotChange.
+type ChangeKind uint8
+
+const (
+	// ChangeAdded is an entry only present in the newer snapshot.
+	ChangeAdded ChangeKind = iota
+
+	// ChangeRemoved is an entry only present in the older snapshot.
+	ChangeRemoved
+
+	// ChangeModified is an entry present in both snapshots, with different content or mode.
+	ChangeModified
+)
+
+// String returns the name of the change kind.
+func (k ChangeKind) String() string {
+	switch k {
+	case ChangeAdded:
+		return "added"
+	case ChangeRemoved:
+		return "removed"
+	case ChangeModified:
+		return "modified"
+	default:
+		return fmt.Sprintf("ChangeKind(%d)", uint8(k))
+	}
+}
+
+// SnapshotChange is a difference between two snapshots.
+type SnapshotChange struct {
+	// Path of the changed entry.
+	Path string
+
+	// Kind of the change.
+	Kind ChangeKind
+
+	// Delta between the versions of a modified file, in terms of their chunks. It is nil otherwise.
+	Delta *Delta
+}
+
+// DiffSnapshots returns the entries added, removed or modified from old to new, ordered by their paths.
+// Modification times alone do not count as modification.
+func DiffSnapshots(old, new *Snapshot) []SnapshotChange {
+	oldEntries := make(map[string]SnapshotEntry, len(old.Entries))
+	for _, e := range old.Entries {
+		oldEntries[e.Path] = e
+	}
+	var changes []SnapshotChange
+	for _, e := range new.Entries {
+		o, ok := oldEntries[e.Path]
+		delete(oldEntries, e.Path)
+		switch {
+		case !ok:
+			changes = append(changes, SnapshotChange{Path: e.Path, Kind: ChangeAdded})
+		case e.Manifest != nil && o.Manifest != nil:
+			if o.Mode != e.Mode || !sameContent(o.Manifest, e.Manifest) {
+				d := DiffManifests(o.Manifest, e.Manifest)
+				changes = append(changes, SnapshotChange{Path: e.Path, Kind: ChangeModified, Delta: &d})
+			}
+		case o.Mode != e.Mode:
+			changes = append(changes, SnapshotChange{Path: e.Path, Kind: ChangeModified})
+		}
+	}
+	for path := range oldEntries {
+		changes = append(changes, SnapshotChange{Path: path, Kind: ChangeRemoved})
+	}
+	sort.Slice(changes, func(i, j int) bool { return changes[i].Path < changes[j].Path })
+	return changes
+}
+
+// sameContent reports whether two manifests consist of the same chunks.
+func sameContent(a, b *Manifest) bool {
+	if a.Size != b.Size || len(a.Chunks) != len(b.Chunks) {
+		return false
+	}
+	for i := range a.Chunks {
+		if !bytes.Equal(a.Chunks[i].Hash, b.Chunks[i].Hash) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,110 @@
+package ae
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"testing"
+	"testing/fstest"
+	"time"
+)
+
+func TestRepository_Snapshot(t *testing.T) {
+	dir := t.TempDir()
+	r, err := InitRepository(filepath.Join(dir, "repo"), RepositoryConfig{Parameters: Parameters{AverageSize: 16 * 1024}, PackSize: 256 * 1024})
+	require.NoError(t, err)
+
+	mtime := time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+	tree := fstest.MapFS{
+		"docs":           {Mode: fs.ModeDir | 0o750, ModTime: mtime},
+		"docs/a.txt":     {Data: testFile[:300*1024], Mode: 0o640, ModTime: mtime},
+		"docs/empty":     {Mode: fs.ModeDir | 0o755, ModTime: mtime},
+		"b.bin":          {Data: testFile[MiB : 2*MiB], Mode: 0o600, ModTime: mtime},
+		"c.bin":          {Data: []byte("unchanged"), Mode: 0o644, ModTime: mtime},
+		"link":           {Data: []byte("b.bin"), Mode: fs.ModeSymlink | 0o777},
+		"docs/later.txt": {Data: []byte("removed later"), Mode: 0o644, ModTime: mtime},
+	}
+	s1, err := r.CreateSnapshot(tree, "daily")
+	require.NoError(t, err)
+	assert.Len(t, s1.ID, 64)
+	var paths []string
+	for _, e := range s1.Entries {
+		paths = append(paths, e.Path)
+	}
+	assert.Equal(t, []string{"b.bin", "c.bin", "docs", "docs/a.txt", "docs/empty", "docs/later.txt"}, paths)
+
+	original := make(map[string][]byte)
+	for path, f := range tree {
+		original[path] = f.Data
+	}
+
+	// The second snapshot edits, adds and removes files.
+	edited := append(append([]byte{}, testFile[MiB:MiB+MiB/2]...), testFile[3*MiB:3*MiB+MiB/2]...)
+	tree["b.bin"] = &fstest.MapFile{Data: edited, Mode: 0o600, ModTime: mtime.Add(time.Hour)}
+	tree["new.txt"] = &fstest.MapFile{Data: []byte("new"), Mode: 0o644, ModTime: mtime}
+	delete(tree, "docs/later.txt")
+	s2, err := r.CreateSnapshot(tree)
+	require.NoError(t, err)
+
+	snapshots, err := r.Snapshots()
+	require.NoError(t, err)
+	require.Len(t, snapshots, 2)
+	assert.Equal(t, s1.ID, snapshots[0].ID)
+	assert.Equal(t, []string{"daily"}, snapshots[0].Tags)
+	assert.True(t, s1.Time.Equal(snapshots[0].Time))
+
+	changes := DiffSnapshots(s1, s2)
+	require.Len(t, changes, 3)
+	assert.Equal(t, "b.bin", changes[0].Path)
+	assert.Equal(t, ChangeModified, changes[0].Kind)
+	assert.Greater(t, changes[0].Delta.UnchangedBytes, int64(0))
+	assert.Equal(t, SnapshotChange{Path: "docs/later.txt", Kind: ChangeRemoved}, changes[1])
+	assert.Equal(t, SnapshotChange{Path: "new.txt", Kind: ChangeAdded}, changes[2])
+	assert.Equal(t, "modified", ChangeModified.String())
+
+	t.Run("restore", func(t *testing.T) {
+		out := filepath.Join(dir, "out")
+		s, err := r.Snapshot(s1.ID)
+		require.NoError(t, err)
+		require.NoError(t, r.RestoreSnapshot(s, out))
+		for _, e := range s1.Entries {
+			info, err := os.Stat(filepath.Join(out, e.Path))
+			require.NoError(t, err)
+			assert.Equal(t, e.Mode, info.Mode(), e.Path)
+			assert.True(t, mtime.Equal(info.ModTime()), e.Path)
+			if e.Manifest != nil {
+				data, err := os.ReadFile(filepath.Join(out, e.Path))
+				require.NoError(t, err)
+				assert.Equal(t, original[e.Path], data, e.Path)
+			}
+		}
+		_, err = os.Lstat(filepath.Join(out, "link"))
+		assert.ErrorIs(t, err, fs.ErrNotExist)
+	})
+
+	t.Run("garbage", func(t *testing.T) {
+		report, err := r.CollectGarbage(true)
+		require.NoError(t, err)
+		assert.Zero(t, report.Garbage)
+
+		require.NoError(t, r.DeleteSnapshot(s1.ID))
+		report, err = r.CollectGarbage(false)
+		require.NoError(t, err)
+		assert.Greater(t, report.Deleted, int64(0))
+
+		var buf bytes.Buffer
+		for _, e := range s2.Entries {
+			if e.Manifest != nil {
+				buf.Reset()
+				require.NoError(t, Reassemble(&buf, e.Manifest, r.Store()))
+			}
+		}
+		_, err = r.Snapshot(s1.ID)
+		assert.ErrorIs(t, err, fs.ErrNotExist)
+		_, err = r.Snapshot("../config")
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,160 @@
+package ae
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+)
+
+// fileRegion is a range of a file that either holds data or is a hole.
+type fileRegion struct {
+	offset, length int64
+	hole           bool
+}
+
+// ChunkFile chunks the file f like StoreFile and puts every chunk into s, unless s is nil.
+// Holes in sparse files, as reported by SEEK_HOLE and SEEK_DATA on Linux, FreeBSD and macOS, are not read:
+// they are recorded as zero-chunks of the maximum chunk size, all of which share a single stored chunk.
+// Holes shorter than the average chunk size are chunked as data, as are all files on other platforms,
+// so the manifest of a file without holes is the same as the one built by StoreFile.
+//
+// Chunk boundaries start afresh after every hole. ReassembleSparse recreates the holes.
+// Files other than regular ones, such as pipes, are read from their current position instead.
+func ChunkFile(f *os.File, s ChunkStore, opts *Options) (*Manifest, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if !info.Mode().IsRegular() {
+		if s == nil {
+			return BuildManifest(f, opts)
+		}
+		return StoreFile(f, s, opts)
+	}
+	regions, err := fileRegions(f, info.Size())
+	if err != nil {
+		return nil, err
+	}
+	m, err := buildManifest(bytes.NewReader(nil), opts, nil)
+	if err != nil {
+		return nil, err
+	}
+	regions = mergeHoles(regions, m.Parameters.AverageSize)
+
+	hasher, err := manifestHasher(m)
+	if err != nil {
+		return nil, err
+	}
+	zeroHashes := make(map[int64][]byte)
+	for _, region := range regions {
+		if !region.hole {
+			var fn func(c *Chunk) error
+			if s != nil {
+				fn = func(c *Chunk) error {
+					if err := s.Put(c.Sum, c.Data); err != nil {
+						return fmt.Errorf("ae: storing chunk at offset %d: %w", region.offset+c.Offset, err)
+					}
+					return nil
+				}
+			}
+			rm, err := buildManifest(io.NewSectionReader(f, region.offset, region.length), opts, fn)
+			if err != nil {
+				return nil, err
+			}
+			for _, ref := range rm.Chunks {
+				ref.Offset += region.offset
+				m.Chunks = append(m.Chunks, ref)
+			}
+			m.Size += rm.Size
+			continue
+		}
+		for off := int64(0); off < region.length; off += m.Parameters.MaxSize {
+			n := region.length - off
+			if n > m.Parameters.MaxSize {
+				n = m.Parameters.MaxSize
+			}
+			hash, ok := zeroHashes[n]
+			if !ok {
+				zeros := make([]byte, n)
+				h := hasher()
+				h.Write(zeros)
+				hash = h.Sum(nil)
+				if s != nil {
+					if err := s.Put(hash, zeros); err != nil {
+						return nil, fmt.Errorf("ae: storing chunk at offset %d: %w", region.offset+off, err)
+					}
+				}
+				zeroHashes[n] = hash
+			}
+			m.Chunks = append(m.Chunks, ChunkRef{Offset: region.offset + off, Length: n, Hash: hash})
+			m.Size += n
+		}
+	}
+	return m, nil
+}
+
+// mergeHoles turns the holes shorter than minSize into data, merging them with the data around them.
+func mergeHoles(regions []fileRegion, minSize int64) []fileRegion {
+	var merged []fileRegion
+	for _, region := range regions {
+		if region.hole && region.length < minSize {
+			region.hole = false
+		}
+		if last := len(merged) - 1; last >= 0 && merged[last].hole == region.hole {
+			merged[last].length += region.length
+			continue
+		}
+		merged = append(merged, region)
+	}
+	return merged
+}
+
+// ReassembleSparse writes the file described by m to f from offset 0 like Reassemble, but skips chunks of zeros
+// instead of writing them, leaving holes in file systems supporting sparse files. Any previous content of f is discarded.
+// Chunks whose hash is known to be of zeros are not even fetched again.
+func ReassembleSparse(f *os.File, m *Manifest, s ChunkStore) error {
+	hasher, err := manifestHasher(m)
+	if err != nil {
+		return err
+	}
+	if err := f.Truncate(0); err != nil {
+		return err
+	}
+	h := hasher()
+	zeroHashes := make(map[string]bool)
+	for i, ref := range m.Chunks {
+		if zeroHashes[string(ref.Hash)] {
+			continue
+		}
+		data, err := fetchChunk(s, h, i, ref)
+		if err != nil {
+			return err
+		}
+		if isZero(data) {
+			zeroHashes[string(ref.Hash)] = true
+			continue
+		}
+		if _, err := f.WriteAt(data, ref.Offset); err != nil {
+			return err
+		}
+	}
+	// Extending the file leaves a hole where it ends in zeros.
+	return f.Truncate(m.Size)
+}
+
+// isZero reports whether b consists of zeros only.
+func isZero(b []byte) bool {
+	for len(b) >= 8 {
+		if b[0]|b[1]|b[2]|b[3]|b[4]|b[5]|b[6]|b[7] != 0 {
+			return false
+		}
+		b = b[8:]
+	}
+	for _, x := range b {
+		if x != 0 {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,89 @@
+package ae
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// holes returns the total length of the holes that fileRegions detects in f.
+func holes(t *testing.T, f *os.File) int64 {
+	info, err := f.Stat()
+	require.NoError(t, err)
+	regions, err := fileRegions(f, info.Size())
+	require.NoError(t, err)
+	var n int64
+	for _, region := range regions {
+		if region.hole {
+			n += region.length
+		}
+	}
+	return n
+}
+
+func TestChunkFile(t *testing.T) {
+	dir := t.TempDir()
+	f, err := os.Create(filepath.Join(dir, "sparse"))
+	require.NoError(t, err)
+	defer f.Close()
+	const size = 64 * MiB
+	require.NoError(t, f.Truncate(size))
+	_, err = f.WriteAt(testFile[:MiB], 0)
+	require.NoError(t, err)
+	_, err = f.WriteAt(testFile[MiB:2*MiB], 32*MiB)
+	require.NoError(t, err)
+	want := make([]byte, size)
+	copy(want, testFile[:MiB])
+	copy(want[32*MiB:], testFile[MiB:2*MiB])
+	if holes(t, f) == 0 {
+		t.Skip("file system does not report holes")
+	}
+
+	s := NewMemoryStore(0)
+	opts := &Options{AverageSize: 64 * 1024}
+	m, err := ChunkFile(f, s, opts)
+	require.NoError(t, err)
+	require.NoError(t, m.Validate())
+	assert.Equal(t, int64(size), m.Size)
+	// The holes make up a handful of chunks sharing at most two hashes.
+	assert.Less(t, countChunks(t, s), int(2*MiB/(64*1024)+4))
+	var buf bytes.Buffer
+	require.NoError(t, Reassemble(&buf, m, s))
+	assert.True(t, bytes.Equal(want, buf.Bytes()))
+
+	out, err := os.Create(filepath.Join(dir, "restored"))
+	require.NoError(t, err)
+	defer out.Close()
+	_, err = out.Write(testFile[:4*MiB])
+	require.NoError(t, err)
+	require.NoError(t, ReassembleSparse(out, m, s))
+	restored, err := os.ReadFile(out.Name())
+	require.NoError(t, err)
+	assert.True(t, bytes.Equal(want, restored))
+	assert.Greater(t, holes(t, out), int64(size-4*MiB))
+
+	// Without holes, the manifest is the one built by StoreFile.
+	dense, err := os.Open(out.Name())
+	require.NoError(t, err)
+	defer dense.Close()
+	_, err = out.WriteAt(testFile[:size], 0)
+	require.NoError(t, err)
+	m, err = ChunkFile(dense, nil, opts)
+	require.NoError(t, err)
+	expected, err := BuildManifest(bytes.NewReader(testFile[:size]), opts)
+	require.NoError(t, err)
+	assert.Equal(t, expected, m)
+}
+
+func TestMergeHoles(t *testing.T) {
+	regions := mergeHoles([]fileRegion{
+		{offset: 0, length: 10},
+		{offset: 10, length: 5, hole: true},
+		{offset: 15, length: 10},
+		{offset: 25, length: 100, hole: true},
+	}, 8)
+	assert.Equal(t, []fileRegion{{offset: 0, length: 25}, {offset: 25, length: 100, hole: true}}, regions)
+}
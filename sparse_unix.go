@@ -0,0 +1,54 @@
+//go:build linux || freebsd || darwin
+
+package ae
+
+import (
+	"errors"
+	"os"
+	"runtime"
+	"syscall"
+)
+
+// fileRegions returns the data and holes of the file f of the given size, as reported by SEEK_DATA and SEEK_HOLE.
+// If the file system does not support them, the whole file is data.
+func fileRegions(f *os.File, size int64) ([]fileRegion, error) {
+	// The values of the whence arguments differ between the platforms.
+	seekData, seekHole := 3, 4
+	if runtime.GOOS == "darwin" {
+		seekData, seekHole = 4, 3
+	}
+	whole := []fileRegion{{offset: 0, length: size}}
+	var regions []fileRegion
+	for off := int64(0); off < size; {
+		data, err := f.Seek(off, seekData)
+		if errors.Is(err, syscall.ENXIO) {
+			// There is no data past off.
+			data = size
+		} else if errors.Is(err, syscall.EINVAL) {
+			return whole, nil
+		} else if err != nil {
+			return nil, err
+		}
+		if data > size {
+			data = size
+		}
+		if data > off {
+			regions = append(regions, fileRegion{offset: off, length: data - off, hole: true})
+		}
+		if data == size {
+			break
+		}
+		hole, err := f.Seek(data, seekHole)
+		if errors.Is(err, syscall.EINVAL) {
+			return whole, nil
+		} else if err != nil {
+			return nil, err
+		}
+		if hole > size {
+			hole = size
+		}
+		regions = append(regions, fileRegion{offset: data, length: hole - data})
+		off = hole
+	}
+	return regions, nil
+}
@@ -0,0 +1,36 @@
+package ae
+
+import (
+	"io"
+)
+
+// Splitter adapts the Chunker to the Splitter interface of go-ipfs-chunker (github.com/ipfs/boxo/chunker),
+// so that AE chunking can be plugged into UnixFS imports. Go interfaces being implicit, this package does not
+// depend on go-ipfs-chunker; a SplitterGen is written as
+//
+//	func(r io.Reader) chunk.Splitter { return ae.NewSplitter(r, opts) }
+type Splitter struct {
+	ch *Chunker
+	r  io.Reader
+}
+
+// NewSplitter returns a Splitter chunking r with opts.
+// No hash is computed unless configured, as UnixFS hashes the blocks itself.
+func NewSplitter(r io.Reader, opts *Options) *Splitter {
+	return &Splitter{ch: NewChunker(r, opts), r: r}
+}
+
+// Reader returns the reader being split.
+func (s *Splitter) Reader() io.Reader {
+	return s.r
+}
+
+// NextBytes returns the data of the next chunk, or io.EOF once the input is exhausted.
+// The caller owns the returned slice.
+func (s *Splitter) NextBytes() ([]byte, error) {
+	c, err := s.ch.Next()
+	if err != nil {
+		return nil, err
+	}
+	return c.Data, nil
+}
@@ -0,0 +1,39 @@
+package ae
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"testing"
+)
+
+// ipfsSplitter mirrors the Splitter interface of go-ipfs-chunker.
+type ipfsSplitter interface {
+	Reader() io.Reader
+	NextBytes() ([]byte, error)
+}
+
+var _ ipfsSplitter = (*Splitter)(nil)
+
+func TestSplitter(t *testing.T) {
+	input := testFile[:4*MiB]
+	opts := &Options{AverageSize: 64 * 1024}
+	r := bytes.NewReader(input)
+	s := NewSplitter(r, opts)
+	assert.Equal(t, r, s.Reader())
+
+	var blocks [][]byte
+	for {
+		b, err := s.NextBytes()
+		if err == io.EOF {
+			break
+		}
+		assert.NoError(t, err)
+		blocks = append(blocks, b)
+	}
+	assert.Equal(t, getChunks(NewChunker(bytes.NewReader(input), opts)), blocks)
+
+	b, err := s.NextBytes()
+	assert.Nil(t, b)
+	assert.Equal(t, io.EOF, err)
+}
@@ -0,0 +1,120 @@
+package ae
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"io"
+)
+
+// stateVersion is the version of the format written by SaveState,
+// which follows the values of version 1 with the optional parameters as in a binary manifest
+// and, for a tar stream, the offset of its next header.
+const stateVersion = 2
+
+// stateMagic prefixes every saved state.
+var stateMagic = []byte("AEcs")
+
+// ErrInvalidState is returned by RestoreChunker if the state cannot be decoded.
+var ErrInvalidState = errors.New("ae: invalid chunker state")
+
+// errStateBoundaries is returned when saving the state of a Chunker with explicit Boundaries, which are not part of it.
+var errStateBoundaries = errors.New("ae: the state of a Chunker with explicit Boundaries cannot be saved")
+
+// Offset returns the offset in the input at which the next chunk starts.
+func (ch *Chunker) Offset() int64 {
+	return ch.offset
+}
+
+// SaveState captures the state of the Chunker so that it can be resumed later on by RestoreChunker.
+// Boundaries only depend on the data following the last one,
+// so the state merely consists of the parameters and the offset of the next chunk,
+// plus the offset of the next header of a tar stream. Chunkers with explicit Boundaries cannot be saved.
+func (ch *Chunker) SaveState() ([]byte, error) {
+	if ch.err != nil && ch.err != io.EOF {
+		return nil, ch.err
+	}
+	if len(ch.entries) > 0 {
+		return nil, errStateBoundaries
+	}
+	state := make([]byte, 0, len(stateMagic)+1+4*binary.MaxVarintLen64)
+	state = append(state, stateMagic...)
+	state = append(state, stateVersion)
+	var buf [binary.MaxVarintLen64]byte
+	for _, v := range []int64{ch.avgSize, ch.maxSize, int64(ch.extremum), ch.offset} {
+		state = append(state, buf[:binary.PutVarint(buf[:], v)]...)
+	}
+	p := ch.parameters()
+	state = appendParams(state, &p)
+	if ch.tar {
+		next := ch.offset
+		if ch.tarInput != nil {
+			var ok bool
+			if next, ok = ch.tarInput.nextHeader(ch.offset, ch.boundaries); !ok {
+				return nil, errors.New("ae: cannot save the state within a sparse file of a tar stream")
+			}
+		}
+		state = append(state, buf[:binary.PutVarint(buf[:], next)]...)
+	}
+	return state, nil
+}
+
+// RestoreChunker returns a Chunker that continues where the one that saved state left off,
+// yielding the exact same chunks from there on.
+// If r implements io.Seeker, it is positioned at the start of the next chunk (cf. Offset).
+// Otherwise, r must already yield the input from that position on.
+// Options that cannot be serialized, such as the Hasher, are not part of the state and thus not restored.
+func RestoreChunker(r io.Reader, state []byte) (*Chunker, error) {
+	if len(state) < len(stateMagic)+1 || string(state[:len(stateMagic)]) != string(stateMagic) {
+		return nil, ErrInvalidState
+	}
+	version := state[len(stateMagic)]
+	if version != 1 && version != stateVersion {
+		return nil, ErrInvalidState
+	}
+	state = state[len(stateMagic)+1:]
+
+	var values [4]int64
+	for i := range values {
+		v, n := binary.Varint(state)
+		if n <= 0 {
+			return nil, ErrInvalidState
+		}
+		values[i] = v
+		state = state[n:]
+	}
+	p := Parameters{AverageSize: values[0], MaxSize: values[1], Mode: Extremum(values[2])}
+	offset := values[3]
+	var next int64
+	if version == stateVersion {
+		br := bytes.NewReader(state)
+		if err := readParams(br, &p); err != nil {
+			return nil, ErrInvalidState
+		}
+		state = state[len(state)-br.Len():]
+		if p.Tar {
+			v, n := binary.Varint(state)
+			if n <= 0 || v >= 0 && v < offset {
+				return nil, ErrInvalidState
+			}
+			next = v
+			state = state[n:]
+		}
+	}
+	if len(state) != 0 || p.AverageSize <= 0 || p.MaxSize <= 0 || (p.Mode != MAX && p.Mode != MIN) || offset < 0 {
+		return nil, ErrInvalidState
+	}
+
+	if s, ok := r.(io.Seeker); ok {
+		if _, err := s.Seek(offset, io.SeekStart); err != nil {
+			return nil, err
+		}
+	}
+	ch := NewChunker(r, p.Options())
+	ch.offset = offset
+	if p.Tar {
+		ch.tarInput = resumeTarReader(r, offset, next)
+		ch.reader = ch.tarInput
+	}
+	return ch, nil
+}
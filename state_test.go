@@ -0,0 +1,58 @@
+package ae
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"testing"
+)
+
+func TestChunker_SaveState(t *testing.T) {
+	input := testFile[:4*MiB]
+	opts := &Options{AverageSize: 64 * 1024, MaxSize: 100 * 1024, Mode: MIN}
+	expected := getChunks(NewChunker(bytes.NewReader(input), opts))
+
+	c := NewChunker(bytes.NewReader(input), opts)
+	var chunks [][]byte
+	for i := 0; i < 10; i++ {
+		chunks = append(chunks, c.NextChunk())
+	}
+	state, err := c.SaveState()
+	assert.NoError(t, err)
+
+	t.Run("resume with seeker", func(t *testing.T) {
+		r, err := RestoreChunker(bytes.NewReader(input), state)
+		assert.NoError(t, err)
+		assert.Equal(t, c.Offset(), r.Offset())
+		assert.Equal(t, expected, append(chunks, getChunks(r)...))
+	})
+
+	t.Run("resume with positioned reader", func(t *testing.T) {
+		r, err := RestoreChunker(io.MultiReader(bytes.NewReader(input[c.Offset():])), state)
+		assert.NoError(t, err)
+		resumed := getChunks(r)
+		assert.Equal(t, expected, append(chunks, resumed...))
+	})
+
+	t.Run("offsets continue", func(t *testing.T) {
+		r, err := RestoreChunker(bytes.NewReader(input), state)
+		assert.NoError(t, err)
+		chunk, err := r.Next()
+		assert.NoError(t, err)
+		assert.Equal(t, c.Offset(), chunk.Offset)
+	})
+
+	t.Run("explicit boundaries", func(t *testing.T) {
+		c := NewChunker(bytes.NewReader(input), &Options{AverageSize: 64 * 1024, Boundaries: []int64{MiB}})
+		c.NextChunk()
+		_, err := c.SaveState()
+		assert.Error(t, err)
+	})
+
+	t.Run("invalid state", func(t *testing.T) {
+		for _, s := range [][]byte{nil, []byte("AEcs"), state[:len(state)-1], append(state, 0)} {
+			_, err := RestoreChunker(bytes.NewReader(input), s)
+			assert.Equal(t, ErrInvalidState, err)
+		}
+	})
+}
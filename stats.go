@@ -0,0 +1,248 @@
+package ae
+
+import (
+	"math"
+	"sync"
+)
+
+// ChunkerStats are counters describing the work done by a Chunker.
+type ChunkerStats struct {
+	// BytesRead from the input, including bytes that are buffered but not yet emitted.
+	BytesRead int64
+
+	// BytesEmitted as part of chunks.
+	BytesEmitted int64
+
+	// Chunks emitted.
+	Chunks int64
+
+	// ExtremumCuts is the number of boundaries found by the algorithm.
+	ExtremumCuts int64
+
+	// MaxSizeCuts is the number of chunks truncated at MaxSize.
+	MaxSizeCuts int64
+
+	// EOFCuts is the number of chunks that ended with the input.
+	EOFCuts int64
+
+	// ForcedCuts is the number of chunks cut by a flush.
+	ForcedCuts int64
+
+	// AlignedCuts is the number of boundaries found by the algorithm and moved onto a multiple of the alignment.
+	AlignedCuts int64
+
+	// FixedCuts is the number of chunks of the fixed size of the Fixed algorithm, which do not count as truncated.
+	FixedCuts int64
+}
+
+// AverageSize returns the realized average size of the emitted chunks.
+func (s ChunkerStats) AverageSize() float64 {
+	if s.Chunks == 0 {
+		return 0
+	}
+	return float64(s.BytesEmitted) / float64(s.Chunks)
+}
+
+// TruncationRate returns the share of the emitted chunks that were truncated at MaxSize.
+// A high rate means that MaxSize is too small for the data, as the chunks are then no longer content defined.
+func (s ChunkerStats) TruncationRate() float64 {
+	if s.Chunks == 0 {
+		return 0
+	}
+	return float64(s.MaxSizeCuts) / float64(s.Chunks)
+}
+
+// count records a chunk of n bytes that ended for the given reason.
+func (s *ChunkerStats) count(n int, reason CutReason) {
+	s.Chunks++
+	s.BytesEmitted += int64(n)
+	switch reason {
+	case CutEOF:
+		s.EOFCuts++
+	case CutExtremum:
+		s.ExtremumCuts++
+	case CutForced:
+		s.ForcedCuts++
+	case CutAligned:
+		s.AlignedCuts++
+	case CutFixed:
+		s.FixedCuts++
+	default:
+		s.MaxSizeCuts++
+	}
+}
+
+// Stats returns the counters of the Chunker.
+func (ch *Chunker) Stats() ChunkerStats {
+	return ch.stats
+}
+
+// statsAccuracy is the relative accuracy of the quantiles estimated by Stats.
+const statsAccuracy = 0.01
+
+// statsGamma is the ratio between the bounds of the buckets of the quantile sketch of Stats.
+var statsGamma = (1 + statsAccuracy) / (1 - statsAccuracy)
+
+// Stats aggregates chunk sizes in constant memory. Besides exact counts, mean, variance and extrema,
+// it estimates quantiles with a logarithmic sketch accurate to 1% of the true value.
+// The zero value is ready to use, and it is safe for concurrent use.
+type Stats struct {
+	mu    sync.Mutex
+	count int64
+	total int64
+	min   int64
+	max   int64
+
+	// mean and m2 are updated with Welford's algorithm; m2 is the sum of squared deviations from the mean.
+	mean float64
+	m2   float64
+
+	// zeros is the number of sizes less than 1, which do not fit the sketch.
+	zeros int64
+
+	// buckets counts the sizes in (statsGamma^(i-1), statsGamma^i] at index i.
+	buckets []int64
+}
+
+// Add records a chunk of n bytes.
+func (s *Stats) Add(n int64) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.count == 0 || n < s.min {
+		s.min = n
+	}
+	if s.count == 0 || n > s.max {
+		s.max = n
+	}
+	s.count++
+	s.total += n
+	d := float64(n) - s.mean
+	s.mean += d / float64(s.count)
+	s.m2 += d * (float64(n) - s.mean)
+
+	if n < 1 {
+		s.zeros++
+		return
+	}
+	i := int(math.Ceil(math.Log(float64(n)) / math.Log(statsGamma)))
+	for len(s.buckets) <= i {
+		s.buckets = append(s.buckets, 0)
+	}
+	s.buckets[i]++
+}
+
+// Merge adds all sizes recorded by o to s, e.g. to aggregate the statistics of several workers.
+func (s *Stats) Merge(o *Stats) {
+	o.mu.Lock()
+	count, total, lo, hi, mean, m2, zeros := o.count, o.total, o.min, o.max, o.mean, o.m2, o.zeros
+	buckets := append([]int64(nil), o.buckets...)
+	o.mu.Unlock()
+	if count == 0 {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.count == 0 || lo < s.min {
+		s.min = lo
+	}
+	if s.count == 0 || hi > s.max {
+		s.max = hi
+	}
+	n := s.count + count
+	d := mean - s.mean
+	s.m2 += m2 + d*d*float64(s.count)*float64(count)/float64(n)
+	s.mean += d * float64(count) / float64(n)
+	s.count = n
+	s.total += total
+	s.zeros += zeros
+	for len(s.buckets) < len(buckets) {
+		s.buckets = append(s.buckets, 0)
+	}
+	for i, c := range buckets {
+		s.buckets[i] += c
+	}
+}
+
+// Count returns the number of recorded chunks.
+func (s *Stats) Count() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.count
+}
+
+// Total returns the sum of the recorded sizes.
+func (s *Stats) Total() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.total
+}
+
+// Mean returns the mean size, or 0 if no chunks were recorded.
+func (s *Stats) Mean() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.mean
+}
+
+// Variance returns the population variance of the sizes.
+func (s *Stats) Variance() float64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.count == 0 {
+		return 0
+	}
+	return s.m2 / float64(s.count)
+}
+
+// StdDev returns the population standard deviation of the sizes.
+func (s *Stats) StdDev() float64 {
+	return math.Sqrt(s.Variance())
+}
+
+// Min returns the smallest recorded size.
+func (s *Stats) Min() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.min
+}
+
+// Max returns the largest recorded size.
+func (s *Stats) Max() int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.max
+}
+
+// Quantile estimates the q-quantile (0 to 1) of the sizes, e.g. 0.5 for the median,
+// within 1% of the size of that rank. It returns 0 if no chunks were recorded.
+func (s *Stats) Quantile(q float64) int64 {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.count == 0 {
+		return 0
+	}
+	rank := int64(math.Ceil(q * float64(s.count)))
+	if rank < 1 {
+		rank = 1
+	}
+	if rank <= s.zeros {
+		return s.min
+	}
+	seen := s.zeros
+	for i, c := range s.buckets {
+		seen += c
+		if seen >= rank {
+			v := int64(math.Round(2 * math.Pow(statsGamma, float64(i)) / (statsGamma + 1)))
+			if v < s.min {
+				v = s.min
+			}
+			if v > s.max {
+				v = s.max
+			}
+			return v
+		}
+	}
+	return s.max
+}
@@ -0,0 +1,152 @@
+package ae
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"math"
+	"math/rand"
+	"sort"
+	"sync"
+	"testing"
+)
+
+func TestChunker_Stats(t *testing.T) {
+	t.Run("random data", func(t *testing.T) {
+		input := testFile[:8*MiB]
+		// With the default MaxSize of twice the average size, random data is never truncated.
+		c := NewChunker(bytes.NewReader(input), &Options{AverageSize: 64 * 1024})
+		chunks := getChunks(c)
+
+		s := c.Stats()
+		assert.Equal(t, int64(len(input)), s.BytesRead)
+		assert.Equal(t, int64(len(input)), s.BytesEmitted)
+		assert.Equal(t, int64(len(chunks)), s.Chunks)
+		assert.Equal(t, s.Chunks, s.ExtremumCuts+s.MaxSizeCuts+s.EOFCuts)
+		assert.Equal(t, int64(1), s.EOFCuts)
+		assert.Equal(t, s.Chunks-1, s.ExtremumCuts)
+		assert.InDelta(t, float64(len(input))/float64(len(chunks)), s.AverageSize(), 0.001)
+	})
+
+	t.Run("strictly increasing bytes are truncated", func(t *testing.T) {
+		data := make([]byte, 1000)
+		for i := range data {
+			data[i] = byte(i / 4)
+		}
+		c := NewChunker(bytes.NewReader(data), &Options{AverageSize: 100, MaxSize: 300})
+		getChunks(c)
+		assert.Equal(t, ChunkerStats{BytesRead: 1000, BytesEmitted: 1000, Chunks: 4, MaxSizeCuts: 3, EOFCuts: 1}, c.Stats())
+		assert.Equal(t, 0.75, c.Stats().TruncationRate())
+	})
+
+	t.Run("fixed chunks are not truncated", func(t *testing.T) {
+		c := NewChunker(bytes.NewReader(make([]byte, 1000)), &Options{AverageSize: 300, Algorithm: Fixed})
+		getChunks(c)
+		assert.Equal(t, ChunkerStats{BytesRead: 1000, BytesEmitted: 1000, Chunks: 4, FixedCuts: 3, EOFCuts: 1}, c.Stats())
+		assert.Zero(t, c.Stats().TruncationRate())
+	})
+
+	t.Run("no chunks", func(t *testing.T) {
+		assert.Zero(t, ChunkerStats{}.AverageSize())
+		assert.Zero(t, ChunkerStats{}.TruncationRate())
+	})
+}
+
+func TestStats(t *testing.T) {
+	rnd := rand.New(rand.NewSource(1))
+	sizes := make([]int64, 100000)
+	for i := range sizes {
+		sizes[i] = 1 + rnd.Int63n(1<<20)
+	}
+
+	var s Stats
+	var total int64
+	for _, n := range sizes {
+		s.Add(n)
+		total += n
+	}
+	sorted := append([]int64(nil), sizes...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	mean := float64(total) / float64(len(sizes))
+	var variance float64
+	for _, n := range sizes {
+		variance += (float64(n) - mean) * (float64(n) - mean)
+	}
+	variance /= float64(len(sizes))
+
+	assert.Equal(t, int64(len(sizes)), s.Count())
+	assert.Equal(t, total, s.Total())
+	assert.InEpsilon(t, mean, s.Mean(), 1e-9)
+	assert.InEpsilon(t, variance, s.Variance(), 1e-9)
+	assert.InEpsilon(t, math.Sqrt(variance), s.StdDev(), 1e-9)
+	assert.Equal(t, sorted[0], s.Min())
+	assert.Equal(t, sorted[len(sorted)-1], s.Max())
+	for _, q := range []float64{0, 0.01, 0.25, 0.5, 0.9, 0.99, 1} {
+		rank := int(math.Ceil(q * float64(len(sorted))))
+		if rank < 1 {
+			rank = 1
+		}
+		assert.InEpsilon(t, sorted[rank-1], s.Quantile(q), statsAccuracy, "q=%g", q)
+	}
+
+	t.Run("merge", func(t *testing.T) {
+		var a, b Stats
+		for i, n := range sizes {
+			if i%3 == 0 {
+				a.Add(n)
+			} else {
+				b.Add(n)
+			}
+		}
+		var merged Stats
+		merged.Merge(&a)
+		merged.Merge(&Stats{})
+		merged.Merge(&b)
+		assert.Equal(t, s.Count(), merged.Count())
+		assert.Equal(t, s.Total(), merged.Total())
+		assert.InEpsilon(t, s.Mean(), merged.Mean(), 1e-9)
+		assert.InEpsilon(t, s.Variance(), merged.Variance(), 1e-9)
+		assert.Equal(t, s.Min(), merged.Min())
+		assert.Equal(t, s.Max(), merged.Max())
+		for _, q := range []float64{0.1, 0.5, 0.9} {
+			assert.Equal(t, s.Quantile(q), merged.Quantile(q))
+		}
+	})
+
+	t.Run("concurrent", func(t *testing.T) {
+		var c Stats
+		var wg sync.WaitGroup
+		for i := 0; i < 8; i++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				for j := 0; j < 1000; j++ {
+					c.Add(int64(j))
+				}
+			}()
+		}
+		wg.Wait()
+		assert.Equal(t, int64(8000), c.Count())
+		assert.Equal(t, int64(0), c.Min())
+		assert.Equal(t, int64(0), c.Quantile(0.0001))
+		assert.Equal(t, int64(999), c.Max())
+	})
+
+	t.Run("empty", func(t *testing.T) {
+		var e Stats
+		assert.Zero(t, e.Count())
+		assert.Zero(t, e.Mean())
+		assert.Zero(t, e.Variance())
+		assert.Zero(t, e.Quantile(0.5))
+	})
+
+	t.Run("chunker", func(t *testing.T) {
+		var cs Stats
+		for _, c := range getChunks(NewChunker(bytes.NewReader(testFile[:4*MiB]), &Options{AverageSize: 64 * 1024})) {
+			cs.Add(int64(len(c)))
+		}
+		assert.Equal(t, int64(4*MiB), cs.Total())
+		assert.LessOrEqual(t, cs.Max(), int64(128*1024))
+		assert.LessOrEqual(t, cs.Quantile(0.5), cs.Max())
+	})
+}
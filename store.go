@@ -0,0 +1,46 @@
+package ae
+
+import (
+	"errors"
+)
+
+// ErrChunkNotFound is returned by a ChunkStore if there is no chunk stored under a hash.
+var ErrChunkNotFound = errors.New("ae: chunk not found")
+
+// ErrWalkUnsupported is returned if a ChunkStore is unable to enumerate its chunks.
+var ErrWalkUnsupported = errors.New("ae: store cannot enumerate its chunks")
+
+// ErrReadOnly is returned by a ChunkStore that cannot be written to, such as an HTTPStore.
+var ErrReadOnly = errors.New("ae: store is read-only")
+
+// ChunkStore stores chunks addressed by their hash.
+// Implementations must be safe for concurrent use.
+type ChunkStore interface {
+	// Put stores data under hash. Storing a chunk that is already present is a no-op.
+	Put(hash []byte, data []byte) error
+
+	// Get returns the data stored under hash, or ErrChunkNotFound.
+	Get(hash []byte) ([]byte, error)
+
+	// Has reports whether a chunk is stored under hash.
+	Has(hash []byte) (bool, error)
+
+	// Delete removes the chunk stored under hash, or returns ErrChunkNotFound.
+	Delete(hash []byte) error
+}
+
+// ChunkWalker is implemented by ChunkStores that can enumerate their chunks.
+type ChunkWalker interface {
+	// Walk calls fn for every stored chunk with its hash and stored size in bytes.
+	// It stops at the first error returned by fn. The store must not be modified by fn.
+	Walk(fn func(hash []byte, size int64) error) error
+}
+
+// walkStore calls fn for every chunk in s, or returns ErrWalkUnsupported if s is not a ChunkWalker.
+func walkStore(s ChunkStore, fn func(hash []byte, size int64) error) error {
+	w, ok := s.(ChunkWalker)
+	if !ok {
+		return ErrWalkUnsupported
+	}
+	return w.Walk(fn)
+}
@@ -0,0 +1,127 @@
+// Package storeurl opens the ChunkStore backends of this module by URL, so that the backend of a tool
+// can be chosen by its configuration.
+//
+// The scheme of the URL selects the backend:
+//
+//	s3://bucket/prefix         the S3 API, see s3store
+//	azblob://container/prefix  Azure Blob Storage, see azstore
+//	gs://bucket/prefix         Google Cloud Storage, see gcsstore
+//	file:///dir                a directory, see ae.FileStore
+//	https://host/dir           a directory on a static web server, read-only, see ae.HTTPStore
+//
+// A URL without a scheme is the path of a directory. The query parameters "endpoint", "concurrency" and "retries"
+// configure any of the remote backends, "region" the S3 backend and "account" the Azure backend.
+// Credentials are taken from the environment: AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN for S3,
+// AZURE_STORAGE_ACCOUNT, AZURE_STORAGE_KEY and AZURE_STORAGE_SAS_TOKEN for Azure,
+// and GOOGLE_OAUTH_ACCESS_TOKEN for Cloud Storage.
+package storeurl
+
+import (
+	"fmt"
+	ae "github.com/mg98/ae-chunker-go"
+	"github.com/mg98/ae-chunker-go/azstore"
+	"github.com/mg98/ae-chunker-go/gcsstore"
+	"github.com/mg98/ae-chunker-go/s3store"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// Open returns the ChunkStore described by rawURL.
+func Open(rawURL string) (ae.ChunkStore, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Scheme == "" || len(u.Scheme) == 1 {
+		// Not a URL, or a Windows path with a drive letter.
+		return ae.NewFileStore(rawURL, nil)
+	}
+	query := u.Query()
+	prefix := strings.TrimPrefix(u.Path, "/")
+	if prefix != "" && !strings.HasSuffix(prefix, "/") {
+		prefix += "/"
+	}
+	concurrency, err := intParam(query, "concurrency")
+	if err != nil {
+		return nil, err
+	}
+	retries, err := intParam(query, "retries")
+	if err != nil {
+		return nil, err
+	}
+
+	switch u.Scheme {
+	case "file":
+		if u.Host != "" && u.Host != "localhost" {
+			return nil, fmt.Errorf("storeurl: unsupported host %q in %s", u.Host, rawURL)
+		}
+		return ae.NewFileStore(u.Path, nil)
+	case "http", "https":
+		return ae.NewHTTPStore(rawURL, nil)
+	case "s3":
+		endpoint := query.Get("endpoint")
+		if endpoint == "" {
+			endpoint = os.Getenv("AWS_ENDPOINT_URL")
+		}
+		region := query.Get("region")
+		if region == "" {
+			region = os.Getenv("AWS_REGION")
+		}
+		if endpoint == "" {
+			r := region
+			if r == "" {
+				r = "us-east-1"
+			}
+			endpoint = "https://s3." + r + ".amazonaws.com"
+		}
+		return s3store.New(s3store.Options{
+			Endpoint:        endpoint,
+			Region:          region,
+			Bucket:          u.Host,
+			Prefix:          prefix,
+			AccessKeyID:     os.Getenv("AWS_ACCESS_KEY_ID"),
+			SecretAccessKey: os.Getenv("AWS_SECRET_ACCESS_KEY"),
+			SessionToken:    os.Getenv("AWS_SESSION_TOKEN"),
+			Concurrency:     concurrency,
+			Retries:         retries,
+		})
+	case "azblob":
+		account := query.Get("account")
+		if account == "" {
+			account = os.Getenv("AZURE_STORAGE_ACCOUNT")
+		}
+		return azstore.New(azstore.Options{
+			Endpoint:    query.Get("endpoint"),
+			Account:     account,
+			Key:         os.Getenv("AZURE_STORAGE_KEY"),
+			SASToken:    os.Getenv("AZURE_STORAGE_SAS_TOKEN"),
+			Container:   u.Host,
+			Prefix:      prefix,
+			Concurrency: concurrency,
+			Retries:     retries,
+		})
+	case "gs":
+		return gcsstore.New(gcsstore.Options{
+			Endpoint:    query.Get("endpoint"),
+			Bucket:      u.Host,
+			Prefix:      prefix,
+			Token:       os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN"),
+			Concurrency: concurrency,
+			Retries:     retries,
+		})
+	default:
+		return nil, fmt.Errorf("storeurl: unsupported scheme %q", u.Scheme)
+	}
+}
+
+// intParam returns the integer value of the query parameter name, or 0 if it is not set.
+func intParam(query url.Values, name string) (int, error) {
+	v := query.Get(name)
+	if v == "" {
+		return 0, nil
+	}
+	n, err := strconv.Atoi(v)
+	if err != nil {
+		return 0, fmt.Errorf("storeurl: invalid %s %q", name, v)
+	}
+	return n, nil
+}
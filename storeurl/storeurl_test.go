@@ -0,0 +1,47 @@
+package storeurl
+
+import (
+	ae "github.com/mg98/ae-chunker-go"
+	"github.com/mg98/ae-chunker-go/azstore"
+	"github.com/mg98/ae-chunker-go/gcsstore"
+	"github.com/mg98/ae-chunker-go/s3store"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"path/filepath"
+	"testing"
+)
+
+func TestOpen(t *testing.T) {
+	dir := t.TempDir()
+	for _, rawURL := range []string{filepath.Join(dir, "a"), "file://" + filepath.ToSlash(filepath.Join(dir, "b"))} {
+		s, err := Open(rawURL)
+		require.NoError(t, err, rawURL)
+		assert.IsType(t, &ae.FileStore{}, s)
+		require.NoError(t, s.Put([]byte{1}, []byte("foo")))
+	}
+	assert.DirExists(t, filepath.Join(dir, "a"))
+	assert.DirExists(t, filepath.Join(dir, "b"))
+
+	t.Setenv("AZURE_STORAGE_ACCOUNT", "account")
+	for rawURL, want := range map[string]ae.ChunkStore{
+		"s3://bucket/chunks?region=eu-central-1&concurrency=4": &s3store.Store{},
+		"azblob://container/chunks":                            &azstore.Store{},
+		"gs://bucket?retries=-1":                               &gcsstore.Store{},
+	} {
+		s, err := Open(rawURL)
+		require.NoError(t, err, rawURL)
+		assert.IsType(t, want, s, rawURL)
+	}
+
+	for _, rawURL := range []string{
+		"ftp://host/dir",
+		"file://host/dir",
+		"s3://bucket?concurrency=many",
+		"gs:///prefix",
+		"azblob://container?account=",
+	} {
+		t.Setenv("AZURE_STORAGE_ACCOUNT", "")
+		_, err := Open(rawURL)
+		assert.Error(t, err, rawURL)
+	}
+}
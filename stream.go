@@ -0,0 +1,56 @@
+package ae
+
+import (
+	"context"
+	"io"
+)
+
+// ChunkStream delivers the chunks of a Chunker on a channel, see Chunker.Stream.
+type ChunkStream struct {
+	// C receives the chunks in order. It is closed once the input is exhausted, reading fails or the context ends.
+	C <-chan *Chunk
+
+	// err is the reason C was closed, valid once done is closed.
+	err error
+
+	// done is closed after err is set.
+	done chan struct{}
+}
+
+// Stream chunks the input in a goroutine and delivers the chunks on the channel of the returned ChunkStream,
+// which queues up to depth chunks. Once the queue is full, reading is suspended until the consumer catches up,
+// so a slow consumer holds at most depth+1 chunks besides the buffer of the Chunker in memory.
+// A depth of 0 hands over every chunk as the consumer receives it.
+// The goroutine stops once ctx is cancelled, so consumers that stop receiving early must cancel it.
+// The Chunker must not be used otherwise while streaming.
+func (ch *Chunker) Stream(ctx context.Context, depth int) *ChunkStream {
+	c := make(chan *Chunk, depth)
+	s := &ChunkStream{C: c, done: make(chan struct{})}
+	go func() {
+		defer close(s.done)
+		defer close(c)
+		for {
+			chunk, err := ch.Next()
+			if err != nil {
+				if err != io.EOF {
+					s.err = err
+				}
+				return
+			}
+			select {
+			case c <- chunk:
+			case <-ctx.Done():
+				s.err = ctx.Err()
+				return
+			}
+		}
+	}()
+	return s
+}
+
+// Err returns the error that ended the stream, or nil if the input was exhausted.
+// It blocks until C is closed.
+func (s *ChunkStream) Err() error {
+	<-s.done
+	return s.err
+}
@@ -0,0 +1,50 @@
+package ae
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestChunker_Stream(t *testing.T) {
+	input := testFile[:4*MiB]
+	opts := &Options{AverageSize: 16 * 1024, MaxSize: 32 * 1024}
+	want := getChunks(NewChunker(bytes.NewReader(input), opts))
+
+	t.Run("chunks arrive in order", func(t *testing.T) {
+		s := NewChunker(bytes.NewReader(input), opts).Stream(context.Background(), 4)
+		var got [][]byte
+		for c := range s.C {
+			got = append(got, c.Data)
+		}
+		assert.NoError(t, s.Err())
+		assert.Equal(t, want, got)
+	})
+
+	t.Run("slow consumers hold the producer back", func(t *testing.T) {
+		var n int64
+		r := &countingReader{r: bytes.NewReader(input), n: &n}
+		ctx, cancel := context.WithCancel(context.Background())
+		s := NewChunker(r, opts).Stream(ctx, 2)
+		<-s.C
+		time.Sleep(50 * time.Millisecond)
+		// The queue, the chunk waiting for room in it and the read buffer.
+		assert.LessOrEqual(t, atomic.LoadInt64(&n), int64(4*32*1024+minBufSize))
+		cancel()
+		for range s.C {
+		}
+		assert.Equal(t, context.Canceled, s.Err())
+	})
+
+	t.Run("reader errors end the stream", func(t *testing.T) {
+		errFoo := errors.New("foo")
+		s := NewChunker(&errReader{bytes.NewReader(input), errFoo}, opts).Stream(context.Background(), 0)
+		for range s.C {
+		}
+		assert.Equal(t, errFoo, s.Err())
+	})
+}
@@ -0,0 +1,203 @@
+package ae
+
+import (
+	"archive/tar"
+	"bytes"
+	"io"
+	"math"
+	"strings"
+)
+
+// tarBlockSize is the size of the blocks of a tar stream, which every entry is aligned to.
+const tarBlockSize = 512
+
+// tarReader passes through a tar stream and records the offsets at which its entries start.
+// The end-of-archive marker counts as an entry, too.
+// An offset is recorded before any byte following it is returned by Read.
+type tarReader struct {
+	r  io.Reader
+	tr *tar.Reader
+
+	// base is the offset of r in the tar stream.
+	base int64
+
+	// skip is the number of bytes of r to pass through before the first header.
+	skip int64
+
+	// next is the offset of the header following the current entry, or -1 if unknown.
+	next int64
+
+	// raw holds the bytes consumed by tr that have not been returned by Read yet.
+	raw bytes.Buffer
+
+	// read is the number of bytes returned by Read.
+	read int64
+
+	// entries are the offsets recorded since the last call to take.
+	entries []int64
+
+	// end is set once the end of the archive has been reached.
+	end bool
+
+	err error
+}
+
+// newTarReader returns a tarReader reading the tar stream r.
+func newTarReader(r io.Reader) *tarReader {
+	return resumeTarReader(r, 0, 0)
+}
+
+// resumeTarReader returns a tarReader reading the tar stream from offset on, as r yields it,
+// whose next header starts at next, or none follows if next is negative.
+func resumeTarReader(r io.Reader, offset, next int64) *tarReader {
+	t := &tarReader{r: r, base: offset, skip: next - offset, next: next}
+	if next < 0 {
+		t.skip, t.end = 0, true
+	}
+	t.tr = tar.NewReader(io.TeeReader(r, &t.raw))
+	return t
+}
+
+// Read reads the tar stream as is.
+func (t *tarReader) Read(p []byte) (int, error) {
+	if len(p) == 0 {
+		return 0, nil
+	}
+	for t.raw.Len() == 0 {
+		if t.err != nil {
+			return 0, t.err
+		}
+		t.advance(len(p))
+	}
+	n, _ := t.raw.Read(p)
+	t.read += int64(n)
+	return n, nil
+}
+
+// take returns the offsets of the entries recorded since the last call.
+func (t *tarReader) take() []int64 {
+	entries := t.entries
+	t.entries = nil
+	return entries
+}
+
+// advance consumes up to n more bytes of the stream into raw.
+// At the end of an entry, it parses the header of the next one to locate its start.
+func (t *tarReader) advance(n int) {
+	if t.end || t.skip > 0 {
+		k := int64(n)
+		if t.skip > 0 && t.skip < k {
+			k = t.skip
+		}
+		k, err := io.CopyN(&t.raw, t.r, k)
+		if t.skip > 0 {
+			t.skip -= k
+		}
+		if err != nil {
+			t.err = err
+		}
+		return
+	}
+	if k, err := io.CopyN(io.Discard, t.tr, int64(n)); k > 0 && err == nil {
+		return
+	} else if err != nil && err != io.EOF {
+		t.err = err
+		return
+	}
+
+	// The data of the entry ends within the current block, the next entry starts with the block after it.
+	start := blockAlign(t.consumed())
+	hdr, err := t.tr.Next()
+	switch {
+	case err == io.EOF:
+		t.end = true
+	case err != nil:
+		t.err = err
+		return
+	default:
+		t.next = entryEnd(hdr, t.consumed())
+	}
+	if start > 0 && start < t.consumed() {
+		t.entries = append(t.entries, start)
+	}
+}
+
+// consumed returns the offset in the tar stream up to which tr has consumed it.
+func (t *tarReader) consumed() int64 {
+	return t.base + t.read + int64(t.raw.Len())
+}
+
+// nextHeader returns the offset of the first header at or after offset, which must not have been read past yet,
+// along with whether it is known. A negative offset states that no header follows.
+// The entries not taken yet are taken into account, in addition to pending, the ones taken already.
+func (t *tarReader) nextHeader(offset int64, pending []int64) (int64, bool) {
+	for _, entries := range [][]int64{pending, t.entries} {
+		for _, e := range entries {
+			if e >= offset {
+				return e, true
+			}
+		}
+	}
+	if t.end {
+		return -1, true
+	}
+	return t.next, t.next >= 0
+}
+
+// blockAlign rounds offset up to a multiple of tarBlockSize.
+func blockAlign(offset int64) int64 {
+	return (offset + tarBlockSize - 1) / tarBlockSize * tarBlockSize
+}
+
+// entryEnd returns the offset of the header following the entry with header hdr whose data starts at offset,
+// or -1 if it cannot be told from the header, as of sparse files.
+func entryEnd(hdr *tar.Header, offset int64) int64 {
+	if hdr.Typeflag == tar.TypeGNUSparse {
+		return -1
+	}
+	for key := range hdr.PAXRecords {
+		if strings.HasPrefix(key, "GNU.sparse.") {
+			return -1
+		}
+	}
+	switch hdr.Typeflag {
+	case tar.TypeLink, tar.TypeSymlink, tar.TypeChar, tar.TypeBlock, tar.TypeDir, tar.TypeFifo:
+		// These entries have no data, whatever their size.
+		return offset
+	}
+	return blockAlign(offset + hdr.Size)
+}
+
+// tarEntries returns the offsets of the entries of the tar stream r but the first, including the end-of-archive marker,
+// as recorded by a tarReader. It merely reads the headers, skipping the data of all entries but sparse files.
+func tarEntries(r io.ReaderAt) ([]int64, error) {
+	sr := io.NewSectionReader(r, 0, math.MaxInt64)
+	tr := tar.NewReader(sr)
+	var entries []int64
+	var next int64
+	for {
+		hdr, err := tr.Next()
+		if next > 0 {
+			entries = append(entries, next)
+		}
+		if err == io.EOF {
+			return entries, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		offset, err := sr.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return nil, err
+		}
+		if next = entryEnd(hdr, offset); next < 0 {
+			if _, err := io.Copy(io.Discard, tr); err != nil {
+				return nil, err
+			}
+			if offset, err = sr.Seek(0, io.SeekCurrent); err != nil {
+				return nil, err
+			}
+			next = blockAlign(offset)
+		}
+	}
+}
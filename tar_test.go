@@ -0,0 +1,106 @@
+package ae
+
+import (
+	"archive/tar"
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"io"
+	"testing"
+)
+
+// chunkSums returns the hashes of all chunks of r, along with the reasons of their cuts.
+func chunkSums(t *testing.T, r io.Reader, opts *Options) (map[string]bool, []CutReason) {
+	sums := make(map[string]bool)
+	var reasons []CutReason
+	ch := NewChunker(r, opts)
+	for {
+		c, err := ch.Next()
+		if err == io.EOF {
+			return sums, reasons
+		}
+		require.NoError(t, err)
+		sums[string(c.Sum)] = true
+		reasons = append(reasons, c.Reason)
+	}
+}
+
+func TestOptions_Boundaries(t *testing.T) {
+	file := testFile[:MiB]
+	opts := &Options{AverageSize: 16 * 1024, SHA256: true}
+	want, _ := chunkSums(t, bytes.NewReader(file), opts)
+
+	for _, prefix := range []int64{1, 1000, 12345} {
+		input := append(append([]byte{}, testFile[MiB:MiB+prefix]...), file...)
+		o := *opts
+		o.Boundaries = []int64{prefix}
+		got, reasons := chunkSums(t, bytes.NewReader(input), &o)
+		for sum := range want {
+			assert.True(t, got[sum], "prefix %d", prefix)
+		}
+		assert.Contains(t, reasons, CutForced)
+	}
+
+	// Boundaries also apply to Chunkers from a pool.
+	p := NewChunkerPool(&Options{AverageSize: 16 * 1024, Boundaries: []int64{100}})
+	for i := 0; i < 2; i++ {
+		ch := p.Get(bytes.NewReader(file))
+		c, err := ch.Next()
+		require.NoError(t, err)
+		assert.Len(t, c.Data, 100)
+		p.Put(ch)
+	}
+}
+
+// tarArchive returns a tar stream of the files, named a, b, c and so on.
+func tarArchive(t *testing.T, files ...[]byte) []byte {
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for i, data := range files {
+		require.NoError(t, tw.WriteHeader(&tar.Header{Name: string(rune('a' + i)), Mode: 0o644, Size: int64(len(data))}))
+		_, err := tw.Write(data)
+		require.NoError(t, err)
+	}
+	require.NoError(t, tw.Close())
+	return buf.Bytes()
+}
+
+func TestOptions_Tar(t *testing.T) {
+	shared := testFile[:300*1024]
+	a := tarArchive(t, testFile[MiB:MiB+777], shared)
+	b := tarArchive(t, testFile[2*MiB:2*MiB+5000], testFile[3*MiB:3*MiB+12], shared)
+
+	opts := &Options{AverageSize: 8 * 1024, SHA256: true, Tar: true}
+	sumsA, _ := chunkSums(t, bytes.NewReader(a), opts)
+	sumsB, _ := chunkSums(t, bytes.NewReader(b), opts)
+	var common int
+	for sum := range sumsA {
+		if sumsB[sum] {
+			common++
+		}
+	}
+	// All chunks of the shared file are shared, except for the one ending with the end-of-archive marker.
+	want, _ := chunkSums(t, bytes.NewReader(shared), &Options{AverageSize: 8 * 1024, SHA256: true})
+	assert.GreaterOrEqual(t, common, len(want)-1)
+
+	// The chunks still reassemble the archive.
+	var out []byte
+	for _, chunk := range getChunks(NewChunker(bytes.NewReader(b), opts)) {
+		out = append(out, chunk...)
+	}
+	assert.Equal(t, b, out)
+
+	_, err := NewChunker(bytes.NewReader(bytes.Repeat([]byte{'x'}, 2048)), opts).Next()
+	assert.Error(t, err)
+}
+
+func TestTarEntries(t *testing.T) {
+	archive := tarArchive(t, testFile[:777], nil, testFile[MiB:MiB+5000], testFile[2*MiB:2*MiB+512])
+	tr := newTarReader(bytes.NewReader(archive))
+	_, err := io.Copy(io.Discard, tr)
+	require.NoError(t, err)
+	entries, err := tarEntries(bytes.NewReader(archive))
+	require.NoError(t, err)
+	assert.Equal(t, tr.take(), entries)
+	assert.Equal(t, []int64{1536, 2048, 7680}, entries[:3])
+}
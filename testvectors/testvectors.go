@@ -0,0 +1,129 @@
+// Package testvectors provides canonical inputs along with the chunk boundaries that the
+// asymmetric extremum algorithm is expected to produce for them.
+// Forks and ports to other languages can use them to prove byte-identical chunking.
+//
+// The vectors are also available as plain JSON in vectors.json next to this file.
+// Inputs are not stored verbatim but described by Input, see Input.Bytes for how to generate them.
+package testvectors
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+)
+
+//go:embed vectors.json
+var vectorsJSON []byte
+
+// Kinds of inputs.
+const (
+	// Random inputs consist of bytes drawn from SplitMix64.
+	Random = "random"
+
+	// Zeros inputs consist of zero bytes only.
+	Zeros = "zeros"
+
+	// Sawtooth inputs repeat the sequence 0, 1, ..., 255.
+	Sawtooth = "sawtooth"
+)
+
+// Modes of the algorithm.
+const (
+	// Max is the AE_MAX variant.
+	Max = "max"
+
+	// Min is the AE_MIN variant.
+	Min = "min"
+)
+
+// Input describes the input data of a Vector.
+type Input struct {
+	// Kind of input, one of Random, Zeros or Sawtooth.
+	Kind string `json:"kind"`
+
+	// Seed of the random generator (only for Random).
+	Seed uint64 `json:"seed,omitempty"`
+
+	// Length of the input in bytes.
+	Length int `json:"length"`
+}
+
+// Bytes generates the input data.
+// Random inputs are the little-endian bytes of consecutive SplitMix64 outputs for the seed.
+func (in Input) Bytes() []byte {
+	b := make([]byte, in.Length)
+	switch in.Kind {
+	case Random:
+		state := in.Seed
+		for i := 0; i < len(b); i += 8 {
+			state += 0x9e3779b97f4a7c15
+			z := state
+			z = (z ^ (z >> 30)) * 0xbf58476d1ce4e5b9
+			z = (z ^ (z >> 27)) * 0x94d049bb133111eb
+			z ^= z >> 31
+			for j := 0; j < 8 && i+j < len(b); j++ {
+				b[i+j] = byte(z >> (8 * j))
+			}
+		}
+	case Sawtooth:
+		for i := range b {
+			b[i] = byte(i)
+		}
+	}
+	return b
+}
+
+// Vector is a single test case.
+type Vector struct {
+	// Name identifies the vector.
+	Name string `json:"name"`
+
+	// Input to be chunked.
+	Input Input `json:"input"`
+
+	// Mode of the algorithm, one of Max or Min.
+	Mode string `json:"mode"`
+
+	// AverageSize of the chunks.
+	AverageSize int64 `json:"averageSize"`
+
+	// MaxSize of the chunks, or 0 for the default of twice the average size.
+	MaxSize int64 `json:"maxSize"`
+
+	// Boundaries are the expected end offsets of all chunks in order.
+	Boundaries []int64 `json:"boundaries"`
+}
+
+// Vectors returns all test vectors.
+func Vectors() []Vector {
+	var vectors []Vector
+	if err := json.Unmarshal(vectorsJSON, &vectors); err != nil {
+		panic(err)
+	}
+	return vectors
+}
+
+// ChunkFunc chunks input with the parameters of v and returns the end offsets of all chunks.
+type ChunkFunc func(v Vector, input []byte) ([]int64, error)
+
+// VerifyImplementation runs f against all test vectors and reports the first deviation.
+func VerifyImplementation(f ChunkFunc) error {
+	for _, v := range Vectors() {
+		boundaries, err := f(v, v.Input.Bytes())
+		if err != nil {
+			return fmt.Errorf("%s: %w", v.Name, err)
+		}
+		for i := 0; i < len(v.Boundaries) || i < len(boundaries); i++ {
+			if i >= len(boundaries) {
+				return fmt.Errorf("%s: missing boundary %d at offset %d", v.Name, i, v.Boundaries[i])
+			}
+			if i >= len(v.Boundaries) {
+				return fmt.Errorf("%s: unexpected boundary %d at offset %d", v.Name, i, boundaries[i])
+			}
+			if boundaries[i] != v.Boundaries[i] {
+				return fmt.Errorf("%s: boundary %d at offset %d, expected %d", v.Name, i, boundaries[i], v.Boundaries[i])
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,99 @@
+package ae
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"github.com/mg98/ae-chunker-go/testvectors"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"os"
+	"testing"
+)
+
+var updateVectors = flag.Bool("update-vectors", false, "regenerate testvectors/vectors.json")
+
+// chunkVector chunks input as described by v and returns the chunk boundaries.
+func chunkVector(v testvectors.Vector, input []byte) ([]int64, error) {
+	mode := MAX
+	if v.Mode == testvectors.Min {
+		mode = MIN
+	}
+	c := NewChunker(bytes.NewReader(input), &Options{AverageSize: v.AverageSize, MaxSize: v.MaxSize, Mode: mode})
+	var boundaries []int64
+	for {
+		chunk, err := c.Next()
+		if err == io.EOF {
+			return boundaries, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		boundaries = append(boundaries, chunk.Offset+int64(len(chunk.Data)))
+	}
+}
+
+// generateVectors computes the vectors for all combinations of inputs, modes and sizes.
+func generateVectors() ([]testvectors.Vector, error) {
+	inputs := []testvectors.Input{
+		{Kind: testvectors.Random, Seed: 1, Length: 32 * 1024},
+		{Kind: testvectors.Random, Seed: 2, Length: 100_003},
+		{Kind: testvectors.Zeros, Length: 20_000},
+		{Kind: testvectors.Sawtooth, Length: 20_000},
+	}
+	sizes := [][2]int64{{64, 0}, {256, 0}, {1024, 0}, {1024, 1536}, {4096, 0}, {8192, 16384}, {8192, 4096}}
+
+	var vectors []testvectors.Vector
+	for _, in := range inputs {
+		for _, mode := range []string{testvectors.Max, testvectors.Min} {
+			for _, size := range sizes {
+				v := testvectors.Vector{
+					Name:        fmt.Sprintf("%s-%d-%d/%s/avg=%d,max=%d", in.Kind, in.Seed, in.Length, mode, size[0], size[1]),
+					Input:       in,
+					Mode:        mode,
+					AverageSize: size[0],
+					MaxSize:     size[1],
+				}
+				boundaries, err := chunkVector(v, in.Bytes())
+				if err != nil {
+					return nil, err
+				}
+				v.Boundaries = boundaries
+				vectors = append(vectors, v)
+			}
+		}
+	}
+	return vectors, nil
+}
+
+func TestVectors(t *testing.T) {
+	if *updateVectors {
+		vectors, err := generateVectors()
+		assert.NoError(t, err)
+		// one vector per line keeps the file compact but diffable
+		var buf bytes.Buffer
+		buf.WriteString("[\n")
+		for i, v := range vectors {
+			data, err := json.Marshal(v)
+			assert.NoError(t, err)
+			buf.Write(data)
+			if i < len(vectors)-1 {
+				buf.WriteByte(',')
+			}
+			buf.WriteByte('\n')
+		}
+		buf.WriteString("]\n")
+		assert.NoError(t, os.WriteFile("testvectors/vectors.json", buf.Bytes(), 0644))
+	}
+
+	assert.NotEmpty(t, testvectors.Vectors())
+	assert.NoError(t, testvectors.VerifyImplementation(chunkVector))
+
+	t.Run("deviations are reported", func(t *testing.T) {
+		err := testvectors.VerifyImplementation(func(v testvectors.Vector, input []byte) ([]int64, error) {
+			return []int64{int64(len(input))}, nil
+		})
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,136 @@
+package ae
+
+import (
+	"fmt"
+)
+
+// CutReason tells why a chunk ends where it does.
+type CutReason uint8
+
+const (
+	// CutExtremum is a boundary found by the algorithm, i.e. no byte beat the extremum within the window.
+	CutExtremum CutReason = iota
+
+	// CutMaxSize is a chunk truncated at MaxSize.
+	CutMaxSize
+
+	// CutEOF is a chunk that ends with the input.
+	CutEOF
+
+	// CutForced is a chunk cut by a flush of the ChunkingWriter.
+	CutForced
+
+	// CutAligned is a boundary found by the algorithm that was moved onto a multiple of Options.Alignment.
+	CutAligned
+
+	// CutFixed is a chunk of the fixed size of the Fixed algorithm, or of parameters that leave
+	// the algorithm no room, which is not a truncation.
+	CutFixed
+)
+
+// String returns the name of the reason.
+func (r CutReason) String() string {
+	switch r {
+	case CutExtremum:
+		return "extremum"
+	case CutMaxSize:
+		return "max size"
+	case CutEOF:
+		return "eof"
+	case CutForced:
+		return "forced"
+	case CutAligned:
+		return "aligned"
+	case CutFixed:
+		return "fixed"
+	default:
+		return fmt.Sprintf("CutReason(%d)", uint8(r))
+	}
+}
+
+// cutReason returns why a chunk of n out of pending bytes ends.
+// final states whether the chunk is the remainder of the input.
+func cutReason(n, pending int, final bool) CutReason {
+	switch {
+	case final:
+		return CutEOF
+	case n < pending:
+		return CutExtremum
+	default:
+		return CutMaxSize
+	}
+}
+
+// TraceKind is the kind of a TraceEvent.
+type TraceKind uint8
+
+const (
+	// TraceWindowReset is reported when the scan for a chunk begins.
+	// The first byte of the chunk is the initial extremum.
+	TraceWindowReset TraceKind = iota
+
+	// TraceExtremum is reported when a byte beats the current extremum, which restarts the window.
+	TraceExtremum
+
+	// TraceCut is reported when a chunk is emitted.
+	TraceCut
+)
+
+// String returns the name of the kind.
+func (k TraceKind) String() string {
+	switch k {
+	case TraceWindowReset:
+		return "window reset"
+	case TraceExtremum:
+		return "extremum"
+	case TraceCut:
+		return "cut"
+	default:
+		return fmt.Sprintf("TraceKind(%d)", uint8(k))
+	}
+}
+
+// TraceEvent is a decision of the Chunker as reported to Options.Trace.
+type TraceEvent struct {
+	// Kind of the event.
+	Kind TraceKind
+
+	// Offset in the input of the extremum for TraceWindowReset and TraceExtremum,
+	// or of the end of the chunk for TraceCut.
+	Offset int64
+
+	// Value of the extremum for TraceWindowReset and TraceExtremum.
+	Value byte
+
+	// Reason of the cut for TraceCut.
+	Reason CutReason
+}
+
+// String formats the event for logging.
+func (e TraceEvent) String() string {
+	if e.Kind == TraceCut {
+		return fmt.Sprintf("%s at %d (%s)", e.Kind, e.Offset, e.Reason)
+	}
+	return fmt.Sprintf("%s at %d: %#02x", e.Kind, e.Offset, e.Value)
+}
+
+// tracedCutPoint is equivalent to cutPoint, but reports the extrema to trace.
+// The input starts at offset base of the stream. It uses the scalar scan and is thus slower.
+func (p *params) tracedCutPoint(input []byte, base int64, trace func(TraceEvent)) int {
+	if int64(len(input)) <= p.minSize+p.windowSize {
+		return len(input)
+	}
+	minSize, windowSize := int(p.minSize), int(p.windowSize)
+	markerPos := 0
+	marker := input[0]
+	trace(TraceEvent{Kind: TraceWindowReset, Offset: base, Value: marker})
+	for i := minSize; i < len(input); i++ {
+		if b := input[i]; p.isExtreme(b, marker) {
+			markerPos, marker = i, b
+			trace(TraceEvent{Kind: TraceExtremum, Offset: base + int64(i), Value: b})
+		} else if i == markerPos+windowSize {
+			return i
+		}
+	}
+	return len(input)
+}
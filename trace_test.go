@@ -0,0 +1,107 @@
+package ae
+
+import (
+	"bytes"
+	"github.com/stretchr/testify/assert"
+	"testing"
+)
+
+func TestOptions_Trace(t *testing.T) {
+	input := testFile[:2*MiB]
+
+	for _, mode := range []Extremum{MAX, MIN} {
+		t.Run(mode.String(), func(t *testing.T) {
+			var events []TraceEvent
+			opts := &Options{AverageSize: 16 * 1024, Mode: mode, Trace: func(e TraceEvent) { events = append(events, e) }}
+			ch := NewChunker(bytes.NewReader(input), opts)
+			traced := getChunks(ch)
+			assert.Equal(t, getChunks(NewChunker(bytes.NewReader(input), &Options{AverageSize: 16 * 1024, Mode: mode})), traced)
+
+			var cuts []int64
+			var marker TraceEvent
+			reasons := make(map[CutReason]int64)
+			for _, e := range events {
+				switch e.Kind {
+				case TraceWindowReset:
+					var start int64
+					if len(cuts) > 0 {
+						start = cuts[len(cuts)-1]
+					}
+					assert.Equal(t, start, e.Offset)
+					assert.Equal(t, input[e.Offset], e.Value)
+					marker = e
+				case TraceExtremum:
+					assert.Greater(t, e.Offset, marker.Offset)
+					assert.Equal(t, input[e.Offset], e.Value)
+					if mode == MAX {
+						assert.Greater(t, e.Value, marker.Value)
+					} else {
+						assert.Less(t, e.Value, marker.Value)
+					}
+					marker = e
+				case TraceCut:
+					cuts = append(cuts, e.Offset)
+					reasons[e.Reason]++
+				}
+			}
+
+			assert.Len(t, cuts, len(traced))
+			var offset int64
+			for i, c := range traced {
+				offset += int64(len(c))
+				assert.Equal(t, offset, cuts[i])
+			}
+			s := ch.Stats()
+			assert.Equal(t, s.ExtremumCuts, reasons[CutExtremum])
+			assert.Equal(t, s.MaxSizeCuts, reasons[CutMaxSize])
+			assert.Equal(t, s.EOFCuts, reasons[CutEOF])
+		})
+	}
+
+	t.Run("max size", func(t *testing.T) {
+		data := make([]byte, 1000)
+		for i := range data {
+			data[i] = byte(i / 4)
+		}
+		var cuts []TraceEvent
+		trace := func(e TraceEvent) {
+			if e.Kind == TraceCut {
+				cuts = append(cuts, e)
+			}
+		}
+		getChunks(NewChunker(bytes.NewReader(data), &Options{AverageSize: 100, MaxSize: 300, Trace: trace}))
+		assert.Equal(t, []TraceEvent{
+			{Kind: TraceCut, Offset: 300, Reason: CutMaxSize},
+			{Kind: TraceCut, Offset: 600, Reason: CutMaxSize},
+			{Kind: TraceCut, Offset: 900, Reason: CutMaxSize},
+			{Kind: TraceCut, Offset: 1000, Reason: CutEOF},
+		}, cuts)
+	})
+}
+
+func TestChunk_Reason(t *testing.T) {
+	data := make([]byte, 1000)
+	for i := range data {
+		data[i] = byte(i / 4)
+	}
+	data[150] = 0xff
+	c := NewChunker(bytes.NewReader(data), &Options{AverageSize: 100, MaxSize: 300})
+	var reasons []CutReason
+	for {
+		chunk, err := c.Next()
+		if err != nil {
+			break
+		}
+		reasons = append(reasons, chunk.Reason)
+	}
+	assert.Equal(t, []CutReason{CutExtremum, CutMaxSize, CutMaxSize, CutEOF}, reasons)
+	assert.Equal(t, "forced", CutForced.String())
+	assert.Equal(t, "aligned", CutAligned.String())
+	assert.Equal(t, "fixed", CutFixed.String())
+}
+
+func TestTraceEvent_String(t *testing.T) {
+	assert.Equal(t, "extremum at 42: 0xff", TraceEvent{Kind: TraceExtremum, Offset: 42, Value: 0xff}.String())
+	assert.Equal(t, "window reset at 0: 0x07", TraceEvent{Kind: TraceWindowReset, Value: 7}.String())
+	assert.Equal(t, "cut at 100 (max size)", TraceEvent{Kind: TraceCut, Offset: 100, Reason: CutMaxSize}.String())
+}
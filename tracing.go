@@ -0,0 +1,35 @@
+package ae
+
+import (
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+	"io"
+)
+
+// instrumentationName is the name of the tracer of the package.
+const instrumentationName = "github.com/mg98/ae-chunker-go"
+
+// tracer returns the tracer of the package from tp, or from the global TracerProvider of OpenTelemetry if tp is nil,
+// which does not record anything unless an SDK is installed with otel.SetTracerProvider.
+func tracer(tp trace.TracerProvider) trace.Tracer {
+	if tp == nil {
+		tp = otel.GetTracerProvider()
+	}
+	return tp.Tracer(instrumentationName)
+}
+
+// chunkAttributes returns the attributes describing c on a span.
+func chunkAttributes(c *Chunk) []attribute.KeyValue {
+	return []attribute.KeyValue{attribute.Int64("ae.chunk.offset", c.Offset), attribute.Int("ae.chunk.size", len(c.Data))}
+}
+
+// endSpan ends span, recording err unless it is nil or io.EOF.
+func endSpan(span trace.Span, err error) {
+	if err != nil && err != io.EOF {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
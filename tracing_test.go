@@ -0,0 +1,70 @@
+package ae
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/codes"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+	"go.opentelemetry.io/otel/trace"
+	"testing"
+)
+
+// spanCounts returns the number of spans ended per name and the root span, which is the one without a parent.
+func spanCounts(spans []sdktrace.ReadOnlySpan) (map[string]int, sdktrace.ReadOnlySpan) {
+	counts := make(map[string]int)
+	var root sdktrace.ReadOnlySpan
+	for _, s := range spans {
+		counts[s.Name()]++
+		if !s.Parent().IsValid() {
+			root = s
+		}
+	}
+	return counts, root
+}
+
+func TestPipeline_Run_tracing(t *testing.T) {
+	rec := tracetest.NewSpanRecorder()
+	p := &Pipeline{
+		Hasher:         sha256.New,
+		Sink:           sinkFunc(func(c *Chunk) error { return nil }),
+		TracerProvider: sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec)),
+	}
+	input := testFile[:MiB]
+	chunks := len(getChunks(NewChunker(bytes.NewReader(input), &Options{AverageSize: 64 * 1024})))
+	assert.NoError(t, p.Run(context.Background(), NewChunker(bytes.NewReader(input), &Options{AverageSize: 64 * 1024})))
+
+	counts, root := spanCounts(rec.Ended())
+	// The chunking stage ends with a call returning io.EOF.
+	assert.Equal(t, map[string]int{"ae.Pipeline.Run": 1, "ae.chunk": chunks + 1, "ae.hash": chunks, "ae.sink": chunks}, counts)
+	assert.Equal(t, "ae.Pipeline.Run", root.Name())
+	for _, s := range rec.Ended() {
+		if s != root {
+			assert.Equal(t, root.SpanContext().SpanID(), s.Parent().SpanID())
+		}
+	}
+
+	rec = tracetest.NewSpanRecorder()
+	p.TracerProvider = sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec))
+	p.Sink = sinkFunc(func(c *Chunk) error { return errors.New("disk full") })
+	assert.Error(t, p.Run(context.Background(), NewChunker(bytes.NewReader(input), &Options{AverageSize: 64 * 1024})))
+	_, root = spanCounts(rec.Ended())
+	assert.Equal(t, codes.Error, root.Status().Code)
+	assert.Equal(t, "disk full", root.Status().Description)
+}
+
+func TestCopyChunks_tracing(t *testing.T) {
+	rec := tracetest.NewSpanRecorder()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSpanProcessor(rec)))
+	defer otel.SetTracerProvider(trace.NewNoopTracerProvider())
+
+	_, err := CopyChunks(sinkFunc(func(c *Chunk) error { return nil }), NewChunker(bytes.NewReader(testFile[:100]), nil))
+	assert.NoError(t, err)
+	counts, root := spanCounts(rec.Ended())
+	assert.Equal(t, map[string]int{"ae.CopyChunks": 1, "ae.chunk": 2, "ae.sink": 1}, counts)
+	assert.Equal(t, codes.Unset, root.Status().Code)
+}
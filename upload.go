@@ -0,0 +1,74 @@
+package ae
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+)
+
+// UploadHandler is an http.Handler storing uploaded files in a ChunkStore.
+// The file is streamed in the body of a PUT or POST request and chunked on the fly,
+// so only new chunks are written to the store and uploads of any size take constant memory.
+// The response holds the manifest of the file in JSON, from which it can be restored with Reassemble.
+type UploadHandler struct {
+	// Store receives the chunks of the uploaded files.
+	Store ChunkStore
+
+	// Options configure the chunker (optional). The hash defaults to SHA-256 as with BuildManifest.
+	Options *Options
+
+	// MaxBytes limits the size of an upload (optional).
+	MaxBytes int64
+}
+
+// errUploadTooLarge is returned by a bodyReader once the body exceeds its limit.
+var errUploadTooLarge = errors.New("ae: upload too large")
+
+// bodyReader reads a request body of at most max bytes, if max is positive,
+// and records the first error of reading it.
+type bodyReader struct {
+	r   io.Reader
+	n   int64
+	max int64
+	err error
+}
+
+func (r *bodyReader) Read(p []byte) (int, error) {
+	n, err := r.r.Read(p)
+	r.n += int64(n)
+	if r.max > 0 && r.n > r.max {
+		n, err = 0, errUploadTooLarge
+	}
+	if err != nil && err != io.EOF {
+		r.err = err
+	}
+	return n, err
+}
+
+// ServeHTTP stores the file in the body of the request and responds with its manifest.
+// Failures to read the body are reported as 400 Bad Request, uploads over MaxBytes as 413 Request Entity Too Large,
+// and failures of the store as 500 Internal Server Error.
+func (h *UploadHandler) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPut && r.Method != http.MethodPost {
+		w.Header().Set("Allow", "PUT, POST")
+		http.Error(w, http.StatusText(http.StatusMethodNotAllowed), http.StatusMethodNotAllowed)
+		return
+	}
+	body := &bodyReader{r: r.Body, max: h.MaxBytes}
+	m, err := StoreFile(body, h.Store, h.Options)
+	if err != nil {
+		switch {
+		case body.err == errUploadTooLarge:
+			http.Error(w, fmt.Sprintf("upload exceeds %d bytes", h.MaxBytes), http.StatusRequestEntityTooLarge)
+		case body.err != nil:
+			http.Error(w, "reading upload: "+body.err.Error(), http.StatusBadRequest)
+		default:
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(m)
+}
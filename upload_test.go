@@ -0,0 +1,79 @@
+package ae
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// failingReader returns an error after its data.
+type failingReader struct {
+	data []byte
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	if len(r.data) == 0 {
+		return 0, errors.New("connection reset")
+	}
+	n := copy(p, r.data)
+	r.data = r.data[n:]
+	return n, nil
+}
+
+func TestUploadHandler(t *testing.T) {
+	store := NewMemoryStore(0)
+	h := &UploadHandler{Store: store, Options: &Options{AverageSize: 64 * 1024}, MaxBytes: 2 * MiB}
+	input := testFile[:MiB+123]
+
+	upload := func(method string, body io.Reader) *httptest.ResponseRecorder {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(method, "/upload", body))
+		return rec
+	}
+
+	for _, method := range []string{http.MethodPut, http.MethodPost} {
+		t.Run(method, func(t *testing.T) {
+			rec := upload(method, bytes.NewReader(input))
+			assert.Equal(t, http.StatusOK, rec.Code)
+			assert.Equal(t, "application/json", rec.Header().Get("Content-Type"))
+			var m Manifest
+			assert.NoError(t, json.Unmarshal(rec.Body.Bytes(), &m))
+			want, err := BuildManifest(bytes.NewReader(input), h.Options)
+			assert.NoError(t, err)
+			assert.Equal(t, want, &m)
+
+			var buf bytes.Buffer
+			assert.NoError(t, Reassemble(&buf, &m, store))
+			assert.Equal(t, input, buf.Bytes())
+		})
+	}
+
+	t.Run("method not allowed", func(t *testing.T) {
+		rec := upload(http.MethodGet, nil)
+		assert.Equal(t, http.StatusMethodNotAllowed, rec.Code)
+		assert.Equal(t, "PUT, POST", rec.Header().Get("Allow"))
+	})
+
+	t.Run("too large", func(t *testing.T) {
+		rec := upload(http.MethodPut, bytes.NewReader(testFile[:2*MiB+1]))
+		assert.Equal(t, http.StatusRequestEntityTooLarge, rec.Code)
+	})
+
+	t.Run("broken body", func(t *testing.T) {
+		rec := upload(http.MethodPut, &failingReader{data: input[:1000]})
+		assert.Equal(t, http.StatusBadRequest, rec.Code)
+		assert.Contains(t, rec.Body.String(), "connection reset")
+	})
+
+	t.Run("store fails", func(t *testing.T) {
+		h := &UploadHandler{Store: readOnlyStore{}}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/", bytes.NewReader(input)))
+		assert.Equal(t, http.StatusInternalServerError, rec.Code)
+	})
+}
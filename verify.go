@@ -0,0 +1,75 @@
+package ae
+
+import (
+	"bytes"
+	"io"
+)
+
+// VerifyReport is the result of comparing an input against a Manifest.
+type VerifyReport struct {
+	// OK is set if the input matches the manifest entirely.
+	OK bool
+
+	// Chunks is the number of chunks that matched.
+	Chunks int
+
+	// Bytes is the number of bytes covered by the matching chunks.
+	Bytes int64
+
+	// Mismatch is the index of the first mismatching chunk, or -1 if there is none.
+	Mismatch int
+
+	// Offset of the first mismatching chunk in the input.
+	Offset int64
+
+	// Expected is the first mismatching chunk as recorded in the manifest,
+	// or nil if the input is longer than described by the manifest.
+	Expected *ChunkRef
+
+	// Actual is the first mismatching chunk as found in the input,
+	// or nil if the input is shorter than described by the manifest.
+	Actual *ChunkRef
+}
+
+// Verify chunks r with the parameters of m and compares the chunks against those recorded in m.
+// It stops at the first mismatch, which is described in the report.
+// An error is returned only if m is invalid or r fails.
+func Verify(r io.Reader, m *Manifest) (*VerifyReport, error) {
+	if err := m.Validate(); err != nil {
+		return nil, err
+	}
+	if _, err := LookupHash(m.Parameters.Hash); err != nil {
+		return nil, err
+	}
+
+	report := &VerifyReport{Mismatch: -1}
+	ch := NewChunker(r, m.Parameters.Options())
+	for i := 0; ; i++ {
+		c, err := ch.Next()
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+
+		var actual, expected *ChunkRef
+		if err == nil {
+			actual = &ChunkRef{Offset: c.Offset, Length: int64(len(c.Data)), Hash: c.Sum}
+			ReleaseChunk(c)
+		}
+		if i < len(m.Chunks) {
+			expected = &m.Chunks[i]
+		}
+		if actual == nil && expected == nil {
+			report.OK = true
+			return report, nil
+		}
+		if actual == nil || expected == nil || actual.Length != expected.Length || !bytes.Equal(actual.Hash, expected.Hash) {
+			report.Mismatch = i
+			report.Offset = report.Bytes
+			report.Expected = expected
+			report.Actual = actual
+			return report, nil
+		}
+		report.Chunks++
+		report.Bytes += actual.Length
+	}
+}
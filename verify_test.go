@@ -0,0 +1,121 @@
+package ae
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"testing"
+)
+
+func TestVerify(t *testing.T) {
+	input := testFile[:4*MiB]
+	m, err := BuildManifest(bytes.NewReader(input), &Options{AverageSize: 64 * 1024})
+	assert.NoError(t, err)
+
+	t.Run("match", func(t *testing.T) {
+		report, err := Verify(bytes.NewReader(input), m)
+		assert.NoError(t, err)
+		assert.True(t, report.OK)
+		assert.Equal(t, -1, report.Mismatch)
+		assert.Equal(t, len(m.Chunks), report.Chunks)
+		assert.Equal(t, m.Size, report.Bytes)
+	})
+
+	t.Run("modified", func(t *testing.T) {
+		modified := append([]byte{}, input...)
+		pos := m.Chunks[5].Offset + 100
+		modified[pos]++
+
+		report, err := Verify(bytes.NewReader(modified), m)
+		assert.NoError(t, err)
+		assert.False(t, report.OK)
+		assert.Equal(t, 5, report.Mismatch)
+		assert.Equal(t, 5, report.Chunks)
+		assert.Equal(t, m.Chunks[5].Offset, report.Offset)
+		assert.Equal(t, &m.Chunks[5], report.Expected)
+		assert.NotNil(t, report.Actual)
+		assert.Equal(t, m.Chunks[5].Offset, report.Actual.Offset)
+	})
+
+	t.Run("truncated", func(t *testing.T) {
+		last := m.Chunks[len(m.Chunks)-1]
+		report, err := Verify(bytes.NewReader(input[:last.Offset]), m)
+		assert.NoError(t, err)
+		assert.False(t, report.OK)
+		assert.Equal(t, len(m.Chunks)-1, report.Mismatch)
+		assert.Equal(t, last.Offset, report.Offset)
+		assert.Equal(t, &last, report.Expected)
+		assert.Nil(t, report.Actual)
+	})
+
+	t.Run("extended", func(t *testing.T) {
+		extended := append(append([]byte{}, input...), make([]byte, 1024)...)
+		report, err := Verify(bytes.NewReader(extended), m)
+		assert.NoError(t, err)
+		assert.False(t, report.OK)
+		assert.NotEqual(t, -1, report.Mismatch)
+	})
+
+	t.Run("read error", func(t *testing.T) {
+		errFoo := errors.New("foo")
+		_, err := Verify(&errReader{bytes.NewReader(input), errFoo}, m)
+		assert.Equal(t, errFoo, err)
+	})
+}
+
+func TestVerify_Parameters(t *testing.T) {
+	for _, tc := range []struct {
+		name  string
+		opts  *Options
+		input []byte
+	}{
+		{"alignment", &Options{AverageSize: 64 * 1024, Alignment: 4096}, nil},
+		{"snapping", &Options{AverageSize: 64 * 1024, SnapTolerance: 512, Delimiter: '\n'}, nil},
+		{"rune safe", &Options{AverageSize: 64 * 1024, RuneSafe: true}, nil},
+		{"tar", &Options{AverageSize: 16 * 1024, Tar: true},
+			tarArchive(t, testFile[:300*1024], testFile[MiB:MiB+777], testFile[2*MiB:3*MiB], nil, testFile[3*MiB:3*MiB+5000])},
+	} {
+		t.Run(tc.name, func(t *testing.T) {
+			input := tc.input
+			if input == nil {
+				input = testFile[:4*MiB]
+			}
+			m, err := BuildManifest(bytes.NewReader(input), tc.opts)
+			require.NoError(t, err)
+			report, err := Verify(bytes.NewReader(input), m)
+			require.NoError(t, err)
+			assert.True(t, report.OK)
+
+			data, err := json.Marshal(m)
+			require.NoError(t, err)
+			var decoded Manifest
+			require.NoError(t, json.Unmarshal(data, &decoded))
+			assert.Equal(t, m, &decoded)
+			data, err = m.MarshalBinary()
+			require.NoError(t, err)
+			decoded = Manifest{}
+			require.NoError(t, decoded.UnmarshalBinary(data))
+			assert.Equal(t, m, &decoded)
+			report, err = Verify(bytes.NewReader(input), &decoded)
+			require.NoError(t, err)
+			assert.True(t, report.OK)
+
+			// The chunking resumes identically from a saved state, also within a tar entry.
+			expected := getChunks(NewChunker(bytes.NewReader(input), tc.opts))
+			for _, n := range []int{1, 10, 30} {
+				ch := NewChunker(bytes.NewReader(input), tc.opts)
+				var chunks [][]byte
+				for i := 0; i < n; i++ {
+					chunks = append(chunks, ch.NextChunk())
+				}
+				state, err := ch.SaveState()
+				require.NoError(t, err)
+				restored, err := RestoreChunker(bytes.NewReader(input), state)
+				require.NoError(t, err)
+				assert.Equal(t, expected, append(chunks, getChunks(restored)...), "resumed after %d chunks", n)
+			}
+		})
+	}
+}
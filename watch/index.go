@@ -0,0 +1,139 @@
+package watch
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	ae "github.com/mg98/ae-chunker-go"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// indexVersion is the version of the file format of an Index.
+const indexVersion = 1
+
+// Entry is the state of a file recorded in an Index.
+type Entry struct {
+	// Size of the file when it was chunked.
+	Size int64 `json:"size"`
+
+	// ModTime of the file when it was chunked.
+	ModTime time.Time `json:"modTime"`
+
+	// Manifest of the file.
+	Manifest *ae.Manifest `json:"manifest"`
+}
+
+// Index maps the paths of files to their manifests and is kept in a single JSON file.
+// It is safe for concurrent use.
+type Index struct {
+	path string
+
+	mu    sync.Mutex
+	files map[string]*Entry
+}
+
+// indexFile is the JSON representation of an Index.
+type indexFile struct {
+	Version int               `json:"version"`
+	Files   map[string]*Entry `json:"files"`
+}
+
+// OpenIndex reads the index kept in the file at path, or returns an empty one if the file does not exist yet.
+func OpenIndex(path string) (*Index, error) {
+	x := &Index{path: path, files: make(map[string]*Entry)}
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return x, nil
+	} else if err != nil {
+		return nil, err
+	}
+	var f indexFile
+	if err := json.Unmarshal(data, &f); err != nil {
+		return nil, fmt.Errorf("watch: reading index %s: %w", path, err)
+	}
+	if f.Version != indexVersion {
+		return nil, fmt.Errorf("watch: unsupported index version %d", f.Version)
+	}
+	if f.Files != nil {
+		x.files = f.Files
+	}
+	return x, nil
+}
+
+// Get returns the entry of the file at path.
+func (x *Index) Get(path string) (*Entry, bool) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	e, ok := x.files[path]
+	return e, ok
+}
+
+// Files returns the paths of all files in the index in lexical order.
+func (x *Index) Files() []string {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	paths := make([]string, 0, len(x.files))
+	for p := range x.files {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	return paths
+}
+
+// put records e as the entry of the file at path.
+func (x *Index) put(path string, e *Entry) {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.files[path] = e
+}
+
+// remove removes the file at path, or all files below it if it is a directory, and returns their paths.
+func (x *Index) remove(path string) []string {
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	var removed []string
+	for p := range x.files {
+		if within(path, p) {
+			delete(x.files, p)
+			removed = append(removed, p)
+		}
+	}
+	sort.Strings(removed)
+	return removed
+}
+
+// Save writes the index to its file. The file is replaced atomically, so that it is never left half-written.
+func (x *Index) Save() error {
+	x.mu.Lock()
+	data, err := json.Marshal(indexFile{Version: indexVersion, Files: x.files})
+	x.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	dir := filepath.Dir(x.path)
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return err
+	}
+	f, err := os.CreateTemp(dir, "."+filepath.Base(x.path)+".tmp-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), x.path)
+}
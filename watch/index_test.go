@@ -0,0 +1,43 @@
+package watch
+
+import (
+	ae "github.com/mg98/ae-chunker-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestIndex(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "state", "index.json")
+	x, err := OpenIndex(path)
+	require.NoError(t, err)
+	assert.Empty(t, x.Files())
+
+	m := &ae.Manifest{Size: 3, Parameters: ae.Parameters{AverageSize: 1024, MaxSize: 2048, Hash: "sha256"},
+		Chunks: []ae.ChunkRef{{Offset: 0, Length: 3, Hash: []byte{1, 2, 3}}}}
+	mtime := time.Date(2024, 5, 1, 12, 0, 0, 123, time.UTC)
+	dir := filepath.Join("data", "dir")
+	for _, p := range []string{filepath.Join(dir, "a"), filepath.Join(dir, "b", "c"), filepath.Join("data", "dirty")} {
+		x.put(p, &Entry{Size: 3, ModTime: mtime, Manifest: m})
+	}
+	require.NoError(t, x.Save())
+
+	x, err = OpenIndex(path)
+	require.NoError(t, err)
+	e, ok := x.Get(filepath.Join(dir, "a"))
+	require.True(t, ok)
+	assert.Equal(t, int64(3), e.Size)
+	assert.True(t, mtime.Equal(e.ModTime))
+	assert.Equal(t, m, e.Manifest)
+
+	// Removing a directory removes the files below it, but not those sharing a prefix of its name.
+	assert.Equal(t, []string{filepath.Join(dir, "a"), filepath.Join(dir, "b", "c")}, x.remove(dir))
+	assert.Equal(t, []string{filepath.Join("data", "dirty")}, x.Files())
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"version":2}`), 0o644))
+	_, err = OpenIndex(path)
+	assert.Error(t, err)
+}
@@ -0,0 +1,258 @@
+// Package watch keeps the manifests of the files in directory trees up to date as the files change,
+// which is the foundation of continuous backup agents.
+//
+// A Watcher monitors directories with fsnotify, chunks every file that is created or modified into a ChunkStore
+// and records its manifest in a persistent Index. Files whose size and modification time are unchanged since
+// they were indexed are skipped, e.g. when the watcher is restarted, and only the chunks that were not part of
+// the previous version of a file are written to the store.
+package watch
+
+import (
+	"context"
+	"errors"
+	"github.com/fsnotify/fsnotify"
+	ae "github.com/mg98/ae-chunker-go"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// defaultDelay is the time a Watcher waits for changes to settle unless configured otherwise.
+const defaultDelay = time.Second
+
+// Options configure a Watcher.
+type Options struct {
+	// Chunking are the options of the chunker (optional).
+	Chunking *ae.Options
+
+	// Delay is the time to wait after the last change to a file before it is chunked (optional).
+	// Files that are written to piecemeal are thus chunked once rather than after every write. It defaults to 1s.
+	Delay time.Duration
+
+	// OnUpdate is called after the manifest of a file has been updated, with a nil manifest if the file has been
+	// removed (optional).
+	OnUpdate func(path string, m *ae.Manifest)
+
+	// OnError is called for files that could not be chunked (optional).
+	// The watcher carries on and retries the file at its next change.
+	OnError func(path string, err error)
+}
+
+// Watcher keeps the manifests of the files in the watched directories up to date.
+type Watcher struct {
+	store    ae.ChunkStore
+	index    *Index
+	opts     Options
+	notifier *fsnotify.Watcher
+
+	// mu serializes the updates of files, which come from Add and Run.
+	mu sync.Mutex
+}
+
+// New returns a Watcher storing the chunks of files in s and their manifests in index.
+func New(s ae.ChunkStore, index *Index, opts *Options) (*Watcher, error) {
+	notifier, err := fsnotify.NewWatcher()
+	if err != nil {
+		return nil, err
+	}
+	w := &Watcher{store: s, index: index, notifier: notifier}
+	if opts != nil {
+		w.opts = *opts
+	}
+	if w.opts.Delay <= 0 {
+		w.opts.Delay = defaultDelay
+	}
+	return w, nil
+}
+
+// Add watches the directory dir and all directories below it, and brings the index up to date with the files
+// in them. Files that vanished from dir while the watcher was not running are removed from the index.
+func (w *Watcher) Add(dir string) error {
+	dir, err := filepath.Abs(dir)
+	if err != nil {
+		return err
+	}
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	present := make(map[string]bool)
+	err = filepath.WalkDir(dir, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			return w.notifier.Add(p)
+		}
+		if d.Type().IsRegular() {
+			present[p] = true
+			w.update(p)
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+	for _, p := range w.index.Files() {
+		if !present[p] && within(dir, p) {
+			w.removed(p)
+		}
+	}
+	return w.index.Save()
+}
+
+// Run processes the changes in the watched directories until ctx is cancelled or the watcher is closed.
+// Changes are batched: once no change has occurred for the configured delay,
+// the changed files are chunked and the index is saved.
+func (w *Watcher) Run(ctx context.Context) error {
+	pending := make(map[string]bool)
+	timer := time.NewTimer(w.opts.Delay)
+	timer.Stop()
+	defer timer.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case ev, ok := <-w.notifier.Events:
+			if !ok {
+				return nil
+			}
+			if ev.Op == fsnotify.Chmod {
+				continue
+			}
+			pending[ev.Name] = true
+			timer.Reset(w.opts.Delay)
+		case err, ok := <-w.notifier.Errors:
+			if !ok {
+				return nil
+			}
+			w.fail("", err)
+		case <-timer.C:
+			if err := w.process(pending); err != nil {
+				return err
+			}
+			pending = make(map[string]bool)
+		}
+	}
+}
+
+// Close stops watching. A running Run returns.
+func (w *Watcher) Close() error {
+	return w.notifier.Close()
+}
+
+// process brings the index up to date with the files at the given paths and saves it.
+func (w *Watcher) process(pending map[string]bool) error {
+	paths := make([]string, 0, len(pending))
+	for p := range pending {
+		paths = append(paths, p)
+	}
+	sort.Strings(paths)
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	for _, p := range paths {
+		info, err := os.Lstat(p)
+		switch {
+		case errors.Is(err, fs.ErrNotExist):
+			// Removed or renamed, in which case the new name has an event of its own.
+			w.removed(p)
+		case err != nil:
+			w.fail(p, err)
+		case info.IsDir():
+			// A new directory, which may have been populated before it was watched.
+			err := filepath.WalkDir(p, func(p string, d fs.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+				if d.IsDir() {
+					return w.notifier.Add(p)
+				}
+				if d.Type().IsRegular() {
+					w.update(p)
+				}
+				return nil
+			})
+			if err != nil {
+				w.fail(p, err)
+			}
+		case info.Mode().IsRegular():
+			w.update(p)
+		}
+	}
+	return w.index.Save()
+}
+
+// update chunks the file at path unless it is unchanged since it was indexed.
+func (w *Watcher) update(path string) {
+	f, err := os.Open(path)
+	if err != nil {
+		if errors.Is(err, fs.ErrNotExist) {
+			w.removed(path)
+		} else {
+			w.fail(path, err)
+		}
+		return
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil {
+		w.fail(path, err)
+		return
+	}
+	old, ok := w.index.Get(path)
+	if ok && old.Size == info.Size() && old.ModTime.Equal(info.ModTime()) {
+		return
+	}
+	s := &newChunkStore{ChunkStore: w.store, known: make(map[string]bool)}
+	if ok {
+		for _, ref := range old.Manifest.Chunks {
+			s.known[string(ref.Hash)] = true
+		}
+	}
+	m, err := ae.ChunkFile(f, s, w.opts.Chunking)
+	if err != nil {
+		w.fail(path, err)
+		return
+	}
+	w.index.put(path, &Entry{Size: info.Size(), ModTime: info.ModTime(), Manifest: m})
+	if w.opts.OnUpdate != nil {
+		w.opts.OnUpdate(path, m)
+	}
+}
+
+// removed removes the file or directory at path from the index.
+func (w *Watcher) removed(path string) {
+	for _, p := range w.index.remove(path) {
+		if w.opts.OnUpdate != nil {
+			w.opts.OnUpdate(p, nil)
+		}
+	}
+}
+
+// fail reports err for the file at path.
+func (w *Watcher) fail(path string, err error) {
+	if w.opts.OnError != nil {
+		w.opts.OnError(path, err)
+	}
+}
+
+// within reports whether path is dir or below it.
+func within(dir, path string) bool {
+	return path == dir || strings.HasPrefix(path, dir+string(filepath.Separator))
+}
+
+// newChunkStore skips the chunks of the previous version of a file, which are in the store already.
+type newChunkStore struct {
+	ae.ChunkStore
+	known map[string]bool
+}
+
+// Put stores data under hash unless the chunk is known.
+func (s *newChunkStore) Put(hash []byte, data []byte) error {
+	if s.known[string(hash)] {
+		return nil
+	}
+	return s.ChunkStore.Put(hash, data)
+}
@@ -0,0 +1,130 @@
+package watch
+
+import (
+	"bytes"
+	"context"
+	ae "github.com/mg98/ae-chunker-go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"math/rand"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+// countingStore counts the chunks put into a ChunkStore.
+type countingStore struct {
+	ae.ChunkStore
+	mu   sync.Mutex
+	puts int
+}
+
+func (s *countingStore) Put(hash []byte, data []byte) error {
+	s.mu.Lock()
+	s.puts++
+	s.mu.Unlock()
+	return s.ChunkStore.Put(hash, data)
+}
+
+func (s *countingStore) count() int {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.puts
+}
+
+func TestWatcher(t *testing.T) {
+	dir, err := filepath.Abs(t.TempDir())
+	require.NoError(t, err)
+	data := make([]byte, 1<<20)
+	rand.New(rand.NewSource(1)).Read(data)
+	a := filepath.Join(dir, "a")
+	require.NoError(t, os.WriteFile(a, data, 0o644))
+	gone := filepath.Join(dir, "gone")
+
+	indexPath := filepath.Join(t.TempDir(), "index.json")
+	index, err := OpenIndex(indexPath)
+	require.NoError(t, err)
+	// A file indexed in an earlier run that no longer exists is dropped.
+	index.put(gone, &Entry{Manifest: &ae.Manifest{}})
+	store := &countingStore{ChunkStore: ae.NewMemoryStore(0)}
+	var mu sync.Mutex
+	updates := make(map[string]*ae.Manifest)
+	w, err := New(store, index, &Options{
+		Chunking: &ae.Options{AverageSize: 16 * 1024},
+		Delay:    20 * time.Millisecond,
+		OnUpdate: func(path string, m *ae.Manifest) {
+			mu.Lock()
+			updates[path] = m
+			mu.Unlock()
+		},
+		OnError: func(path string, err error) {
+			t.Errorf("%s: %v", path, err)
+		},
+	})
+	require.NoError(t, err)
+	require.NoError(t, w.Add(dir))
+	assert.Equal(t, []string{a}, index.Files())
+	assert.Contains(t, updates, a)
+	assert.Contains(t, updates, gone)
+	updates = make(map[string]*ae.Manifest)
+	initial := store.count()
+	assert.Greater(t, initial, 10)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- w.Run(ctx) }()
+	// updated waits for the manifest of the file at path to be updated and returns it.
+	updated := func(path string) *ae.Manifest {
+		var m *ae.Manifest
+		var ok bool
+		require.Eventually(t, func() bool {
+			mu.Lock()
+			defer mu.Unlock()
+			m, ok = updates[path]
+			delete(updates, path)
+			return ok
+		}, 5*time.Second, 10*time.Millisecond)
+		return m
+	}
+	content := func(m *ae.Manifest) []byte {
+		var buf bytes.Buffer
+		require.NoError(t, ae.Reassemble(&buf, m, store))
+		return buf.Bytes()
+	}
+
+	// Appending to the file only stores the chunks at its end.
+	f, err := os.OpenFile(a, os.O_WRONLY|os.O_APPEND, 0)
+	require.NoError(t, err)
+	_, err = f.Write([]byte("appended"))
+	require.NoError(t, err)
+	require.NoError(t, f.Close())
+	m := updated(a)
+	assert.True(t, bytes.Equal(append(append([]byte{}, data...), "appended"...), content(m)))
+	assert.Less(t, store.count()-initial, 4)
+
+	// Files in new directories are picked up.
+	b := filepath.Join(dir, "sub", "b")
+	require.NoError(t, os.MkdirAll(filepath.Dir(b), 0o755))
+	require.NoError(t, os.WriteFile(b, []byte("hello"), 0o644))
+	assert.Equal(t, []byte("hello"), content(updated(b)))
+
+	require.NoError(t, os.RemoveAll(filepath.Dir(b)))
+	assert.Nil(t, updated(b))
+
+	cancel()
+	assert.Equal(t, context.Canceled, <-done)
+	require.NoError(t, w.Close())
+
+	// The index has been saved, so a restarted watcher does not chunk the unchanged file again.
+	index, err = OpenIndex(indexPath)
+	require.NoError(t, err)
+	assert.Equal(t, []string{a}, index.Files())
+	w, err = New(store, index, nil)
+	require.NoError(t, err)
+	defer w.Close()
+	puts := store.count()
+	require.NoError(t, w.Add(dir))
+	assert.Equal(t, puts, store.count())
+}
@@ -0,0 +1,177 @@
+package ae
+
+import (
+	"errors"
+	"io"
+	"sync"
+	"time"
+)
+
+// errWriterClosed is returned when writing to a closed ChunkingWriter.
+var errWriterClosed = errors.New("ae: write to closed ChunkingWriter")
+
+// ChunkingWriter chunks the data written to it and hands the chunks over to a ChunkSink,
+// which makes it the push-style dual of the Chunker: data can be chunked as it is produced,
+// e.g. by an archiver writing to an io.Writer. The chunks are the same as those of a Chunker reading the data,
+// unless Options.MaxChunkAge forces boundaries.
+type ChunkingWriter struct {
+	ch   *Chunker
+	src  *writerSource
+	sink ChunkSink
+
+	// mu serializes the calls with the flushes triggered by timer.
+	mu sync.Mutex
+
+	// maxAge is the time after which buffered data is flushed if no boundary was found (optional).
+	maxAge time.Duration
+
+	// timer flushes the buffered data once it is maxAge old, if running.
+	timer *time.Timer
+
+	// written is the number of bytes written so far.
+	written int64
+
+	// err is the first error of the sink or the Chunker.
+	err error
+}
+
+// writerSource is the input of the Chunker of a ChunkingWriter, holding the data written but not yet read.
+type writerSource struct {
+	data   []byte
+	closed bool
+}
+
+func (s *writerSource) Read(p []byte) (int, error) {
+	if len(s.data) == 0 && s.closed {
+		return 0, io.EOF
+	}
+	n := copy(p, s.data)
+	s.data = s.data[n:]
+	return n, nil
+}
+
+// NewChunkingWriter returns a ChunkingWriter passing the chunks of the data written to it to sink.
+// A chunk is emitted once the data following it is known, i.e. up to MaxSize bytes are buffered,
+// and the remaining data is emitted by Close.
+func NewChunkingWriter(sink ChunkSink, opts *Options) *ChunkingWriter {
+	src := &writerSource{}
+	w := &ChunkingWriter{ch: NewChunker(src, opts), src: src, sink: sink}
+	if opts != nil {
+		w.maxAge = opts.MaxChunkAge
+	}
+	return w
+}
+
+// Write buffers p and emits all chunks that can be cut.
+// It returns the first error of the sink, after which the ChunkingWriter is unusable.
+func (w *ChunkingWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.err != nil {
+		return 0, w.err
+	}
+	if w.src.closed {
+		return 0, errWriterClosed
+	}
+	w.src.data = append(w.src.data, p...)
+	w.written += int64(len(p))
+
+	// The Chunker needs MaxSize bytes to cut unless the input has ended,
+	// so the source must never run dry while it fills its buffer.
+	emitted := w.ch.stats.Chunks
+	for w.written-w.ch.stats.BytesEmitted >= w.ch.maxSize {
+		if err := w.emit(); err != nil {
+			return len(p), err
+		}
+	}
+	if w.maxAge > 0 && (w.ch.stats.Chunks > emitted || w.timer == nil) {
+		// The age of the buffered data counts from the last boundary.
+		w.stopTimer()
+		w.timer = time.AfterFunc(w.maxAge, w.expire)
+	}
+	return len(p), nil
+}
+
+// expire flushes the buffered data once no boundary was found for maxAge.
+// An error of the sink is returned by the next call.
+func (w *ChunkingWriter) expire() {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.timer = nil
+	if w.err == nil && !w.src.closed && w.written > w.ch.stats.BytesEmitted {
+		w.flush()
+	}
+}
+
+// stopTimer stops the timer, if running.
+func (w *ChunkingWriter) stopTimer() {
+	if w.timer != nil {
+		w.timer.Stop()
+		w.timer = nil
+	}
+}
+
+// Flush forces a chunk boundary at the current position: all buffered data is emitted,
+// the last chunk with reason CutForced. Chunking then resumes with the data written next,
+// as if it was the start of a new input. This allows live sources, such as logs, to ship their data without delay.
+// With Options.MaxChunkAge, this happens on its own once no boundary was found for that long.
+func (w *ChunkingWriter) Flush() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.flush()
+}
+
+// flush implements Flush.
+func (w *ChunkingWriter) flush() error {
+	w.stopTimer()
+	if w.err != nil {
+		return w.err
+	}
+	if w.src.closed {
+		return errWriterClosed
+	}
+	w.src.closed = true
+	w.ch.forced = true
+	err := w.drain()
+	w.src.closed = false
+	w.ch.forced = false
+	w.ch.err = nil
+	return err
+}
+
+// Close emits the remaining data as the final chunks. It does not close the sink.
+func (w *ChunkingWriter) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.stopTimer()
+	if w.src.closed {
+		return w.err
+	}
+	w.src.closed = true
+	return w.drain()
+}
+
+// drain emits all chunks until the closed source is exhausted.
+func (w *ChunkingWriter) drain() error {
+	for w.err == nil {
+		if err := w.emit(); err == io.EOF {
+			return nil
+		}
+	}
+	return w.err
+}
+
+// emit passes the next chunk to the sink. It returns io.EOF once the input is exhausted.
+func (w *ChunkingWriter) emit() error {
+	c, err := w.ch.Next()
+	if err == io.EOF {
+		return err
+	}
+	if err == nil {
+		err = w.sink.WriteChunk(c)
+	}
+	if err != nil {
+		w.err = err
+	}
+	return err
+}
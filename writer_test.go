@@ -0,0 +1,157 @@
+package ae
+
+import (
+	"bytes"
+	"errors"
+	"github.com/stretchr/testify/assert"
+	"math/rand"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestChunkingWriter(t *testing.T) {
+	input := testFile[:8*MiB]
+	opts := &Options{AverageSize: 64 * 1024, HashName: "sha256"}
+	want, err := BuildManifest(bytes.NewReader(input), opts)
+	assert.NoError(t, err)
+
+	for _, maxWrite := range []int{1000, 64 * 1024, 4 << 20} {
+		var got []ChunkRef
+		w := NewChunkingWriter(sinkFunc(func(c *Chunk) error {
+			got = append(got, ChunkRef{Offset: c.Offset, Length: int64(len(c.Data)), Hash: c.Sum})
+			return nil
+		}), opts)
+		rnd := rand.New(rand.NewSource(1))
+		for data := input; len(data) > 0; {
+			n := rnd.Intn(maxWrite) + 1
+			if n > len(data) {
+				n = len(data)
+			}
+			k, err := w.Write(data[:n])
+			assert.NoError(t, err)
+			assert.Equal(t, n, k)
+			data = data[n:]
+		}
+		// all but the final chunks have been emitted before Close
+		assert.Less(t, len(want.Chunks)-len(got), 4)
+		assert.NoError(t, w.Close())
+		assert.Equal(t, want.Chunks, got, "writes of up to %d bytes", maxWrite)
+
+		_, err := w.Write([]byte{1})
+		assert.Error(t, err)
+		assert.NoError(t, w.Close())
+	}
+
+	t.Run("empty", func(t *testing.T) {
+		w := NewChunkingWriter(sinkFunc(func(c *Chunk) error {
+			t.Error("unexpected chunk")
+			return nil
+		}), opts)
+		assert.NoError(t, w.Close())
+	})
+
+	t.Run("sink fails", func(t *testing.T) {
+		errSink := errors.New("sink failed")
+		w := NewChunkingWriter(sinkFunc(func(c *Chunk) error { return errSink }), opts)
+		_, err := w.Write(input[:MiB])
+		assert.Equal(t, errSink, err)
+		_, err = w.Write(input[MiB:])
+		assert.Equal(t, errSink, err)
+		assert.Equal(t, errSink, w.Close())
+	})
+
+	t.Run("sink fails on close", func(t *testing.T) {
+		errSink := errors.New("sink failed")
+		w := NewChunkingWriter(sinkFunc(func(c *Chunk) error { return errSink }), opts)
+		_, err := w.Write(input[:1000])
+		assert.NoError(t, err)
+		assert.Equal(t, errSink, w.Close())
+	})
+}
+
+func TestChunkingWriter_Flush(t *testing.T) {
+	input := testFile[:4*MiB]
+	opts := &Options{AverageSize: 64 * 1024, HashName: "sha256"}
+	var got []*Chunk
+	w := NewChunkingWriter(sinkFunc(func(c *Chunk) error {
+		got = append(got, c)
+		return nil
+	}), opts)
+
+	flushAt := MiB + 12345
+	_, err := w.Write(input[:flushAt])
+	assert.NoError(t, err)
+	assert.NoError(t, w.Flush())
+	assert.NoError(t, w.Flush(), "flush without data")
+	flushed := len(got)
+	last := got[flushed-1]
+	assert.Equal(t, CutForced, last.Reason)
+	assert.False(t, last.Final)
+	assert.Equal(t, int64(flushAt), last.Offset+int64(len(last.Data)))
+
+	_, err = w.Write(input[flushAt:])
+	assert.NoError(t, err)
+	assert.NoError(t, w.Close())
+	assert.Equal(t, CutEOF, got[len(got)-1].Reason)
+	assert.True(t, got[len(got)-1].Final)
+	assert.Equal(t, int64(1), w.ch.Stats().ForcedCuts)
+
+	// Each side of the flush is chunked like an input of its own.
+	for _, part := range []struct {
+		chunks []*Chunk
+		data   []byte
+		offset int64
+	}{{got[:flushed], input[:flushAt], 0}, {got[flushed:], input[flushAt:], int64(flushAt)}} {
+		want, err := BuildManifest(bytes.NewReader(part.data), opts)
+		assert.NoError(t, err)
+		assert.Len(t, part.chunks, len(want.Chunks))
+		for i, c := range part.chunks {
+			assert.Equal(t, want.Chunks[i].Offset+part.offset, c.Offset)
+			assert.Equal(t, want.Chunks[i].Hash, c.Sum)
+		}
+	}
+
+	_, err = w.Write([]byte{1})
+	assert.Error(t, err)
+	assert.Error(t, w.Flush())
+}
+
+func TestOptions_MaxChunkAge(t *testing.T) {
+	var mu sync.Mutex
+	var got []*Chunk
+	w := NewChunkingWriter(sinkFunc(func(c *Chunk) error {
+		mu.Lock()
+		defer mu.Unlock()
+		got = append(got, c)
+		return nil
+	}), &Options{AverageSize: 64 * 1024, MaxChunkAge: 20 * time.Millisecond})
+	chunks := func() []*Chunk {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]*Chunk(nil), got...)
+	}
+
+	// A trickle of data too small for a boundary is shipped once it is old enough.
+	_, err := w.Write([]byte("first line\n"))
+	assert.NoError(t, err)
+	assert.Eventually(t, func() bool { return len(chunks()) == 1 }, time.Second, time.Millisecond)
+	assert.Equal(t, "first line\n", string(chunks()[0].Data))
+	assert.Equal(t, CutForced, chunks()[0].Reason)
+
+	_, err = w.Write([]byte("second line\n"))
+	assert.NoError(t, err)
+	assert.Eventually(t, func() bool { return len(chunks()) == 2 }, time.Second, time.Millisecond)
+	assert.Equal(t, int64(11), chunks()[1].Offset)
+
+	// Data written in time is chunked as usual, and nothing is left to be flushed after Close.
+	for i := 0; i < 64; i++ {
+		_, err = w.Write(testFile[i*16*1024 : (i+1)*16*1024])
+		assert.NoError(t, err)
+	}
+	assert.NoError(t, w.Close())
+	n := len(chunks())
+	assert.Greater(t, n, 4)
+	time.Sleep(50 * time.Millisecond)
+	assert.Len(t, chunks(), n)
+}